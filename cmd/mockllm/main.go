@@ -0,0 +1,45 @@
+// Command mockllm serves the OpenAI chat completions API with scripted behaviours: latency,
+// stalls, disconnects, HTTP errors, context length errors and tool tags. Point LLM.Endpoint of
+// chat-rag at it to exercise timeouts, retries, degradation and tool calls without real models.
+package main
+
+import (
+	"flag"
+	"net/http"
+	"sort"
+
+	"go.uber.org/zap"
+
+	"github.com/zgsm-ai/chat-rag/internal/logger"
+)
+
+func main() {
+	var addr, scenarioFile string
+	flag.StringVar(&addr, "addr", ":8090", "the listen address")
+	flag.StringVar(&scenarioFile, "f", "", "the scenario file, only the built-in scenarios when empty")
+	flag.Parse()
+
+	scenarios := builtinScenarios()
+	if scenarioFile != "" {
+		loaded, err := loadScenarios(scenarioFile)
+		if err != nil {
+			logger.Error("Failed to load scenarios", zap.Error(err))
+			panic(err)
+		}
+		scenarios = loaded
+	}
+
+	names := make([]string, 0, len(scenarios))
+	for name := range scenarios {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	logger.Info("mockllm starting",
+		zap.String("address", addr),
+		zap.Strings("scenarios", names),
+	)
+	if err := http.ListenAndServe(addr, newMockServer(scenarios)); err != nil {
+		logger.Error("Failed to start mockllm", zap.Error(err))
+		panic(err)
+	}
+}
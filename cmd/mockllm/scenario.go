@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// scenario scripts how the mock model answers a request
+type scenario struct {
+	// Answer content, streamed in chunks of chunkSize characters
+	Content   string `yaml:"content"`
+	ChunkSize int    `yaml:"chunkSize"`
+
+	// Latency to the first token, plus a random jitter up to firstTokenJitterMs
+	FirstTokenDelayMs  int `yaml:"firstTokenDelayMs"`
+	FirstTokenJitterMs int `yaml:"firstTokenJitterMs"`
+	// Delay between stream chunks
+	ChunkDelayMs int `yaml:"chunkDelayMs"`
+
+	// Close the connection after sending this many stream chunks
+	DisconnectAfterChunks int `yaml:"disconnectAfterChunks"`
+
+	// Answer with this HTTP error status
+	StatusCode   int    `yaml:"statusCode"`
+	ErrorMessage string `yaml:"errorMessage"`
+	// Only the first failTimes requests fail, the following ones succeed. 0 fails every request.
+	FailTimes int `yaml:"failTimes"`
+
+	// Answer with the context length error of OpenAI compatible upstreams
+	ContextLengthError bool `yaml:"contextLengthError"`
+
+	// Append a tool tag to the answer until the conversation holds the result of the tool
+	ToolCall *toolCallScenario `yaml:"toolCall"`
+}
+
+// toolCallScenario is the XML tool call written by the mock model
+type toolCallScenario struct {
+	Name   string            `yaml:"name"`
+	Params map[string]string `yaml:"params"`
+}
+
+// scenarioFile is the format of the scenario file
+type scenarioFile struct {
+	Scenarios map[string]scenario `yaml:"scenarios"`
+}
+
+const (
+	defaultScenario  = "default"
+	defaultContent   = "This is a mock response from mockllm. It streams in small chunks to exercise the chat-rag pipeline."
+	defaultChunkSize = 8
+)
+
+// builtinScenarios returns the scenarios available without a scenario file
+func builtinScenarios() map[string]scenario {
+	return map[string]scenario{
+		defaultScenario: {ChunkDelayMs: 20},
+		"slow":          {FirstTokenDelayMs: 3000, FirstTokenJitterMs: 2000, ChunkDelayMs: 200},
+		"stall":         {ChunkDelayMs: 120000},
+		"disconnect":    {ChunkDelayMs: 20, DisconnectAfterChunks: 3},
+		"error500":      {StatusCode: 500, ErrorMessage: "mock internal server error"},
+		"ratelimit":     {StatusCode: 429, ErrorMessage: "mock rate limit exceeded", FailTimes: 2},
+		"contextlength": {ContextLengthError: true},
+		"tool": {
+			Content:      "Let me search the codebase first.\n\n",
+			ChunkDelayMs: 20,
+			ToolCall: &toolCallScenario{
+				Name:   "codebase_search",
+				Params: map[string]string{"query": "mock llm server"},
+			},
+		},
+	}
+}
+
+// loadScenarios returns the built-in scenarios with the scenarios of the file added or replacing them
+func loadScenarios(path string) (map[string]scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read scenario file: %w", err)
+	}
+	var file scenarioFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse scenario file: %w", err)
+	}
+
+	scenarios := builtinScenarios()
+	for name, s := range file.Scenarios {
+		scenarios[strings.ToLower(name)] = s
+	}
+	return scenarios, nil
+}
+
+// answer returns the content of the answer to the messages
+func (s scenario) answer(messages []message) string {
+	if s.ToolCall != nil && s.ToolCall.Name != "" && !toolResultReturned(messages, s.ToolCall.Name) {
+		return s.Content + s.ToolCall.tag()
+	}
+	if s.Content == "" {
+		return defaultContent
+	}
+	return s.Content
+}
+
+// tag returns the XML tool tag detected by the generic tool executor
+func (t *toolCallScenario) tag() string {
+	var b strings.Builder
+	b.WriteString("<" + t.Name + ">\n")
+	names := make([]string, 0, len(t.Params))
+	for name := range t.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "<%s>%s</%s>\n", name, t.Params[name], name)
+	}
+	b.WriteString("</" + t.Name + ">")
+	return b.String()
+}
+
+// toolResultReturned reports whether the last message holds the result of the tool
+func toolResultReturned(messages []message, toolName string) bool {
+	if len(messages) == 0 {
+		return false
+	}
+	last := messages[len(messages)-1]
+	return last.Role == "tool" || strings.Contains(last.text(), "["+toolName+"] Result:")
+}
+
+// chunks splits the content in chunks of the chunk size, in characters
+func chunks(content string, size int) []string {
+	if size <= 0 {
+		size = defaultChunkSize
+	}
+	runes := []rune(content)
+	result := make([]string, 0, len(runes)/size+1)
+	for start := 0; start < len(runes); start += size {
+		result = append(result, string(runes[start:min(start+size, len(runes))]))
+	}
+	return result
+}
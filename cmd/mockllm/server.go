@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/zgsm-ai/chat-rag/internal/logger"
+)
+
+// headerScenario selects the scenario of a request, otherwise it is selected by the model name
+const headerScenario = "x-mock-scenario"
+
+// message is a chat message of the request, content is a string or a list of content parts
+type message struct {
+	Role    string `json:"role"`
+	Content any    `json:"content"`
+}
+
+// text returns the text of the message content
+func (m message) text() string {
+	switch content := m.Content.(type) {
+	case string:
+		return content
+	case []any:
+		var b strings.Builder
+		for _, part := range content {
+			if p, ok := part.(map[string]any); ok {
+				if text, ok := p["text"].(string); ok {
+					b.WriteString(text)
+				}
+			}
+		}
+		return b.String()
+	}
+	return ""
+}
+
+type chatRequest struct {
+	Model    string    `json:"model"`
+	Stream   bool      `json:"stream"`
+	Messages []message `json:"messages"`
+}
+
+// mockServer serves the OpenAI chat completions API with scripted behaviours
+type mockServer struct {
+	scenarios map[string]scenario
+
+	mu sync.Mutex
+	// Requests served per scenario, for failTimes
+	requests map[string]int
+}
+
+func newMockServer(scenarios map[string]scenario) *mockServer {
+	return &mockServer{
+		scenarios: scenarios,
+		requests:  make(map[string]int),
+	}
+}
+
+// ServeHTTP implements http.Handler, any path ending with /chat/completions or /models is served
+func (s *mockServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/chat/completions") && r.Method == http.MethodPost:
+		s.handleChatCompletions(w, r)
+	case strings.HasSuffix(r.URL.Path, "/models") && r.Method == http.MethodGet:
+		s.handleModels(w)
+	default:
+		writeError(w, http.StatusNotFound, "unknown endpoint "+r.Method+" "+r.URL.Path, "not_found")
+	}
+}
+
+// scenarioFor returns the scenario of the request and its name
+func (s *mockServer) scenarioFor(r *http.Request, model string) (string, scenario) {
+	for _, name := range []string{r.Header.Get(headerScenario), model} {
+		name = strings.ToLower(name)
+		if sc, ok := s.scenarios[name]; ok {
+			return name, sc
+		}
+	}
+	return defaultScenario, s.scenarios[defaultScenario]
+}
+
+// shouldFail counts the request of the scenario and reports whether it fails
+func (s *mockServer) shouldFail(name string, failTimes int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests[name]++
+	return failTimes <= 0 || s.requests[name] <= failTimes
+}
+
+func (s *mockServer) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req chatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error(), "invalid_request_error")
+		return
+	}
+	name, sc := s.scenarioFor(r, req.Model)
+	promptTokens := estimateTokens(req.Messages)
+	logger.Info("mock chat completion",
+		zap.String("scenario", name),
+		zap.String("model", req.Model),
+		zap.Bool("stream", req.Stream),
+		zap.Int("messages", len(req.Messages)),
+	)
+
+	if sc.ContextLengthError {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf(
+			"This model's maximum context length is 8192 tokens. However, your messages resulted in %d tokens.",
+			max(promptTokens, 8193)), "context_length_exceeded")
+		return
+	}
+	if sc.StatusCode >= http.StatusBadRequest && s.shouldFail(name, sc.FailTimes) {
+		msg := sc.ErrorMessage
+		if msg == "" {
+			msg = http.StatusText(sc.StatusCode)
+		}
+		writeError(w, sc.StatusCode, msg, "mock_error")
+		return
+	}
+
+	delay := sc.FirstTokenDelayMs
+	if sc.FirstTokenJitterMs > 0 {
+		delay += rand.IntN(sc.FirstTokenJitterMs + 1)
+	}
+	if !sleep(r.Context(), delay) {
+		return
+	}
+
+	content := sc.answer(req.Messages)
+	usage := map[string]int{
+		"prompt_tokens":     promptTokens,
+		"completion_tokens": len(content)/4 + 1,
+		"total_tokens":      promptTokens + len(content)/4 + 1,
+	}
+	id := "chatcmpl-mock-" + uuid.NewString()
+	if !req.Stream {
+		if sc.DisconnectAfterChunks > 0 {
+			panic(http.ErrAbortHandler)
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"id":      id,
+			"object":  "chat.completion",
+			"created": time.Now().Unix(),
+			"model":   req.Model,
+			"choices": []any{map[string]any{
+				"index":         0,
+				"message":       map[string]any{"role": "assistant", "content": content},
+				"finish_reason": "stop",
+			}},
+			"usage": usage,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	flusher, _ := w.(http.Flusher)
+	send := func(delta map[string]any, finishReason any, usage any) {
+		chunk := map[string]any{
+			"id":      id,
+			"object":  "chat.completion.chunk",
+			"created": time.Now().Unix(),
+			"model":   req.Model,
+			"choices": []any{map[string]any{"index": 0, "delta": delta, "finish_reason": finishReason}},
+		}
+		if usage != nil {
+			chunk["usage"] = usage
+		}
+		fmt.Fprintf(w, "data: %s\n", marshal(chunk))
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	send(map[string]any{"role": "assistant", "content": ""}, nil, nil)
+	for i, chunk := range chunks(content, sc.ChunkSize) {
+		if sc.DisconnectAfterChunks > 0 && i == sc.DisconnectAfterChunks {
+			// Abort the response without ending it, the client sees the connection drop
+			panic(http.ErrAbortHandler)
+		}
+		if i > 0 && !sleep(r.Context(), sc.ChunkDelayMs) {
+			return
+		}
+		send(map[string]any{"content": chunk}, nil, nil)
+	}
+	send(map[string]any{}, "stop", usage)
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
+
+func (s *mockServer) handleModels(w http.ResponseWriter) {
+	names := make([]string, 0, len(s.scenarios))
+	for name := range s.scenarios {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	models := make([]any, 0, len(names))
+	for _, name := range names {
+		models = append(models, map[string]any{"id": name, "object": "model", "owned_by": "mockllm"})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"object": "list", "data": models})
+}
+
+// sleep waits for the delay, it returns false when the client went away
+func sleep(ctx context.Context, delayMs int) bool {
+	if delayMs <= 0 {
+		return true
+	}
+	timer := time.NewTimer(time.Duration(delayMs) * time.Millisecond)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// estimateTokens roughly estimates the prompt tokens of the messages, 4 characters per token
+func estimateTokens(messages []message) int {
+	chars := 0
+	for _, m := range messages {
+		chars += len(m.text())
+	}
+	return chars/4 + 1
+}
+
+// marshal encodes the body as upstreams do, leaving HTML characters of code and tool tags unescaped.
+// The encoding ends with a newline.
+func marshal(body any) []byte {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(body)
+	return buf.Bytes()
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(marshal(body))
+}
+
+// writeError writes an error in the OpenAI error format
+func writeError(w http.ResponseWriter, status int, msg string, code string) {
+	writeJSON(w, status, map[string]any{
+		"error": map[string]any{"message": msg, "type": "invalid_request_error", "code": code},
+	})
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/functions"
+)
+
+func post(t *testing.T, url string, scenarioName string, body string) (*http.Response, string) {
+	req, err := http.NewRequest(http.MethodPost, url+"/v1/chat/completions", strings.NewReader(body))
+	require.NoError(t, err)
+	if scenarioName != "" {
+		req.Header.Set(headerScenario, scenarioName)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp, string(data)
+}
+
+func TestMockServer_Stream(t *testing.T) {
+	server := httptest.NewServer(newMockServer(map[string]scenario{
+		defaultScenario: {Content: "hello mock world", ChunkSize: 5},
+	}))
+	defer server.Close()
+
+	resp, body := post(t, server.URL, "", `{"model":"any","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	assert.Contains(t, body, `"content":"hello"`)
+	assert.Contains(t, body, `"content":" mock"`)
+	assert.Contains(t, body, `"finish_reason":"stop"`)
+	assert.True(t, strings.HasSuffix(body, "data: [DONE]\n\n"))
+
+	resp, body = post(t, server.URL, "", `{"model":"any","messages":[{"role":"user","content":"hi"}]}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, `"content":"hello mock world"`)
+}
+
+func TestMockServer_Errors(t *testing.T) {
+	server := httptest.NewServer(newMockServer(builtinScenarios()))
+	defer server.Close()
+
+	// Rate limited twice, then served
+	for i := 0; i < 2; i++ {
+		resp, _ := post(t, server.URL, "ratelimit", `{"model":"m","messages":[]}`)
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	}
+	resp, _ := post(t, server.URL, "ratelimit", `{"model":"m","messages":[]}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Selected by the model name
+	resp, body := post(t, server.URL, "", `{"model":"contextlength","messages":[]}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, body, "This model's maximum context length")
+
+	// The stream ends without [DONE]
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, server.URL+"/v1/chat/completions",
+		strings.NewReader(`{"model":"disconnect","stream":true,"messages":[]}`))
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Error(t, err)
+	assert.NotContains(t, string(data), "[DONE]")
+}
+
+func TestMockServer_ToolCall(t *testing.T) {
+	server := httptest.NewServer(newMockServer(builtinScenarios()))
+	defer server.Close()
+	executor := functions.NewGenericToolExecutor(&config.ToolConfig{
+		GenericTools: []config.GenericToolConfig{{Name: "codebase_search"}},
+	}, nil)
+
+	_, body := post(t, server.URL, "tool", `{"model":"m","messages":[{"role":"user","content":"find it"}]}`)
+	detected, name := executor.DetectTools(context.Background(), body)
+	assert.True(t, detected)
+	assert.Equal(t, "codebase_search", name)
+
+	// The model answers once the tool result is returned
+	_, body = post(t, server.URL, "tool", `{"model":"m","messages":[{"role":"user","content":[{"type":"text","text":"[codebase_search] Result:"}]}]}`)
+	detected, _ = executor.DetectTools(context.Background(), body)
+	assert.False(t, detected)
+}
+
+func TestLoadScenarios(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scenarios.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+scenarios:
+  Flaky:
+    statusCode: 503
+    failTimes: 1
+  default:
+    content: "custom"
+`), 0o644))
+
+	scenarios, err := loadScenarios(path)
+	require.NoError(t, err)
+	assert.Equal(t, 503, scenarios["flaky"].StatusCode)
+	assert.Equal(t, "custom", scenarios[defaultScenario].Content)
+	assert.Contains(t, scenarios, "tool")
+}
@@ -0,0 +1,80 @@
+package bootstrap
+
+import (
+	"time"
+
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/logger"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+	"go.uber.org/zap"
+)
+
+// modelOwner is the owned_by value of every listed model
+const modelOwner = "chat-rag"
+
+// BuildModelList builds the model listing from the router candidates and the model registry.
+// The virtual auto model is listed first when the router is enabled.
+func BuildModelList(cfg config.Config) []types.ModelInfo {
+	created := time.Now().Unix()
+	models := make([]types.ModelInfo, 0)
+	seen := make(map[string]bool)
+
+	add := func(name string) {
+		if name == "" || seen[name] {
+			return
+		}
+		seen[name] = true
+
+		info := types.ModelInfo{
+			ID:              name,
+			Object:          "model",
+			Created:         created,
+			OwnedBy:         modelOwner,
+			FunctionCalling: cfg.IsFuncCallingModel(name),
+		}
+		if registry, ok := cfg.LookupModel(name); ok {
+			info.ContextWindow = registry.ContextWindow
+			info.Vision = registry.Vision
+		}
+		models = append(models, info)
+	}
+
+	if cfg.Router != nil && cfg.Router.Enabled {
+		add(types.AutoModelName)
+	}
+	if cfg.Router != nil {
+		for _, candidate := range cfg.Router.Semantic.Routing.Candidates {
+			if candidate.Enabled {
+				add(candidate.ModelName)
+			}
+		}
+		for _, candidate := range cfg.Router.Priority.Candidates {
+			if candidate.Enabled {
+				add(candidate.ModelName)
+			}
+		}
+	}
+	for _, m := range cfg.LLM.Models {
+		add(m.Name)
+	}
+
+	return models
+}
+
+// GetModelList returns the cached model listing (thread-safe)
+func (svc *ServiceContext) GetModelList() []types.ModelInfo {
+	svc.modelListLock.RLock()
+	defer svc.modelListLock.RUnlock()
+	return svc.modelList
+}
+
+// refreshModelList rebuilds the cached model listing from the current configuration
+// Caller should hold svc.mu when the configuration may be updated concurrently
+func (svc *ServiceContext) refreshModelList() {
+	models := BuildModelList(svc.Config)
+
+	svc.modelListLock.Lock()
+	defer svc.modelListLock.Unlock()
+	svc.modelList = models
+	logger.Info("Model list refreshed", zap.Int("count", len(models)))
+}
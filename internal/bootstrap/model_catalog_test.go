@@ -0,0 +1,88 @@
+package bootstrap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+func TestBuildModelList(t *testing.T) {
+	cfg := config.Config{
+		LLM: config.LLMConfig{
+			FuncCallingModels: []string{"gpt-4o"},
+			Models: []config.ModelConfig{
+				{Name: "gpt-4o", ContextWindow: 128000, Vision: true},
+				{Name: "deepseek-v3", ContextWindow: 64000},
+			},
+		},
+	}
+	cfg.Router = &config.RouterConfig{
+		Enabled: true,
+		Semantic: config.SemanticConfig{
+			Routing: config.RoutingConfig{
+				Candidates: []config.RoutingCandidate{
+					{ModelName: "gpt-4o", Enabled: true},
+					{ModelName: "disabled-model", Enabled: false},
+				},
+			},
+		},
+		Priority: config.PriorityConfig{
+			Candidates: []config.PriorityCandidate{
+				{ModelName: "claude-sonnet", Enabled: true},
+				{ModelName: "gpt-4o", Enabled: true},
+			},
+		},
+	}
+
+	models := BuildModelList(cfg)
+
+	ids := make([]string, 0, len(models))
+	for _, m := range models {
+		ids = append(ids, m.ID)
+	}
+	assert.Equal(t, []string{types.AutoModelName, "gpt-4o", "claude-sonnet", "deepseek-v3"}, ids)
+
+	require.Len(t, models, 4)
+	assert.True(t, models[1].FunctionCalling)
+	assert.True(t, models[1].Vision)
+	assert.Equal(t, 128000, models[1].ContextWindow)
+	assert.False(t, models[2].FunctionCalling)
+	assert.Equal(t, 0, models[2].ContextWindow)
+}
+
+func TestBuildModelList_RouterDisabled(t *testing.T) {
+	cfg := config.Config{}
+	cfg.Router = &config.RouterConfig{
+		Enabled: false,
+		Priority: config.PriorityConfig{
+			Candidates: []config.PriorityCandidate{{ModelName: "gpt-4o", Enabled: true}},
+		},
+	}
+
+	models := BuildModelList(cfg)
+
+	require.Len(t, models, 1)
+	assert.Equal(t, "gpt-4o", models[0].ID)
+}
+
+func TestServiceContext_RefreshModelListOnRouterUpdate(t *testing.T) {
+	svc := &ServiceContext{}
+	svc.refreshModelList()
+	assert.Empty(t, svc.GetModelList())
+
+	svc.updateRouterConfig(&config.RouterConfig{
+		Enabled:  true,
+		Strategy: "priority",
+		Priority: config.PriorityConfig{
+			Candidates: []config.PriorityCandidate{{ModelName: "gpt-4o", Enabled: true}},
+		},
+	})
+
+	models := svc.GetModelList()
+	require.Len(t, models, 2)
+	assert.Equal(t, types.AutoModelName, models[0].ID)
+	assert.Equal(t, "gpt-4o", models[1].ID)
+}
@@ -186,7 +186,7 @@ func getNacosConfigMetadata() []NacosConfigMetadata {
 			UpdateFunc: func(svc *ServiceContext, data interface{}) {
 				if toolsConfig, ok := data.(*config.ToolConfig); ok {
 					logger.Info("Recreating tool executor with new tools configuration")
-					newToolExecutor := functions.NewGenericToolExecutor(toolsConfig)
+					newToolExecutor := svc.ToolReadiness.Wrap(functions.NewGenericToolExecutor(toolsConfig, svc.RedisClient))
 					svc.updateToolExecutor(newToolExecutor)
 					logger.Info("Tool executor successfully recreated with new configuration")
 				}
@@ -3,18 +3,24 @@ package bootstrap
 import (
 	"context"
 	"fmt"
+	"io"
 	"sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/zgsm-ai/chat-rag/internal/client"
 	"github.com/zgsm-ai/chat-rag/internal/config"
 	"github.com/zgsm-ai/chat-rag/internal/functions"
 	"github.com/zgsm-ai/chat-rag/internal/logger"
 	"github.com/zgsm-ai/chat-rag/internal/service"
 	"github.com/zgsm-ai/chat-rag/internal/tokenizer"
+	"github.com/zgsm-ai/chat-rag/internal/types"
 	"go.uber.org/zap"
 )
 
+// Delay before closing a replaced tool executor, letting in-flight tool calls finish
+const toolExecutorCloseDelay = time.Minute
+
 // ServiceContext holds all service dependencies with thread-safe access
 // Fields are exported for backward compatibility while maintaining thread safety through update methods
 type ServiceContext struct {
@@ -27,17 +33,36 @@ type ServiceContext struct {
 	LoggerService  service.LogRecordInterface
 	MetricsService service.MetricsInterface
 
+	// Per-model circuit breaker consulted by routing and degradation
+	CircuitBreaker *service.CircuitBreaker
+
 	// Utilities
 	TokenCounter *tokenizer.TokenCounter
 
 	ToolExecutor functions.ToolExecutor
 
+	// Background readiness prober of the tools, its ready checks serve the tool executor
+	ToolReadiness *service.ToolReadinessProber
+
+	// Conversation state store for the Responses API
+	ConversationStore service.ConversationStoreInterface
+
+	// Exact-match response cache, nil when disabled
+	ResponseCache service.ResponseCacheInterface
+
+	// Semantic response cache, nil when disabled
+	SemanticCache service.SemanticCacheInterface
+
 	// Router strategy instance (maintained as singleton for state consistency)
 	// This ensures round-robin and other stateful strategies maintain their state across requests
 	// Stored as interface{} to avoid circular dependency with router package
 	routerStrategy     interface{}
 	routerStrategyLock sync.RWMutex
 
+	// Cached /v1/models listing, rebuilt when router configuration changes
+	modelList     []types.ModelInfo
+	modelListLock sync.RWMutex
+
 	// Unified Nacos configuration manager
 	NacosConfigManager *NacosConfigManager
 
@@ -89,11 +114,18 @@ func (svc *ServiceContext) initialize() error {
 	initializers := []func() error{
 		svc.initializeTokenCounter,
 		svc.initializeMetricsService,
+		svc.initializeCircuitBreaker,
+		svc.initializeCassette,
 		svc.initializeLoggerService,
 		svc.initializeRedisClient,
+		svc.initializeConversationStore,
+		svc.initializeResponseCache,
+		svc.initializeSemanticCache,
+		svc.initializeToolReadiness,
 		svc.initializeNacosConfig,
 		svc.initializeToolExecutor,
 		svc.initializeRouterStrategy,
+		svc.initializeModelList,
 		svc.startNacosConfigWatching,
 	}
 
@@ -135,6 +167,31 @@ func (svc *ServiceContext) initializeMetricsService() error {
 	return nil
 }
 
+// initializeCircuitBreaker initializes the per-model circuit breaker and exports its state
+func (svc *ServiceContext) initializeCircuitBreaker() error {
+	if svc.CircuitBreaker != nil {
+		return nil // Already set via option
+	}
+
+	svc.CircuitBreaker = service.NewCircuitBreaker(svc.Config.CircuitBreaker)
+	prometheus.MustRegister(svc.CircuitBreaker)
+	logger.Info("Circuit breaker initialized successfully",
+		zap.Bool("enabled", svc.Config.CircuitBreaker.Enabled))
+	return nil
+}
+
+// initializeCassette configures the recording and replay of upstream traffic
+func (svc *ServiceContext) initializeCassette() error {
+	client.ConfigureCassette(svc.Config.Cassette)
+	if svc.Config.Cassette.Mode != config.CassetteModeOff || svc.Config.Cassette.AllowHeader {
+		logger.Info("Cassette configured",
+			zap.String("mode", svc.Config.Cassette.Mode),
+			zap.String("dir", svc.Config.Cassette.Dir),
+			zap.Bool("allowHeader", svc.Config.Cassette.AllowHeader))
+	}
+	return nil
+}
+
 // initializeLoggerService initializes and starts the logger service
 func (svc *ServiceContext) initializeLoggerService() error {
 	svc.LoggerService = service.NewLogRecordService(svc.Config)
@@ -160,6 +217,49 @@ func (svc *ServiceContext) initializeRedisClient() error {
 	return nil
 }
 
+// initializeConversationStore initializes the Responses API conversation store
+func (svc *ServiceContext) initializeConversationStore() error {
+	if svc.ConversationStore != nil {
+		return nil // Already set via option
+	}
+
+	svc.ConversationStore = service.NewConversationStore(svc.Config.Responses, svc.RedisClient)
+	logger.Info("Conversation store initialized successfully",
+		zap.String("store", svc.Config.Responses.Store))
+	return nil
+}
+
+// initializeResponseCache initializes the exact-match response cache when enabled
+func (svc *ServiceContext) initializeResponseCache() error {
+	if svc.ResponseCache != nil || !svc.Config.ResponseCache.Enabled {
+		return nil // Already set via option or disabled
+	}
+
+	ttl := time.Duration(svc.Config.ResponseCache.TTLSec) * time.Second
+	svc.ResponseCache = service.NewResponseCache(svc.RedisClient, ttl)
+	logger.Info("Response cache initialized successfully",
+		zap.Duration("ttl", ttl))
+	return nil
+}
+
+// initializeSemanticCache initializes the semantic response cache when enabled
+func (svc *ServiceContext) initializeSemanticCache() error {
+	if svc.SemanticCache != nil || !svc.Config.SemanticCache.Enabled {
+		return nil // Already set via option or disabled
+	}
+
+	cache, err := service.NewSemanticCache(svc.Config.SemanticCache, svc.RedisClient)
+	if err != nil {
+		return fmt.Errorf("failed to initialize semantic cache: %w", err)
+	}
+	svc.SemanticCache = cache
+	logger.Info("Semantic cache initialized successfully",
+		zap.String("embedder", svc.Config.SemanticCache.Embedder.Type),
+		zap.String("store", svc.Config.SemanticCache.Store),
+		zap.Float64("threshold", svc.Config.SemanticCache.SimilarityThreshold))
+	return nil
+}
+
 // initializeNacosConfig initializes Nacos configuration
 func (svc *ServiceContext) initializeNacosConfig() error {
 	// Check if Nacos is configured
@@ -210,9 +310,23 @@ func (svc *ServiceContext) initializeNacosConfig() error {
 	return nil
 }
 
+// initializeToolReadiness initializes the tool readiness prober and exports its state
+func (svc *ServiceContext) initializeToolReadiness() error {
+	if svc.ToolReadiness != nil {
+		return nil // Already set via option
+	}
+
+	svc.ToolReadiness = service.NewToolReadinessProber(svc.Config.ToolReadiness)
+	prometheus.MustRegister(svc.ToolReadiness)
+	svc.ToolReadiness.Start()
+	logger.Info("Tool readiness prober initialized",
+		zap.Bool("enabled", svc.Config.ToolReadiness.Enabled))
+	return nil
+}
+
 // initializeToolExecutor initializes the tool executor
 func (svc *ServiceContext) initializeToolExecutor() error {
-	svc.ToolExecutor = functions.NewGenericToolExecutor(svc.Config.Tools)
+	svc.ToolExecutor = svc.ToolReadiness.Wrap(functions.NewGenericToolExecutor(svc.Config.Tools, svc.RedisClient))
 	logger.Info("Tool executor initialized successfully")
 	return nil
 }
@@ -225,6 +339,12 @@ func (svc *ServiceContext) initializeRouterStrategy() error {
 	return nil
 }
 
+// initializeModelList builds the initial model listing
+func (svc *ServiceContext) initializeModelList() error {
+	svc.refreshModelList()
+	return nil
+}
+
 // GetRouterStrategy returns the router strategy instance (thread-safe)
 // Returns interface{} to avoid circular dependency - caller should type assert
 func (svc *ServiceContext) GetRouterStrategy() interface{} {
@@ -302,6 +422,9 @@ func (svc *ServiceContext) Stop() error {
 			{"logger service", svc.shutdownLoggerService},
 			{"Nacos connection", svc.shutdownNacosConnection},
 			{"Redis connection", svc.shutdownRedisConnection},
+			{"LLM transports", svc.shutdownLLMTransports},
+			{"tool readiness prober", svc.shutdownToolReadiness},
+			{"tool executor", svc.shutdownToolExecutor},
 		}
 
 		// Execute shutdown steps
@@ -375,6 +498,32 @@ func (svc *ServiceContext) shutdownRedisConnection(ctx context.Context) error {
 	return nil
 }
 
+// shutdownLLMTransports closes the idle upstream connections of the pooled LLM transports
+func (svc *ServiceContext) shutdownLLMTransports(ctx context.Context) error {
+	logger.Info("Closing LLM transport connections...")
+	client.CloseIdleTransports()
+	return nil
+}
+
+// shutdownToolReadiness stops the background probing of the tools
+func (svc *ServiceContext) shutdownToolReadiness(ctx context.Context) error {
+	svc.ToolReadiness.Stop()
+	return nil
+}
+
+// shutdownToolExecutor closes the tool server connections, stdio MCP servers are stopped
+func (svc *ServiceContext) shutdownToolExecutor(ctx context.Context) error {
+	svc.mu.RLock()
+	executor := svc.ToolExecutor
+	svc.mu.RUnlock()
+
+	if closer, ok := executor.(io.Closer); ok {
+		logger.Info("Closing tool executor connections...")
+		return closer.Close()
+	}
+	return nil
+}
+
 // Direct field access for backward compatibility
 // These fields can be accessed directly while maintaining thread safety through the update methods
 
@@ -395,7 +544,17 @@ func (svc *ServiceContext) updateRulesConfig(config *config.RulesConfig) {
 func (svc *ServiceContext) updateToolExecutor(executor functions.ToolExecutor) {
 	svc.mu.Lock()
 	defer svc.mu.Unlock()
+	previous := svc.ToolExecutor
 	svc.ToolExecutor = executor
+
+	// Connections of the previous executor, such as MCP servers, are closed once in-flight tool calls are done
+	if closer, ok := previous.(io.Closer); ok {
+		time.AfterFunc(toolExecutorCloseDelay, func() {
+			if err := closer.Close(); err != nil {
+				logger.Warn("Failed to close previous tool executor", zap.Error(err))
+			}
+		})
+	}
 }
 
 func (svc *ServiceContext) updatePreciseContextConfig(config *config.PreciseContextConfig) {
@@ -415,4 +574,7 @@ func (svc *ServiceContext) updateRouterConfig(routerConfig *config.RouterConfig)
 	// Clear cached router strategy so it will be recreated on next use with new config
 	svc.SetRouterStrategy(nil)
 	logger.Info("Router configuration updated, strategy cache cleared")
+
+	// Router candidates drive the model listing
+	svc.refreshModelList()
 }
@@ -0,0 +1,214 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/timeout"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+const (
+	anthropicDefaultEndpoint = "https://api.anthropic.com/v1/messages"
+	anthropicAPIVersion      = "2023-06-01"
+)
+
+// AnthropicClient adapts the Anthropic Messages API to LLMInterface
+type AnthropicClient struct {
+	*LLMClient
+	maxTokens int
+}
+
+// newAnthropicClient wraps the base client, the API token is sent as x-api-key
+func newAnthropicClient(base *LLMClient, target config.UpstreamTarget) *AnthropicClient {
+	if base.endpoint == "" {
+		base.endpoint = anthropicDefaultEndpoint
+	}
+
+	headers := nativeProviderHeaders(base.headers, "x-api-key", target.ApiToken)
+	headers.Set("anthropic-version", anthropicAPIVersion)
+	base.headers = &headers
+
+	return &AnthropicClient{
+		LLMClient: base,
+		maxTokens: target.MaxOutputTokens,
+	}
+}
+
+// GenerateContent generate content using a structured message format
+func (c *AnthropicClient) GenerateContent(ctx context.Context, systemPrompt string, userMessages []types.Message) (string, error) {
+	return c.generateContent(ctx, c.ChatLLMWithMessagesRaw, systemPrompt, userMessages)
+}
+
+// ChatLLMWithMessagesStreamRaw streams the Anthropic response as OpenAI style chunks
+func (c *AnthropicClient) ChatLLMWithMessagesStreamRaw(ctx context.Context, params types.LLMRequestParams, idleTimer *timeout.IdleTimer, callback func(LLMResponse) error) error {
+	if callback == nil {
+		return fmt.Errorf("callback function cannot be nil")
+	}
+
+	payload := types.NewAnthropicUpstreamRequest(c.modelName, c.withTools(params), c.maxTokens)
+	payload.Stream = true
+
+	resp, err := c.send(ctx, c.endpoint, payload, idleTimer)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return c.nativeAPIError(resp, "AnthropicClient get streaming error response", anthropicErrorMessage)
+	}
+
+	stream := &anthropicStream{
+		emitter:    newChunkEmitter(c.modelName, resp.Header, callback),
+		toolBlocks: make(map[int]int),
+	}
+	if err := c.scanSSEData(ctx, resp.Body, idleTimer, stream.handle); err != nil {
+		return err
+	}
+	return stream.emitter.emitDone()
+}
+
+// ChatLLMWithMessagesRaw sends a non-streaming Anthropic request and converts the response
+func (c *AnthropicClient) ChatLLMWithMessagesRaw(ctx context.Context, params types.LLMRequestParams, idleTimer *timeout.IdleTimer) (types.ChatCompletionResponse, error) {
+	payload := types.NewAnthropicUpstreamRequest(c.modelName, c.withTools(params), c.maxTokens)
+
+	resp, err := c.send(ctx, c.endpoint, payload, idleTimer)
+	if err != nil {
+		return types.ChatCompletionResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return types.ChatCompletionResponse{}, c.nativeAPIError(resp, "AnthropicClient get error response", anthropicErrorMessage)
+	}
+
+	body, err := c.readBody(ctx, resp, idleTimer)
+	if err != nil {
+		return types.ChatCompletionResponse{}, err
+	}
+
+	var result types.AnthropicMessagesResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return types.ChatCompletionResponse{}, fmt.Errorf("failed to parse anthropic response (body: %s): %w", string(body), err)
+	}
+
+	chatResp := result.ToChatCompletionResponse()
+	chatResp.Created = time.Now().Unix()
+	return chatResp, nil
+}
+
+// anthropicStream translates Anthropic stream events into OpenAI style chunks
+type anthropicStream struct {
+	emitter    *chunkEmitter
+	toolBlocks map[int]int // content block index -> tool call index
+	usage      types.AnthropicUsage
+}
+
+func (s *anthropicStream) handle(data string) error {
+	var event types.AnthropicStreamEvent
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		return fmt.Errorf("failed to parse anthropic stream event: %w", err)
+	}
+
+	blockIndex := 0
+	if event.Index != nil {
+		blockIndex = *event.Index
+	}
+
+	switch event.Type {
+	case types.AnthropicEventMessageStart:
+		if event.Message != nil {
+			s.emitter.id = event.Message.ID
+			if event.Message.Model != "" {
+				s.emitter.model = event.Message.Model
+			}
+			s.usage = event.Message.Usage
+		}
+		return s.emitter.emitDelta(types.Delta{Role: types.RoleAssistant})
+
+	case types.AnthropicEventContentBlockStart:
+		data, _ := json.Marshal(event.ContentBlock)
+		var block types.AnthropicContentBlock
+		if err := json.Unmarshal(data, &block); err != nil || block.Type != types.AnthropicBlockToolUse {
+			return nil
+		}
+		toolIndex := len(s.toolBlocks)
+		s.toolBlocks[blockIndex] = toolIndex
+		return s.emitter.emitToolCall(toolIndex, block.ID, block.Name, "")
+
+	case types.AnthropicEventContentBlockDelta:
+		switch event.Delta["type"] {
+		case "text_delta":
+			text, _ := event.Delta["text"].(string)
+			return s.emitter.emitDelta(types.Delta{Content: text})
+		case "thinking_delta":
+			thinking, _ := event.Delta["thinking"].(string)
+			return s.emitter.emitDelta(types.Delta{ReasoningContent: thinking})
+		case "input_json_delta":
+			toolIndex, ok := s.toolBlocks[blockIndex]
+			if !ok {
+				return nil
+			}
+			partial, _ := event.Delta["partial_json"].(string)
+			return s.emitter.emitToolCall(toolIndex, "", "", partial)
+		}
+
+	case types.AnthropicEventMessageDelta:
+		if event.Usage != nil {
+			s.usage.OutputTokens = event.Usage.OutputTokens
+		}
+		if stopReason, ok := event.Delta["stop_reason"].(string); ok && stopReason != "" {
+			return s.emitter.emitFinish(types.AnthropicFinishReason(stopReason))
+		}
+
+	case types.AnthropicEventMessageStop:
+		if err := s.emitter.emitUsage(s.usage.ToUsage()); err != nil {
+			return err
+		}
+		return s.emitter.emitDone()
+
+	case types.AnthropicEventError:
+		if event.Error != nil {
+			return types.NewHTTPStatusError(anthropicErrorStatus(event.Error.Type), event.Error.Message)
+		}
+		return types.NewModelServiceUnavailableError()
+	}
+
+	return nil
+}
+
+// anthropicErrorMessage extracts the message of an Anthropic error body
+func anthropicErrorMessage(body []byte) string {
+	var errResp types.AnthropicErrorResponse
+	if err := json.Unmarshal(body, &errResp); err != nil {
+		return ""
+	}
+	return errResp.Error.Message
+}
+
+// anthropicErrorStatus maps an Anthropic error type to the HTTP status of the same error
+func anthropicErrorStatus(errorType string) int {
+	switch errorType {
+	case "invalid_request_error":
+		return http.StatusBadRequest
+	case "authentication_error":
+		return http.StatusUnauthorized
+	case "permission_error":
+		return http.StatusForbidden
+	case "not_found_error":
+		return http.StatusNotFound
+	case "request_too_large":
+		return http.StatusRequestEntityTooLarge
+	case "rate_limit_error":
+		return http.StatusTooManyRequests
+	case "overloaded_error":
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,294 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+// streamResult accumulates the OpenAI style chunks written by a native adapter
+type streamResult struct {
+	content      string
+	reasoning    string
+	toolCalls    map[int]*types.ToolCallInfo
+	finishReason string
+	usage        *types.Usage
+	done         bool
+}
+
+// collectStream runs a streaming call and accumulates the chunks like ChatCompletionLogic does
+func collectStream(t *testing.T, llm LLMInterface, params types.LLMRequestParams) (*streamResult, error) {
+	t.Helper()
+	result := &streamResult{toolCalls: make(map[int]*types.ToolCallInfo)}
+	err := llm.ChatLLMWithMessagesStreamRaw(context.Background(), params, nil, func(resp LLMResponse) error {
+		data, ok := strings.CutPrefix(resp.ResonseLine, "data: ")
+		if !ok {
+			t.Fatalf("Unexpected stream line: %s", resp.ResonseLine)
+		}
+		if data == "[DONE]" {
+			result.done = true
+			return nil
+		}
+
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content          string `json:"content"`
+					ReasoningContent string `json:"reasoning_content"`
+					ToolCalls        []struct {
+						Index    int    `json:"index"`
+						ID       string `json:"id"`
+						Function struct {
+							Name      string `json:"name"`
+							Arguments string `json:"arguments"`
+						} `json:"function"`
+					} `json:"tool_calls"`
+				} `json:"delta"`
+				FinishReason *string `json:"finish_reason"`
+			} `json:"choices"`
+			Usage *types.Usage `json:"usage"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatalf("Invalid chunk %s: %v", data, err)
+		}
+		if chunk.Usage != nil {
+			result.usage = chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			result.content += choice.Delta.Content
+			result.reasoning += choice.Delta.ReasoningContent
+			for _, call := range choice.Delta.ToolCalls {
+				info, ok := result.toolCalls[call.Index]
+				if !ok {
+					info = &types.ToolCallInfo{}
+					result.toolCalls[call.Index] = info
+				}
+				if call.ID != "" {
+					info.ID = call.ID
+					info.Function.Name = call.Function.Name
+				}
+				info.Function.Arguments += call.Function.Arguments
+			}
+			if choice.FinishReason != nil {
+				result.finishReason = *choice.FinishReason
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+func newTestNativeClient(t *testing.T, provider, endpoint string) LLMInterface {
+	t.Helper()
+	headers := make(http.Header)
+	headers.Set("Authorization", "Bearer user-token")
+	headers.Set("X-Request-Id", "req-1")
+	headers.Set("Cookie", "session=user-session")
+	headers.Set("zgsm-client-id", "client-1")
+
+	llmConfig := config.LLMConfig{
+		Models: []config.ModelConfig{{
+			Name:     "native-model",
+			Provider: provider,
+			Endpoint: endpoint,
+			ApiToken: "sk-native",
+		}},
+	}
+	llm, err := NewLLMClient(llmConfig, config.LLMTimeoutConfig{}, "native-model", &headers)
+	if err != nil {
+		t.Fatalf("NewLLMClient failed: %v", err)
+	}
+	return llm
+}
+
+func TestNewLLMClient_NativeProviderRequiresToken(t *testing.T) {
+	headers := make(http.Header)
+	headers.Set("Authorization", "Bearer user-token")
+	for _, provider := range []string{config.ProviderAnthropic, config.ProviderGemini, config.ProviderAzure} {
+		llmConfig := config.LLMConfig{
+			Models: []config.ModelConfig{{Name: "native-model", Provider: provider, Endpoint: "http://upstream"}},
+		}
+		if err := llmConfig.Validate(); err == nil {
+			t.Errorf("Expected the %s model without apiToken to be invalid", provider)
+		}
+		if _, err := NewLLMClient(llmConfig, config.LLMTimeoutConfig{}, "native-model", &headers); err == nil {
+			t.Errorf("Expected an error creating the %s client without apiToken", provider)
+		}
+	}
+}
+
+func testToolParams() types.LLMRequestParams {
+	return types.LLMRequestParams{
+		Messages: []types.Message{
+			{Role: types.RoleSystem, Content: "You are helpful"},
+			{Role: types.RoleUser, Content: "find usages"},
+			{
+				Role:    types.RoleAssistant,
+				Content: "",
+				Extra: map[string]any{"tool_calls": []any{map[string]any{
+					"id":       "call_1",
+					"type":     "function",
+					"function": map[string]any{"name": "search", "arguments": `{"q":"foo"}`},
+				}}},
+			},
+			{Role: types.RoleTool, Content: "found 3", Extra: map[string]any{"tool_call_id": "call_1"}},
+		},
+		Extra: map[string]any{
+			"max_tokens": float64(512),
+			"tools": []any{map[string]any{
+				"type": "function",
+				"function": map[string]any{
+					"name":       "search",
+					"parameters": map[string]any{"type": "object"},
+				},
+			}},
+		},
+	}
+}
+
+func TestAnthropicClient_Stream(t *testing.T) {
+	var request types.AnthropicMessagesRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("x-api-key"); got != "sk-native" {
+			t.Errorf("Expected x-api-key sk-native, got %s", got)
+		}
+		if got := r.Header.Get("Authorization"); got != "" {
+			t.Errorf("Authorization header must not be forwarded, got %s", got)
+		}
+		if r.Header.Get("Cookie") != "" || r.Header.Get("zgsm-client-id") != "" {
+			t.Errorf("Headers of the caller must not be forwarded, got %v", r.Header)
+		}
+		if got := r.Header.Get("X-Request-Id"); got != "req-1" {
+			t.Errorf("Expected X-Request-Id req-1, got %s", got)
+		}
+		if got := r.Header.Get("anthropic-version"); got == "" {
+			t.Error("Missing anthropic-version header")
+		}
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &request); err != nil {
+			t.Errorf("Invalid request body: %v", err)
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		events := []string{
+			`{"type":"message_start","message":{"id":"msg_1","model":"claude-test","usage":{"input_tokens":12,"output_tokens":1}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"hmm"}}`,
+			`{"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"Hel"}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"lo"}}`,
+			`{"type":"content_block_start","index":2,"content_block":{"type":"tool_use","id":"toolu_1","name":"search","input":{}}}`,
+			`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"{\"q\":"}}`,
+			`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"\"bar\"}"}}`,
+			`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":7}}`,
+			`{"type":"message_stop"}`,
+		}
+		for _, event := range events {
+			var typed struct {
+				Type string `json:"type"`
+			}
+			json.Unmarshal([]byte(event), &typed)
+			io.WriteString(w, "event: "+typed.Type+"\ndata: "+event+"\n\n")
+		}
+	}))
+	defer server.Close()
+
+	llm := newTestNativeClient(t, config.ProviderAnthropic, server.URL)
+	result, err := collectStream(t, llm, testToolParams())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Request translation
+	if request.MaxTokens != 512 || !request.Stream {
+		t.Errorf("Unexpected max_tokens %d or stream %v", request.MaxTokens, request.Stream)
+	}
+	if request.System == nil {
+		t.Error("System prompt was not translated")
+	}
+	if len(request.Messages) != 3 {
+		t.Fatalf("Expected 3 messages, got %d", len(request.Messages))
+	}
+	toolResult, _ := json.Marshal(request.Messages[2])
+	if !strings.Contains(string(toolResult), `"tool_use_id":"call_1"`) {
+		t.Errorf("Tool result was not translated: %s", toolResult)
+	}
+	if len(request.Tools) != 1 || request.Tools[0].Name != "search" {
+		t.Errorf("Tools were not translated: %+v", request.Tools)
+	}
+
+	// Response translation
+	if result.content != "Hello" || result.reasoning != "hmm" {
+		t.Errorf("Unexpected content %q or reasoning %q", result.content, result.reasoning)
+	}
+	call := result.toolCalls[0]
+	if call == nil || call.ID != "toolu_1" || call.Function.Name != "search" || call.Function.Arguments != `{"q":"bar"}` {
+		t.Errorf("Unexpected tool call: %+v", call)
+	}
+	if result.finishReason != "tool_calls" {
+		t.Errorf("Expected finish reason tool_calls, got %s", result.finishReason)
+	}
+	if result.usage == nil || result.usage.PromptTokens != 12 || result.usage.CompletionTokens != 7 {
+		t.Errorf("Unexpected usage: %+v", result.usage)
+	}
+	if !result.done {
+		t.Error("Missing [DONE]")
+	}
+}
+
+func TestAnthropicClient_Raw(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{
+			"id": "msg_1",
+			"type": "message",
+			"role": "assistant",
+			"model": "claude-test",
+			"content": [{"type": "text", "text": "summary"}],
+			"stop_reason": "end_turn",
+			"usage": {"input_tokens": 10, "output_tokens": 2}
+		}`)
+	}))
+	defer server.Close()
+
+	llm := newTestNativeClient(t, config.ProviderAnthropic, server.URL)
+	content, err := llm.GenerateContent(context.Background(), "summarize", []types.Message{{Role: types.RoleUser, Content: "text"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if content != "summary" {
+		t.Errorf("Expected summary, got %s", content)
+	}
+}
+
+func TestAnthropicClient_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "stream-error") {
+			io.WriteString(w, "event: error\n"+`data: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`+"\n\n")
+			return
+		}
+		w.WriteHeader(http.StatusTooManyRequests)
+		io.WriteString(w, `{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`)
+	}))
+	defer server.Close()
+
+	llm := newTestNativeClient(t, config.ProviderAnthropic, server.URL)
+	_, err := collectStream(t, llm, testToolParams())
+	var apiErr *types.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Expected 429 APIError, got %v", err)
+	}
+
+	llm = newTestNativeClient(t, config.ProviderAnthropic, server.URL+"/stream-error")
+	_, err = collectStream(t, llm, testToolParams())
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 APIError, got %v", err)
+	}
+}
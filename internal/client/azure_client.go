@@ -0,0 +1,49 @@
+package client
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/zgsm-ai/chat-rag/internal/config"
+)
+
+const azureDefaultAPIVersion = "2024-10-21"
+
+// newAzureClient configures the base client for an Azure OpenAI deployment.
+// Azure speaks the OpenAI protocol, only the deployment url and the api-key header differ.
+func newAzureClient(base *LLMClient, target config.UpstreamTarget) (*LLMClient, error) {
+	if base.endpoint == "" {
+		return nil, fmt.Errorf("NewLLMClient llmEndpoint cannot be empty")
+	}
+
+	endpoint, err := azureDeploymentURL(base.endpoint, base.modelName)
+	if err != nil {
+		return nil, fmt.Errorf("NewLLMClient invalid azure endpoint: %w", err)
+	}
+	base.endpoint = endpoint
+
+	headers := nativeProviderHeaders(base.headers, "api-key", target.ApiToken)
+	base.headers = &headers
+
+	return base, nil
+}
+
+// azureDeploymentURL builds the chat completions url of a deployment from the resource endpoint.
+// Full deployment urls are kept as is, the api-version query is added when missing.
+func azureDeploymentURL(endpoint, deployment string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	if !strings.Contains(u.Path, "/openai/deployments/") {
+		u.Path = strings.TrimRight(u.Path, "/") + "/openai/deployments/" + deployment + "/chat/completions"
+	}
+
+	query := u.Query()
+	if query.Get("api-version") == "" {
+		query.Set("api-version", azureDefaultAPIVersion)
+		u.RawQuery = query.Encode()
+	}
+	return u.String(), nil
+}
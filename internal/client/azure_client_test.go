@@ -0,0 +1,63 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+func TestAzureDeploymentURL(t *testing.T) {
+	testCases := []struct {
+		endpoint string
+		expected string
+	}{
+		{
+			"https://res.openai.azure.com",
+			"https://res.openai.azure.com/openai/deployments/gpt-4o/chat/completions?api-version=" + azureDefaultAPIVersion,
+		},
+		{
+			"https://res.openai.azure.com/openai/deployments/prod-4o/chat/completions?api-version=2025-01-01-preview",
+			"https://res.openai.azure.com/openai/deployments/prod-4o/chat/completions?api-version=2025-01-01-preview",
+		},
+	}
+
+	for _, tc := range testCases {
+		got, err := azureDeploymentURL(tc.endpoint, "gpt-4o")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got != tc.expected {
+			t.Errorf("Expected %s, got %s", tc.expected, got)
+		}
+	}
+}
+
+func TestAzureClient_Stream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/openai/deployments/native-model/chat/completions" || r.URL.Query().Get("api-version") == "" {
+			t.Errorf("Unexpected url: %s", r.URL.String())
+		}
+		if got := r.Header.Get("api-key"); got != "sk-native" {
+			t.Errorf("Expected api-key sk-native, got %s", got)
+		}
+		if got := r.Header.Get("Authorization"); got != "" {
+			t.Errorf("Authorization header must not be forwarded, got %s", got)
+		}
+		w.Write([]byte(`data: {"choices":[{"index":0,"delta":{"content":"Hi"},"finish_reason":"stop"}]}` + "\n\n" + "data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	llm := newTestNativeClient(t, config.ProviderAzure, server.URL)
+	result, err := collectStream(t, llm, types.LLMRequestParams{
+		Messages: []types.Message{{Role: types.RoleUser, Content: "hello"}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.content != "Hi" || result.finishReason != "stop" || !result.done {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+}
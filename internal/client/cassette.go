@@ -0,0 +1,327 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/logger"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+// cassetteConfig is the recording and replay configuration of every upstream client
+var cassetteConfig atomic.Pointer[config.CassetteConfig]
+
+type cassetteModeKey struct{}
+
+// ConfigureCassette sets how upstream LLM and tool traffic is recorded and replayed
+func ConfigureCassette(cfg config.CassetteConfig) {
+	cassetteConfig.Store(&cfg)
+}
+
+// WithCassetteMode returns a context whose upstream requests use the cassette mode instead of the configured one
+func WithCassetteMode(ctx context.Context, mode string) context.Context {
+	return context.WithValue(ctx, cassetteModeKey{}, mode)
+}
+
+// IsCassetteMode reports whether the mode is a known cassette mode
+func IsCassetteMode(mode string) bool {
+	switch mode {
+	case config.CassetteModeOff, config.CassetteModeRecord, config.CassetteModeReplay:
+		return true
+	}
+	return false
+}
+
+// cassette is a recorded request and response pair
+type cassette struct {
+	Key        string           `json:"key"`
+	Request    cassetteRequest  `json:"request"`
+	Response   cassetteResponse `json:"response"`
+	Complete   bool             `json:"complete"`
+	RecordedAt time.Time        `json:"recorded_at"`
+}
+
+type cassetteRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+type cassetteResponse struct {
+	StatusCode int             `json:"status_code"`
+	Header     http.Header     `json:"header,omitempty"`
+	Chunks     []cassetteChunk `json:"chunks"`
+}
+
+// cassetteChunk is a read of the response body, SSE responses keep their timing
+type cassetteChunk struct {
+	// Time since the previous chunk
+	DelayMs int64  `json:"delay_ms"`
+	Data    string `json:"data"`
+}
+
+// cassetteTransport records upstream traffic to cassette files or serves it back from them
+type cassetteTransport struct {
+	next http.RoundTripper
+}
+
+// withCassette wraps the transport with cassette recording and replay
+func withCassette(next http.RoundTripper) http.RoundTripper {
+	return &cassetteTransport{next: next}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *cassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	cfg := cassetteConfig.Load()
+	mode := config.CassetteModeOff
+	if cfg != nil {
+		mode = cfg.Mode
+	}
+	if ctxMode, ok := req.Context().Value(cassetteModeKey{}).(string); ok {
+		mode = ctxMode
+	}
+	if req.Header.Get(types.HeaderCassetteMode) != "" {
+		// The mode header is consumed here, upstreams never see it
+		req = req.Clone(req.Context())
+		req.Header.Del(types.HeaderCassetteMode)
+	}
+	if mode != config.CassetteModeRecord && mode != config.CassetteModeReplay {
+		return t.next.RoundTrip(req)
+	}
+	if cfg == nil {
+		cfg = &config.CassetteConfig{Dir: "cassettes"}
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read request body: %w", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	key := cassetteKey(req, body, cfg.IgnoreFields)
+	path := filepath.Join(cfg.Dir, key+".json")
+
+	if mode == config.CassetteModeReplay {
+		return replayCassette(req, path, cfg.ReplayTiming)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &recordingBody{
+		body: resp.Body,
+		path: path,
+		last: time.Now(),
+		cassette: cassette{
+			Key:        key,
+			Request:    cassetteRequest{Method: req.Method, URL: req.URL.String(), Body: string(body)},
+			Response:   cassetteResponse{StatusCode: resp.StatusCode, Header: resp.Header.Clone()},
+			RecordedAt: time.Now(),
+		},
+	}
+	return resp, nil
+}
+
+// cassetteKey returns the hash matching a request to its cassette. Hosts and headers are left out,
+// so that cassettes replay against any deployment; query parameters and JSON body fields are
+// sorted, and the ignored ones are dropped.
+func cassetteKey(req *http.Request, body []byte, ignoreFields []string) string {
+	query := req.URL.Query()
+	for _, field := range ignoreFields {
+		query.Del(field)
+	}
+
+	h := sha256.New()
+	h.Write([]byte(req.Method + "\n" + req.URL.Path + "\n" + query.Encode() + "\n"))
+	h.Write(normalizeCassetteBody(body, ignoreFields))
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// normalizeCassetteBody returns the JSON body without the ignored top-level fields, with sorted keys.
+// Other bodies are returned as they are.
+func normalizeCassetteBody(body []byte, ignoreFields []string) []byte {
+	var decoded any
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return body
+	}
+	if fields, ok := decoded.(map[string]any); ok {
+		for _, field := range ignoreFields {
+			delete(fields, field)
+		}
+	}
+	normalized, err := json.Marshal(decoded)
+	if err != nil {
+		return body
+	}
+	return normalized
+}
+
+// replayCassette serves the response recorded for the request
+func replayCassette(req *http.Request, path string, timing bool) (*http.Response, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("no cassette recorded for %s %s (%s)", req.Method, req.URL.Path, filepath.Base(path))
+		}
+		return nil, fmt.Errorf("read cassette: %w", err)
+	}
+	var c cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("decode cassette %s: %w", filepath.Base(path), err)
+	}
+
+	header := c.Response.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Del("Content-Length")
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", c.Response.StatusCode, http.StatusText(c.Response.StatusCode)),
+		StatusCode:    c.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          &replayBody{ctx: req.Context(), chunks: c.Response.Chunks, timing: timing},
+		ContentLength: -1,
+		Request:       req,
+	}, nil
+}
+
+// replayBody returns the recorded chunks, waiting their recorded delays when timing is replayed
+type replayBody struct {
+	ctx     context.Context
+	chunks  []cassetteChunk
+	timing  bool
+	pending []byte
+}
+
+// Read implements io.Reader
+func (b *replayBody) Read(p []byte) (int, error) {
+	for len(b.pending) == 0 {
+		if len(b.chunks) == 0 {
+			return 0, io.EOF
+		}
+		chunk := b.chunks[0]
+		b.chunks = b.chunks[1:]
+		if b.timing && chunk.DelayMs > 0 {
+			timer := time.NewTimer(time.Duration(chunk.DelayMs) * time.Millisecond)
+			select {
+			case <-b.ctx.Done():
+				timer.Stop()
+				return 0, b.ctx.Err()
+			case <-timer.C:
+			}
+		}
+		b.pending = []byte(chunk.Data)
+	}
+	if err := b.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n := copy(p, b.pending)
+	b.pending = b.pending[n:]
+	return n, nil
+}
+
+// Close implements io.Closer
+func (b *replayBody) Close() error {
+	b.chunks = nil
+	b.pending = nil
+	return nil
+}
+
+// recordingBody records the response body as it is read and writes the cassette when it is
+// read to the end or closed
+type recordingBody struct {
+	body     io.ReadCloser
+	path     string
+	last     time.Time
+	cassette cassette
+	once     sync.Once
+}
+
+// Read implements io.Reader
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if n > 0 {
+		now := time.Now()
+		b.cassette.Response.Chunks = append(b.cassette.Response.Chunks, cassetteChunk{
+			DelayMs: now.Sub(b.last).Milliseconds(),
+			Data:    string(p[:n]),
+		})
+		b.last = now
+	}
+	if err == io.EOF {
+		b.cassette.Complete = true
+		b.save()
+	}
+	return n, err
+}
+
+// Close implements io.Closer
+func (b *recordingBody) Close() error {
+	b.save()
+	return b.body.Close()
+}
+
+// save writes the cassette once, a body closed before its end is recorded as incomplete
+func (b *recordingBody) save() {
+	b.once.Do(func() {
+		if err := writeCassette(b.path, &b.cassette); err != nil {
+			logger.Warn("failed to write cassette", zap.String("path", b.path), zap.Error(err))
+			return
+		}
+		logger.Info("cassette recorded",
+			zap.String("key", b.cassette.Key),
+			zap.String("url", b.cassette.Request.URL),
+			zap.Int("chunks", len(b.cassette.Response.Chunks)),
+			zap.Bool("complete", b.cassette.Complete),
+		)
+	})
+}
+
+// writeCassette writes the cassette file atomically, concurrent replays never read a partial file
+func writeCassette(path string, c *cassette) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+strings.TrimSuffix(filepath.Base(path), ".json")+"-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
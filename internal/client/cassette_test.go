@@ -0,0 +1,99 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zgsm-ai/chat-rag/internal/config"
+)
+
+func TestCassetteRecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	ConfigureCassette(config.CassetteConfig{Mode: config.CassetteModeOff, Dir: dir})
+	defer ConfigureCassette(config.CassetteConfig{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-cassette-mode") != "" {
+			t.Error("cassette mode header reached the upstream")
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		for _, line := range []string{"data: {\"id\":\"1\"}\n\n", "data: [DONE]\n\n"} {
+			io.WriteString(w, line)
+			flusher.Flush()
+		}
+	}))
+	httpClient := &http.Client{Transport: withCassette(http.DefaultTransport)}
+
+	send := func(mode string, url string) (string, error) {
+		ctx := WithCassetteMode(context.Background(), mode)
+		req, _ := http.NewRequestWithContext(ctx, http.MethodPost, url+"/v1/chat/completions",
+			strings.NewReader(`{"model":"m","stream":true}`))
+		req.Header.Set("x-cassette-mode", mode)
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	recorded, err := send(config.CassetteModeRecord, server.URL)
+	if err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("expected 1 cassette, got %d", len(files))
+	}
+	data, _ := os.ReadFile(files[0])
+	if !strings.Contains(string(data), `"complete": true`) {
+		t.Errorf("cassette not marked complete: %s", data)
+	}
+
+	// Replay needs no network
+	server.Close()
+	replayed, err := send(config.CassetteModeReplay, "http://upstream.invalid")
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if replayed != recorded {
+		t.Errorf("replayed %q, recorded %q", replayed, recorded)
+	}
+
+	// Unrecorded requests fail with the cassette key
+	ctx := WithCassetteMode(context.Background(), config.CassetteModeReplay)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, "http://upstream.invalid/v1/other", strings.NewReader(`{}`))
+	if _, err := httpClient.Do(req); err == nil || !strings.Contains(err.Error(), "no cassette recorded") {
+		t.Errorf("expected missing cassette error, got %v", err)
+	}
+}
+
+func TestCassetteKey(t *testing.T) {
+	newRequest := func(url string) *http.Request {
+		req, _ := http.NewRequest(http.MethodPost, url, nil)
+		return req
+	}
+	ignore := []string{"user", "ts"}
+
+	a := cassetteKey(newRequest("http://a/v1/chat?x=1&ts=1"), []byte(`{"model":"m","user":"u1","n":1}`), ignore)
+	b := cassetteKey(newRequest("http://b/v1/chat?ts=2&x=1"), []byte(`{"n":1,"user":"u2","model":"m"}`), ignore)
+	if a != b {
+		t.Errorf("expected equal keys for normalized requests, got %s and %s", a, b)
+	}
+
+	c := cassetteKey(newRequest("http://a/v1/chat?x=1"), []byte(`{"model":"other","n":1}`), ignore)
+	if a == c {
+		t.Error("expected different keys for different bodies")
+	}
+	if len(a) != 32 {
+		t.Errorf("expected 32 character key, got %d", len(a))
+	}
+}
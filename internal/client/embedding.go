@@ -0,0 +1,73 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// EmbeddingClient calls an OpenAI compatible embeddings endpoint
+type EmbeddingClient struct {
+	httpClient *HTTPClient
+	model      string
+	apiToken   string
+}
+
+// embeddingRequest is the body of an embeddings request
+type embeddingRequest struct {
+	Model string `json:"model,omitempty"`
+	Input string `json:"input"`
+}
+
+// embeddingResponse is the body of an embeddings response
+type embeddingResponse struct {
+	Data []struct {
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// NewEmbeddingClient creates a client of the embeddings endpoint
+func NewEmbeddingClient(endpoint, model, apiToken string, timeout time.Duration) *EmbeddingClient {
+	return &EmbeddingClient{
+		httpClient: NewHTTPClient(endpoint, HTTPClientConfig{Timeout: timeout}),
+		model:      model,
+		apiToken:   apiToken,
+	}
+}
+
+// Embed returns the embedding vector of the text
+func (c *EmbeddingClient) Embed(ctx context.Context, text string) ([]float32, error) {
+	req := Request{
+		Method: http.MethodPost,
+		Body:   embeddingRequest{Model: c.model, Input: text},
+	}
+	if c.apiToken != "" {
+		req.Authorization = "Bearer " + c.apiToken
+	}
+
+	resp, err := c.httpClient.DoRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embeddings response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embeddings request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result embeddingResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal embeddings response: %w", err)
+	}
+	if len(result.Data) == 0 || len(result.Data[0].Embedding) == 0 {
+		return nil, fmt.Errorf("embeddings response has no embedding")
+	}
+	return result.Data[0].Embedding, nil
+}
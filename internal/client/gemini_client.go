@@ -0,0 +1,191 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/timeout"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+const geminiDefaultEndpoint = "https://generativelanguage.googleapis.com/v1beta"
+
+// GeminiClient adapts the Gemini generateContent API to LLMInterface
+type GeminiClient struct {
+	*LLMClient
+	maxTokens int
+}
+
+// newGeminiClient wraps the base client, the endpoint is the API base url the model path is appended to
+// and the API token is sent as x-goog-api-key
+func newGeminiClient(base *LLMClient, target config.UpstreamTarget) *GeminiClient {
+	if base.endpoint == "" {
+		base.endpoint = geminiDefaultEndpoint
+	}
+
+	headers := nativeProviderHeaders(base.headers, "x-goog-api-key", target.ApiToken)
+	base.headers = &headers
+
+	return &GeminiClient{
+		LLMClient: base,
+		maxTokens: target.MaxOutputTokens,
+	}
+}
+
+// modelURL returns the url of a model method, e.g. generateContent
+func (c *GeminiClient) modelURL(method string) string {
+	return fmt.Sprintf("%s/models/%s:%s", strings.TrimRight(c.endpoint, "/"), url.PathEscape(c.modelName), method)
+}
+
+// GenerateContent generate content using a structured message format
+func (c *GeminiClient) GenerateContent(ctx context.Context, systemPrompt string, userMessages []types.Message) (string, error) {
+	return c.generateContent(ctx, c.ChatLLMWithMessagesRaw, systemPrompt, userMessages)
+}
+
+// ChatLLMWithMessagesStreamRaw streams the Gemini response as OpenAI style chunks
+func (c *GeminiClient) ChatLLMWithMessagesStreamRaw(ctx context.Context, params types.LLMRequestParams, idleTimer *timeout.IdleTimer, callback func(LLMResponse) error) error {
+	if callback == nil {
+		return fmt.Errorf("callback function cannot be nil")
+	}
+
+	payload := types.NewGeminiRequest(c.withTools(params), c.maxTokens)
+	resp, err := c.send(ctx, c.modelURL("streamGenerateContent")+"?alt=sse", payload, idleTimer)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return c.nativeAPIError(resp, "GeminiClient get streaming error response", geminiErrorMessage)
+	}
+
+	stream := &geminiStream{emitter: newChunkEmitter(c.modelName, resp.Header, callback)}
+	if err := c.scanSSEData(ctx, resp.Body, idleTimer, stream.handle); err != nil {
+		return err
+	}
+	return stream.finish()
+}
+
+// ChatLLMWithMessagesRaw sends a non-streaming Gemini request and converts the response
+func (c *GeminiClient) ChatLLMWithMessagesRaw(ctx context.Context, params types.LLMRequestParams, idleTimer *timeout.IdleTimer) (types.ChatCompletionResponse, error) {
+	payload := types.NewGeminiRequest(c.withTools(params), c.maxTokens)
+	resp, err := c.send(ctx, c.modelURL("generateContent"), payload, idleTimer)
+	if err != nil {
+		return types.ChatCompletionResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return types.ChatCompletionResponse{}, c.nativeAPIError(resp, "GeminiClient get error response", geminiErrorMessage)
+	}
+
+	body, err := c.readBody(ctx, resp, idleTimer)
+	if err != nil {
+		return types.ChatCompletionResponse{}, err
+	}
+
+	var result types.GeminiResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return types.ChatCompletionResponse{}, fmt.Errorf("failed to parse gemini response (body: %s): %w", string(body), err)
+	}
+
+	chatResp := result.ToChatCompletionResponse(c.modelName)
+	chatResp.Created = time.Now().Unix()
+	return chatResp, nil
+}
+
+// geminiStream translates Gemini stream chunks into OpenAI style chunks.
+// Gemini sends whole function calls in one chunk and cumulative usage in every chunk,
+// so the finish reason and usage are written once the stream ends.
+type geminiStream struct {
+	emitter      *chunkEmitter
+	started      bool
+	toolCalls    int
+	finishReason string
+	usage        *types.GeminiUsage
+}
+
+func (s *geminiStream) handle(data string) error {
+	var chunk struct {
+		types.GeminiResponse
+		Error *types.GeminiError `json:"error,omitempty"`
+	}
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return fmt.Errorf("failed to parse gemini stream chunk: %w", err)
+	}
+	if chunk.Error != nil {
+		return types.NewHTTPStatusError(chunk.Error.Code, chunk.Error.Message)
+	}
+
+	if chunk.ResponseID != "" {
+		s.emitter.id = chunk.ResponseID
+	}
+	if chunk.UsageMetadata != nil {
+		s.usage = chunk.UsageMetadata
+	}
+	if !s.started {
+		s.started = true
+		if err := s.emitter.emitDelta(types.Delta{Role: types.RoleAssistant}); err != nil {
+			return err
+		}
+	}
+	if len(chunk.Candidates) == 0 {
+		return nil
+	}
+
+	candidate := chunk.Candidates[0]
+	for _, part := range candidate.Content.Parts {
+		var err error
+		switch {
+		case part.FunctionCall != nil:
+			arguments, _ := json.Marshal(part.FunctionCall.Args)
+			if part.FunctionCall.Args == nil {
+				arguments = []byte("{}")
+			}
+			id := types.GeminiToolCallID(part.FunctionCall, s.emitter.id, s.toolCalls)
+			err = s.emitter.emitToolCall(s.toolCalls, id, part.FunctionCall.Name, string(arguments))
+			s.toolCalls++
+		case part.Thought:
+			err = s.emitter.emitDelta(types.Delta{ReasoningContent: part.Text})
+		case part.Text != "":
+			err = s.emitter.emitDelta(types.Delta{Content: part.Text})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if candidate.FinishReason != "" {
+		s.finishReason = candidate.FinishReason
+	}
+	return nil
+}
+
+// finish writes the finish reason, usage and [DONE] after the upstream stream ends
+func (s *geminiStream) finish() error {
+	if s.finishReason != "" {
+		if err := s.emitter.emitFinish(types.GeminiFinishReason(s.finishReason, s.toolCalls > 0)); err != nil {
+			return err
+		}
+	}
+	if s.usage != nil {
+		if err := s.emitter.emitUsage(s.usage.ToUsage()); err != nil {
+			return err
+		}
+	}
+	return s.emitter.emitDone()
+}
+
+// geminiErrorMessage extracts the message of a Gemini error body
+func geminiErrorMessage(body []byte) string {
+	var errResp types.GeminiErrorResponse
+	if err := json.Unmarshal(body, &errResp); err != nil {
+		return ""
+	}
+	return errResp.Error.Message
+}
@@ -0,0 +1,122 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+func TestGeminiClient_Stream(t *testing.T) {
+	var request types.GeminiRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1beta/models/native-model:streamGenerateContent" || r.URL.Query().Get("alt") != "sse" {
+			t.Errorf("Unexpected url: %s", r.URL.String())
+		}
+		if got := r.Header.Get("x-goog-api-key"); got != "sk-native" {
+			t.Errorf("Expected x-goog-api-key sk-native, got %s", got)
+		}
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &request); err != nil {
+			t.Errorf("Invalid request body: %v", err)
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		chunks := []string{
+			`{"responseId":"resp-1","candidates":[{"content":{"role":"model","parts":[{"text":"plan","thought":true}]}}]}`,
+			`{"responseId":"resp-1","candidates":[{"content":{"role":"model","parts":[{"text":"Hel"}]}}],"usageMetadata":{"promptTokenCount":9,"candidatesTokenCount":1,"totalTokenCount":10}}`,
+			`{"responseId":"resp-1","candidates":[{"content":{"role":"model","parts":[{"text":"lo"},{"functionCall":{"name":"search","args":{"q":"bar"}}}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":9,"candidatesTokenCount":4,"thoughtsTokenCount":2,"totalTokenCount":15}}`,
+		}
+		for _, chunk := range chunks {
+			io.WriteString(w, "data: "+chunk+"\r\n\r\n")
+		}
+	}))
+	defer server.Close()
+
+	llm := newTestNativeClient(t, config.ProviderGemini, server.URL+"/v1beta")
+	result, err := collectStream(t, llm, testToolParams())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Request translation
+	if request.SystemInstruction == nil || len(request.Contents) != 3 {
+		t.Fatalf("Unexpected request: %+v", request)
+	}
+	response := request.Contents[2].Parts[0].FunctionResponse
+	if response == nil || response.Name != "search" || response.Response["content"] != "found 3" {
+		t.Errorf("Tool result was not translated: %+v", response)
+	}
+	if request.GenerationConfig == nil || request.GenerationConfig.MaxOutputTokens != 512 {
+		t.Errorf("Unexpected generation config: %+v", request.GenerationConfig)
+	}
+	if len(request.Tools) != 1 || request.Tools[0].FunctionDeclarations[0].Name != "search" {
+		t.Errorf("Tools were not translated: %+v", request.Tools)
+	}
+
+	// Response translation
+	if result.content != "Hello" || result.reasoning != "plan" {
+		t.Errorf("Unexpected content %q or reasoning %q", result.content, result.reasoning)
+	}
+	call := result.toolCalls[0]
+	if call == nil || call.ID == "" || call.Function.Name != "search" || call.Function.Arguments != `{"q":"bar"}` {
+		t.Errorf("Unexpected tool call: %+v", call)
+	}
+	if result.finishReason != "tool_calls" {
+		t.Errorf("Expected finish reason tool_calls, got %s", result.finishReason)
+	}
+	if result.usage == nil || result.usage.PromptTokens != 9 || result.usage.CompletionTokens != 6 {
+		t.Errorf("Unexpected usage: %+v", result.usage)
+	}
+	if !result.done {
+		t.Error("Missing [DONE]")
+	}
+}
+
+func TestGeminiClient_Raw(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models/native-model:generateContent" {
+			t.Errorf("Unexpected path: %s", r.URL.Path)
+		}
+		io.WriteString(w, `{
+			"candidates": [{"content": {"role": "model", "parts": [{"text": "summary"}]}, "finishReason": "MAX_TOKENS"}],
+			"usageMetadata": {"promptTokenCount": 5, "candidatesTokenCount": 1, "totalTokenCount": 6}
+		}`)
+	}))
+	defer server.Close()
+
+	llm := newTestNativeClient(t, config.ProviderGemini, server.URL)
+	resp, err := llm.ChatLLMWithMessagesRaw(context.Background(), types.LLMRequestParams{
+		Messages: []types.Message{{Role: types.RoleUser, Content: "text"}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(resp.Choices) != 1 || resp.Choices[0].Message.Content != "summary" || resp.Choices[0].FinishReason != "length" {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+	if resp.Usage.TotalTokens != 6 {
+		t.Errorf("Unexpected usage: %+v", resp.Usage)
+	}
+}
+
+func TestGeminiClient_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `{"error":{"code":400,"message":"API key not valid","status":"INVALID_ARGUMENT"}}`)
+	}))
+	defer server.Close()
+
+	llm := newTestNativeClient(t, config.ProviderGemini, server.URL)
+	_, err := llm.ChatLLMWithMessagesRaw(context.Background(), testToolParams(), nil)
+	var apiErr *types.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 APIError, got %v", err)
+	}
+}
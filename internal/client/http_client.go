@@ -3,6 +3,7 @@ package client
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -14,6 +15,8 @@ import (
 // HTTPClientConfig defines the configuration for HTTP client
 type HTTPClientConfig struct {
 	Timeout time.Duration
+	// TLS settings of the connections, the default ones when nil
+	TLSConfig *tls.Config
 }
 
 // HTTPClient represents a generic HTTP client
@@ -28,10 +31,18 @@ func NewHTTPClient(endpoint string, config HTTPClientConfig) *HTTPClient {
 		config.Timeout = 3 * time.Second
 	}
 
+	transport := http.DefaultTransport
+	if config.TLSConfig != nil {
+		tlsTransport := http.DefaultTransport.(*http.Transport).Clone()
+		tlsTransport.TLSClientConfig = config.TLSConfig
+		transport = tlsTransport
+	}
+
 	return &HTTPClient{
 		endpoint: endpoint,
 		httpClient: &http.Client{
-			Timeout: config.Timeout,
+			Timeout:   config.Timeout,
+			Transport: withCassette(transport),
 		},
 	}
 }
@@ -52,9 +52,11 @@ type LLMClient struct {
 }
 
 // NewLLMClient creates a new LLM client instance
+// The upstream is resolved from the model registry, native providers get their own adapter
 func NewLLMClient(llmConfig config.LLMConfig, timeoutConfig config.LLMTimeoutConfig, modelName string, headers *http.Header) (LLMInterface, error) {
-	// Check for empty endpoint
-	if llmConfig.Endpoint == "" || headers == nil {
+	// Resolve the upstream from the model registry, falling back to the global endpoint
+	target := llmConfig.ResolveUpstream(modelName)
+	if headers == nil || (target.Endpoint == "" && target.Provider == config.ProviderOpenAI) {
 		return nil, fmt.Errorf("NewLLMClient llmEndpoint cannot be empty")
 	}
 
@@ -63,22 +65,77 @@ func NewLLMClient(llmConfig config.LLMConfig, timeoutConfig config.LLMTimeoutCon
 		idleTimeout = 30 * time.Second
 	}
 
-	// Create HTTP client with idle timeout as ResponseHeaderTimeout
-	httpClient := &http.Client{
-		Transport: &http.Transport{
-			ResponseHeaderTimeout: idleTimeout,
-		},
-	}
-
-	return &LLMClient{
+	base := &LLMClient{
 		modelName:              modelName,
-		endpoint:               llmConfig.Endpoint,
-		httpClient:             httpClient,
+		endpoint:               target.Endpoint,
 		headers:                headers,
 		idleTimeout:            idleTimeout,
 		timeoutConfig:          timeoutConfig,
 		StreamChunkInfoEnabled: llmConfig.ChunkMetricsEnabled,
-	}, nil
+	}
+
+	// Models served by their own endpoint go directly to the vendor
+	direct := target.Provider != config.ProviderOpenAI || target.Endpoint != llmConfig.Endpoint
+	if direct && target.ApiToken == "" {
+		return nil, fmt.Errorf("NewLLMClient apiToken of %s model %s cannot be empty", target.Provider, modelName)
+	}
+
+	var llm LLMInterface
+	switch target.Provider {
+	case config.ProviderOpenAI:
+		if direct {
+			vendor := nativeProviderHeaders(headers, "Authorization", bearerToken(target.ApiToken))
+			base.headers = &vendor
+		} else if target.ApiToken != "" {
+			base.headers = withBearerToken(headers, target.ApiToken)
+		}
+		llm = base
+	case config.ProviderAzure:
+		azure, err := newAzureClient(base, target)
+		if err != nil {
+			return nil, err
+		}
+		llm = azure
+	case config.ProviderAnthropic:
+		llm = newAnthropicClient(base, target)
+	case config.ProviderGemini:
+		llm = newGeminiClient(base, target)
+	default:
+		return nil, fmt.Errorf("NewLLMClient provider %s of model %s is not supported", target.Provider, modelName)
+	}
+
+	// Share the pooled transport of the upstream, with idle timeout as ResponseHeaderTimeout
+	base.httpClient = &http.Client{
+		Transport: withCassette(sharedTransport(base.endpoint, idleTimeout, llmConfig.Transport)),
+	}
+	return llm, nil
+}
+
+// withBearerToken returns a copy of headers authorized with the given API token
+func withBearerToken(headers *http.Header, token string) *http.Header {
+	cloned := headers.Clone()
+	cloned.Set("Authorization", bearerToken(token))
+	return &cloned
+}
+
+// bearerToken returns the Authorization value of the API token
+func bearerToken(token string) string {
+	if !strings.HasPrefix(strings.ToLower(token), "bearer ") {
+		return "Bearer " + token
+	}
+	return token
+}
+
+// nativeProviderHeaders returns the headers sent to a native provider or a model with its own endpoint,
+// built from an allowlist so that the credentials and identity headers of the caller never reach the vendor
+func nativeProviderHeaders(headers *http.Header, authHeader, token string) http.Header {
+	native := make(http.Header)
+	native.Set("Content-Type", "application/json")
+	native.Set(authHeader, token)
+	if requestID := headers.Get(types.HeaderRequestId); requestID != "" {
+		native.Set(types.HeaderRequestId, requestID)
+	}
+	return native
 }
 
 func (c *LLMClient) GetModelName() string {
@@ -89,8 +146,38 @@ func (c *LLMClient) SetTools(tools []types.Function) {
 	c.tools = tools
 }
 
+// withTools returns the params with the tools set on the client, unless the request carries its own.
+// The passthrough fields are copied so that the request of the caller is left untouched.
+func (c *LLMClient) withTools(params types.LLMRequestParams) types.LLMRequestParams {
+	if len(c.tools) == 0 {
+		return params
+	}
+	if _, ok := params.Extra["tools"]; ok {
+		return params
+	}
+
+	extra := make(map[string]any, len(params.Extra)+1)
+	for k, v := range params.Extra {
+		extra[k] = v
+	}
+	extra["tools"] = c.tools
+	params.Extra = extra
+	return params
+}
+
 // GenerateContent generate content using a structured message format
 func (c *LLMClient) GenerateContent(ctx context.Context, systemPrompt string, userMessages []types.Message) (string, error) {
+	return c.generateContent(ctx, c.ChatLLMWithMessagesRaw, systemPrompt, userMessages)
+}
+
+// generateContent sends the system and user prompts through the given non-streaming call
+// It is shared by the clients of every provider
+func (c *LLMClient) generateContent(
+	ctx context.Context,
+	chatRaw func(context.Context, types.LLMRequestParams, *timeout.IdleTimer) (types.ChatCompletionResponse, error),
+	systemPrompt string,
+	userMessages []types.Message,
+) (string, error) {
 	// Create a new slice of messages for the summary request
 	var messages []types.Message
 
@@ -114,7 +201,7 @@ func (c *LLMClient) GenerateContent(ctx context.Context, systemPrompt string, us
 		cancel()
 	}()
 
-	result, err := c.ChatLLMWithMessagesRaw(ctx, params, idleTimer)
+	result, err := chatRaw(ctx, params, idleTimer)
 	if err != nil {
 		return "", fmt.Errorf("failed to get response from ChatLLMWithMessagesRaw: %w", err)
 	}
@@ -171,6 +258,7 @@ func (c *LLMClient) ChatLLMWithMessagesStreamRaw(ctx context.Context, params typ
 		return fmt.Errorf("callback function cannot be nil")
 	}
 
+	params = c.withTools(params)
 	if params.Extra == nil {
 		params.Extra = make(map[string]any)
 	}
@@ -188,62 +276,12 @@ func (c *LLMClient) ChatLLMWithMessagesStreamRaw(ctx context.Context, params typ
 		},
 	}
 
-	// Create request
-	jsonData, err := json.Marshal(requestPayload)
+	resp, err := c.send(ctx, c.endpoint, requestPayload, idleTimer)
 	if err != nil {
-		return fmt.Errorf("failed to marshal request payload: %w", err)
-	}
-
-	reader := strings.NewReader(string(jsonData))
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, reader)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Set request headers
-	for key, values := range *c.headers {
-		for _, value := range values {
-			req.Header.Add(key, value)
-		}
-	}
-
-	// Ensure Content-Length is set correctly
-	req.ContentLength = int64(reader.Len())
-
-	// Log before sending request to LLM
-	logger.InfoC(ctx, "Starting request to LLM model ...")
-	requestStart := time.Now()
-
-	// Send request
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		// Check if it's a timeout error
-		if ctx.Err() != nil && idleTimer != nil && idleTimer.IsTimedOut() {
-			if idleTimer.Reason() == timeout.IdleTimeoutReasonTotal {
-				return types.NewTotalIdleTimeoutError()
-			}
-			return types.NewStreamIdleTimeoutError()
-		}
-
-		// Check if it's a context cancellation (client disconnect)
-		if errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled) {
-			logger.WarnC(ctx, "Context canceled connecting to LLM service", zap.Error(err))
-			return context.Canceled
-		}
-
-		logger.ErrorC(ctx, "Failed to connect to LLM service", zap.Error(err))
-		return types.NewModelServiceUnavailableError()
+		return err
 	}
 	defer resp.Body.Close()
 
-	// Reset idle timer after receiving response headers
-	firstByteLatency := time.Since(requestStart)
-	if idleTimer != nil {
-		idleTimer.Reset()
-	}
-	logger.InfoC(ctx, "Received response headers from LLM",
-		zap.Duration("firstByteLatency", firstByteLatency))
-
 	if resp.StatusCode != http.StatusOK {
 		return c.handleAPIError(resp, "LLMClient get straming error response")
 	}
@@ -326,22 +364,7 @@ func (c *LLMClient) ChatLLMWithMessagesStreamRaw(ctx context.Context, params typ
 		streamEnd <- true
 	}
 	if err := scanner.Err(); err != nil {
-		// Check if it's a context timeout
-		if ctx.Err() != nil && idleTimer != nil && idleTimer.IsTimedOut() {
-			if idleTimer.Reason() == timeout.IdleTimeoutReasonTotal {
-				return types.NewTotalIdleTimeoutError()
-			}
-			return types.NewStreamIdleTimeoutError()
-		}
-
-		// Check if it's a context cancellation (client disconnect)
-		if errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled) {
-			logger.WarnC(ctx, "Context canceled reading response", zap.Error(err))
-			return context.Canceled
-		}
-
-		logger.ErrorC(ctx, "Error reading response", zap.Error(err))
-		return types.NewNetWorkError()
+		return c.readError(ctx, err, idleTimer, "Error reading response")
 	}
 	// Wait for chunk time calculation (max 3 seconds)
 	if chunkTimeCaculated != nil {
@@ -361,6 +384,7 @@ func (c *LLMClient) ChatLLMWithMessagesStreamRaw(ctx context.Context, params typ
 // ChatLLMWithMessagesRaw directly calls the API using HTTP client to get raw non-streaming response
 func (c *LLMClient) ChatLLMWithMessagesRaw(ctx context.Context, params types.LLMRequestParams, idleTimer *timeout.IdleTimer) (types.ChatCompletionResponse, error) {
 	// Prepare request data structure
+	params = c.withTools(params)
 	if params.Extra == nil {
 		params.Extra = make(map[string]any)
 	}
@@ -373,17 +397,45 @@ func (c *LLMClient) ChatLLMWithMessagesRaw(ctx context.Context, params types.LLM
 
 	nil_resp := types.ChatCompletionResponse{}
 
-	// Log request data for debugging
-	jsonData, err := json.Marshal(requestPayload)
+	resp, err := c.send(ctx, c.endpoint, requestPayload, idleTimer)
+	if err != nil {
+		return nil_resp, err
+	}
+	defer resp.Body.Close()
+
+	// Check response status code
+	if resp.StatusCode != http.StatusOK {
+		err := c.handleAPIError(resp, "LLMClient get error response")
+		return nil_resp, err
+	}
+
+	bodyData, err := c.readBody(ctx, resp, idleTimer)
 	if err != nil {
-		return nil_resp, fmt.Errorf("failed to marshal request payload: %w", err)
+		return nil_resp, err
 	}
 
+	var result types.ChatCompletionResponse
+	if err := json.Unmarshal(bodyData, &result); err != nil {
+		bodyStr := string(bodyData)
+		return nil_resp, fmt.Errorf("failed to parse response (invalid JSON? body: %s)\nerror: %w", bodyStr, err)
+	}
+
+	return result, nil
+}
+
+// send posts the JSON payload to url with the request headers
+// Transport errors are mapped to idle timeout, cancellation or service unavailable errors
+func (c *LLMClient) send(ctx context.Context, url string, payload any, idleTimer *timeout.IdleTimer) (*http.Response, error) {
 	// Create request
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request payload: %w", err)
+	}
+
 	reader := strings.NewReader(string(jsonData))
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, reader)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, reader)
 	if err != nil {
-		return nil_resp, fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// Set request headers
@@ -396,27 +448,30 @@ func (c *LLMClient) ChatLLMWithMessagesRaw(ctx context.Context, params types.LLM
 	// Ensure Content-Length is set correctly
 	req.ContentLength = int64(reader.Len())
 
+	// Log before sending request to LLM
+	logger.InfoC(ctx, "Starting request to LLM model ...")
 	requestStart := time.Now()
+
 	// Send request
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		// Check if it's a timeout error
 		if ctx.Err() != nil && idleTimer != nil && idleTimer.IsTimedOut() {
 			if idleTimer.Reason() == timeout.IdleTimeoutReasonTotal {
-				return nil_resp, types.NewTotalIdleTimeoutError()
+				return nil, types.NewTotalIdleTimeoutError()
 			}
-			return nil_resp, types.NewStreamIdleTimeoutError()
+			return nil, types.NewStreamIdleTimeoutError()
 		}
 
+		// Check if it's a context cancellation (client disconnect)
 		if errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled) {
 			logger.WarnC(ctx, "Context canceled connecting to LLM service", zap.Error(err))
-			return nil_resp, context.Canceled
+			return nil, context.Canceled
 		}
 
 		logger.ErrorC(ctx, "Failed to connect to LLM service", zap.Error(err))
-		return nil_resp, types.NewModelServiceUnavailableError()
+		return nil, types.NewModelServiceUnavailableError()
 	}
-	defer resp.Body.Close()
 
 	// Reset idle timer after receiving response headers
 	firstByteLatency := time.Since(requestStart)
@@ -426,13 +481,11 @@ func (c *LLMClient) ChatLLMWithMessagesRaw(ctx context.Context, params types.LLM
 	logger.InfoC(ctx, "Received response headers from LLM",
 		zap.Duration("firstByteLatency", firstByteLatency))
 
-	// Check response status code
-	if resp.StatusCode != http.StatusOK {
-		err := c.handleAPIError(resp, "LLMClient get error response")
-		return nil_resp, err
-	}
+	return resp, nil
+}
 
-	// Read response body in chunks, resetting idle timer
+// readBody reads the whole response body in chunks, resetting the idle timer
+func (c *LLMClient) readBody(ctx context.Context, resp *http.Response, idleTimer *timeout.IdleTimer) ([]byte, error) {
 	const chunkSize = 8192
 	buf := make([]byte, chunkSize)
 	var bodyData []byte
@@ -447,30 +500,42 @@ func (c *LLMClient) ChatLLMWithMessagesRaw(ctx context.Context, params types.LLM
 			}
 		}
 		if err == io.EOF {
-			break
+			return bodyData, nil
 		}
 		if err != nil {
 			// Check if it's a timeout error
 			if ctx.Err() != nil && idleTimer != nil && idleTimer.IsTimedOut() {
 				if idleTimer.Reason() == timeout.IdleTimeoutReasonTotal {
-					return nil_resp, types.NewTotalIdleTimeoutError()
+					return nil, types.NewTotalIdleTimeoutError()
 				}
-				return nil_resp, types.NewStreamIdleTimeoutError()
+				return nil, types.NewStreamIdleTimeoutError()
 			}
 
 			if errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled) {
-				return nil_resp, context.Canceled
+				return nil, context.Canceled
 			}
 
-			return nil_resp, fmt.Errorf("failed to read response body: %w", err)
+			return nil, fmt.Errorf("failed to read response body: %w", err)
 		}
 	}
+}
 
-	var result types.ChatCompletionResponse
-	if err := json.Unmarshal(bodyData, &result); err != nil {
-		bodyStr := string(bodyData)
-		return nil_resp, fmt.Errorf("failed to parse response (invalid JSON? body: %s)\nerror: %w", bodyStr, err)
+// readError maps an error of reading the streaming body to idle timeout, cancellation or network errors
+func (c *LLMClient) readError(ctx context.Context, err error, idleTimer *timeout.IdleTimer, logMessage string) error {
+	// Check if it's a context timeout
+	if ctx.Err() != nil && idleTimer != nil && idleTimer.IsTimedOut() {
+		if idleTimer.Reason() == timeout.IdleTimeoutReasonTotal {
+			return types.NewTotalIdleTimeoutError()
+		}
+		return types.NewStreamIdleTimeoutError()
 	}
 
-	return result, nil
+	// Check if it's a context cancellation (client disconnect)
+	if errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled) {
+		logger.WarnC(ctx, "Context canceled reading response", zap.Error(err))
+		return context.Canceled
+	}
+
+	logger.ErrorC(ctx, logMessage, zap.Error(err))
+	return types.NewNetWorkError()
 }
@@ -0,0 +1,167 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zgsm-ai/chat-rag/internal/logger"
+	"github.com/zgsm-ai/chat-rag/internal/timeout"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+	"go.uber.org/zap"
+)
+
+// openAIStreamChunk is a chat completion chunk in the OpenAI streaming format
+type openAIStreamChunk struct {
+	ID      string               `json:"id"`
+	Object  string               `json:"object"`
+	Created int64                `json:"created"`
+	Model   string               `json:"model"`
+	Choices []openAIStreamChoice `json:"choices"`
+	Usage   *types.Usage         `json:"usage,omitempty"`
+}
+
+type openAIStreamChoice struct {
+	Index        int         `json:"index"`
+	Delta        types.Delta `json:"delta"`
+	FinishReason *string     `json:"finish_reason"`
+}
+
+// chunkEmitter writes native stream events as OpenAI style SSE lines to the stream callback,
+// so that native providers look the same as an OpenAI compatible upstream to ChatCompletionLogic
+type chunkEmitter struct {
+	id       string
+	model    string
+	created  int64
+	response LLMResponse
+	callback func(LLMResponse) error
+	done     bool
+}
+
+func newChunkEmitter(model string, header http.Header, callback func(LLMResponse) error) *chunkEmitter {
+	return &chunkEmitter{
+		model:    model,
+		created:  time.Now().Unix(),
+		response: LLMResponse{Header: &header},
+		callback: callback,
+	}
+}
+
+// emitDelta writes a chunk carrying the delta of the first choice
+func (e *chunkEmitter) emitDelta(delta types.Delta) error {
+	return e.write(openAIStreamChunk{
+		Choices: []openAIStreamChoice{{Delta: delta}},
+	})
+}
+
+// emitToolCall writes a tool call delta, id and name are only sent with the first delta of a call
+func (e *chunkEmitter) emitToolCall(index int, id, name, arguments string) error {
+	call := map[string]any{
+		"index":    index,
+		"function": map[string]any{"arguments": arguments},
+	}
+	if id != "" {
+		call["id"] = id
+		call["type"] = "function"
+		call["function"].(map[string]any)["name"] = name
+	}
+	return e.emitDelta(types.Delta{ToolCalls: []any{call}})
+}
+
+// emitFinish writes the finish reason of the first choice
+func (e *chunkEmitter) emitFinish(reason string) error {
+	return e.write(openAIStreamChunk{
+		Choices: []openAIStreamChoice{{FinishReason: &reason}},
+	})
+}
+
+// emitUsage writes the usage chunk, which has no choices like stream_options.include_usage
+func (e *chunkEmitter) emitUsage(usage types.Usage) error {
+	return e.write(openAIStreamChunk{
+		Choices: []openAIStreamChoice{},
+		Usage:   &usage,
+	})
+}
+
+// emitDone writes the terminating [DONE] line once
+func (e *chunkEmitter) emitDone() error {
+	if e.done {
+		return nil
+	}
+	e.done = true
+	return e.writeLine("data: [DONE]")
+}
+
+func (e *chunkEmitter) write(chunk openAIStreamChunk) error {
+	chunk.ID = e.id
+	chunk.Object = "chat.completion.chunk"
+	chunk.Created = e.created
+	chunk.Model = e.model
+
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		return fmt.Errorf("failed to marshal stream chunk: %w", err)
+	}
+	return e.writeLine("data: " + string(data))
+}
+
+func (e *chunkEmitter) writeLine(line string) error {
+	e.response.ResonseLine = line
+	if err := e.callback(e.response); err != nil {
+		return fmt.Errorf("callback error: %w", err)
+	}
+	return nil
+}
+
+// scanSSEData reads a native SSE stream and passes the payload of every data line to handle
+func (c *LLMClient) scanSSEData(ctx context.Context, body io.Reader, idleTimer *timeout.IdleTimer, handle func(data string) error) error {
+	scanner := bufio.NewScanner(body)
+	// Increase buffer size to handle long response lines
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		// Reset idle timer on each line received
+		if idleTimer != nil {
+			idleTimer.Reset()
+		}
+
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "" {
+			continue
+		}
+		if err := handle(data); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return c.readError(ctx, err, idleTimer, "Error reading response")
+	}
+	return nil
+}
+
+// nativeAPIError converts an error response of a native provider into an APIError,
+// message extracts the error message from the provider specific body
+func (c *LLMClient) nativeAPIError(resp *http.Response, logMessage string, message func(body []byte) string) error {
+	body, _ := io.ReadAll(resp.Body)
+	logger.Warn(logMessage,
+		zap.Int("status code", resp.StatusCode),
+		zap.String("body", string(body)),
+	)
+
+	msg := message(body)
+	if msg == "" {
+		msg = string(body)
+	}
+	if msg == "" {
+		msg = "None"
+	}
+	return types.NewHTTPStatusError(resp.StatusCode, msg)
+}
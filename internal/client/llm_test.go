@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/zgsm-ai/chat-rag/internal/config"
 	"github.com/zgsm-ai/chat-rag/internal/types"
 )
 
@@ -154,3 +155,87 @@ func TestLLMClient_Integration(t *testing.T) {
 	// If you want to run this test, remove t.Skip() and add the following import:
 	// import "context"
 }
+
+func TestNewLLMClient_ModelRegistry(t *testing.T) {
+	llmConfig := config.LLMConfig{
+		Endpoint: "http://global/v1/chat/completions",
+		Models: []config.ModelConfig{
+			{Name: "registered", Endpoint: "http://registered/v1/chat/completions", ApiToken: "sk-test"},
+			{Name: "token-only", ApiToken: "Bearer sk-other"},
+			{Name: "native", Provider: "unknown"},
+			{Name: "tokenless", Endpoint: "http://tokenless/v1/chat/completions"},
+		},
+	}
+	headers := make(http.Header)
+	headers.Set("Authorization", "Bearer user-token")
+	headers.Set("X-Request-Id", "req-1")
+	headers.Set("X-Costrict-Version", "1.0")
+	headers.Set("Cookie", "session=secret")
+
+	testCases := []struct {
+		name          string
+		model         string
+		expectError   bool
+		expectedURL   string
+		expectedToken string
+	}{
+		{"registered model", "registered", false, "http://registered/v1/chat/completions", "Bearer sk-test"},
+		{"registry token only", "token-only", false, "http://global/v1/chat/completions", "Bearer sk-other"},
+		{"unregistered model", "other", false, "http://global/v1/chat/completions", "Bearer user-token"},
+		{"unsupported provider", "native", true, "", ""},
+		{"own endpoint without token", "tokenless", true, "", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			llm, err := NewLLMClient(llmConfig, config.LLMTimeoutConfig{}, tc.model, &headers)
+			if tc.expectError {
+				if err == nil {
+					t.Fatal("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			client := llm.(*LLMClient)
+			if client.endpoint != tc.expectedURL {
+				t.Errorf("Expected endpoint %s, got %s", tc.expectedURL, client.endpoint)
+			}
+			if got := client.headers.Get("Authorization"); got != tc.expectedToken {
+				t.Errorf("Expected authorization %s, got %s", tc.expectedToken, got)
+			}
+		})
+	}
+
+	// A model with its own endpoint only receives allowlisted headers
+	llm, err := NewLLMClient(llmConfig, config.LLMTimeoutConfig{}, "registered", &headers)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	direct := llm.(*LLMClient).headers
+	if got := direct.Get("X-Request-Id"); got != "req-1" {
+		t.Errorf("Expected request ID req-1, got %s", got)
+	}
+	for _, name := range []string{"X-Costrict-Version", "Cookie"} {
+		if got := direct.Get(name); got != "" {
+			t.Errorf("Header %s of the caller was forwarded: %s", name, got)
+		}
+	}
+	// Models of the global endpoint keep the headers of the caller
+	llm, _ = NewLLMClient(llmConfig, config.LLMTimeoutConfig{}, "token-only", &headers)
+	if got := llm.(*LLMClient).headers.Get("X-Costrict-Version"); got != "1.0" {
+		t.Errorf("Expected caller header on global endpoint, got %s", got)
+	}
+
+	// The caller's headers must not be modified by registry tokens
+	if got := headers.Get("Authorization"); got != "Bearer user-token" {
+		t.Errorf("Caller headers were modified: %s", got)
+	}
+
+	// Without registry entry and global endpoint the client cannot be created
+	if _, err := NewLLMClient(config.LLMConfig{}, config.LLMTimeoutConfig{}, "other", &headers); err == nil {
+		t.Error("Expected error for empty endpoint")
+	}
+}
@@ -0,0 +1,686 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/logger"
+)
+
+const (
+	mcpProtocolVersion = "2025-03-26"
+	mcpCallTimeout     = 30 * time.Second
+	// Largest message read from a stdio server
+	mcpMaxMessageSize = 16 * 1024 * 1024
+)
+
+var errMCPClosed = errors.New("mcp server connection closed")
+
+// MCPTool is a tool listed by an MCP server
+type MCPTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema MCPInputSchema `json:"inputSchema"`
+}
+
+// MCPInputSchema is the JSON schema of the tool arguments
+type MCPInputSchema struct {
+	Type       string                 `json:"type"`
+	Properties map[string]MCPProperty `json:"properties"`
+	Required   []string               `json:"required"`
+}
+
+// MCPProperty is the JSON schema of a tool argument, its nested properties and array items
+type MCPProperty struct {
+	// A type name or a list of type names
+	Type        any                    `json:"type"`
+	Description string                 `json:"description"`
+	Default     any                    `json:"default,omitempty"`
+	Enum        []any                  `json:"enum,omitempty"`
+	Format      string                 `json:"format,omitempty"`
+	Minimum     *float64               `json:"minimum,omitempty"`
+	Maximum     *float64               `json:"maximum,omitempty"`
+	MinLength   *int                   `json:"minLength,omitempty"`
+	MaxLength   *int                   `json:"maxLength,omitempty"`
+	Items       *MCPProperty           `json:"items,omitempty"`
+	MinItems    *int                   `json:"minItems,omitempty"`
+	MaxItems    *int                   `json:"maxItems,omitempty"`
+	Properties  map[string]MCPProperty `json:"properties,omitempty"`
+	Required    []string               `json:"required,omitempty"`
+}
+
+// SchemaType returns the JSON schema type of the argument, the first one that is not null
+func (p MCPProperty) SchemaType() string {
+	switch t := p.Type.(type) {
+	case string:
+		return t
+	case []any:
+		for _, item := range t {
+			if name, ok := item.(string); ok && name != "null" {
+				return name
+			}
+		}
+	}
+	return "string"
+}
+
+// MCPClient is a client of an MCP server, connected on first use and reconnected when the
+// connection was lost
+type MCPClient struct {
+	name string
+	cfg  config.MCPServerConfig
+
+	mu     sync.Mutex
+	conn   mcpTransport
+	closed bool
+}
+
+// NewMCPClient creates a client of the MCP server
+func NewMCPClient(name string, cfg config.MCPServerConfig) *MCPClient {
+	return &MCPClient{name: name, cfg: cfg}
+}
+
+// ListTools returns the tools of the server, following pagination
+func (c *MCPClient) ListTools(ctx context.Context) ([]MCPTool, error) {
+	var tools []MCPTool
+	cursor := ""
+	for {
+		params := map[string]any{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var page struct {
+			Tools      []MCPTool `json:"tools"`
+			NextCursor string    `json:"nextCursor"`
+		}
+		if err := c.call(ctx, "tools/list", params, &page); err != nil {
+			return nil, err
+		}
+		tools = append(tools, page.Tools...)
+		if page.NextCursor == "" || page.NextCursor == cursor {
+			return tools, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// CallTool calls the tool and returns the text of its result
+func (c *MCPClient) CallTool(ctx context.Context, name string, args map[string]any) (string, error) {
+	var result struct {
+		Content []struct {
+			Type     string `json:"type"`
+			Text     string `json:"text"`
+			MimeType string `json:"mimeType"`
+			Resource *struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"resource"`
+		} `json:"content"`
+		StructuredContent any  `json:"structuredContent"`
+		IsError           bool `json:"isError"`
+	}
+	if err := c.call(ctx, "tools/call", map[string]any{"name": name, "arguments": args}, &result); err != nil {
+		return "", err
+	}
+
+	var text strings.Builder
+	for _, content := range result.Content {
+		if text.Len() > 0 {
+			text.WriteString("\n")
+		}
+		switch {
+		case content.Type == "text":
+			text.WriteString(content.Text)
+		case content.Resource != nil && content.Resource.Text != "":
+			text.WriteString(content.Resource.Text)
+		default:
+			fmt.Fprintf(&text, "[%s %s content omitted]", content.Type, content.MimeType)
+		}
+	}
+	if text.Len() == 0 && result.StructuredContent != nil {
+		data, err := json.Marshal(result.StructuredContent)
+		if err == nil {
+			text.Write(data)
+		}
+	}
+	if result.IsError {
+		return "", fmt.Errorf("mcp tool %s failed: %s", name, text.String())
+	}
+	return text.String(), nil
+}
+
+// Ping checks that the server answers
+func (c *MCPClient) Ping(ctx context.Context) error {
+	return c.call(ctx, "ping", map[string]any{}, nil)
+}
+
+// Close closes the connection to the server for good, stdio servers are stopped
+func (c *MCPClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.close()
+	c.conn = nil
+	return err
+}
+
+// call sends the request on the connection, connecting first when needed
+func (c *MCPClient) call(ctx context.Context, method string, params any, result any) error {
+	conn, err := c.connection(ctx)
+	if err != nil {
+		return fmt.Errorf("connect to mcp server %s: %w", c.name, err)
+	}
+
+	raw, err := conn.call(ctx, method, params)
+	if err != nil {
+		if !conn.alive() {
+			c.drop(conn)
+		}
+		return fmt.Errorf("mcp %s on %s: %w", method, c.name, err)
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(raw, result); err != nil {
+		return fmt.Errorf("decode mcp %s result: %w", method, err)
+	}
+	return nil
+}
+
+// connection returns the initialized connection to the server
+func (c *MCPClient) connection(ctx context.Context) (mcpTransport, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil, errMCPClosed
+	}
+	if c.conn != nil && c.conn.alive() {
+		return c.conn, nil
+	}
+	if c.conn != nil {
+		c.conn.close()
+		c.conn = nil
+	}
+
+	var conn mcpTransport
+	var err error
+	switch c.cfg.Transport {
+	case config.MCPTransportStdio:
+		conn, err = startStdioTransport(c.name, c.cfg)
+	case config.MCPTransportHTTP:
+		conn = newHTTPMCPTransport(c.cfg)
+	default:
+		err = fmt.Errorf("unknown mcp transport %q", c.cfg.Transport)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	initParams := map[string]any{
+		"protocolVersion": mcpProtocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]any{"name": "chat-rag", "version": "1.0.0"},
+	}
+	if _, err := conn.call(ctx, "initialize", initParams); err != nil {
+		conn.close()
+		return nil, fmt.Errorf("initialize: %w", err)
+	}
+	if err := conn.notify(ctx, "notifications/initialized", nil); err != nil {
+		conn.close()
+		return nil, fmt.Errorf("initialized notification: %w", err)
+	}
+
+	logger.Info("mcp server connected", zap.String("server", c.name), zap.String("transport", c.cfg.Transport))
+	c.conn = conn
+	return conn, nil
+}
+
+// drop forgets the lost connection, the next call reconnects
+func (c *MCPClient) drop(conn mcpTransport) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == conn {
+		c.conn.close()
+		c.conn = nil
+	}
+}
+
+// mcpTransport carries JSON-RPC messages to an MCP server
+type mcpTransport interface {
+	// call sends a request and returns its result
+	call(ctx context.Context, method string, params any) (json.RawMessage, error)
+	// notify sends a notification
+	notify(ctx context.Context, method string, params any) error
+	alive() bool
+	close() error
+}
+
+type rpcRequest struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      *int64 `json:"id,omitempty"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// rpcMessage is a message received from the server: a response, or a request or notification of the server
+type rpcMessage struct {
+	ID     *int64          `json:"id"`
+	Method string          `json:"method"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+// stdioTransport exchanges newline delimited JSON-RPC messages with a server process
+type stdioTransport struct {
+	name   string
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	nextID atomic.Int64
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	pending map[int64]chan rpcMessage
+	done    chan struct{}
+	err     error
+}
+
+// stdioBaseEnv is the service environment always passed to server processes
+var stdioBaseEnv = []string{"PATH", "HOME"}
+
+// stdioEnv returns the environment of the server process: PATH, HOME and the inherited variables of the
+// service environment, then the configured variables. Other service variables, such as credentials, are
+// not passed to the server.
+func stdioEnv(cfg config.MCPServerConfig) []string {
+	env := make([]string, 0, len(stdioBaseEnv)+len(cfg.InheritEnv)+len(cfg.Env))
+	for _, name := range append(append([]string(nil), stdioBaseEnv...), cfg.InheritEnv...) {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return append(env, cfg.Env...)
+}
+
+// startStdioTransport starts the server process
+func startStdioTransport(name string, cfg config.MCPServerConfig) (*stdioTransport, error) {
+	if cfg.Command == "" {
+		return nil, fmt.Errorf("mcp stdio server %s has no command", name)
+	}
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	cmd.Env = stdioEnv(cfg)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start %s: %w", cfg.Command, err)
+	}
+
+	t := &stdioTransport{
+		name:    name,
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: make(map[int64]chan rpcMessage),
+		done:    make(chan struct{}),
+	}
+	go t.readLoop(stdout)
+	go func() {
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			logger.Debug("mcp server stderr", zap.String("server", name), zap.String("line", scanner.Text()))
+		}
+	}()
+	return t, nil
+}
+
+// readLoop dispatches the responses of the server to the pending calls
+func (t *stdioTransport) readLoop(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), mcpMaxMessageSize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var msg rpcMessage
+		if err := json.Unmarshal(line, &msg); err != nil {
+			logger.Warn("invalid message from mcp server", zap.String("server", t.name), zap.Error(err))
+			continue
+		}
+		if msg.Method != "" {
+			t.answerServerRequest(msg)
+			continue
+		}
+		if msg.ID == nil {
+			continue
+		}
+		t.mu.Lock()
+		ch, ok := t.pending[*msg.ID]
+		delete(t.pending, *msg.ID)
+		t.mu.Unlock()
+		if ok {
+			ch <- msg
+		}
+	}
+
+	err := scanner.Err()
+	if err == nil {
+		err = errMCPClosed
+	}
+	t.mu.Lock()
+	t.err = err
+	t.mu.Unlock()
+	close(t.done)
+}
+
+// answerServerRequest answers the requests of the server, none of its client features are supported
+func (t *stdioTransport) answerServerRequest(msg rpcMessage) {
+	if msg.ID == nil {
+		return
+	}
+	response := map[string]any{"jsonrpc": "2.0", "id": *msg.ID}
+	if msg.Method == "ping" {
+		response["result"] = map[string]any{}
+	} else {
+		response["error"] = rpcError{Code: -32601, Message: "method not found"}
+	}
+	t.write(response)
+}
+
+func (t *stdioTransport) write(msg any) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	_, err = t.stdin.Write(append(data, '\n'))
+	return err
+}
+
+func (t *stdioTransport) call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	id := t.nextID.Add(1)
+	ch := make(chan rpcMessage, 1)
+	t.mu.Lock()
+	t.pending[id] = ch
+	t.mu.Unlock()
+	defer func() {
+		t.mu.Lock()
+		delete(t.pending, id)
+		t.mu.Unlock()
+	}()
+
+	if err := t.write(rpcRequest{JSONRPC: "2.0", ID: &id, Method: method, Params: params}); err != nil {
+		return nil, err
+	}
+	select {
+	case msg := <-ch:
+		if msg.Error != nil {
+			return nil, msg.Error
+		}
+		return msg.Result, nil
+	case <-t.done:
+		return nil, t.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (t *stdioTransport) notify(ctx context.Context, method string, params any) error {
+	return t.write(rpcRequest{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (t *stdioTransport) alive() bool {
+	select {
+	case <-t.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// close stops the server process, killing it when it does not exit on its own
+func (t *stdioTransport) close() error {
+	t.stdin.Close()
+	select {
+	case <-t.done:
+	case <-time.After(2 * time.Second):
+		t.cmd.Process.Kill()
+	}
+	return t.cmd.Wait()
+}
+
+// httpMCPTransport is the streamable HTTP transport, responses come as JSON or as an SSE stream
+type httpMCPTransport struct {
+	url        string
+	headers    map[string]string
+	httpClient *http.Client
+	nextID     atomic.Int64
+
+	mu        sync.Mutex
+	sessionID string
+	expired   bool
+}
+
+func newHTTPMCPTransport(cfg config.MCPServerConfig) *httpMCPTransport {
+	return &httpMCPTransport{
+		url:        cfg.URL,
+		headers:    cfg.Headers,
+		httpClient: &http.Client{Transport: withCassette(http.DefaultTransport)},
+	}
+}
+
+func (t *httpMCPTransport) call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	id := t.nextID.Add(1)
+	resp, err := t.post(ctx, rpcRequest{JSONRPC: "2.0", ID: &id, Method: method, Params: params})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	msg, err := readHTTPResponse(resp, id)
+	if err != nil {
+		return nil, err
+	}
+	if msg.Error != nil {
+		return nil, msg.Error
+	}
+	return msg.Result, nil
+}
+
+func (t *httpMCPTransport) notify(ctx context.Context, method string, params any) error {
+	resp, err := t.post(ctx, rpcRequest{JSONRPC: "2.0", Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// post sends the message with the session of the connection
+func (t *httpMCPTransport) post(ctx context.Context, msg rpcRequest) (*http.Response, error) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+	t.mu.Lock()
+	if t.sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", t.sessionID)
+	}
+	t.mu.Unlock()
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if sessionID := resp.Header.Get("Mcp-Session-Id"); sessionID != "" {
+		t.mu.Lock()
+		t.sessionID = sessionID
+		t.mu.Unlock()
+	}
+	if resp.StatusCode == http.StatusNotFound && req.Header.Get("Mcp-Session-Id") != "" {
+		// The session expired, a new one is initialized on the next call
+		t.mu.Lock()
+		t.expired = true
+		t.mu.Unlock()
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("status: %d, response: %s", resp.StatusCode, body)
+	}
+	return resp, nil
+}
+
+// readHTTPResponse reads the response of the request from a JSON body or an SSE stream
+func readHTTPResponse(resp *http.Response, id int64) (rpcMessage, error) {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/event-stream" {
+		var msg rpcMessage
+		if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+			return msg, fmt.Errorf("decode response: %w", err)
+		}
+		return msg, nil
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), mcpMaxMessageSize)
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		if value, ok := strings.CutPrefix(line, "data:"); ok {
+			data.WriteString(strings.TrimPrefix(value, " "))
+			continue
+		}
+		if line != "" || data.Len() == 0 {
+			continue
+		}
+
+		// End of an event
+		var msg rpcMessage
+		err := json.Unmarshal([]byte(data.String()), &msg)
+		data.Reset()
+		if err == nil && msg.Method == "" && msg.ID != nil && *msg.ID == id {
+			return msg, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return rpcMessage{}, err
+	}
+	return rpcMessage{}, fmt.Errorf("event stream ended without the response")
+}
+
+func (t *httpMCPTransport) alive() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return !t.expired
+}
+
+// close ends the session
+func (t *httpMCPTransport) close() error {
+	t.mu.Lock()
+	sessionID := t.sessionID
+	t.mu.Unlock()
+	if sessionID == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, t.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Mcp-Session-Id", sessionID)
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// mcpToolClient runs one tool of an MCP server
+type mcpToolClient struct {
+	server  *MCPClient
+	tool    string
+	params  []string
+	timeout time.Duration
+}
+
+// Execute calls the tool with the parameters of its input schema
+func (c *mcpToolClient) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	args := make(map[string]any, len(c.params))
+	for _, name := range c.params {
+		if value, ok := params[name]; ok {
+			args[name] = value
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return c.server.CallTool(ctx, c.tool, args)
+}
+
+// CheckReady pings the server
+func (c *mcpToolClient) CheckReady(ctx context.Context, params map[string]interface{}) (bool, error) {
+	if err := c.server.Ping(ctx); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// mcpServerKey identifies the server of the configuration, tools of the same server share its connection
+func mcpServerKey(cfg config.MCPServerConfig) string {
+	if cfg.Transport == config.MCPTransportHTTP {
+		return cfg.Transport + " " + cfg.URL
+	}
+	return cfg.Transport + " " + cfg.Command + " " + strings.Join(cfg.Args, " ") + " " + strings.Join(cfg.Env, " ")
+}
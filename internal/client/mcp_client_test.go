@@ -0,0 +1,221 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zgsm-ai/chat-rag/internal/config"
+)
+
+// fakeMCPRequest is a request received by the fake MCP server
+type fakeMCPRequest struct {
+	ID     *int64         `json:"id"`
+	Method string         `json:"method"`
+	Params map[string]any `json:"params"`
+}
+
+// fakeMCPResult answers the request like an MCP server with an echo and a failing tool,
+// tools are listed in two pages
+func fakeMCPResult(req fakeMCPRequest) any {
+	switch req.Method {
+	case "initialize":
+		return map[string]any{
+			"protocolVersion": mcpProtocolVersion,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]any{"name": "fake", "version": "1.0.0"},
+		}
+	case "tools/list":
+		if req.Params["cursor"] == "page2" {
+			return map[string]any{"tools": []any{map[string]any{"name": "fail", "description": "Always fails"}}}
+		}
+		return map[string]any{
+			"tools": []any{map[string]any{
+				"name":        "echo",
+				"description": "Echo the text",
+				"inputSchema": map[string]any{
+					"type":       "object",
+					"properties": map[string]any{"text": map[string]any{"type": "string", "description": "Text to echo"}},
+					"required":   []any{"text"},
+				},
+			}},
+			"nextCursor": "page2",
+		}
+	case "tools/call":
+		args, _ := req.Params["arguments"].(map[string]any)
+		if req.Params["name"] == "fail" {
+			return map[string]any{"content": []any{map[string]any{"type": "text", "text": "boom"}}, "isError": true}
+		}
+		return map[string]any{"content": []any{map[string]any{"type": "text", "text": fmt.Sprint("echo: ", args["text"])}}}
+	default:
+		return map[string]any{}
+	}
+}
+
+func fakeMCPResponse(req fakeMCPRequest) []byte {
+	data, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": *req.ID, "result": fakeMCPResult(req)})
+	return data
+}
+
+// TestMCPHelperProcess is the fake stdio MCP server started by the stdio tests
+func TestMCPHelperProcess(t *testing.T) {
+	if os.Getenv("MCP_HELPER_PROCESS") != "1" {
+		return
+	}
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var req fakeMCPRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil || req.ID == nil {
+			continue
+		}
+		os.Stdout.Write(append(fakeMCPResponse(req), '\n'))
+	}
+	os.Exit(0)
+}
+
+func testMCPClient(t *testing.T, client *MCPClient) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tools, err := client.ListTools(ctx)
+	if err != nil {
+		t.Fatalf("list tools: %v", err)
+	}
+	if len(tools) != 2 || tools[0].Name != "echo" || tools[1].Name != "fail" {
+		t.Fatalf("unexpected tools: %+v", tools)
+	}
+	if tools[0].InputSchema.Properties["text"].SchemaType() != "string" || tools[0].InputSchema.Required[0] != "text" {
+		t.Errorf("unexpected input schema: %+v", tools[0].InputSchema)
+	}
+
+	result, err := client.CallTool(ctx, "echo", map[string]any{"text": "hi"})
+	if err != nil || result != "echo: hi" {
+		t.Errorf("expected echo result, got %q, %v", result, err)
+	}
+	if _, err := client.CallTool(ctx, "fail", nil); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("expected tool error, got %v", err)
+	}
+	if err := client.Ping(ctx); err != nil {
+		t.Errorf("ping: %v", err)
+	}
+}
+
+func TestMCPClient_Stdio(t *testing.T) {
+	client := NewMCPClient("fake", config.MCPServerConfig{
+		Transport: config.MCPTransportStdio,
+		Command:   os.Args[0],
+		Args:      []string{"-test.run=TestMCPHelperProcess"},
+		Env:       []string{"MCP_HELPER_PROCESS=1"},
+	})
+	defer client.Close()
+
+	testMCPClient(t, client)
+}
+
+func TestStdioEnv(t *testing.T) {
+	t.Setenv("PATH", "/usr/bin")
+	t.Setenv("MCP_TEST_SECRET", "s3cret")
+	t.Setenv("MCP_TEST_LANG", "C")
+
+	env := stdioEnv(config.MCPServerConfig{Env: []string{"LOG_LEVEL=warn"}, InheritEnv: []string{"MCP_TEST_LANG", "MCP_TEST_UNSET"}})
+	got := strings.Join(env, " ")
+	for _, want := range []string{"PATH=/usr/bin", "MCP_TEST_LANG=C", "LOG_LEVEL=warn"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %s in %q", want, got)
+		}
+	}
+	if strings.Contains(got, "MCP_TEST_SECRET") || strings.Contains(got, "MCP_TEST_UNSET") {
+		t.Errorf("unexpected variables in %q", got)
+	}
+}
+
+func TestMCPClient_HTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req fakeMCPRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Method == "initialize" {
+			w.Header().Set("Mcp-Session-Id", "session-1")
+		} else if r.Header.Get("Mcp-Session-Id") != "session-1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if req.ID == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		// Tool calls are answered in an event stream, after a notification
+		if req.Method == "tools/call" {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n")
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", fakeMCPResponse(req))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(fakeMCPResponse(req))
+	}))
+	defer server.Close()
+
+	client := NewMCPClient("fake", config.MCPServerConfig{
+		Transport: config.MCPTransportHTTP,
+		URL:       server.URL,
+		Headers:   map[string]string{"Authorization": "Bearer token"},
+	})
+	defer client.Close()
+
+	testMCPClient(t, client)
+}
+
+func TestGenericClientFactory_MCPTool(t *testing.T) {
+	factory := NewGenericClientFactory(nil)
+	defer factory.Close()
+
+	mcpConfig := &config.MCPServerConfig{
+		Transport: config.MCPTransportStdio,
+		Command:   os.Args[0],
+		Args:      []string{"-test.run=TestMCPHelperProcess"},
+		Env:       []string{"MCP_HELPER_PROCESS=1"},
+		Prefix:    "fake_",
+	}
+	toolClient, err := factory.CreateClient(config.GenericToolConfig{
+		Name:       "fake_echo",
+		Type:       config.ToolTypeMCP,
+		MCP:        mcpConfig,
+		Parameters: []config.GenericToolParameter{{Name: "text", Type: "string"}},
+	})
+	if err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+
+	ctx := context.Background()
+	if ready, err := toolClient.CheckReady(ctx, nil); !ready || err != nil {
+		t.Errorf("expected ready, got %v, %v", ready, err)
+	}
+	// Common parameters are not arguments of the tool
+	result, err := toolClient.Execute(ctx, map[string]interface{}{"text": "hello", CommonParamClientID: "client"})
+	if err != nil || result != "echo: hello" {
+		t.Errorf("expected echo result, got %q, %v", result, err)
+	}
+
+	// Tools of the same server share its connection
+	if factory.MCPServer(config.GenericToolConfig{Name: "other", MCP: mcpConfig}) != toolClient.(*mcpToolClient).server {
+		t.Error("expected the server client to be shared")
+	}
+
+	factory.Close()
+	if _, err := toolClient.Execute(ctx, map[string]interface{}{"text": "hello"}); err == nil {
+		t.Error("expected an error after close")
+	}
+}
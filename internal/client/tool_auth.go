@@ -0,0 +1,362 @@
+package client
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/model"
+)
+
+const (
+	defaultAPIKeyHeader = "X-API-Key"
+
+	// OAuth2 tokens are renewed this long before they expire
+	oauth2ExpirySkew = 30 * time.Second
+	// Lifetime of OAuth2 tokens whose response has no expires_in
+	defaultOAuth2TokenTTL = 5 * time.Minute
+	oauth2TokenTimeout    = 10 * time.Second
+)
+
+// toolAuthenticator applies the credentials of a tool to its requests
+type toolAuthenticator interface {
+	authenticate(ctx context.Context, req *Request, params map[string]interface{}) error
+}
+
+// tokenInvalidator is implemented by authenticators whose cached token may be refused before it expires
+type tokenInvalidator interface {
+	invalidate()
+}
+
+// toolAuth authenticates the requests of a tool and adds the allowed identity headers
+type toolAuth struct {
+	authenticator toolAuthenticator
+	// Header name to identity field, in lower case
+	identityHeaders map[string]string
+}
+
+// newToolAuth Create the authentication of the tool configuration
+func newToolAuth(authConfig config.ToolAuthConfig) (*toolAuth, error) {
+	authenticator, err := newToolAuthenticator(authConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	identityHeaders := make(map[string]string, len(authConfig.IdentityHeaders))
+	for header, field := range authConfig.IdentityHeaders {
+		field = strings.ToLower(field)
+		if _, allowed := identityFields[field]; !allowed {
+			return nil, fmt.Errorf("identity field %s of header %s is not allowed", field, header)
+		}
+		identityHeaders[header] = field
+	}
+
+	return &toolAuth{authenticator: authenticator, identityHeaders: identityHeaders}, nil
+}
+
+// newToolAuthenticator Create the authenticator of the configured strategy
+func newToolAuthenticator(authConfig config.ToolAuthConfig) (toolAuthenticator, error) {
+	switch authConfig.Type {
+	case "", config.ToolAuthPassthrough:
+		return passthroughAuth{}, nil
+	case config.ToolAuthNone:
+		return noAuth{}, nil
+	case config.ToolAuthMTLS:
+		if authConfig.TLS == nil || authConfig.TLS.CertFile == "" || authConfig.TLS.KeyFile == "" {
+			return nil, fmt.Errorf("mtls auth requires tls.certFile and tls.keyFile")
+		}
+		return noAuth{}, nil
+	case config.ToolAuthAPIKey:
+		key := cmp.Or(authConfig.Key, envValue(authConfig.KeyEnv))
+		if key == "" {
+			return nil, fmt.Errorf("apiKey auth requires key or a non-empty keyEnv")
+		}
+		return apiKeyAuth{header: cmp.Or(authConfig.Header, defaultAPIKeyHeader), key: key}, nil
+	case config.ToolAuthBearer:
+		if authConfig.TokenFile != "" {
+			return bearerAuth{tokenFile: authConfig.TokenFile}, nil
+		}
+		token := envValue(authConfig.TokenEnv)
+		if token == "" {
+			return nil, fmt.Errorf("bearer auth requires tokenFile or a non-empty tokenEnv")
+		}
+		return bearerAuth{token: token}, nil
+	case config.ToolAuthOAuth2:
+		return newOAuth2Auth(authConfig.OAuth2)
+	default:
+		return nil, fmt.Errorf("unsupported auth type: %s", authConfig.Type)
+	}
+}
+
+// apply Authenticate the request and add the identity headers of the end user
+func (a *toolAuth) apply(ctx context.Context, req *Request, params map[string]interface{}) error {
+	if err := a.authenticator.authenticate(ctx, req, params); err != nil {
+		return fmt.Errorf("failed to authenticate request: %w", err)
+	}
+	if len(a.identityHeaders) == 0 {
+		return nil
+	}
+
+	identity, exists := model.GetIdentityFromContext(ctx)
+	if !exists {
+		return nil
+	}
+	if req.Headers == nil {
+		req.Headers = make(map[string]string)
+	}
+	for header, field := range a.identityHeaders {
+		if value := identityFields[field](identity); value != "" {
+			req.Headers[header] = value
+		}
+	}
+	return nil
+}
+
+// invalidate Drop the cached token after the tool refused it
+func (a *toolAuth) invalidate() {
+	if invalidator, ok := a.authenticator.(tokenInvalidator); ok {
+		invalidator.invalidate()
+	}
+}
+
+// identityFields Identity fields allowed in identity headers, by lower case name
+var identityFields = map[string]func(identity *model.Identity) string{
+	"clientid":      func(identity *model.Identity) string { return identity.ClientID },
+	"clientide":     func(identity *model.Identity) string { return identity.ClientIDE },
+	"clientversion": func(identity *model.Identity) string { return identity.ClientVersion },
+	"clientos":      func(identity *model.Identity) string { return identity.ClientOS },
+	"username":      func(identity *model.Identity) string { return identity.UserName },
+	"projectpath":   func(identity *model.Identity) string { return identity.ProjectPath },
+	"language":      func(identity *model.Identity) string { return identity.Language },
+	"caller":        func(identity *model.Identity) string { return identity.Caller },
+	"taskid":        func(identity *model.Identity) string { return identity.TaskID },
+	"requestid":     func(identity *model.Identity) string { return identity.RequestID },
+	"userid": func(identity *model.Identity) string {
+		if identity.UserInfo == nil {
+			return ""
+		}
+		return identity.UserInfo.UUID
+	},
+	"email": func(identity *model.Identity) string {
+		if identity.UserInfo == nil {
+			return ""
+		}
+		return identity.UserInfo.Email
+	},
+}
+
+// passthroughAuth forwards the token of the end user
+type passthroughAuth struct{}
+
+func (passthroughAuth) authenticate(ctx context.Context, req *Request, params map[string]interface{}) error {
+	req.Authorization = getStringParam(params, CommonParamAuthorization)
+	return nil
+}
+
+// noAuth sends no credentials in the request, mTLS tools authenticate with their client certificate
+type noAuth struct{}
+
+func (noAuth) authenticate(ctx context.Context, req *Request, params map[string]interface{}) error {
+	return nil
+}
+
+// apiKeyAuth sends a static API key in a header
+type apiKeyAuth struct {
+	header string
+	key    string
+}
+
+func (a apiKeyAuth) authenticate(ctx context.Context, req *Request, params map[string]interface{}) error {
+	if req.Headers == nil {
+		req.Headers = make(map[string]string)
+	}
+	req.Headers[a.header] = a.key
+	return nil
+}
+
+// bearerAuth sends a bearer token of an environment variable, or of a file read on every request so that
+// rotated tokens are picked up
+type bearerAuth struct {
+	token     string
+	tokenFile string
+}
+
+func (a bearerAuth) authenticate(ctx context.Context, req *Request, params map[string]interface{}) error {
+	token := a.token
+	if a.tokenFile != "" {
+		data, err := os.ReadFile(a.tokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read token file: %w", err)
+		}
+		if token = strings.TrimSpace(string(data)); token == "" {
+			return fmt.Errorf("token file %s is empty", a.tokenFile)
+		}
+	}
+	req.Authorization = "Bearer " + token
+	return nil
+}
+
+// oauth2Auth sends a token of the OAuth2 client credentials grant, cached until shortly before it expires
+type oauth2Auth struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	scopes       []string
+	httpClient   *http.Client
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// newOAuth2Auth Create the OAuth2 client credentials authenticator
+func newOAuth2Auth(oauth2Config *config.OAuth2ClientConfig) (*oauth2Auth, error) {
+	if oauth2Config == nil || oauth2Config.TokenURL == "" || oauth2Config.ClientID == "" {
+		return nil, fmt.Errorf("oauth2 auth requires oauth2.tokenUrl and oauth2.clientId")
+	}
+	secret := cmp.Or(oauth2Config.ClientSecret, envValue(oauth2Config.ClientSecretEnv))
+	if secret == "" {
+		return nil, fmt.Errorf("oauth2 auth requires clientSecret or a non-empty clientSecretEnv")
+	}
+
+	return &oauth2Auth{
+		tokenURL:     oauth2Config.TokenURL,
+		clientID:     oauth2Config.ClientID,
+		clientSecret: secret,
+		scopes:       oauth2Config.Scopes,
+		// Tokens are never recorded to cassettes nor replayed from them, a replayed or stale token
+		// would be cached and sent for every user
+		httpClient: &http.Client{
+			Timeout:   oauth2TokenTimeout,
+			Transport: http.DefaultTransport,
+		},
+	}, nil
+}
+
+func (a *oauth2Auth) authenticate(ctx context.Context, req *Request, params map[string]interface{}) error {
+	token, err := a.getToken(ctx)
+	if err != nil {
+		return err
+	}
+	req.Authorization = "Bearer " + token
+	return nil
+}
+
+// getToken Get the cached token, a new one is requested once it is about to expire. Concurrent requests
+// wait for the same token request.
+func (a *oauth2Auth) getToken(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" && time.Now().Before(a.expiry) {
+		return a.token, nil
+	}
+
+	token, ttl, err := a.requestToken(ctx)
+	if err != nil {
+		return "", err
+	}
+	a.token = token
+	a.expiry = time.Now().Add(ttl - oauth2ExpirySkew)
+	return token, nil
+}
+
+// requestToken Request a token from the token endpoint, the client authenticates with HTTP basic auth
+func (a *oauth2Auth) requestToken(ctx context.Context) (string, time.Duration, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(a.scopes) > 0 {
+		form.Set("scope", strings.Join(a.scopes, " "))
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, a.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create token request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpReq.SetBasicAuth(url.QueryEscape(a.clientID), url.QueryEscape(a.clientSecret))
+
+	resp, err := a.httpClient.Do(httpReq)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to request token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("token request failed! status: %d, response: %s", resp.StatusCode, body)
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return "", 0, fmt.Errorf("failed to parse token response: %w", err)
+	}
+	if tokenResp.AccessToken == "" {
+		return "", 0, fmt.Errorf("token response has no access_token")
+	}
+
+	ttl := defaultOAuth2TokenTTL
+	if tokenResp.ExpiresIn > 0 {
+		ttl = time.Duration(tokenResp.ExpiresIn) * time.Second
+	}
+	return tokenResp.AccessToken, max(ttl, oauth2ExpirySkew), nil
+}
+
+// invalidate Drop the cached token, the next request gets a new one
+func (a *oauth2Auth) invalidate() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.token = ""
+}
+
+// toolTLSConfig Load the client certificate and CA of the tool, nil when none is configured
+func toolTLSConfig(tlsConfig *config.ToolTLSConfig) (*tls.Config, error) {
+	if tlsConfig == nil || (tlsConfig.CertFile == "" && tlsConfig.CAFile == "") {
+		return nil, nil
+	}
+
+	result := &tls.Config{MinVersion: tls.VersionTLS12}
+	if tlsConfig.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(tlsConfig.CertFile, tlsConfig.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		result.Certificates = []tls.Certificate{cert}
+	}
+	if tlsConfig.CAFile != "" {
+		ca, err := os.ReadFile(tlsConfig.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate found in CA file %s", tlsConfig.CAFile)
+		}
+		result.RootCAs = pool
+	}
+	return result, nil
+}
+
+// envValue Get the trimmed value of the environment variable, empty when no variable is given
+func envValue(name string) string {
+	if name == "" {
+		return ""
+	}
+	return strings.TrimSpace(os.Getenv(name))
+}
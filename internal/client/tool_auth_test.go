@@ -0,0 +1,276 @@
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/model"
+)
+
+// newAuthToolClient creates the client of a tool whose search endpoint echoes the auth headers
+func newAuthToolClient(t *testing.T, auth config.ToolAuthConfig) GenericClientInterface {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "authorization=%s key=%s user=%s", r.Header.Get("Authorization"),
+			r.Header.Get("X-Service-Key"), r.Header.Get("X-User-Id"))
+	}))
+	t.Cleanup(server.Close)
+
+	toolClient, err := NewGenericClientFactory(nil).CreateClient(config.GenericToolConfig{
+		Name:      "knowledge_search",
+		Method:    http.MethodGet,
+		Endpoints: config.GenericToolEndpoints{Search: server.URL, Ready: server.URL},
+		Auth:      auth,
+	})
+	if err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	return toolClient
+}
+
+func TestToolAuth(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("file-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TOOL_API_KEY", "env-key")
+
+	tests := []struct {
+		name string
+		auth config.ToolAuthConfig
+		want string
+	}{
+		{"passthrough", config.ToolAuthConfig{}, "authorization=Bearer user-jwt key= user="},
+		{"none", config.ToolAuthConfig{Type: config.ToolAuthNone}, "authorization= key= user="},
+		{"api key", config.ToolAuthConfig{Type: config.ToolAuthAPIKey, Header: "X-Service-Key", KeyEnv: "TOOL_API_KEY"},
+			"authorization= key=env-key user="},
+		{"bearer file", config.ToolAuthConfig{Type: config.ToolAuthBearer, TokenFile: tokenFile},
+			"authorization=Bearer file-token key= user="},
+		{"identity headers", config.ToolAuthConfig{Type: config.ToolAuthNone, IdentityHeaders: map[string]string{"x-user-id": "userId"}},
+			"authorization= key= user=user-1"},
+	}
+
+	ctx := context.WithValue(context.Background(), model.IdentityContextKey,
+		&model.Identity{UserInfo: &model.UserInfo{UUID: "user-1"}})
+	params := map[string]interface{}{CommonParamAuthorization: "Bearer user-jwt"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := newAuthToolClient(t, tt.auth).Execute(ctx, params)
+			if err != nil {
+				t.Fatalf("execute: %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("got %q, want %q", result, tt.want)
+			}
+		})
+	}
+}
+
+func TestToolAuth_InvalidConfig(t *testing.T) {
+	tests := []config.ToolAuthConfig{
+		{Type: "kerberos"},
+		{Type: config.ToolAuthAPIKey, KeyEnv: "TOOL_AUTH_UNSET_ENV"},
+		{Type: config.ToolAuthBearer},
+		{Type: config.ToolAuthOAuth2, OAuth2: &config.OAuth2ClientConfig{TokenURL: "http://idp/token", ClientID: "chat-rag"}},
+		{Type: config.ToolAuthMTLS},
+		{IdentityHeaders: map[string]string{"X-Token": "authToken"}},
+	}
+	for _, auth := range tests {
+		_, err := NewGenericClientFactory(nil).CreateClient(config.GenericToolConfig{Name: "tool", Auth: auth})
+		if err == nil {
+			t.Errorf("expected an error for %+v", auth)
+		}
+	}
+}
+
+func TestToolAuth_OAuth2(t *testing.T) {
+	var tokens atomic.Int32
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientID, secret, _ := r.BasicAuth()
+		if clientID != "chat-rag" || secret != "s3cret" || r.FormValue("grant_type") != "client_credentials" ||
+			r.FormValue("scope") != "search read" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":3600}`, tokens.Add(1))
+	}))
+	defer idp.Close()
+
+	// The tool refuses the first token, as if it was revoked
+	tool := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, r.Header.Get("Authorization"))
+	}))
+	defer tool.Close()
+
+	t.Setenv("TOOL_CLIENT_SECRET", "s3cret")
+	toolClient, err := NewGenericClientFactory(nil).CreateClient(config.GenericToolConfig{
+		Name:      "knowledge_search",
+		Method:    http.MethodGet,
+		Endpoints: config.GenericToolEndpoints{Search: tool.URL, Ready: tool.URL},
+		Auth: config.ToolAuthConfig{Type: config.ToolAuthOAuth2, OAuth2: &config.OAuth2ClientConfig{
+			TokenURL: idp.URL, ClientID: "chat-rag", ClientSecretEnv: "TOOL_CLIENT_SECRET", Scopes: []string{"search", "read"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		result, err := toolClient.Execute(context.Background(), map[string]interface{}{})
+		if err != nil {
+			t.Fatalf("execute: %v", err)
+		}
+		if result != "Bearer token-2" {
+			t.Errorf("got %q, want the renewed token", result)
+		}
+	}
+	if tokens.Load() != 2 {
+		t.Errorf("expected 2 token requests, got %d", tokens.Load())
+	}
+}
+
+func TestToolAuth_OAuth2Cassette(t *testing.T) {
+	dir := t.TempDir()
+	ConfigureCassette(config.CassetteConfig{Mode: config.CassetteModeRecord, Dir: dir})
+	defer ConfigureCassette(config.CassetteConfig{})
+
+	var tokens atomic.Int32
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":3600}`, tokens.Add(1))
+	}))
+	defer idp.Close()
+	tool := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Header.Get("Authorization"))
+	}))
+	defer tool.Close()
+
+	toolClient, err := NewGenericClientFactory(nil).CreateClient(config.GenericToolConfig{
+		Name:      "knowledge_search",
+		Method:    http.MethodGet,
+		Endpoints: config.GenericToolEndpoints{Search: tool.URL, Ready: tool.URL},
+		Auth: config.ToolAuthConfig{Type: config.ToolAuthOAuth2, OAuth2: &config.OAuth2ClientConfig{
+			TokenURL: idp.URL, ClientID: "chat-rag", ClientSecret: "s3cret",
+		}},
+	})
+	if err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+
+	// A request in replay mode still gets its token from the token endpoint
+	result, err := toolClient.Execute(WithCassetteMode(context.Background(), config.CassetteModeReplay), map[string]interface{}{})
+	if err == nil {
+		t.Fatalf("expected the tool call to miss its cassette, got %q", result)
+	}
+	if tokens.Load() != 1 {
+		t.Fatalf("expected 1 token request, got %d", tokens.Load())
+	}
+
+	// Recorded traffic does not include the token response
+	if _, err := toolClient.Execute(context.Background(), map[string]interface{}{}); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("expected the tool call to be recorded, got %v, %v", files, err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "access_token") {
+			t.Errorf("token response recorded in %s", file)
+		}
+	}
+}
+
+func TestToolAuth_MTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	writeClientCertificate(t, certFile, keyFile)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "cn=%s authorization=%s", r.TLS.PeerCertificates[0].Subject.CommonName, r.Header.Get("Authorization"))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := filepath.Join(dir, "ca.crt")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	toolClient, err := NewGenericClientFactory(nil).CreateClient(config.GenericToolConfig{
+		Name:      "knowledge_search",
+		Method:    http.MethodGet,
+		Endpoints: config.GenericToolEndpoints{Search: server.URL, Ready: server.URL},
+		Auth: config.ToolAuthConfig{Type: config.ToolAuthMTLS, TLS: &config.ToolTLSConfig{
+			CertFile: certFile, KeyFile: keyFile, CAFile: caFile,
+		}},
+	})
+	if err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+
+	result, err := toolClient.Execute(context.Background(), map[string]interface{}{CommonParamAuthorization: "Bearer user-jwt"})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if result != "cn=chat-rag authorization=" {
+		t.Errorf("got %q", result)
+	}
+}
+
+// writeClientCertificate writes a self-signed client certificate and its key as PEM files
+func writeClientCertificate(t *testing.T, certFile string, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "chat-rag"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,188 @@
+package client
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/logger"
+)
+
+const (
+	toolCacheRedisKeyPrefix   = "tool_cache:"
+	toolCacheRedisField       = "result"
+	toolVersionRedisKeyPrefix = "tool_cache_version:"
+	toolVersionRedisField     = "version"
+
+	defaultToolCacheTTL     = 10 * time.Minute
+	defaultToolVersionField = "version"
+	// The index version of a codebase is kept this long after its last ready check
+	toolVersionTTL = 24 * time.Hour
+)
+
+type toolCacheHitKey struct{}
+
+// WithToolCacheStatus returns a context whose tool calls report in the flag whether their result was
+// served from the result cache
+func WithToolCacheStatus(ctx context.Context) (context.Context, *atomic.Bool) {
+	hit := &atomic.Bool{}
+	return context.WithValue(ctx, toolCacheHitKey{}, hit), hit
+}
+
+// markToolCacheHit reports a cache hit to the caller of the tool
+func markToolCacheHit(ctx context.Context) {
+	if hit, ok := ctx.Value(toolCacheHitKey{}).(*atomic.Bool); ok {
+		hit.Store(true)
+	}
+}
+
+// readyVersionChecker is implemented by tool clients whose ready endpoint reports the index version
+type readyVersionChecker interface {
+	checkReadyVersion(ctx context.Context, params map[string]interface{}, versionField string) (bool, string, error)
+}
+
+// cachedToolClient serves repeated tool calls from the results cached in Redis. Results are cached by tool,
+// parameters, client and codebase, and by the index version last reported by the ready endpoint.
+type cachedToolClient struct {
+	next         GenericClientInterface
+	redisClient  RedisInterface
+	tool         string
+	params       []string
+	ttl          time.Duration
+	versionField string
+}
+
+// newCachedToolClient Wrap the tool client with the result cache of the tool configuration
+func newCachedToolClient(next GenericClientInterface, redisClient RedisInterface, toolConfig config.GenericToolConfig) *cachedToolClient {
+	params := make([]string, 0, len(toolConfig.Parameters))
+	for _, param := range toolConfig.Parameters {
+		params = append(params, param.Name)
+	}
+	ttl := defaultToolCacheTTL
+	if toolConfig.Cache.TTLSec > 0 {
+		ttl = time.Duration(toolConfig.Cache.TTLSec) * time.Second
+	}
+
+	return &cachedToolClient{
+		next:         next,
+		redisClient:  redisClient,
+		tool:         toolConfig.Name,
+		params:       params,
+		ttl:          ttl,
+		versionField: cmp.Or(toolConfig.Cache.VersionField, defaultToolVersionField),
+	}
+}
+
+// Execute Serve the result from the cache, otherwise execute the tool and cache its result
+func (c *cachedToolClient) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	scope := c.scope(params)
+	version, _ := c.redisClient.GetHashField(ctx, toolVersionRedisKeyPrefix+scope, toolVersionRedisField)
+	key := toolCacheRedisKeyPrefix + toolCacheKey(scope, version, c.arguments(params))
+
+	// Missing results and Redis errors are both misses
+	if result, err := c.redisClient.GetHashField(ctx, key, toolCacheRedisField); err == nil {
+		logger.InfoC(ctx, "tool result served from cache", zap.String("tool", c.tool))
+		markToolCacheHit(ctx)
+		return result, nil
+	}
+
+	result, err := c.next.Execute(ctx, params)
+	if err != nil {
+		return "", err
+	}
+	if err := c.redisClient.SetHashField(ctx, key, toolCacheRedisField, result, c.ttl); err != nil {
+		logger.WarnC(ctx, "failed to cache tool result", zap.String("tool", c.tool), zap.Error(err))
+	}
+	return result, nil
+}
+
+// CheckReady Check service availability, a new index version reported by the ready endpoint invalidates
+// the results cached for the codebase
+func (c *cachedToolClient) CheckReady(ctx context.Context, params map[string]interface{}) (bool, error) {
+	checker, ok := c.next.(readyVersionChecker)
+	if !ok {
+		return c.next.CheckReady(ctx, params)
+	}
+
+	ready, version, err := checker.checkReadyVersion(ctx, params, c.versionField)
+	if !ready || version == "" {
+		return ready, err
+	}
+
+	key := toolVersionRedisKeyPrefix + c.scope(params)
+	if previous, _ := c.redisClient.GetHashField(ctx, key, toolVersionRedisField); previous != "" && previous != version {
+		logger.InfoC(ctx, "tool index version changed, cached results are invalidated",
+			zap.String("tool", c.tool), zap.String("previous", previous), zap.String("version", version))
+	}
+	if err := c.redisClient.SetHashField(ctx, key, toolVersionRedisField, version, toolVersionTTL); err != nil {
+		logger.WarnC(ctx, "failed to store tool index version", zap.String("tool", c.tool), zap.Error(err))
+	}
+	return ready, err
+}
+
+// scope Identify the tool, client and codebase of the call
+func (c *cachedToolClient) scope(params map[string]interface{}) string {
+	h := sha256.New()
+	h.Write([]byte(getStringParam(params, CommonParamClientID) + "\n" + getStringParam(params, CommonParamCodebasePath)))
+	return c.tool + ":" + hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// arguments Get the tool parameters of the call, surrounding whitespace of strings is not significant
+func (c *cachedToolClient) arguments(params map[string]interface{}) map[string]interface{} {
+	arguments := make(map[string]interface{}, len(c.params))
+	for _, name := range c.params {
+		value, exists := params[name]
+		if !exists {
+			continue
+		}
+		if s, ok := value.(string); ok {
+			value = strings.TrimSpace(s)
+		}
+		arguments[name] = value
+	}
+	return arguments
+}
+
+// toolCacheKey Hash the scope, index version and arguments of a call, map keys are sorted by encoding/json
+func toolCacheKey(scope string, version string, arguments map[string]interface{}) string {
+	data, _ := json.Marshal(arguments)
+	h := sha256.New()
+	h.Write([]byte(version + "\n"))
+	h.Write(data)
+	return scope + ":" + hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// readyVersion Read the index version from the field of the JSON ready response, nested fields are
+// separated by dots. It is empty when the response has none.
+func readyVersion(body io.Reader, field string) string {
+	var value any
+	if err := json.NewDecoder(body).Decode(&value); err != nil {
+		return ""
+	}
+	for _, name := range strings.Split(field, ".") {
+		fields, ok := value.(map[string]any)
+		if !ok {
+			return ""
+		}
+		value = fields[name]
+	}
+
+	switch v := value.(type) {
+	case nil, map[string]any, []any:
+		return ""
+	case string:
+		return v
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
@@ -0,0 +1,134 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zgsm-ai/chat-rag/internal/config"
+)
+
+// memoryRedis keeps hash fields in memory, expiration is ignored
+type memoryRedis struct {
+	RedisInterface
+	mu     sync.Mutex
+	fields map[string]string
+}
+
+func (r *memoryRedis) SetHashField(ctx context.Context, key string, field string, value interface{}, expiration time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fields[key+"/"+field] = fmt.Sprint(value)
+	return nil
+}
+
+func (r *memoryRedis) GetHashField(ctx context.Context, key string, field string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	value, exists := r.fields[key+"/"+field]
+	if !exists {
+		return "", fmt.Errorf("hash field does not exist: %s:%s", key, field)
+	}
+	return value, nil
+}
+
+func TestCachedToolClient(t *testing.T) {
+	var searches atomic.Int32
+	var version atomic.Value
+	version.Store("v1")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ready" {
+			fmt.Fprintf(w, `{"data":{"version":%q}}`, version.Load())
+			return
+		}
+		searches.Add(1)
+		fmt.Fprintf(w, "results for %s in %s", r.URL.Query().Get("query"), r.URL.Query().Get("codebasePath"))
+	}))
+	defer server.Close()
+
+	factory := NewGenericClientFactory(&memoryRedis{fields: make(map[string]string)})
+	toolClient, err := factory.CreateClient(config.GenericToolConfig{
+		Name:       "codebase_search",
+		Method:     http.MethodGet,
+		Endpoints:  config.GenericToolEndpoints{Search: server.URL + "/search", Ready: server.URL + "/ready"},
+		Parameters: []config.GenericToolParameter{{Name: "query", Type: "string"}},
+		Cache:      config.ToolCacheConfig{Enabled: true, VersionField: "data.version"},
+	})
+	if err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+
+	execute := func(query string, codebasePath string) (string, bool) {
+		ctx, hit := WithToolCacheStatus(context.Background())
+		result, err := toolClient.Execute(ctx, map[string]interface{}{
+			"query":                  query,
+			CommonParamCodebasePath:  codebasePath,
+			CommonParamClientID:      "client",
+			CommonParamClientVersion: "1.0." + fmt.Sprint(searches.Load()),
+		})
+		if err != nil {
+			t.Fatalf("execute: %v", err)
+		}
+		return result, hit.Load()
+	}
+	ready := func() {
+		params := map[string]interface{}{CommonParamCodebasePath: "/repo", CommonParamClientID: "client"}
+		if ok, err := toolClient.CheckReady(context.Background(), params); !ok || err != nil {
+			t.Fatalf("expected ready, got %v, %v", ok, err)
+		}
+	}
+
+	ready()
+	if result, hit := execute("router", "/repo"); hit || result != "results for router in /repo" {
+		t.Errorf("expected a miss, got %q, %v", result, hit)
+	}
+	// Surrounding whitespace and the client version do not change the key
+	if result, hit := execute(" router\n", "/repo"); !hit || result != "results for router in /repo" {
+		t.Errorf("expected a hit, got %q, %v", result, hit)
+	}
+	if _, hit := execute("router", "/other"); hit {
+		t.Error("expected a miss for another codebase")
+	}
+	if searches.Load() != 2 {
+		t.Errorf("expected 2 searches, got %d", searches.Load())
+	}
+
+	// The same version keeps the results, a new one invalidates them
+	ready()
+	if _, hit := execute("router", "/repo"); !hit {
+		t.Error("expected a hit with the same index version")
+	}
+	version.Store("v2")
+	ready()
+	if _, hit := execute("router", "/repo"); hit {
+		t.Error("expected a miss after the index version changed")
+	}
+	if searches.Load() != 3 {
+		t.Errorf("expected 3 searches, got %d", searches.Load())
+	}
+}
+
+func TestReadyVersion(t *testing.T) {
+	tests := []struct {
+		body  string
+		field string
+		want  string
+	}{
+		{`{"version":"abc"}`, "version", "abc"},
+		{`{"data":{"version":42}}`, "data.version", "42"},
+		{`{"data":{"version":{"id":1}}}`, "data.version", ""},
+		{`{"status":"ok"}`, "version", ""},
+		{`ready`, "version", ""},
+	}
+	for _, tt := range tests {
+		if got := readyVersion(strings.NewReader(tt.body), tt.field); got != tt.want {
+			t.Errorf("readyVersion(%s, %s) = %q, want %q", tt.body, tt.field, got, tt.want)
+		}
+	}
+}
@@ -2,13 +2,18 @@ package client
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
+	"go.uber.org/zap"
+
 	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/logger"
 	"github.com/zgsm-ai/chat-rag/internal/types"
 )
 
@@ -44,18 +49,29 @@ type GenericToolClient struct {
 	readyClient     *HTTPClient
 	requestBuilder  *GenericRequestBuilder
 	responseHandler *GenericResponseHandler
+	auth            *toolAuth
+	// Retries of failed idempotent requests, and the interval before the first one
+	maxRetryCount int
+	retryInterval time.Duration
 }
 
 // GenericClientFactory Generic client factory
 type GenericClientFactory struct {
 	clients map[string]GenericClientInterface
-	mutex   sync.RWMutex
+	// MCP server connections, shared by the tools of a server
+	mcpServers map[string]*MCPClient
+	// Result cache of the tools with caching enabled, nil disables caching
+	redisClient RedisInterface
+	mutex       sync.RWMutex
 }
 
-// NewGenericClientFactory Create new generic client factory
-func NewGenericClientFactory() *GenericClientFactory {
+// NewGenericClientFactory Create new generic client factory, tool results are cached in Redis when
+// the Redis client is given
+func NewGenericClientFactory(redisClient RedisInterface) *GenericClientFactory {
 	return &GenericClientFactory{
-		clients: make(map[string]GenericClientInterface),
+		clients:     make(map[string]GenericClientInterface),
+		mcpServers:  make(map[string]*MCPClient),
+		redisClient: redisClient,
 	}
 }
 
@@ -69,10 +85,25 @@ func (f *GenericClientFactory) CreateClient(toolConfig config.GenericToolConfig)
 		return client, nil
 	}
 
-	// Create new generic client
-	client, err := f.createGenericClient(toolConfig)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create universal client for tool %s: %w", toolConfig.Name, err)
+	// MCP tools call their server
+	var client GenericClientInterface
+	if toolConfig.IsMCP() {
+		client = f.createMCPToolClient(toolConfig)
+	} else {
+		// Create new generic client
+		genericClient, err := f.createGenericClient(toolConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create universal client for tool %s: %w", toolConfig.Name, err)
+		}
+		client = genericClient
+	}
+
+	if toolConfig.Limits.MaxConcurrency > 0 {
+		client = newLimitedToolClient(client, toolConfig)
+	}
+	// Cache hits take no slot of the concurrency limit
+	if toolConfig.Cache.Enabled && f.redisClient != nil {
+		client = newCachedToolClient(client, f.redisClient, toolConfig)
 	}
 
 	// Cache client instance
@@ -82,12 +113,34 @@ func (f *GenericClientFactory) CreateClient(toolConfig config.GenericToolConfig)
 
 // createGenericClient Create generic client instance
 func (f *GenericClientFactory) createGenericClient(toolConfig config.GenericToolConfig) (*GenericToolClient, error) {
+	auth, err := newToolAuth(toolConfig.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("invalid auth configuration: %w", err)
+	}
+	tlsConfig, err := toolTLSConfig(toolConfig.Auth.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tls configuration: %w", err)
+	}
+
 	// Configure HTTP client
+	limits := toolConfig.Limits
 	searchConfig := HTTPClientConfig{
-		Timeout: 5 * time.Second,
+		Timeout:   defaultToolTimeout,
+		TLSConfig: tlsConfig,
+	}
+	if limits.TimeoutMs > 0 {
+		searchConfig.Timeout = time.Duration(limits.TimeoutMs) * time.Millisecond
 	}
 	readyConfig := HTTPClientConfig{
-		Timeout: 3 * time.Second,
+		Timeout:   defaultToolReadyTimeout,
+		TLSConfig: tlsConfig,
+	}
+	if limits.ReadyTimeoutMs > 0 {
+		readyConfig.Timeout = time.Duration(limits.ReadyTimeoutMs) * time.Millisecond
+	}
+	retryInterval := defaultToolRetryInterval
+	if limits.RetryIntervalMs > 0 {
+		retryInterval = time.Duration(limits.RetryIntervalMs) * time.Millisecond
 	}
 
 	// Create HTTP clients
@@ -100,9 +153,51 @@ func (f *GenericClientFactory) createGenericClient(toolConfig config.GenericTool
 		readyClient:     readyClient,
 		requestBuilder:  &GenericRequestBuilder{toolConfig: toolConfig},
 		responseHandler: &GenericResponseHandler{},
+		auth:            auth,
+		maxRetryCount:   max(limits.MaxRetryCount, 0),
+		retryInterval:   retryInterval,
 	}, nil
 }
 
+// MCPServer Get the client of the MCP server of the tool configuration
+func (f *GenericClientFactory) MCPServer(toolConfig config.GenericToolConfig) *MCPClient {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.mcpServer(toolConfig)
+}
+
+// mcpServer Get or create the MCP server client, the caller holds the lock
+func (f *GenericClientFactory) mcpServer(toolConfig config.GenericToolConfig) *MCPClient {
+	key := mcpServerKey(*toolConfig.MCP)
+	if server, exists := f.mcpServers[key]; exists {
+		return server
+	}
+	server := NewMCPClient(toolConfig.Name, *toolConfig.MCP)
+	f.mcpServers[key] = server
+	return server
+}
+
+// createMCPToolClient Create the client of a tool listed by an MCP server
+func (f *GenericClientFactory) createMCPToolClient(toolConfig config.GenericToolConfig) *mcpToolClient {
+	params := make([]string, 0, len(toolConfig.Parameters))
+	for _, param := range toolConfig.Parameters {
+		params = append(params, param.Name)
+	}
+	timeout := mcpCallTimeout
+	if toolConfig.MCP.TimeoutMs > 0 {
+		timeout = time.Duration(toolConfig.MCP.TimeoutMs) * time.Millisecond
+	} else if toolConfig.Limits.TimeoutMs > 0 {
+		timeout = time.Duration(toolConfig.Limits.TimeoutMs) * time.Millisecond
+	}
+
+	return &mcpToolClient{
+		server:  f.mcpServer(toolConfig),
+		tool:    strings.TrimPrefix(toolConfig.Name, toolConfig.MCP.Prefix),
+		params:  params,
+		timeout: timeout,
+	}
+}
+
 // ClearCache Clear client cache
 func (f *GenericClientFactory) ClearCache() {
 	f.mutex.Lock()
@@ -111,11 +206,24 @@ func (f *GenericClientFactory) ClearCache() {
 	f.clients = make(map[string]GenericClientInterface)
 }
 
+// Close Close the MCP server connections for good, stdio servers are stopped
+func (f *GenericClientFactory) Close() error {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	var errs []error
+	for _, server := range f.mcpServers {
+		if err := server.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // Execute Execute tool request
 func (c *GenericToolClient) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
-	httpReq := c.requestBuilder.BuildRequest(params)
-
-	resp, err := c.searchClient.DoRequest(ctx, httpReq)
+	idempotent := c.toolConfig.Limits.Idempotent || isIdempotentMethod(c.toolConfig.Method)
+	resp, err := c.doRequest(ctx, c.searchClient, c.requestBuilder.BuildRequest(params), params, idempotent)
 	if err != nil {
 		return "", fmt.Errorf("failed to execute request: %w", err)
 	}
@@ -126,9 +234,7 @@ func (c *GenericToolClient) Execute(ctx context.Context, params map[string]inter
 
 // CheckReady Check service availability
 func (c *GenericToolClient) CheckReady(ctx context.Context, params map[string]interface{}) (bool, error) {
-	httpReq := c.requestBuilder.BuildReadyRequest(params)
-
-	resp, err := c.readyClient.DoRequest(ctx, httpReq)
+	resp, err := c.doRequest(ctx, c.readyClient, c.requestBuilder.BuildReadyRequest(params), params, true)
 	if err != nil {
 		return false, fmt.Errorf("failed to check ready status: %w", err)
 	}
@@ -137,6 +243,65 @@ func (c *GenericToolClient) CheckReady(ctx context.Context, params map[string]in
 	return c.responseHandler.HandleReadyResponse(resp)
 }
 
+// checkReadyVersion Check service availability and read the index version of the ready response
+func (c *GenericToolClient) checkReadyVersion(ctx context.Context, params map[string]interface{}, versionField string) (bool, string, error) {
+	resp, err := c.doRequest(ctx, c.readyClient, c.requestBuilder.BuildReadyRequest(params), params, true)
+	if err != nil {
+		return false, "", fmt.Errorf("failed to check ready status: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		ready, err := c.responseHandler.HandleReadyResponse(resp)
+		return ready, "", err
+	}
+	return true, readyVersion(resp.Body, versionField), nil
+}
+
+// doRequest Send the request, failed idempotent requests are retried with exponential backoff
+func (c *GenericToolClient) doRequest(ctx context.Context, httpClient *HTTPClient, req Request, params map[string]interface{}, idempotent bool) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := c.sendRequest(ctx, httpClient, req, params)
+		if !idempotent || attempt >= c.maxRetryCount || !isRetryableResponse(resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		backoff := c.retryInterval << attempt
+		logger.WarnC(ctx, "tool request failed, retrying", zap.String("tool", c.toolConfig.Name),
+			zap.Int("attempt", attempt+1), zap.Duration("backoff", backoff), zap.Error(err))
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// sendRequest Authenticate and send the request. A request refused with a cached token is sent once more
+// with a new token.
+func (c *GenericToolClient) sendRequest(ctx context.Context, httpClient *HTTPClient, req Request, params map[string]interface{}) (*http.Response, error) {
+	if err := c.auth.apply(ctx, &req, params); err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.DoRequest(ctx, req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	if _, ok := c.auth.authenticator.(tokenInvalidator); !ok {
+		return resp, nil
+	}
+
+	resp.Body.Close()
+	c.auth.invalidate()
+	if err := c.auth.apply(ctx, &req, params); err != nil {
+		return nil, err
+	}
+	return httpClient.DoRequest(ctx, req)
+}
+
 // GenericRequestBuilder Generic request builder
 type GenericRequestBuilder struct {
 	toolConfig config.GenericToolConfig
@@ -160,11 +325,6 @@ func (b *GenericRequestBuilder) BuildRequest(params map[string]interface{}) Requ
 		req.Body = b.buildRequestBody(params)
 	}
 
-	// Set authentication information
-	if _, exists := params["authorization"]; exists {
-		req.Authorization = getStringParam(params, "authorization")
-	}
-
 	return req
 }
 
@@ -179,7 +339,6 @@ func (b *GenericRequestBuilder) BuildReadyRequest(params map[string]interface{})
 			"clientId":     getStringParam(params, "clientId"),
 			"codebasePath": getStringParam(params, "codebasePath"),
 		},
-		Authorization: getStringParam(params, "authorization"),
 	}
 }
 
@@ -0,0 +1,137 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/logger"
+)
+
+const (
+	defaultToolTimeout       = 5 * time.Second
+	defaultToolReadyTimeout  = 3 * time.Second
+	defaultToolRetryInterval = 200 * time.Millisecond
+)
+
+// ErrToolBusy is returned when the calls of a tool in flight reached its concurrency limit
+var ErrToolBusy = errors.New("tool is busy")
+
+// toolSlotKey identifies the concurrency slots of a tool, one set per tool and limit
+type toolSlotKey struct {
+	tool  string
+	limit int
+}
+
+var (
+	toolSlotsMu sync.Mutex
+	toolSlots   = make(map[toolSlotKey]chan struct{})
+)
+
+// sharedToolSlots returns the concurrency slots of the tool, shared by all its clients in the process so that
+// clients created again on configuration changes keep the same limit
+func sharedToolSlots(tool string, limit int) chan struct{} {
+	key := toolSlotKey{tool: tool, limit: limit}
+
+	toolSlotsMu.Lock()
+	defer toolSlotsMu.Unlock()
+	if slots, ok := toolSlots[key]; ok {
+		return slots
+	}
+	slots := make(chan struct{}, limit)
+	toolSlots[key] = slots
+	return slots
+}
+
+// limitedToolClient bounds the calls of a tool in flight. Calls beyond the limit wait for a free slot up to
+// the queue timeout, then are refused as busy instead of piling up on a slow service.
+type limitedToolClient struct {
+	next         GenericClientInterface
+	tool         string
+	slots        chan struct{}
+	queueTimeout time.Duration
+}
+
+// newLimitedToolClient Wrap the tool client with the concurrency limit of the tool configuration
+func newLimitedToolClient(next GenericClientInterface, toolConfig config.GenericToolConfig) *limitedToolClient {
+	return &limitedToolClient{
+		next:         next,
+		tool:         toolConfig.Name,
+		slots:        sharedToolSlots(toolConfig.Name, toolConfig.Limits.MaxConcurrency),
+		queueTimeout: time.Duration(toolConfig.Limits.QueueTimeoutMs) * time.Millisecond,
+	}
+}
+
+// Execute Execute the tool once a slot is free
+func (c *limitedToolClient) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	if err := c.acquire(ctx); err != nil {
+		return "", err
+	}
+	defer func() { <-c.slots }()
+
+	return c.next.Execute(ctx, params)
+}
+
+// CheckReady Check service availability, readiness checks are not limited
+func (c *limitedToolClient) CheckReady(ctx context.Context, params map[string]interface{}) (bool, error) {
+	return c.next.CheckReady(ctx, params)
+}
+
+// checkReadyVersion Check service availability and read the index version, when the tool client reports it
+func (c *limitedToolClient) checkReadyVersion(ctx context.Context, params map[string]interface{}, versionField string) (bool, string, error) {
+	if checker, ok := c.next.(readyVersionChecker); ok {
+		return checker.checkReadyVersion(ctx, params, versionField)
+	}
+	ready, err := c.next.CheckReady(ctx, params)
+	return ready, "", err
+}
+
+// acquire Take a slot, waiting up to the queue timeout
+func (c *limitedToolClient) acquire(ctx context.Context) error {
+	select {
+	case c.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	if c.queueTimeout > 0 {
+		timer := time.NewTimer(c.queueTimeout)
+		defer timer.Stop()
+		select {
+		case c.slots <- struct{}{}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	logger.WarnC(ctx, "tool call refused, too many calls in flight",
+		zap.String("tool", c.tool), zap.Int("limit", cap(c.slots)))
+	return fmt.Errorf("%w: %d calls of %s are in flight, try again later", ErrToolBusy, cap(c.slots), c.tool)
+}
+
+// isIdempotentMethod reports whether requests of the HTTP method can be sent again safely
+func isIdempotentMethod(method string) bool {
+	switch strings.ToUpper(method) {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// isRetryableResponse reports whether the failed attempt may succeed when sent again
+func isRetryableResponse(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled)
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
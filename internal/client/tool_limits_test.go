@@ -0,0 +1,133 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zgsm-ai/chat-rag/internal/config"
+)
+
+// newLimitsToolClient creates the client of a tool served by the handler
+func newLimitsToolClient(t *testing.T, name string, method string, limits config.ToolLimitsConfig, handler http.HandlerFunc) GenericClientInterface {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	toolClient, err := NewGenericClientFactory(nil).CreateClient(config.GenericToolConfig{
+		Name:      name,
+		Method:    method,
+		Endpoints: config.GenericToolEndpoints{Search: server.URL, Ready: server.URL},
+		Limits:    limits,
+	})
+	if err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	return toolClient
+}
+
+func TestToolLimits_Retry(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		idempotent   bool
+		wantAttempts int32
+		wantErr      bool
+	}{
+		{"get is retried", http.MethodGet, false, 3, false},
+		{"post is not retried", http.MethodPost, false, 1, true},
+		{"idempotent post is retried", http.MethodPost, true, 3, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			toolClient := newLimitsToolClient(t, "retry_search", tt.method, config.ToolLimitsConfig{
+				MaxRetryCount: 3, RetryIntervalMs: 1, Idempotent: tt.idempotent,
+			}, func(w http.ResponseWriter, r *http.Request) {
+				if attempts.Add(1) < 3 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				fmt.Fprint(w, "results")
+			})
+
+			result, err := toolClient.Execute(context.Background(), map[string]interface{}{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && result != "results" {
+				t.Errorf("got %q", result)
+			}
+			if attempts.Load() != tt.wantAttempts {
+				t.Errorf("got %d attempts, want %d", attempts.Load(), tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestToolLimits_Timeout(t *testing.T) {
+	slow := func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		fmt.Fprint(w, "results")
+	}
+
+	short := newLimitsToolClient(t, "timeout_search", http.MethodGet, config.ToolLimitsConfig{TimeoutMs: 50}, slow)
+	if _, err := short.Execute(context.Background(), map[string]interface{}{}); err == nil {
+		t.Error("expected the call to time out")
+	}
+	long := newLimitsToolClient(t, "timeout_search", http.MethodGet, config.ToolLimitsConfig{TimeoutMs: 2000}, slow)
+	if result, err := long.Execute(context.Background(), map[string]interface{}{}); err != nil || result != "results" {
+		t.Errorf("got %q, %v", result, err)
+	}
+}
+
+func TestToolLimits_Bulkhead(t *testing.T) {
+	release := make(chan struct{})
+	var started atomic.Int32
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		started.Add(1)
+		<-release
+		fmt.Fprint(w, "results")
+	}
+
+	busy := newLimitsToolClient(t, "bulkhead_search", http.MethodGet, config.ToolLimitsConfig{MaxConcurrency: 1}, handler)
+	queued := newLimitsToolClient(t, "bulkhead_search", http.MethodGet,
+		config.ToolLimitsConfig{MaxConcurrency: 1, QueueTimeoutMs: 5000}, handler)
+
+	first := make(chan error, 1)
+	go func() {
+		_, err := busy.Execute(context.Background(), map[string]interface{}{})
+		first <- err
+	}()
+	for started.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The slot is taken by the first call, across the clients of the tool
+	start := time.Now()
+	if _, err := busy.Execute(context.Background(), map[string]interface{}{}); !errors.Is(err, ErrToolBusy) {
+		t.Errorf("expected ErrToolBusy, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("busy call took %v, expected it to be refused at once", elapsed)
+	}
+
+	second := make(chan error, 1)
+	go func() {
+		_, err := queued.Execute(context.Background(), map[string]interface{}{})
+		second <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	if err := <-first; err != nil {
+		t.Errorf("first call: %v", err)
+	}
+	if err := <-second; err != nil {
+		t.Errorf("queued call: %v", err)
+	}
+}
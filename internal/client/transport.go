@@ -0,0 +1,81 @@
+package client
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/zgsm-ai/chat-rag/internal/config"
+)
+
+// transportKey identifies a pooled transport, one per upstream host and settings
+type transportKey struct {
+	host                  string
+	responseHeaderTimeout time.Duration
+	settings              config.LLMTransportConfig
+}
+
+var (
+	transportsMu sync.Mutex
+	transports   = make(map[transportKey]*http.Transport)
+)
+
+// sharedTransport returns the pooled transport of the upstream, creating it on first use.
+// Clients of the same upstream reuse TCP/TLS connections across requests, retries and
+// degradation attempts. Per-request idle timeouts are enforced by the IdleTimer context.
+func sharedTransport(endpoint string, responseHeaderTimeout time.Duration, settings config.LLMTransportConfig) *http.Transport {
+	key := transportKey{
+		host:                  upstreamHost(endpoint),
+		responseHeaderTimeout: responseHeaderTimeout,
+		settings:              settings.WithDefaults(),
+	}
+
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	if transport, ok := transports[key]; ok {
+		return transport
+	}
+	transport := newPooledTransport(responseHeaderTimeout, key.settings)
+	transports[key] = transport
+	return transport
+}
+
+// newPooledTransport creates a transport with the pool settings
+func newPooledTransport(responseHeaderTimeout time.Duration, settings config.LLMTransportConfig) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   time.Duration(settings.DialTimeoutMs) * time.Millisecond,
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     !settings.DisableHTTP2,
+		MaxIdleConns:          settings.MaxIdleConns,
+		MaxIdleConnsPerHost:   settings.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       settings.MaxConnsPerHost,
+		IdleConnTimeout:       time.Duration(settings.IdleConnTimeoutMs) * time.Millisecond,
+		TLSHandshakeTimeout:   time.Duration(settings.TLSHandshakeTimeoutMs) * time.Millisecond,
+		ResponseHeaderTimeout: responseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// upstreamHost returns the scheme and host of the endpoint
+func upstreamHost(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return endpoint
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// CloseIdleTransports closes the idle connections of every pooled transport
+func CloseIdleTransports() {
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	for _, transport := range transports {
+		transport.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,124 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+// newCountingServer starts an OpenAI style upstream that counts the TCP connections it accepts
+func newCountingServer() (*httptest.Server, *atomic.Int64) {
+	var conns atomic.Int64
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		io.WriteString(w, `{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`)
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	server.Start()
+	return server, &conns
+}
+
+var benchParams = types.LLMRequestParams{
+	Messages: []types.Message{{Role: types.RoleUser, Content: "hello"}},
+}
+
+func TestSharedTransport_Reuse(t *testing.T) {
+	settings := config.LLMTransportConfig{}
+	a := sharedTransport("http://upstream-a/v1/chat/completions", time.Second, settings)
+	b := sharedTransport("http://upstream-a/v1/messages", time.Second, settings)
+	c := sharedTransport("http://upstream-b/v1/chat/completions", time.Second, settings)
+
+	if a != b {
+		t.Error("Endpoints of the same host should share a transport")
+	}
+	if a == c {
+		t.Error("Different hosts should not share a transport")
+	}
+	if a.MaxIdleConnsPerHost != settings.WithDefaults().MaxIdleConnsPerHost {
+		t.Errorf("Expected default MaxIdleConnsPerHost, got %d", a.MaxIdleConnsPerHost)
+	}
+}
+
+func TestNewLLMClient_ReusesConnections(t *testing.T) {
+	server, conns := newCountingServer()
+	defer server.Close()
+
+	headers := make(http.Header)
+	llmConfig := config.LLMConfig{Endpoint: server.URL}
+	for i := 0; i < 10; i++ {
+		llm, err := NewLLMClient(llmConfig, config.LLMTimeoutConfig{}, "test-model", &headers)
+		if err != nil {
+			t.Fatalf("NewLLMClient failed: %v", err)
+		}
+		if _, err := llm.ChatLLMWithMessagesRaw(context.Background(), benchParams, nil); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	if got := conns.Load(); got != 1 {
+		t.Errorf("Expected 1 connection for sequential requests, got %d", got)
+	}
+}
+
+// BenchmarkLLMClient_SharedTransport creates a client per request like ChatCompletionLogic does,
+// with connections pooled per upstream
+func BenchmarkLLMClient_SharedTransport(b *testing.B) {
+	server, conns := newCountingServer()
+	defer server.Close()
+
+	headers := make(http.Header)
+	llmConfig := config.LLMConfig{Endpoint: server.URL}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			llm, err := NewLLMClient(llmConfig, config.LLMTimeoutConfig{}, "test-model", &headers)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := llm.ChatLLMWithMessagesRaw(context.Background(), benchParams, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.ReportMetric(float64(conns.Load())/float64(b.N), "conns/op")
+}
+
+// BenchmarkLLMClient_TransportPerClient is the previous behavior, a new transport for every client
+func BenchmarkLLMClient_TransportPerClient(b *testing.B) {
+	server, conns := newCountingServer()
+	defer server.Close()
+
+	headers := make(http.Header)
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			transport := &http.Transport{ResponseHeaderTimeout: 30 * time.Second}
+			llm := &LLMClient{
+				modelName:  "test-model",
+				endpoint:   server.URL,
+				headers:    &headers,
+				httpClient: &http.Client{Transport: transport},
+			}
+			if _, err := llm.ChatLLMWithMessagesRaw(context.Background(), benchParams, nil); err != nil {
+				b.Fatal(err)
+			}
+			// Release the connection so the benchmark does not run out of file descriptors
+			transport.CloseIdleConnections()
+		}
+	})
+	b.ReportMetric(float64(conns.Load())/float64(b.N), "conns/op")
+}
@@ -1,10 +1,16 @@
 package config
 
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
 // ParameterSource Parameter source enumeration
 type ParameterSource string
 
 const (
-	ParameterSourceLLM    ParameterSource = "llm"    // Extract from LLM response, LLM must provide XML format
+	ParameterSourceLLM    ParameterSource = "llm"    // Extract from LLM response, XML tags or function call arguments
 	ParameterSourceManual ParameterSource = "manual" // Manual setting, get from default field in config file
 )
 
@@ -17,6 +23,22 @@ const (
 	ParameterTypeFloat   ParameterType = "float"
 	ParameterTypeBoolean ParameterType = "boolean"
 	ParameterTypeArray   ParameterType = "array"
+	ParameterTypeNumber  ParameterType = "number" // JSON schema name of float
+	ParameterTypeObject  ParameterType = "object"
+)
+
+// ToolType Tool backend enumeration
+type ToolType string
+
+const (
+	ToolTypeHTTP ToolType = "http" // HTTP search and ready endpoints, the default
+	ToolTypeMCP  ToolType = "mcp"  // MCP server, its tools are discovered with tools/list
+)
+
+// MCP transports
+const (
+	MCPTransportStdio = "stdio"
+	MCPTransportHTTP  = "http"
 )
 
 // LLMConfig
@@ -24,6 +46,139 @@ type LLMConfig struct {
 	Endpoint            string
 	FuncCallingModels   []string
 	ChunkMetricsEnabled bool
+	// Model registry, per-model upstream and capabilities
+	Models []ModelConfig `mapstructure:"models" yaml:"models"`
+	// Pooled HTTP transport shared by the LLM clients of the same upstream
+	Transport LLMTransportConfig `mapstructure:"transport" yaml:"transport"`
+}
+
+// LLMTransportConfig holds the connection pool settings of the upstream HTTP transports
+type LLMTransportConfig struct {
+	// Max idle connections across all upstreams
+	MaxIdleConns int `mapstructure:"maxIdleConns" yaml:"maxIdleConns"`
+	// Max idle connections kept per upstream host
+	MaxIdleConnsPerHost int `mapstructure:"maxIdleConnsPerHost" yaml:"maxIdleConnsPerHost"`
+	// Max connections per upstream host, 0 means no limit
+	MaxConnsPerHost int `mapstructure:"maxConnsPerHost" yaml:"maxConnsPerHost"`
+	// How long an idle connection is kept in the pool
+	IdleConnTimeoutMs int `mapstructure:"idleConnTimeoutMs" yaml:"idleConnTimeoutMs"`
+	// TCP dial timeout
+	DialTimeoutMs int `mapstructure:"dialTimeoutMs" yaml:"dialTimeoutMs"`
+	// TLS handshake timeout
+	TLSHandshakeTimeoutMs int `mapstructure:"tlsHandshakeTimeoutMs" yaml:"tlsHandshakeTimeoutMs"`
+	// Disable HTTP/2 negotiation with TLS upstreams
+	DisableHTTP2 bool `mapstructure:"disableHTTP2" yaml:"disableHTTP2"`
+}
+
+// WithDefaults returns the transport settings with unset values replaced by defaults
+func (c LLMTransportConfig) WithDefaults() LLMTransportConfig {
+	if c.MaxIdleConns <= 0 {
+		c.MaxIdleConns = 512
+	}
+	if c.MaxIdleConnsPerHost <= 0 {
+		c.MaxIdleConnsPerHost = 64
+	}
+	if c.MaxConnsPerHost < 0 {
+		c.MaxConnsPerHost = 0
+	}
+	if c.IdleConnTimeoutMs <= 0 {
+		c.IdleConnTimeoutMs = 90000
+	}
+	if c.DialTimeoutMs <= 0 {
+		c.DialTimeoutMs = 10000
+	}
+	if c.TLSHandshakeTimeoutMs <= 0 {
+		c.TLSHandshakeTimeoutMs = 10000
+	}
+	return c
+}
+
+// Upstream provider types of the model registry
+const (
+	ProviderOpenAI    = "openai"
+	ProviderAnthropic = "anthropic"
+	ProviderGemini    = "gemini"
+	ProviderAzure     = "azure"
+)
+
+// ModelConfig holds the registry entry of a single model
+type ModelConfig struct {
+	Name string `mapstructure:"name" yaml:"name"`
+	// Upstream endpoint of the model, empty falls back to LLM.Endpoint for openai models
+	// and to the public API of native providers
+	Endpoint string `mapstructure:"endpoint" yaml:"endpoint"`
+	// Upstream API token, empty keeps the Authorization header of the request for openai models of the
+	// global endpoint. Required for native providers and models with their own endpoint, which never
+	// receive the headers of the request
+	ApiToken string `mapstructure:"apiToken" yaml:"apiToken"`
+	// Upstream provider type, defaults to openai
+	Provider string `mapstructure:"provider" yaml:"provider"`
+	// Max context window in tokens, 0 means unknown
+	ContextWindow int `mapstructure:"contextWindow" yaml:"contextWindow"`
+	// Max output tokens, 0 means unknown
+	MaxOutputTokens int `mapstructure:"maxOutputTokens" yaml:"maxOutputTokens"`
+	// Whether the model accepts image input
+	Vision bool `mapstructure:"vision" yaml:"vision"`
+	// Price per million tokens
+	Pricing ModelPricing `mapstructure:"pricing" yaml:"pricing"`
+}
+
+// ModelPricing holds the token prices of a model, per million tokens
+type ModelPricing struct {
+	Input       float64 `mapstructure:"input" yaml:"input"`
+	Output      float64 `mapstructure:"output" yaml:"output"`
+	CachedInput float64 `mapstructure:"cachedInput" yaml:"cachedInput"`
+}
+
+// UpstreamTarget is the resolved upstream of a model
+type UpstreamTarget struct {
+	Endpoint        string
+	ApiToken        string
+	Provider        string
+	MaxOutputTokens int
+}
+
+// LookupModel returns the registry entry of the given model
+func (c *LLMConfig) LookupModel(name string) (ModelConfig, bool) {
+	for _, m := range c.Models {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return ModelConfig{}, false
+}
+
+// Validate checks the model registry, every native provider model needs its own API token
+func (c *LLMConfig) Validate() error {
+	for _, m := range c.Models {
+		provider := strings.ToLower(m.Provider)
+		if provider != "" && provider != ProviderOpenAI && m.ApiToken == "" {
+			return fmt.Errorf("model %s of provider %s has no apiToken", m.Name, m.Provider)
+		}
+		if m.Endpoint != "" && m.Endpoint != c.Endpoint && m.ApiToken == "" {
+			return fmt.Errorf("model %s with its own endpoint has no apiToken", m.Name)
+		}
+	}
+	return nil
+}
+
+// ResolveUpstream resolves the upstream of the given model from the registry.
+// OpenAI compatible models without an endpoint fall back to the global endpoint,
+// native providers fall back to the default endpoint of the provider
+func (c *LLMConfig) ResolveUpstream(name string) UpstreamTarget {
+	target := UpstreamTarget{Provider: ProviderOpenAI}
+	if m, ok := c.LookupModel(name); ok {
+		target.Endpoint = m.Endpoint
+		target.ApiToken = m.ApiToken
+		target.MaxOutputTokens = m.MaxOutputTokens
+		if m.Provider != "" {
+			target.Provider = strings.ToLower(m.Provider)
+		}
+	}
+	if target.Endpoint == "" && target.Provider == ProviderOpenAI {
+		target.Endpoint = c.Endpoint
+	}
+	return target
 }
 
 // LLMTimeoutConfig holds idle timeout configuration for LLM requests
@@ -63,6 +218,123 @@ type GenericToolConfig struct {
 	Method      string                 `yaml:"method"`      // HTTP request method
 	Parameters  []GenericToolParameter `yaml:"parameters"`  // Parameter definitions
 	Rule        string                 `yaml:"rule"`        // Tool usage rules
+	Type        ToolType               `yaml:"type"`        // Tool backend, http when empty
+	MCP         *MCPServerConfig       `yaml:"mcp"`         // MCP server of mcp tools
+	Cache       ToolCacheConfig        `yaml:"cache"`       // Result cache of the tool
+	Response    ToolResponseConfig     `yaml:"response"`    // Processing of the tool response
+	Auth        ToolAuthConfig         `yaml:"auth"`        // Authentication of the tool requests
+	Limits      ToolLimitsConfig       `yaml:"limits"`      // Timeouts, retries and concurrency of the tool calls
+}
+
+// ToolLimitsConfig Timeouts, retries and concurrency limit of the calls of a tool
+type ToolLimitsConfig struct {
+	// Timeout of a call attempt, 5s when 0. MCP tools take mcp.timeoutMs first, and 30s when both are 0
+	TimeoutMs      int `yaml:"timeoutMs"`
+	ReadyTimeoutMs int `yaml:"readyTimeoutMs"` // Timeout of a readiness check, 3s when 0
+	// Retries of failed idempotent HTTP calls: GET tools, tools marked idempotent and readiness checks.
+	// Connection errors, 429 and 5xx responses are retried, after RetryIntervalMs doubled on each retry
+	MaxRetryCount   int  `yaml:"maxRetryCount"`
+	RetryIntervalMs int  `yaml:"retryIntervalMs"` // 200ms when 0
+	Idempotent      bool `yaml:"idempotent"`      // The calls of the tool can be retried whatever the method
+	// Calls of the tool in flight at the same time across the process, unlimited when 0
+	MaxConcurrency int `yaml:"maxConcurrency"`
+	// Wait for a free slot before the call is refused as busy, refused at once when 0
+	QueueTimeoutMs int `yaml:"queueTimeoutMs"`
+}
+
+// ToolAuthType Authentication strategy of the requests of a tool
+type ToolAuthType string
+
+const (
+	// ToolAuthPassthrough forwards the token of the end user, the default
+	ToolAuthPassthrough ToolAuthType = "passthrough"
+	// ToolAuthNone sends no credentials
+	ToolAuthNone ToolAuthType = "none"
+	// ToolAuthAPIKey sends a static API key in a header
+	ToolAuthAPIKey ToolAuthType = "apiKey"
+	// ToolAuthBearer sends a bearer token read from an environment variable or a file
+	ToolAuthBearer ToolAuthType = "bearer"
+	// ToolAuthOAuth2 sends a token of the OAuth2 client credentials grant
+	ToolAuthOAuth2 ToolAuthType = "oauth2"
+	// ToolAuthMTLS authenticates with the client certificate only
+	ToolAuthMTLS ToolAuthType = "mtls"
+)
+
+// ToolAuthConfig Authentication of the requests of a tool. Every strategy but passthrough keeps the
+// token of the end user from the tool.
+type ToolAuthConfig struct {
+	Type ToolAuthType `yaml:"type"` // Strategy, passthrough when empty
+	// Header of the API key, X-API-Key when empty
+	Header string `yaml:"header"`
+	// API key, or the environment variable holding it
+	Key    string `yaml:"key"`
+	KeyEnv string `yaml:"keyEnv"`
+	// Environment variable or file holding the bearer token, the file is read on every request
+	TokenEnv  string `yaml:"tokenEnv"`
+	TokenFile string `yaml:"tokenFile"`
+	// OAuth2 client credentials grant of the oauth2 strategy
+	OAuth2 *OAuth2ClientConfig `yaml:"oauth2"`
+	// Client certificate, required by the mtls strategy and allowed with the others
+	TLS *ToolTLSConfig `yaml:"tls"`
+	// Extra headers sent with identity fields of the end user, header name to field name.
+	// Fields: clientId, clientIde, clientVersion, clientOs, userId, userName, email, projectPath,
+	// language, caller, taskId, requestId
+	IdentityHeaders map[string]string `yaml:"identityHeaders"`
+}
+
+// OAuth2ClientConfig OAuth2 client credentials grant, tokens are cached until shortly before they expire
+type OAuth2ClientConfig struct {
+	TokenURL string `yaml:"tokenUrl"`
+	ClientID string `yaml:"clientId"`
+	// Client secret, or the environment variable holding it
+	ClientSecret    string   `yaml:"clientSecret"`
+	ClientSecretEnv string   `yaml:"clientSecretEnv"`
+	Scopes          []string `yaml:"scopes"`
+}
+
+// ToolTLSConfig Client certificate of the tool requests, and the CA verifying the tool server
+type ToolTLSConfig struct {
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+	CAFile   string `yaml:"caFile"` // System roots when empty
+}
+
+// ToolResponseConfig Processing of the tool response before it is given to the model, JSON responses only
+type ToolResponseConfig struct {
+	// gjson path of the fields to keep, e.g. data.results.#.{path,content}, the whole response when empty
+	Select string `yaml:"select"`
+	// Go template rendering the selected value to text, the selected JSON when empty
+	Template string `yaml:"template"`
+	// Go template of the instruction following the results of XML tools, the default instruction when empty
+	FollowUp string `yaml:"followUp"`
+}
+
+// ToolCacheConfig Result cache of a tool, results are kept in Redis by parameters, client and codebase
+type ToolCacheConfig struct {
+	Enabled bool `yaml:"enabled"` // Cache the results of the tool
+	TTLSec  int  `yaml:"ttlSec"`  // Expiration of cached results, 600s when 0
+	// JSON field of the ready response with the index version, "version" when empty.
+	// Results cached before the version changed are no longer served
+	VersionField string `yaml:"versionField"`
+}
+
+// IsMCP reports whether the tool is served by an MCP server
+func (t GenericToolConfig) IsMCP() bool {
+	return t.Type == ToolTypeMCP && t.MCP != nil
+}
+
+// MCPServerConfig MCP server configuration, every tool listed by the server becomes a tool
+type MCPServerConfig struct {
+	Transport  string            `yaml:"transport"`  // stdio or http
+	Command    string            `yaml:"command"`    // Server command of the stdio transport
+	Args       []string          `yaml:"args"`       // Server command arguments
+	Env        []string          `yaml:"env"`        // Extra KEY=VALUE environment of the server command
+	InheritEnv []string          `yaml:"inheritEnv"` // Service environment variables passed besides PATH and HOME
+	URL        string            `yaml:"url"`        // Streamable HTTP endpoint of the http transport
+	Headers    map[string]string `yaml:"headers"`    // Extra headers of the http transport
+	Tools      []string          `yaml:"tools"`      // Tools offered to the model, all listed tools when empty
+	Prefix     string            `yaml:"prefix"`     // Prefix of the tool names, to avoid clashes between servers
+	TimeoutMs  int               `yaml:"timeoutMs"`  // Timeout of a tool call, 30s when 0
 }
 
 // GenericToolEndpoints Tool endpoint configuration
@@ -80,6 +352,25 @@ type GenericToolParameter struct {
 	Default     interface{} `yaml:"default,omitempty"` // Default value (optional)
 	// Parameter source
 	Source ParameterSource `yaml:"source"`
+	// JSON schema of the parameter, it takes precedence over type
+	Schema *ParameterSchema `yaml:"schema,omitempty"`
+}
+
+// ParameterSchema JSON schema of a tool parameter, its nested properties and array items
+type ParameterSchema struct {
+	Type        string                      `yaml:"type"`        // string, integer, number, boolean, array or object
+	Description string                      `yaml:"description"` // Description of nested properties and items
+	Enum        []interface{}               `yaml:"enum"`        // Allowed values
+	Format      string                      `yaml:"format"`      // String format: date-time, date, email, uri or uuid
+	Minimum     *float64                    `yaml:"minimum"`     // Smallest allowed number
+	Maximum     *float64                    `yaml:"maximum"`     // Largest allowed number
+	MinLength   *int                        `yaml:"minLength"`   // Shortest allowed string
+	MaxLength   *int                        `yaml:"maxLength"`   // Longest allowed string
+	Items       *ParameterSchema            `yaml:"items"`       // Schema of array items
+	MinItems    *int                        `yaml:"minItems"`    // Fewest allowed array items
+	MaxItems    *int                        `yaml:"maxItems"`    // Most allowed array items
+	Properties  map[string]*ParameterSchema `yaml:"properties"`  // Schemas of object properties
+	Required    []string                    `yaml:"required"`    // Required object properties
 }
 
 // LogConfig holds logging configuration
@@ -174,6 +465,60 @@ type Config struct {
 
 	// Request verification configuration
 	RequestVerify RequestVerifyConfig `mapstructure:"requestVerify" yaml:"requestVerify"`
+
+	// Responses API conversation state configuration
+	Responses ResponsesConfig `mapstructure:"responses" yaml:"responses"`
+
+	// Per-model circuit breaker configuration
+	CircuitBreaker CircuitBreakerConfig `mapstructure:"circuitBreaker" yaml:"circuitBreaker"`
+
+	// Hedged streaming requests configuration
+	Hedge HedgeConfig `mapstructure:"hedge" yaml:"hedge"`
+
+	// Exact-match response cache configuration
+	ResponseCache ResponseCacheConfig `mapstructure:"responseCache" yaml:"responseCache"`
+
+	// Semantic (near-duplicate) response cache configuration
+	SemanticCache SemanticCacheConfig `mapstructure:"semanticCache" yaml:"semanticCache"`
+
+	// Provider prompt cache breakpoints configuration
+	PromptCache PromptCacheConfig `mapstructure:"promptCache" yaml:"promptCache"`
+
+	// Context-length error recovery configuration
+	ContextRecovery ContextRecoveryConfig `mapstructure:"contextRecovery" yaml:"contextRecovery"`
+
+	// Reasoning content handling configuration
+	Reasoning ReasoningConfig `mapstructure:"reasoning" yaml:"reasoning"`
+
+	// Mid-stream failover continuation configuration
+	StreamContinuation StreamContinuationConfig `mapstructure:"streamContinuation" yaml:"streamContinuation"`
+
+	// Upstream traffic recording and replay configuration
+	Cassette CassetteConfig `mapstructure:"cassette" yaml:"cassette"`
+
+	// Server tool execution configuration
+	ToolExecution ToolExecutionConfig `mapstructure:"toolExecution" yaml:"toolExecution"`
+
+	// Background readiness probing of the server tools
+	ToolReadiness ToolReadinessConfig `mapstructure:"toolReadiness" yaml:"toolReadiness"`
+
+	// Access control of the admin endpoints
+	Admin AdminConfig `mapstructure:"admin" yaml:"admin"`
+}
+
+// LookupModel returns the registry entry of the given model
+func (c *Config) LookupModel(name string) (ModelConfig, bool) {
+	return c.LLM.LookupModel(name)
+}
+
+// IsFuncCallingModel reports whether the model is listed in LLM.FuncCallingModels
+func (c *Config) IsFuncCallingModel(name string) bool {
+	for _, m := range c.LLM.FuncCallingModels {
+		if m == name {
+			return true
+		}
+	}
+	return false
 }
 
 // RouterConfig holds router related configuration
@@ -329,3 +674,288 @@ type RequestVerifyConfig struct {
 	Enabled           bool `yaml:"enabled"`           // Enable request verification
 	EnabledTimeVerify bool `yaml:"enabledTimeVerify"` // Enable timestamp verification
 }
+
+// ResponsesConfig holds Responses API conversation state configuration
+type ResponsesConfig struct {
+	// Store backend, "redis" or "memory"
+	Store string `mapstructure:"store" yaml:"store"`
+	// Conversation state expiration in seconds
+	TTLSec int `mapstructure:"ttlSec" yaml:"ttlSec"`
+	// Max conversations kept by the memory store
+	MaxEntries int `mapstructure:"maxEntries" yaml:"maxEntries"`
+}
+
+// CircuitBreakerConfig holds per-model circuit breaker configuration
+type CircuitBreakerConfig struct {
+	Enabled bool `mapstructure:"enabled" yaml:"enabled"`
+	// Length of the window in which the error rate is measured
+	WindowSec int `mapstructure:"windowSec" yaml:"windowSec"`
+	// Minimum requests in the window before the error rate can trip the breaker
+	MinRequests int `mapstructure:"minRequests" yaml:"minRequests"`
+	// Error rate (0-1) in the window that trips the breaker
+	ErrorRateThreshold float64 `mapstructure:"errorRateThreshold" yaml:"errorRateThreshold"`
+	// Consecutive timeouts that trip the breaker
+	ConsecutiveTimeouts int `mapstructure:"consecutiveTimeouts" yaml:"consecutiveTimeouts"`
+	// How long an open breaker rejects requests before letting probes through
+	OpenDurationSec int `mapstructure:"openDurationSec" yaml:"openDurationSec"`
+	// Concurrent probe requests allowed while half-open
+	HalfOpenMaxRequests int `mapstructure:"halfOpenMaxRequests" yaml:"halfOpenMaxRequests"`
+}
+
+// HedgeConfig holds hedged request configuration for auto mode streaming.
+// When the model has not produced a first token within the hedge delay, the same
+// prompt is also sent to the next model of the degradation order and the first
+// model to produce a token wins.
+type HedgeConfig struct {
+	// Hedge delay for callers without their own delay, 0 disables hedging
+	DelayMs int `mapstructure:"delayMs" yaml:"delayMs"`
+	// Hedge delay per caller (x-caller header), 0 disables hedging for the caller
+	CallerDelayMs map[string]int `mapstructure:"callerDelayMs" yaml:"callerDelayMs"`
+}
+
+// DelayFor returns the hedge delay of the caller, 0 when hedging is disabled
+func (c HedgeConfig) DelayFor(caller string) time.Duration {
+	delayMs := c.DelayMs
+	if d, ok := c.CallerDelayMs[strings.ToLower(caller)]; ok {
+		delayMs = d
+	}
+	if delayMs <= 0 {
+		return 0
+	}
+	return time.Duration(delayMs) * time.Millisecond
+}
+
+// ResponseCacheConfig holds exact-match chat completion response cache configuration
+type ResponseCacheConfig struct {
+	Enabled bool `mapstructure:"enabled" yaml:"enabled"`
+	// Cached response expiration in seconds
+	TTLSec int `mapstructure:"ttlSec" yaml:"ttlSec"`
+	// Only cache requests with temperature 0
+	DeterministicOnly bool `mapstructure:"deterministicOnly" yaml:"deterministicOnly"`
+	// Share cached responses between users, by default each user or client has its own responses
+	ShareAcrossUsers bool `mapstructure:"shareAcrossUsers" yaml:"shareAcrossUsers"`
+}
+
+// SemanticCacheConfig holds near-duplicate chat completion response cache configuration
+type SemanticCacheConfig struct {
+	Enabled bool `mapstructure:"enabled" yaml:"enabled"`
+	// Embedder of the last user message
+	Embedder EmbedderConfig `mapstructure:"embedder" yaml:"embedder"`
+	// Index backend, "redis" or "memory"
+	Store string `mapstructure:"store" yaml:"store"`
+	// Minimum cosine similarity of a cached question to be served
+	SimilarityThreshold float64 `mapstructure:"similarityThreshold" yaml:"similarityThreshold"`
+	// Cached answer expiration in seconds
+	TTLSec int `mapstructure:"ttlSec" yaml:"ttlSec"`
+	// Max cached answers per scope
+	MaxEntries int `mapstructure:"maxEntries" yaml:"maxEntries"`
+	// Share cached answers between users and conversations with the same system prompt,
+	// by default each user or client has its own answers per conversation
+	ShareAcrossUsers bool `mapstructure:"shareAcrossUsers" yaml:"shareAcrossUsers"`
+	// Agents whose requests are served from the cache
+	Agents []string `mapstructure:"agents" yaml:"agents"`
+	// Modes (extra_body.mode) whose requests are served from the cache
+	Modes []string `mapstructure:"modes" yaml:"modes"`
+}
+
+// Applies reports whether requests of the agent or mode are served from the semantic cache
+func (c SemanticCacheConfig) Applies(agent, mode string) bool {
+	for _, a := range c.Agents {
+		if agent != "" && strings.EqualFold(a, agent) {
+			return true
+		}
+	}
+	for _, m := range c.Modes {
+		if mode != "" && strings.EqualFold(m, mode) {
+			return true
+		}
+	}
+	return false
+}
+
+// EmbedderConfig holds text embedder configuration
+type EmbedderConfig struct {
+	// Embedder type, "http" for an OpenAI compatible embeddings endpoint or "hashing" for a local embedder
+	Type string `mapstructure:"type" yaml:"type"`
+	// Embeddings endpoint, e.g. http://host/v1/embeddings
+	Endpoint string `mapstructure:"endpoint" yaml:"endpoint"`
+	ApiToken string `mapstructure:"apiToken" yaml:"apiToken"`
+	Model    string `mapstructure:"model" yaml:"model"`
+	// Vector dimensions of the hashing embedder
+	Dimensions int `mapstructure:"dimensions" yaml:"dimensions"`
+	// Embeddings request timeout in milliseconds
+	TimeoutMs int `mapstructure:"timeoutMs" yaml:"timeoutMs"`
+}
+
+const (
+	// PromptCacheStrategyLastTurns marks the end of the last N stable history turns
+	PromptCacheStrategyLastTurns = "lastTurns"
+	// PromptCacheStrategySystemOnly only marks the system message
+	PromptCacheStrategySystemOnly = "systemOnly"
+)
+
+// PromptCacheConfig holds provider prompt cache breakpoint configuration
+type PromptCacheConfig struct {
+	Enabled bool `mapstructure:"enabled" yaml:"enabled"`
+	// Breakpoint placement strategy, "lastTurns" or "systemOnly"
+	Strategy string `mapstructure:"strategy" yaml:"strategy"`
+	// Number of stable history turns marked by the lastTurns strategy
+	Turns int `mapstructure:"turns" yaml:"turns"`
+	// Max breakpoints per request including existing ones, the provider limit
+	MaxBreakpoints int `mapstructure:"maxBreakpoints" yaml:"maxBreakpoints"`
+}
+
+const (
+	// ShrinkStepDropToolResults replaces the content of older tool results with a placeholder
+	ShrinkStepDropToolResults = "dropToolResults"
+	// ShrinkStepSummarizeHistory replaces older history with a summary of the summary model
+	ShrinkStepSummarizeHistory = "summarizeHistory"
+	// ShrinkStepTruncateUserContent truncates oversized user messages
+	ShrinkStepTruncateUserContent = "truncateUserContent"
+)
+
+// ContextRecoveryConfig holds configuration of the recovery from context-length errors
+type ContextRecoveryConfig struct {
+	Enabled bool `mapstructure:"enabled" yaml:"enabled"`
+	// Default shrink policy
+	Policy ShrinkPolicyConfig `mapstructure:"policy" yaml:"policy"`
+	// Shrink policies by agent name, keys are lowercase
+	Agents map[string]ShrinkPolicyConfig `mapstructure:"agents" yaml:"agents"`
+}
+
+// ShrinkPolicyConfig holds how a prompt is shrunk after a context-length error
+type ShrinkPolicyConfig struct {
+	// Shrink steps applied one per retry, in order
+	Steps []string `mapstructure:"steps" yaml:"steps"`
+	// Most recent messages left untouched by the tool result and summary steps
+	KeepRecentMessages int `mapstructure:"keepRecentMessages" yaml:"keepRecentMessages"`
+	// Characters kept of an oversized user message
+	MaxUserContentChars int `mapstructure:"maxUserContentChars" yaml:"maxUserContentChars"`
+	// Model summarizing older history, defaults to ContextCompressConfig.SummaryModel
+	SummaryModel string `mapstructure:"summaryModel" yaml:"summaryModel"`
+}
+
+// PolicyFor returns the shrink policy of the agent, fields it leaves unset come from the default policy
+func (c ContextRecoveryConfig) PolicyFor(agent string) ShrinkPolicyConfig {
+	policy := c.Policy
+	if agentPolicy, ok := c.Agents[strings.ToLower(agent)]; ok && agent != "" {
+		if len(agentPolicy.Steps) > 0 {
+			policy.Steps = agentPolicy.Steps
+		}
+		if agentPolicy.KeepRecentMessages > 0 {
+			policy.KeepRecentMessages = agentPolicy.KeepRecentMessages
+		}
+		if agentPolicy.MaxUserContentChars > 0 {
+			policy.MaxUserContentChars = agentPolicy.MaxUserContentChars
+		}
+		if agentPolicy.SummaryModel != "" {
+			policy.SummaryModel = agentPolicy.SummaryModel
+		}
+	}
+
+	if len(policy.Steps) == 0 {
+		policy.Steps = []string{ShrinkStepDropToolResults, ShrinkStepSummarizeHistory, ShrinkStepTruncateUserContent}
+	}
+	if policy.KeepRecentMessages <= 0 {
+		policy.KeepRecentMessages = 4
+	}
+	if policy.MaxUserContentChars <= 0 {
+		policy.MaxUserContentChars = 20000
+	}
+	return policy
+}
+
+const (
+	// ReasoningModePassthrough forwards reasoning as the upstream returned it
+	ReasoningModePassthrough = "passthrough"
+	// ReasoningModeStrip removes reasoning from responses
+	ReasoningModeStrip = "strip"
+	// ReasoningModeConvert moves inline <think> reasoning into the reasoning_content field
+	ReasoningModeConvert = "convert"
+)
+
+// ReasoningConfig holds how reasoning content of model responses is handled
+type ReasoningConfig struct {
+	// Reasoning handling for clients without their own mode: passthrough, strip or convert
+	Mode string `mapstructure:"mode" yaml:"mode"`
+	// Reasoning handling per client IDE (zgsm-client-ide header), keys are lowercase
+	ClientModes map[string]string `mapstructure:"clientModes" yaml:"clientModes"`
+	// reasoning_effort sent per prompt mode when the request sets none, keys are lowercase
+	Efforts map[string]string `mapstructure:"efforts" yaml:"efforts"`
+}
+
+// ModeFor returns the reasoning handling of the client IDE
+func (c ReasoningConfig) ModeFor(clientIDE string) string {
+	if mode, ok := c.ClientModes[strings.ToLower(clientIDE)]; ok && clientIDE != "" {
+		return mode
+	}
+	if c.Mode == "" {
+		return ReasoningModePassthrough
+	}
+	return c.Mode
+}
+
+// EffortFor returns the reasoning_effort of the prompt mode, empty when none is configured
+func (c ReasoningConfig) EffortFor(promptMode string) string {
+	return c.Efforts[strings.ToLower(promptMode)]
+}
+
+// StreamContinuationConfig holds how streams that fail after the first token was sent are continued
+type StreamContinuationConfig struct {
+	Enabled bool `mapstructure:"enabled" yaml:"enabled"`
+	// Continuation attempts, on the failed model first, then on the next models of the degradation order
+	MaxAttempts int `mapstructure:"maxAttempts" yaml:"maxAttempts"`
+}
+
+// Cassette modes
+const (
+	CassetteModeOff    = "off"
+	CassetteModeRecord = "record"
+	CassetteModeReplay = "replay"
+)
+
+// CassetteConfig holds how upstream LLM and tool HTTP traffic is recorded to and replayed from cassette files
+type CassetteConfig struct {
+	// off, record or replay
+	Mode string `mapstructure:"mode" yaml:"mode"`
+	// Directory of the cassette files
+	Dir string `mapstructure:"dir" yaml:"dir"`
+	// Allow requests to set the mode with the x-cassette-mode header
+	AllowHeader bool `mapstructure:"allowHeader" yaml:"allowHeader"`
+	// Top-level body fields and query parameters left out of the request match, e.g. volatile ids
+	IgnoreFields []string `mapstructure:"ignoreFields" yaml:"ignoreFields"`
+	// Replay response chunks with their recorded delays, otherwise as fast as they are read
+	ReplayTiming bool `mapstructure:"replayTiming" yaml:"replayTiming"`
+}
+
+// ToolExecutionConfig holds how the server tools invoked by the model are executed
+type ToolExecutionConfig struct {
+	// Tools of one model turn run concurrently, at most this many at a time per request.
+	// 1 runs them one after the other
+	MaxConcurrency int `mapstructure:"maxConcurrency" yaml:"maxConcurrency"`
+}
+
+// ToolReadinessConfig holds how the readiness of the server tools is probed in the background.
+// Requests read the cached status instead of checking every tool before building the prompt
+type ToolReadinessConfig struct {
+	Enabled bool `mapstructure:"enabled" yaml:"enabled"`
+	// Interval between two probes of a tool for a client and codebase
+	IntervalSec int `mapstructure:"intervalSec" yaml:"intervalSec"`
+	// A probed status is served this long, older ones are checked again by the request
+	TTLSec int `mapstructure:"ttlSec" yaml:"ttlSec"`
+	// Longest wait of a request on a ready check, the last known status is used after it
+	BudgetMs int `mapstructure:"budgetMs" yaml:"budgetMs"`
+	// Clients and codebases without requests for this long are no longer probed
+	IdleSec int `mapstructure:"idleSec" yaml:"idleSec"`
+	// Ready checks running at the same time
+	MaxConcurrency int `mapstructure:"maxConcurrency" yaml:"maxConcurrency"`
+}
+
+// AdminConfig holds who may call the admin endpoints. The endpoints refuse every request when neither
+// a token nor a user is configured
+type AdminConfig struct {
+	// Shared token accepted in the x-admin-token header
+	Token string `mapstructure:"token" yaml:"token"`
+	// Users allowed without the token, matched against the user id, email or user name of the identity
+	Users []string `mapstructure:"users" yaml:"users"`
+}
@@ -32,6 +32,9 @@ func MustLoadConfig(configPath string) Config {
 	if err != nil {
 		panic("Failed to load config: " + err.Error())
 	}
+	if err := c.LLM.Validate(); err != nil {
+		panic("Failed to load config: " + err.Error())
+	}
 
 	// Apply defaults: if fallbackModelName not set, use the first candidate
 	if c != nil && c.Router != nil && c.Router.Semantic.Routing.FallbackModelName == "" {
@@ -116,6 +119,133 @@ func MustLoadConfig(configPath string) Config {
 		}
 	}
 
+	// Apply LLM transport pool defaults
+	if c != nil {
+		c.LLM.Transport = c.LLM.Transport.WithDefaults()
+	}
+
+	// Apply responses API conversation state defaults
+	if c != nil {
+		if c.Responses.Store == "" {
+			c.Responses.Store = "redis"
+		}
+		if c.Responses.TTLSec <= 0 {
+			c.Responses.TTLSec = 86400
+		}
+		if c.Responses.MaxEntries <= 0 {
+			c.Responses.MaxEntries = 10000
+		}
+	}
+
+	// Apply response cache defaults
+	if c != nil && c.ResponseCache.TTLSec <= 0 {
+		c.ResponseCache.TTLSec = 3600
+	}
+
+	// Apply prompt cache defaults
+	if c != nil {
+		if c.PromptCache.Strategy == "" {
+			c.PromptCache.Strategy = PromptCacheStrategyLastTurns
+		}
+		if c.PromptCache.Turns <= 0 {
+			c.PromptCache.Turns = 2
+		}
+		if c.PromptCache.MaxBreakpoints <= 0 {
+			c.PromptCache.MaxBreakpoints = 4
+		}
+	}
+
+	// Apply reasoning defaults
+	if c != nil && c.Reasoning.Mode == "" {
+		c.Reasoning.Mode = ReasoningModePassthrough
+	}
+
+	// Apply stream continuation defaults
+	if c != nil && c.StreamContinuation.MaxAttempts <= 0 {
+		c.StreamContinuation.MaxAttempts = 2
+	}
+
+	// Apply cassette defaults
+	if c != nil {
+		if c.Cassette.Mode == "" {
+			c.Cassette.Mode = CassetteModeOff
+		}
+		if c.Cassette.Dir == "" {
+			c.Cassette.Dir = "cassettes"
+		}
+	}
+
+	// Apply semantic cache defaults
+	if c != nil {
+		if c.SemanticCache.Store == "" {
+			c.SemanticCache.Store = "redis"
+		}
+		if c.SemanticCache.SimilarityThreshold <= 0 {
+			c.SemanticCache.SimilarityThreshold = 0.92
+		}
+		if c.SemanticCache.TTLSec <= 0 {
+			c.SemanticCache.TTLSec = 86400
+		}
+		if c.SemanticCache.MaxEntries <= 0 {
+			c.SemanticCache.MaxEntries = 1000
+		}
+		if c.SemanticCache.Embedder.Type == "" {
+			c.SemanticCache.Embedder.Type = "hashing"
+		}
+		if c.SemanticCache.Embedder.Dimensions <= 0 {
+			c.SemanticCache.Embedder.Dimensions = 512
+		}
+		if c.SemanticCache.Embedder.TimeoutMs <= 0 {
+			c.SemanticCache.Embedder.TimeoutMs = 3000
+		}
+	}
+
+	// Apply circuit breaker defaults
+	if c != nil {
+		if c.CircuitBreaker.WindowSec <= 0 {
+			c.CircuitBreaker.WindowSec = 60
+		}
+		if c.CircuitBreaker.MinRequests <= 0 {
+			c.CircuitBreaker.MinRequests = 10
+		}
+		if c.CircuitBreaker.ErrorRateThreshold <= 0 || c.CircuitBreaker.ErrorRateThreshold > 1 {
+			c.CircuitBreaker.ErrorRateThreshold = 0.5
+		}
+		if c.CircuitBreaker.ConsecutiveTimeouts <= 0 {
+			c.CircuitBreaker.ConsecutiveTimeouts = 3
+		}
+		if c.CircuitBreaker.OpenDurationSec <= 0 {
+			c.CircuitBreaker.OpenDurationSec = 30
+		}
+		if c.CircuitBreaker.HalfOpenMaxRequests <= 0 {
+			c.CircuitBreaker.HalfOpenMaxRequests = 1
+		}
+	}
+
+	// Apply tool execution defaults
+	if c != nil && c.ToolExecution.MaxConcurrency <= 0 {
+		c.ToolExecution.MaxConcurrency = 4
+	}
+
+	// Apply tool readiness defaults
+	if c != nil {
+		if c.ToolReadiness.IntervalSec <= 0 {
+			c.ToolReadiness.IntervalSec = 30
+		}
+		if c.ToolReadiness.TTLSec <= 0 {
+			c.ToolReadiness.TTLSec = 90
+		}
+		if c.ToolReadiness.BudgetMs <= 0 {
+			c.ToolReadiness.BudgetMs = 300
+		}
+		if c.ToolReadiness.IdleSec <= 0 {
+			c.ToolReadiness.IdleSec = 1800
+		}
+		if c.ToolReadiness.MaxConcurrency <= 0 {
+			c.ToolReadiness.MaxConcurrency = 8
+		}
+	}
+
 	// Apply timeout and retry defaults for routing (model degradation scenarios)
 	ApplyRouterDefaults(c)
 
@@ -0,0 +1,218 @@
+package functions
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/zgsm-ai/chat-rag/internal/client"
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/logger"
+)
+
+const (
+	// Tools of reachable MCP servers are listed again after this interval
+	mcpToolsRefreshInterval = 5 * time.Minute
+	// Unreachable MCP servers are tried again after this interval
+	mcpToolsRetryInterval = 30 * time.Second
+	mcpListTimeout        = 10 * time.Second
+)
+
+// mcpServerTools Tools listed by an MCP server
+type mcpServerTools struct {
+	tools      []config.GenericToolConfig
+	listedAt   time.Time
+	failed     bool
+	refreshing bool
+}
+
+// toolConfigs Get the configuration of every tool, MCP servers are replaced by the tools they list
+func (e *GenericToolExecutor) toolConfigs() []config.GenericToolConfig {
+	e.refreshMCPTools()
+
+	e.mcpMutex.RLock()
+	defer e.mcpMutex.RUnlock()
+	tools := make([]config.GenericToolConfig, 0, len(e.toolConfig.GenericTools))
+	for _, toolConfig := range e.toolConfig.GenericTools {
+		if !toolConfig.IsMCP() {
+			tools = append(tools, toolConfig)
+			continue
+		}
+		if server, exists := e.mcpTools[toolConfig.Name]; exists {
+			tools = append(tools, server.tools...)
+		}
+	}
+	return tools
+}
+
+// refreshMCPTools List the tools of the MCP servers in the background, when they were never listed
+// or the last listing is outdated
+func (e *GenericToolExecutor) refreshMCPTools() {
+	now := time.Now()
+	e.mcpMutex.Lock()
+	defer e.mcpMutex.Unlock()
+	for _, toolConfig := range e.toolConfig.GenericTools {
+		if !toolConfig.IsMCP() {
+			continue
+		}
+		server, exists := e.mcpTools[toolConfig.Name]
+		if !exists {
+			server = &mcpServerTools{}
+			e.mcpTools[toolConfig.Name] = server
+		}
+		interval := mcpToolsRefreshInterval
+		if server.failed {
+			interval = mcpToolsRetryInterval
+		}
+		if server.refreshing || (!server.listedAt.IsZero() && now.Sub(server.listedAt) < interval) {
+			continue
+		}
+
+		server.refreshing = true
+		go e.listMCPTools(toolConfig)
+	}
+}
+
+// listMCPTools List the tools of the MCP server
+func (e *GenericToolExecutor) listMCPTools(serverConfig config.GenericToolConfig) {
+	ctx, cancel := context.WithTimeout(context.Background(), mcpListTimeout)
+	defer cancel()
+	listed, err := e.clientFactory.MCPServer(serverConfig).ListTools(ctx)
+
+	e.mcpMutex.Lock()
+	defer e.mcpMutex.Unlock()
+	server := e.mcpTools[serverConfig.Name]
+	server.refreshing = false
+	server.listedAt = time.Now()
+	server.failed = err != nil
+	if err != nil {
+		// Tools listed before stay available, the server may be back by the time they are called
+		logger.Warn("failed to list mcp tools", zap.String("server", serverConfig.Name), zap.Error(err))
+		return
+	}
+
+	server.tools = mcpToolConfigs(serverConfig, listed)
+	names := make([]string, 0, len(server.tools))
+	for _, tool := range server.tools {
+		names = append(names, tool.Name)
+	}
+	logger.Info("mcp tools listed", zap.String("server", serverConfig.Name), zap.Strings("tools", names))
+}
+
+// mcpToolConfigs Convert the tools listed by the MCP server to tool configurations
+func mcpToolConfigs(serverConfig config.GenericToolConfig, listed []client.MCPTool) []config.GenericToolConfig {
+	tools := make([]config.GenericToolConfig, 0, len(listed))
+	for _, tool := range listed {
+		if len(serverConfig.MCP.Tools) > 0 && !slices.Contains(serverConfig.MCP.Tools, tool.Name) {
+			continue
+		}
+
+		name := serverConfig.MCP.Prefix + tool.Name
+		params := mcpToolParameters(tool.InputSchema)
+		toolConfig := config.GenericToolConfig{
+			Name:        name,
+			Description: xmlToolDescription(name, tool.Description, params),
+			Capability:  fmt.Sprintf("- You can use the %s tool: %s\n", name, firstLine(tool.Description)),
+			Parameters:  params,
+			Type:        config.ToolTypeMCP,
+			MCP:         serverConfig.MCP,
+			Cache:       serverConfig.Cache,
+			Response:    serverConfig.Response,
+		}
+		// Capability and rules of the server are given once, with its first tool
+		if serverConfig.Capability != "" {
+			toolConfig.Capability = ""
+		}
+		if len(tools) == 0 {
+			toolConfig.Capability += serverConfig.Capability
+			toolConfig.Rule = serverConfig.Rule
+		}
+		tools = append(tools, toolConfig)
+	}
+	return tools
+}
+
+// mcpToolParameters Convert the input schema of an MCP tool to parameters given by the LLM
+func mcpToolParameters(schema client.MCPInputSchema) []config.GenericToolParameter {
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	params := make([]config.GenericToolParameter, 0, len(names))
+	for _, name := range names {
+		property := schema.Properties[name]
+		params = append(params, config.GenericToolParameter{
+			Name:        name,
+			Type:        string(mcpParameterType(property.SchemaType())),
+			Description: property.Description,
+			Required:    slices.Contains(schema.Required, name),
+			Default:     property.Default,
+			Source:      config.ParameterSourceLLM,
+			Schema:      mcpParameterSchema(property),
+		})
+	}
+	return params
+}
+
+// mcpParameterType Convert a JSON schema type to a tool parameter type
+func mcpParameterType(schemaType string) config.ParameterType {
+	switch schemaType {
+	case "integer":
+		return config.ParameterTypeInteger
+	case "number":
+		return config.ParameterTypeFloat
+	case "boolean":
+		return config.ParameterTypeBoolean
+	case "array":
+		return config.ParameterTypeArray
+	case "object":
+		return config.ParameterTypeObject
+	default:
+		return config.ParameterTypeString
+	}
+}
+
+// mcpParameterSchema Convert the JSON schema of an MCP tool argument
+func mcpParameterSchema(property client.MCPProperty) *config.ParameterSchema {
+	schema := &config.ParameterSchema{
+		Type:        property.SchemaType(),
+		Description: property.Description,
+		Enum:        property.Enum,
+		Format:      property.Format,
+		Minimum:     property.Minimum,
+		Maximum:     property.Maximum,
+		MinLength:   property.MinLength,
+		MaxLength:   property.MaxLength,
+		MinItems:    property.MinItems,
+		MaxItems:    property.MaxItems,
+		Required:    property.Required,
+	}
+	if property.Items != nil {
+		schema.Items = mcpParameterSchema(*property.Items)
+	}
+	if len(property.Properties) > 0 {
+		schema.Properties = make(map[string]*config.ParameterSchema, len(property.Properties))
+		for name, nested := range property.Properties {
+			schema.Properties[name] = mcpParameterSchema(nested)
+		}
+	}
+	return schema
+}
+
+// firstLine Get the first line of the text
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	return line
+}
+
+// Close Close the connections to the MCP servers
+func (e *GenericToolExecutor) Close() error {
+	return e.clientFactory.Close()
+}
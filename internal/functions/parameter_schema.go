@@ -0,0 +1,463 @@
+package functions
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+// parameterSchema Get the JSON schema of the parameter, parameters without one get the schema of their type
+func parameterSchema(param config.GenericToolParameter) *config.ParameterSchema {
+	if param.Schema != nil {
+		if param.Schema.Type != "" {
+			return param.Schema
+		}
+		schema := *param.Schema
+		schema.Type = jsonSchemaType(param.Type)
+		return &schema
+	}
+
+	schema := &config.ParameterSchema{Type: jsonSchemaType(param.Type)}
+	if schema.Type == string(config.ParameterTypeArray) {
+		schema.Items = &config.ParameterSchema{Type: string(config.ParameterTypeString)}
+	}
+	return schema
+}
+
+// jsonSchemaType Convert a tool parameter type to its JSON schema type
+func jsonSchemaType(paramType string) string {
+	switch config.ParameterType(strings.ToLower(paramType)) {
+	case config.ParameterTypeInteger, config.ParameterTypeBoolean, config.ParameterTypeArray,
+		config.ParameterTypeNumber, config.ParameterTypeObject:
+		return strings.ToLower(paramType)
+	case config.ParameterTypeFloat:
+		return string(config.ParameterTypeNumber)
+	default:
+		return string(config.ParameterTypeString)
+	}
+}
+
+// convertSchemaValue Convert a text value to the type of the schema. Arrays and objects are given as JSON,
+// arrays may also be given one item per line.
+func convertSchemaValue(value string, schema *config.ParameterSchema) (interface{}, error) {
+	trimmed := strings.TrimSpace(value)
+	switch config.ParameterType(schema.Type) {
+	case config.ParameterTypeInteger:
+		intValue, err := strconv.Atoi(trimmed)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert %s to integer: %w", value, err)
+		}
+		return intValue, nil
+	case config.ParameterTypeNumber:
+		floatValue, err := strconv.ParseFloat(trimmed, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert %s to number: %w", value, err)
+		}
+		return floatValue, nil
+	case config.ParameterTypeBoolean:
+		boolValue, err := strconv.ParseBool(trimmed)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert %s to boolean: %w", value, err)
+		}
+		return boolValue, nil
+	case config.ParameterTypeArray:
+		items := []interface{}{}
+		if strings.HasPrefix(trimmed, "[") {
+			if err := json.Unmarshal([]byte(trimmed), &items); err != nil {
+				return nil, fmt.Errorf("cannot convert %s to array: %w", value, err)
+			}
+		} else {
+			for _, line := range strings.Split(trimmed, "\n") {
+				if line = strings.TrimSpace(line); line != "" {
+					items = append(items, line)
+				}
+			}
+		}
+		return coerceSchemaValue(items, schema)
+	case config.ParameterTypeObject:
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(trimmed), &fields); err != nil {
+			return nil, fmt.Errorf("cannot convert %s to object: %w", value, err)
+		}
+		return coerceSchemaValue(fields, schema)
+	default:
+		return value, nil
+	}
+}
+
+// coerceSchemaValue Convert a decoded JSON value to the type of the schema. Text is converted like XML values,
+// integral numbers become integers and arrays of strings become string slices. Values of another type are
+// left for validation to report.
+func coerceSchemaValue(value interface{}, schema *config.ParameterSchema) (interface{}, error) {
+	if schema == nil || value == nil {
+		return value, nil
+	}
+	if text, ok := value.(string); ok && schema.Type != string(config.ParameterTypeString) {
+		return convertSchemaValue(text, schema)
+	}
+
+	switch config.ParameterType(schema.Type) {
+	case config.ParameterTypeString:
+		return jsonText(value)
+	case config.ParameterTypeInteger:
+		if n, ok := value.(float64); ok && n == math.Trunc(n) {
+			return int(n), nil
+		}
+	case config.ParameterTypeArray:
+		items, ok := value.([]interface{})
+		if !ok {
+			return value, nil
+		}
+		coerced := make([]interface{}, len(items))
+		for i, item := range items {
+			var err error
+			if coerced[i], err = coerceSchemaValue(item, schema.Items); err != nil {
+				return nil, fmt.Errorf("item %d: %w", i, err)
+			}
+		}
+		if schema.Items == nil || schema.Items.Type != string(config.ParameterTypeString) {
+			return coerced, nil
+		}
+		values := make([]string, 0, len(coerced))
+		for _, item := range coerced {
+			text, _ := jsonText(item)
+			values = append(values, text)
+		}
+		return values, nil
+	case config.ParameterTypeObject:
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return value, nil
+		}
+		for name, propertySchema := range schema.Properties {
+			if field, exists := fields[name]; exists {
+				coerced, err := coerceSchemaValue(field, propertySchema)
+				if err != nil {
+					return nil, fmt.Errorf("property %s: %w", name, err)
+				}
+				fields[name] = coerced
+			}
+		}
+	}
+	return value, nil
+}
+
+// jsonText Get a JSON value as text, numbers without exponent
+func jsonText(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+}
+
+// validateSchemaValue Validate the value against the schema
+func validateSchemaValue(value interface{}, schema *config.ParameterSchema) error {
+	if schema == nil {
+		return nil
+	}
+
+	switch config.ParameterType(schema.Type) {
+	case config.ParameterTypeString:
+		text, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("expected string, got %T", value)
+		}
+		length := utf8.RuneCountInString(text)
+		if schema.MinLength != nil && length < *schema.MinLength {
+			return fmt.Errorf("length %d is less than %d", length, *schema.MinLength)
+		}
+		if schema.MaxLength != nil && length > *schema.MaxLength {
+			return fmt.Errorf("length %d is greater than %d", length, *schema.MaxLength)
+		}
+		if err := validateFormat(text, schema.Format); err != nil {
+			return err
+		}
+	case config.ParameterTypeInteger:
+		n, ok := numberValue(value)
+		if !ok || n != math.Trunc(n) {
+			return fmt.Errorf("expected integer, got %T", value)
+		}
+		if err := validateRange(n, schema); err != nil {
+			return err
+		}
+	case config.ParameterTypeNumber:
+		n, ok := numberValue(value)
+		if !ok {
+			return fmt.Errorf("expected number, got %T", value)
+		}
+		if err := validateRange(n, schema); err != nil {
+			return err
+		}
+	case config.ParameterTypeBoolean:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("expected boolean, got %T", value)
+		}
+	case config.ParameterTypeArray:
+		items := reflect.ValueOf(value)
+		if value == nil || items.Kind() != reflect.Slice {
+			return fmt.Errorf("expected array, got %T", value)
+		}
+		if schema.MinItems != nil && items.Len() < *schema.MinItems {
+			return fmt.Errorf("%d items are fewer than %d", items.Len(), *schema.MinItems)
+		}
+		if schema.MaxItems != nil && items.Len() > *schema.MaxItems {
+			return fmt.Errorf("%d items are more than %d", items.Len(), *schema.MaxItems)
+		}
+		for i := 0; i < items.Len(); i++ {
+			if err := validateSchemaValue(items.Index(i).Interface(), schema.Items); err != nil {
+				return fmt.Errorf("item %d: %w", i, err)
+			}
+		}
+	case config.ParameterTypeObject:
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected object, got %T", value)
+		}
+		for _, name := range schema.Required {
+			if _, exists := fields[name]; !exists {
+				return fmt.Errorf("required property %s is missing", name)
+			}
+		}
+		for _, name := range sortedPropertyNames(schema) {
+			if field, exists := fields[name]; exists {
+				if err := validateSchemaValue(field, schema.Properties[name]); err != nil {
+					return fmt.Errorf("property %s: %w", name, err)
+				}
+			}
+		}
+	}
+
+	if len(schema.Enum) > 0 && !enumContains(schema.Enum, value) {
+		return fmt.Errorf("%v is not one of %v", value, schema.Enum)
+	}
+	return nil
+}
+
+// validateRange Validate the number against the minimum and maximum of the schema
+func validateRange(n float64, schema *config.ParameterSchema) error {
+	if schema.Minimum != nil && n < *schema.Minimum {
+		return fmt.Errorf("%v is less than the minimum %v", n, *schema.Minimum)
+	}
+	if schema.Maximum != nil && n > *schema.Maximum {
+		return fmt.Errorf("%v is greater than the maximum %v", n, *schema.Maximum)
+	}
+	return nil
+}
+
+// validateFormat Validate the string format, unknown formats are not checked
+func validateFormat(text string, format string) error {
+	var err error
+	switch format {
+	case "date-time":
+		_, err = time.Parse(time.RFC3339, text)
+	case "date":
+		_, err = time.Parse(time.DateOnly, text)
+	case "email":
+		_, err = mail.ParseAddress(text)
+	case "uri":
+		var u *url.URL
+		if u, err = url.Parse(text); err == nil && u.Scheme == "" {
+			err = fmt.Errorf("missing scheme")
+		}
+	case "uuid":
+		_, err = uuid.Parse(text)
+	}
+	if err != nil {
+		return fmt.Errorf("%q is not a valid %s: %w", text, format, err)
+	}
+	return nil
+}
+
+// numberValue Get the value as a number, when it is one
+func numberValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// enumContains Report whether the value is one of the allowed values, numbers are compared by value
+func enumContains(enum []interface{}, value interface{}) bool {
+	n, isNumber := numberValue(value)
+	for _, allowed := range enum {
+		if m, ok := numberValue(allowed); ok && isNumber {
+			if m == n {
+				return true
+			}
+			continue
+		}
+		if fmt.Sprint(allowed) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedPropertyNames Get the property names of an object schema in a stable order
+func sortedPropertyNames(schema *config.ParameterSchema) []string {
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// schemaProperty Convert the schema to the JSON schema of a function calling parameter
+func schemaProperty(schema *config.ParameterSchema) types.PropertyDetails {
+	property := types.PropertyDetails{
+		Type:        jsonSchemaType(schema.Type),
+		Description: schema.Description,
+		Enum:        schema.Enum,
+		Format:      schema.Format,
+		Minimum:     schema.Minimum,
+		Maximum:     schema.Maximum,
+		MinLength:   schema.MinLength,
+		MaxLength:   schema.MaxLength,
+		MinItems:    schema.MinItems,
+		MaxItems:    schema.MaxItems,
+		Required:    schema.Required,
+	}
+	if schema.Items != nil {
+		items := schemaProperty(schema.Items)
+		property.Items = &items
+	}
+	if len(schema.Properties) > 0 {
+		property.Properties = make(map[string]types.PropertyDetails, len(schema.Properties))
+		for name, propertySchema := range schema.Properties {
+			property.Properties[name] = schemaProperty(propertySchema)
+		}
+	}
+	return property
+}
+
+// schemaSummary Describe the schema to the model of XML tools, arrays and objects are expected as JSON
+func schemaSummary(schema *config.ParameterSchema) string {
+	var parts []string
+	switch config.ParameterType(schema.Type) {
+	case config.ParameterTypeArray:
+		items := string(config.ParameterTypeString)
+		if schema.Items != nil {
+			items = schemaSummary(schema.Items)
+		}
+		parts = append(parts, "JSON array of "+items)
+		if schema.MinItems != nil {
+			parts = append(parts, fmt.Sprintf("at least %d items", *schema.MinItems))
+		}
+		if schema.MaxItems != nil {
+			parts = append(parts, fmt.Sprintf("at most %d items", *schema.MaxItems))
+		}
+	case config.ParameterTypeObject:
+		fields := make([]string, 0, len(schema.Properties))
+		for _, name := range sortedPropertyNames(schema) {
+			field := fmt.Sprintf("%s (%s", name, schemaSummary(schema.Properties[name]))
+			for _, required := range schema.Required {
+				if required == name {
+					field += ", required"
+				}
+			}
+			fields = append(fields, field+")")
+		}
+		if len(fields) == 0 {
+			parts = append(parts, "JSON object")
+		} else {
+			parts = append(parts, "JSON object with "+strings.Join(fields, "; "))
+		}
+	default:
+		parts = append(parts, jsonSchemaType(schema.Type))
+	}
+
+	if schema.Format != "" {
+		parts = append(parts, "format "+schema.Format)
+	}
+	if len(schema.Enum) > 0 {
+		values := make([]string, 0, len(schema.Enum))
+		for _, value := range schema.Enum {
+			data, _ := json.Marshal(value)
+			values = append(values, string(data))
+		}
+		parts = append(parts, "one of "+strings.Join(values, ", "))
+	}
+	if schema.Minimum != nil {
+		parts = append(parts, fmt.Sprintf("minimum %v", *schema.Minimum))
+	}
+	if schema.Maximum != nil {
+		parts = append(parts, fmt.Sprintf("maximum %v", *schema.Maximum))
+	}
+	if schema.MinLength != nil {
+		parts = append(parts, fmt.Sprintf("at least %d characters", *schema.MinLength))
+	}
+	if schema.MaxLength != nil {
+		parts = append(parts, fmt.Sprintf("at most %d characters", *schema.MaxLength))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// xmlToolDescription Build the XML tool description from the parameters given by the LLM
+func xmlToolDescription(name string, description string, params []config.GenericToolParameter) string {
+	llmParams := make([]config.GenericToolParameter, 0, len(params))
+	for _, param := range params {
+		if param.Source == config.ParameterSourceLLM {
+			llmParams = append(llmParams, param)
+		}
+	}
+
+	var b strings.Builder
+	if description = strings.TrimSpace(description); description != "" {
+		fmt.Fprintf(&b, "Description: %s\n", description)
+	}
+	if len(llmParams) > 0 {
+		b.WriteString("Parameters:\n")
+		for _, param := range llmParams {
+			requirement := "optional"
+			if param.Required {
+				requirement = "required"
+			}
+			line := fmt.Sprintf("- %s: (%s, %s) %s", param.Name, requirement,
+				schemaSummary(parameterSchema(param)), param.Description)
+			b.WriteString(strings.TrimRight(line, " ") + "\n")
+		}
+	}
+	fmt.Fprintf(&b, "Usage:\n<%s>\n", name)
+	for _, param := range llmParams {
+		optional := ""
+		if !param.Required {
+			optional = " (optional)"
+		}
+		fmt.Fprintf(&b, "<%s>%s here%s</%s>\n", param.Name, param.Name, optional, param.Name)
+	}
+	fmt.Fprintf(&b, "</%s>", name)
+	return b.String()
+}
@@ -0,0 +1,410 @@
+package functions
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zgsm-ai/chat-rag/internal/config"
+)
+
+func intPtr(v int) *int {
+	return &v
+}
+
+// searchToolConfig returns the configuration of a single tool named search with the parameters
+func searchToolConfig(params ...config.GenericToolParameter) config.ToolConfig {
+	return config.ToolConfig{GenericTools: []config.GenericToolConfig{{Name: "search", Parameters: params}}}
+}
+
+func TestExtractParameters_Required(t *testing.T) {
+	query := config.GenericToolParameter{Name: "query", Type: "string", Source: config.ParameterSourceLLM, Required: true}
+	limit := config.GenericToolParameter{Name: "limit", Type: "integer", Source: config.ParameterSourceLLM, Default: 10}
+	codebase := config.GenericToolParameter{Name: "codebase", Source: config.ParameterSourceManual, Default: "/repo"}
+
+	tests := []struct {
+		name    string
+		params  []config.GenericToolParameter
+		content string
+		want    map[string]interface{}
+		wantErr string
+	}{
+		{
+			name:    "xml all given",
+			params:  []config.GenericToolParameter{query, limit, codebase},
+			content: "<search><query>redis</query><limit>5</limit></search>",
+			want:    map[string]interface{}{"query": "redis", "limit": 5, "codebase": "/repo"},
+		},
+		{
+			name:    "xml optional missing takes its default",
+			params:  []config.GenericToolParameter{query, limit},
+			content: "<search><query>redis</query></search>",
+			want:    map[string]interface{}{"query": "redis", "limit": 10},
+		},
+		{
+			name:    "xml optional missing without default is left out",
+			params:  []config.GenericToolParameter{query, {Name: "path", Source: config.ParameterSourceLLM}},
+			content: "<search><query>redis</query></search>",
+			want:    map[string]interface{}{"query": "redis"},
+		},
+		{
+			name:    "xml required missing",
+			params:  []config.GenericToolParameter{query, limit},
+			content: "<search><limit>5</limit></search>",
+			wantErr: "required parameter query not found",
+		},
+		{
+			name:    "xml tool tag missing",
+			params:  []config.GenericToolParameter{query},
+			content: "<other><query>redis</query></other>",
+			wantErr: "failed to extract tool content",
+		},
+		{
+			name:    "json all given",
+			params:  []config.GenericToolParameter{query, limit},
+			content: `{"query": "redis", "limit": 5}`,
+			want:    map[string]interface{}{"query": "redis", "limit": 5},
+		},
+		{
+			name:    "json required missing",
+			params:  []config.GenericToolParameter{query, limit},
+			content: `{"limit": 5}`,
+			wantErr: "required parameter query not found",
+		},
+		{
+			name:    "json required null",
+			params:  []config.GenericToolParameter{query},
+			content: `{"query": null}`,
+			wantErr: "required parameter query not found",
+		},
+		{
+			name:    "manual required without default",
+			params:  []config.GenericToolParameter{query, {Name: "token", Source: config.ParameterSourceManual, Required: true}},
+			content: `{"query": "redis"}`,
+			wantErr: "required manual parameter token must have a default value",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := NewGenericParameterParser().ExtractParameters(searchToolConfig(tt.params...), "search", tt.content)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, params)
+		})
+	}
+}
+
+func TestValidateParameters_Required(t *testing.T) {
+	toolConfig := searchToolConfig(
+		config.GenericToolParameter{Name: "query", Type: "string", Source: config.ParameterSourceLLM, Required: true},
+		config.GenericToolParameter{Name: "limit", Type: "integer", Source: config.ParameterSourceLLM},
+	).GenericTools[0]
+
+	tests := []struct {
+		name    string
+		params  map[string]interface{}
+		wantErr string
+	}{
+		{"all given", map[string]interface{}{"query": "redis", "limit": 5}, ""},
+		{"optional missing", map[string]interface{}{"query": "redis"}, ""},
+		{"required missing", map[string]interface{}{"limit": 5}, "required parameter query is missing"},
+		{"wrong type", map[string]interface{}{"query": "redis", "limit": "five"}, "parameter limit validation failed: expected integer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewGenericParameterParser().ValidateParameters(toolConfig, tt.params)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConvertParameter_Coercion(t *testing.T) {
+	schema := func(s config.ParameterSchema) config.GenericToolParameter {
+		return config.GenericToolParameter{Name: "value", Schema: &s}
+	}
+
+	tests := []struct {
+		name    string
+		param   config.GenericToolParameter
+		value   interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{"xml integer", schema(config.ParameterSchema{Type: "integer"}), " 42 ", 42, false},
+		{"xml invalid integer", schema(config.ParameterSchema{Type: "integer"}), "forty", nil, true},
+		{"xml number", schema(config.ParameterSchema{Type: "number"}), "2.5", 2.5, false},
+		{"xml boolean", schema(config.ParameterSchema{Type: "boolean"}), "true", true, false},
+		{"xml invalid boolean", schema(config.ParameterSchema{Type: "boolean"}), "yes", nil, true},
+		{"xml string is kept as is", schema(config.ParameterSchema{Type: "string"}), " redis ", " redis ", false},
+		{"json integral number to integer", schema(config.ParameterSchema{Type: "integer"}), 3.0, 3, false},
+		{"json fractional number is left for validation", schema(config.ParameterSchema{Type: "integer"}), 3.5, 3.5, false},
+		{"json number to string", schema(config.ParameterSchema{Type: "string"}), 7.0, "7", false},
+		{"json boolean to string", schema(config.ParameterSchema{Type: "string"}), true, "true", false},
+		{"json text to boolean", schema(config.ParameterSchema{Type: "boolean"}), "false", false, false},
+		{"schema without type takes the parameter type",
+			config.GenericToolParameter{Name: "value", Type: "integer", Schema: &config.ParameterSchema{Description: "count"}},
+			"8", 8, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewGenericParameterParser().ConvertParameter(tt.value, tt.param)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestValidateSchemaValue_Enum(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  *config.ParameterSchema
+		value   interface{}
+		wantErr bool
+	}{
+		{"string allowed", &config.ParameterSchema{Type: "string", Enum: []interface{}{"go", "python"}}, "go", false},
+		{"string not allowed", &config.ParameterSchema{Type: "string", Enum: []interface{}{"go", "python"}}, "java", true},
+		{"string enum is case sensitive", &config.ParameterSchema{Type: "string", Enum: []interface{}{"go"}}, "Go", true},
+		{"integer allowed", &config.ParameterSchema{Type: "integer", Enum: []interface{}{1, 2, 3}}, 2, false},
+		{"integer compared by value", &config.ParameterSchema{Type: "integer", Enum: []interface{}{1, 2, 3}}, 2.0, false},
+		{"integer not allowed", &config.ParameterSchema{Type: "integer", Enum: []interface{}{1, 2, 3}}, 4, true},
+		{"array items allowed", &config.ParameterSchema{Type: "array",
+			Items: &config.ParameterSchema{Type: "string", Enum: []interface{}{"a", "b"}}}, []string{"a", "b"}, false},
+		{"array item not allowed", &config.ParameterSchema{Type: "array",
+			Items: &config.ParameterSchema{Type: "string", Enum: []interface{}{"a", "b"}}}, []string{"a", "c"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSchemaValue(tt.value, tt.schema)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestConvertAndValidate_Nested(t *testing.T) {
+	filter := &config.ParameterSchema{
+		Type:     "object",
+		Required: []string{"language"},
+		Properties: map[string]*config.ParameterSchema{
+			"language": {Type: "string", Enum: []interface{}{"go", "python"}},
+			"limit":    {Type: "integer"},
+			"paths":    {Type: "array", Items: &config.ParameterSchema{Type: "string"}, MaxItems: intPtr(2)},
+		},
+	}
+	ranges := &config.ParameterSchema{
+		Type:     "array",
+		MinItems: intPtr(1),
+		Items: &config.ParameterSchema{
+			Type:       "object",
+			Required:   []string{"start"},
+			Properties: map[string]*config.ParameterSchema{"start": {Type: "integer"}, "end": {Type: "integer"}},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		schema  *config.ParameterSchema
+		value   interface{}
+		want    interface{}
+		wantErr string
+	}{
+		{
+			name:   "xml object with nested array",
+			schema: filter,
+			value:  `{"language": "go", "limit": "5", "paths": ["a", 1]}`,
+			want:   map[string]interface{}{"language": "go", "limit": 5, "paths": []string{"a", "1"}},
+		},
+		{
+			name:   "json object",
+			schema: filter,
+			value:  map[string]interface{}{"language": "python", "limit": 5.0},
+			want:   map[string]interface{}{"language": "python", "limit": 5},
+		},
+		{
+			name:    "required property missing",
+			schema:  filter,
+			value:   `{"limit": 5}`,
+			wantErr: "required property language is missing",
+		},
+		{
+			name:    "property of the wrong type",
+			schema:  filter,
+			value:   map[string]interface{}{"language": "go", "limit": true},
+			wantErr: "property limit: expected integer",
+		},
+		{
+			name:    "nested enum",
+			schema:  filter,
+			value:   map[string]interface{}{"language": "java"},
+			wantErr: "property language: java is not one of",
+		},
+		{
+			name:    "nested array too long",
+			schema:  filter,
+			value:   `{"language": "go", "paths": ["a", "b", "c"]}`,
+			wantErr: "property paths: 3 items are more than 2",
+		},
+		{
+			name:    "invalid object text",
+			schema:  filter,
+			value:   `{"language": `,
+			wantErr: "cannot convert",
+		},
+		{
+			name:   "array of objects",
+			schema: ranges,
+			value:  `[{"start": 1, "end": 3}, {"start": "10"}]`,
+			want:   []interface{}{map[string]interface{}{"start": 1, "end": 3}, map[string]interface{}{"start": 10}},
+		},
+		{
+			name:    "array item missing a required property",
+			schema:  ranges,
+			value:   `[{"start": 1}, {"end": 3}]`,
+			wantErr: "item 1: required property start is missing",
+		},
+		{
+			name:    "array item of the wrong type",
+			schema:  ranges,
+			value:   []interface{}{"not an object"},
+			wantErr: "item 0: cannot convert",
+		},
+		{
+			name:    "empty array",
+			schema:  ranges,
+			value:   `[]`,
+			wantErr: "0 items are fewer than 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			param := config.GenericToolParameter{Name: "value", Schema: tt.schema}
+			got, err := NewGenericParameterParser().ConvertParameter(tt.value, param)
+			if err == nil {
+				err = validateSchemaValue(got, tt.schema)
+			}
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestConvertParameter_FlatType(t *testing.T) {
+	tests := []struct {
+		name      string
+		paramType string
+		value     string
+		want      interface{}
+		wantErr   bool
+	}{
+		{"string", "string", "redis", "redis", false},
+		{"unknown type is a string", "text", "redis", "redis", false},
+		{"integer", "integer", "42", 42, false},
+		{"invalid integer", "integer", "4.2", nil, true},
+		{"float", "float", "1.5", 1.5, false},
+		{"number", "number", "1.5", 1.5, false},
+		{"boolean", "boolean", "false", false, false},
+		{"type is case insensitive", "Integer", "7", 7, false},
+		{"array split on commas", "array", "a,b", []string{"a", "b"}, false},
+		{"array of one item", "array", "a", []string{"a"}, false},
+		{"json array keeps commas", "array", `["a,b", "c"]`, []string{"a,b", "c"}, false},
+		{"object", "object", `{"a": 1}`, map[string]interface{}{"a": 1.0}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			param := config.GenericToolParameter{Name: "value", Type: tt.paramType}
+			got, err := NewGenericParameterParser().ConvertParameter(tt.value, param)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, validateSchemaValue(got, parameterSchema(param)), "flat values pass the schema of their type")
+		})
+	}
+}
+
+func TestParameterSchema_FlatType(t *testing.T) {
+	tests := []struct {
+		paramType string
+		want      *config.ParameterSchema
+	}{
+		{"string", &config.ParameterSchema{Type: "string"}},
+		{"", &config.ParameterSchema{Type: "string"}},
+		{"integer", &config.ParameterSchema{Type: "integer"}},
+		{"float", &config.ParameterSchema{Type: "number"}},
+		{"Boolean", &config.ParameterSchema{Type: "boolean"}},
+		{"array", &config.ParameterSchema{Type: "array", Items: &config.ParameterSchema{Type: "string"}}},
+		{"object", &config.ParameterSchema{Type: "object"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.paramType, func(t *testing.T) {
+			assert.Equal(t, tt.want, parameterSchema(config.GenericToolParameter{Type: tt.paramType}))
+		})
+	}
+}
+
+func TestToolDefinitionAndDescription_FlatParameters(t *testing.T) {
+	toolConfig := searchToolConfig(
+		config.GenericToolParameter{Name: "query", Type: "string", Description: "Search query", Source: config.ParameterSourceLLM, Required: true},
+		config.GenericToolParameter{Name: "topK", Type: "float", Source: config.ParameterSourceLLM, Default: 5},
+		config.GenericToolParameter{Name: "paths", Type: "array", Source: config.ParameterSourceLLM},
+		config.GenericToolParameter{Name: "codebase", Source: config.ParameterSourceManual, Default: "/repo"},
+	)
+	toolConfig.GenericTools[0].Description = "Search the codebase"
+	executor := NewGenericToolExecutor(&toolConfig, nil)
+
+	definition, err := executor.GetToolDefinition("search")
+	require.NoError(t, err)
+	parameters := definition.Function.Parameters
+	assert.Equal(t, []string{"query"}, parameters.Required)
+	require.Len(t, parameters.Properties, 3, "manual parameters are not offered to the model")
+	assert.Equal(t, "string", parameters.Properties["query"].Type)
+	assert.Equal(t, "Search query", parameters.Properties["query"].Description)
+	assert.Equal(t, "number", parameters.Properties["topK"].Type)
+	assert.Equal(t, 5, parameters.Properties["topK"].Default)
+	assert.Equal(t, "array", parameters.Properties["paths"].Type)
+	require.NotNil(t, parameters.Properties["paths"].Items)
+	assert.Equal(t, "string", parameters.Properties["paths"].Items.Type)
+	assert.Equal(t, "Search the codebase", definition.Function.Description)
+
+	// The rule of the tool is sent with the function description
+	toolConfig.GenericTools[0].Rule = "Search before reading files\n"
+	definition, err = executor.GetToolDefinition("search")
+	require.NoError(t, err)
+	assert.Equal(t, "Search the codebase\n\nSearch before reading files", definition.Function.Description)
+
+	description := xmlToolDescription("search", "Search the codebase", toolConfig.GenericTools[0].Parameters)
+	assert.Equal(t, strings.Join([]string{
+		"Description: Search the codebase",
+		"Parameters:",
+		"- query: (required, string) Search query",
+		"- topK: (optional, number)",
+		"- paths: (optional, JSON array of string)",
+		"Usage:",
+		"<search>",
+		"<query>query here</query>",
+		"<topK>topK here (optional)</topK>",
+		"<paths>paths here (optional)</paths>",
+		"</search>",
+	}, "\n"), description)
+}
@@ -2,18 +2,24 @@ package functions
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/zgsm-ai/chat-rag/internal/client"
 	"github.com/zgsm-ai/chat-rag/internal/config"
 	"github.com/zgsm-ai/chat-rag/internal/model"
+	"github.com/zgsm-ai/chat-rag/internal/types"
 )
 
 type ToolExecutor interface {
 	DetectTools(ctx context.Context, content string) (bool, string)
 
+	// DetectAllTools returns the complete tool invocations of the content, in order of appearance
+	DetectAllTools(ctx context.Context, content string) []ToolInvocation
+
 	// ExecuteTools executes tools and returns new messages
 	ExecuteTools(ctx context.Context, toolName string, content string) (string, error)
 
@@ -25,28 +31,53 @@ type ToolExecutor interface {
 
 	GetToolRule(toolName string) (string, error)
 
+	// GetToolFollowUp returns the instruction following the results of the tool, empty for the default one
+	GetToolFollowUp(toolName string) (string, error)
+
+	// GetToolDefinition returns the native function calling definition of the tool
+	GetToolDefinition(toolName string) (types.Function, error)
+
+	// UsesCallerCredentials reports whether the tool calls its service with the credentials or identity
+	// of the end user, so that its calls cannot be made on behalf of another request
+	UsesCallerCredentials(toolName string) bool
+
 	GetAllTools() []string
 }
 
+// ToolInvocation A complete tool invocation of the model output
+type ToolInvocation struct {
+	Name string
+	// Content The invocation from the start tag to the end tag
+	Content string
+}
+
 // GenericToolExecutor Generic tool executor
 type GenericToolExecutor struct {
 	toolConfig      *config.ToolConfig
 	clientFactory   *client.GenericClientFactory
 	parameterParser *GenericParameterParser
+
+	// Tools listed by the MCP servers, by server name
+	mcpTools map[string]*mcpServerTools
+	mcpMutex sync.RWMutex
 }
 
-// NewGenericToolExecutor Create new generic tool executor
-func NewGenericToolExecutor(toolConfig *config.ToolConfig) *GenericToolExecutor {
-	return &GenericToolExecutor{
+// NewGenericToolExecutor Create new generic tool executor, the tools of MCP servers are listed in the background.
+// Results of tools with caching enabled are cached in Redis, when the Redis client is given
+func NewGenericToolExecutor(toolConfig *config.ToolConfig, redisClient client.RedisInterface) *GenericToolExecutor {
+	e := &GenericToolExecutor{
 		toolConfig:      toolConfig,
-		clientFactory:   client.NewGenericClientFactory(),
+		clientFactory:   client.NewGenericClientFactory(redisClient),
 		parameterParser: NewGenericParameterParser(),
+		mcpTools:        make(map[string]*mcpServerTools),
 	}
+	e.refreshMCPTools()
+	return e
 }
 
 // DetectTools Detect tool invocation
 func (e *GenericToolExecutor) DetectTools(ctx context.Context, content string) (bool, string) {
-	for _, toolConfig := range e.toolConfig.GenericTools {
+	for _, toolConfig := range e.toolConfigs() {
 		if strings.Contains(content, "<"+toolConfig.Name+">") {
 			return true, toolConfig.Name
 		}
@@ -54,6 +85,36 @@ func (e *GenericToolExecutor) DetectTools(ctx context.Context, content string) (
 	return false, ""
 }
 
+// DetectAllTools Detect the complete tool invocations, an invocation without its end tag ends the detection
+func (e *GenericToolExecutor) DetectAllTools(ctx context.Context, content string) []ToolInvocation {
+	tools := e.toolConfigs()
+	var invocations []ToolInvocation
+	for pos := 0; pos < len(content); {
+		// The earliest start tag of any tool
+		start, name := -1, ""
+		for _, toolConfig := range tools {
+			index := strings.Index(content[pos:], "<"+toolConfig.Name+">")
+			if index >= 0 && (start < 0 || index < start) {
+				start, name = index, toolConfig.Name
+			}
+		}
+		if start < 0 {
+			break
+		}
+		start += pos
+
+		endTag := "</" + name + ">"
+		end := strings.Index(content[start:], endTag)
+		if end < 0 {
+			break
+		}
+		end += start + len(endTag)
+		invocations = append(invocations, ToolInvocation{Name: name, Content: content[start:end]})
+		pos = end
+	}
+	return invocations
+}
+
 // ExecuteTools Execute tools
 func (e *GenericToolExecutor) ExecuteTools(ctx context.Context, toolName string, content string) (string, error) {
 	// Find tool configuration
@@ -69,7 +130,8 @@ func (e *GenericToolExecutor) ExecuteTools(ctx context.Context, toolName string,
 	}
 
 	// Extract tool parameters, pass context parameters for path parameter processing
-	toolParams, err := e.parameterParser.ExtractParametersWithContext(*e.toolConfig, toolName, content, genericParams)
+	toolParams, err := e.parameterParser.ExtractParametersWithContext(config.ToolConfig{GenericTools: []config.GenericToolConfig{toolConfig}},
+		toolName, content, genericParams)
 	if err != nil {
 		return "", fmt.Errorf("failed to extract parameters: %w", err)
 	}
@@ -100,7 +162,7 @@ func (e *GenericToolExecutor) ExecuteTools(ctx context.Context, toolName string,
 		return "", fmt.Errorf("tool execution failed: %w", err)
 	}
 
-	return result, nil
+	return processToolResponse(toolConfig, result), nil
 }
 
 // CheckToolReady Check tool readiness status
@@ -134,7 +196,12 @@ func (e *GenericToolExecutor) GetToolDescription(toolName string) (string, error
 		return "", err
 	}
 
-	return fmt.Sprintf("## %s\n%s", toolName, toolConfig.Description), nil
+	// Tools without a description are described by their parameters
+	description := toolConfig.Description
+	if description == "" {
+		description = xmlToolDescription(toolName, "", toolConfig.Parameters)
+	}
+	return fmt.Sprintf("## %s\n%s", toolName, description), nil
 }
 
 // GetToolCapability Get tool capability description
@@ -155,10 +222,83 @@ func (e *GenericToolExecutor) GetToolRule(toolName string) (string, error) {
 	return toolConfig.Rule, nil
 }
 
+// GetToolFollowUp Get the instruction following the results of the tool, rendered from its follow-up template
+func (e *GenericToolExecutor) GetToolFollowUp(toolName string) (string, error) {
+	toolConfig, err := e.findToolConfig(toolName)
+	if err != nil {
+		return "", err
+	}
+	if toolConfig.Response.FollowUp == "" {
+		return "", nil
+	}
+	return renderToolTemplate(toolConfig.Response.FollowUp, toolFollowUpData{
+		ToolName: toolName,
+		Tools:    e.GetAllTools(),
+	})
+}
+
+// UsesCallerCredentials Whether the tool forwards the token or identity of the caller, unknown tools are
+// assumed to do so
+func (e *GenericToolExecutor) UsesCallerCredentials(toolName string) bool {
+	toolConfig, err := e.findToolConfig(toolName)
+	if err != nil {
+		return true
+	}
+	// MCP servers are called with the headers of their configuration
+	if toolConfig.Type == config.ToolTypeMCP {
+		return false
+	}
+	authType := toolConfig.Auth.Type
+	return authType == "" || authType == config.ToolAuthPassthrough || len(toolConfig.Auth.IdentityHeaders) > 0
+}
+
+// GetToolDefinition Get the function calling definition of the tool, built from its LLM parameters
+func (e *GenericToolExecutor) GetToolDefinition(toolName string) (types.Function, error) {
+	toolConfig, err := e.findToolConfig(toolName)
+	if err != nil {
+		return types.Function{}, err
+	}
+
+	parameters := types.FunctionParameters{
+		Type:       "object",
+		Properties: make(map[string]types.PropertyDetails),
+		Required:   []string{},
+	}
+	for _, param := range toolConfig.Parameters {
+		if param.Source != config.ParameterSourceLLM {
+			continue
+		}
+
+		property := schemaProperty(parameterSchema(param))
+		property.Description = param.Description
+		property.Default = param.Default
+		parameters.Properties[param.Name] = property
+		if param.Required {
+			parameters.Required = append(parameters.Required, param.Name)
+		}
+	}
+
+	// Function calling models get no system prompt section of the tool, so its rule goes with the description
+	description := toolConfig.Description
+	if rule := strings.TrimSpace(toolConfig.Rule); rule != "" {
+		description = strings.TrimSpace(description + "\n\n" + rule)
+	}
+
+	return types.Function{
+		Type: "function",
+		Function: types.FunctionDefinition{
+			Name:        toolConfig.Name,
+			Description: description,
+			Parameters:  parameters,
+		},
+	}, nil
+}
+
 // GetAllTools Get all tool names
 func (e *GenericToolExecutor) GetAllTools() []string {
-	tools := make([]string, 0, len(e.toolConfig.GenericTools))
-	for _, config := range e.toolConfig.GenericTools {
+	toolConfigs := e.toolConfigs()
+	tools := make([]string, 0, len(toolConfigs))
+	for _, config := range toolConfigs {
 		tools = append(tools, config.Name)
 	}
 	return tools
@@ -166,7 +306,7 @@ func (e *GenericToolExecutor) GetAllTools() []string {
 
 // findToolConfig Find tool configuration
 func (e *GenericToolExecutor) findToolConfig(toolName string) (config.GenericToolConfig, error) {
-	for _, toolConfig := range e.toolConfig.GenericTools {
+	for _, toolConfig := range e.toolConfigs() {
 		if toolConfig.Name == toolName {
 			return toolConfig, nil
 		}
@@ -219,10 +359,18 @@ func (p *GenericParameterParser) ExtractParametersWithContext(toolConfig config.
 		return nil, fmt.Errorf("tool %s not found in configuration", toolName)
 	}
 
+	// Function calling tools pass their arguments as a JSON object, XML tools as tags
+	var jsonArgs map[string]interface{}
+	isJSON := json.Unmarshal([]byte(strings.TrimSpace(content)), &jsonArgs) == nil
+
 	// Extract XML parameters
-	toolContent, err := extractXmlParam(content, currentToolConfig.Name)
-	if err != nil {
-		return nil, fmt.Errorf("failed to extract tool content: %w", err)
+	var toolContent string
+	if !isJSON {
+		var err error
+		toolContent, err = extractXmlParam(content, currentToolConfig.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract tool content: %w", err)
+		}
 	}
 
 	// Get OS type, default to Windows
@@ -232,7 +380,13 @@ func (p *GenericParameterParser) ExtractParametersWithContext(toolConfig config.
 	for _, param := range currentToolConfig.Parameters {
 		// Handle parameters extracted from LLM
 		if param.Source == config.ParameterSourceLLM {
-			value, err := extractXmlParam(toolContent, param.Name)
+			var value interface{}
+			var err error
+			if isJSON {
+				value, err = jsonParam(jsonArgs, param.Name)
+			} else {
+				value, err = extractXmlParam(toolContent, param.Name)
+			}
 			if err != nil {
 				if param.Required {
 					return nil, fmt.Errorf("required parameter %s not found: %w", param.Name, err)
@@ -245,12 +399,12 @@ func (p *GenericParameterParser) ExtractParametersWithContext(toolConfig config.
 			}
 
 			// Special handling for path parameters
-			if strings.Contains(strings.ToLower(param.Name), "path") {
-				value = p.processPathParameter(value, osType)
+			if text, ok := value.(string); ok && strings.Contains(strings.ToLower(param.Name), "path") {
+				value = p.processPathParameter(text, osType)
 			}
 
 			// Type conversion
-			convertedValue, err := p.ConvertParameterType(value, param.Type)
+			convertedValue, err := p.ConvertParameter(value, param)
 			if err != nil {
 				return nil, fmt.Errorf("failed to convert parameter %s: %w", param.Name, err)
 			}
@@ -291,6 +445,15 @@ func extractXmlParam(content, paramName string) (string, error) {
 	return paramValue, nil
 }
 
+// jsonParam Get a parameter of the JSON arguments
+func jsonParam(args map[string]interface{}, paramName string) (interface{}, error) {
+	value, ok := args[paramName]
+	if !ok || value == nil {
+		return nil, fmt.Errorf("argument not found")
+	}
+	return value, nil
+}
+
 // getOSType Get OS type
 func getOSType(contextParams map[string]interface{}) string {
 	osType := "windows"
@@ -314,10 +477,10 @@ func (p *GenericParameterParser) ValidateParameters(toolConfig config.GenericToo
 			}
 		}
 
-		// Type validation
+		// Schema validation
 		if value, exists := params[param.Name]; exists {
-			if err := p.validateParameterType(value, param.Type); err != nil {
-				return fmt.Errorf("parameter %s type validation failed: %w", param.Name, err)
+			if err := validateSchemaValue(value, parameterSchema(param)); err != nil {
+				return fmt.Errorf("parameter %s validation failed: %w", param.Name, err)
 			}
 		}
 	}
@@ -325,6 +488,18 @@ func (p *GenericParameterParser) ValidateParameters(toolConfig config.GenericToo
 	return nil
 }
 
+// ConvertParameter Convert a parameter given by the LLM, XML text or a JSON argument, following its schema.
+// Text of parameters declared without schema is converted by their type
+func (p *GenericParameterParser) ConvertParameter(value interface{}, param config.GenericToolParameter) (interface{}, error) {
+	if text, ok := value.(string); ok {
+		if param.Schema == nil {
+			return p.ConvertParameterType(text, param.Type)
+		}
+		return convertSchemaValue(text, parameterSchema(param))
+	}
+	return coerceSchemaValue(value, parameterSchema(param))
+}
+
 // ConvertParameterType Convert parameter type (public method for testing)
 func (p *GenericParameterParser) ConvertParameterType(value string, paramType string) (interface{}, error) {
 	switch config.ParameterType(strings.ToLower(paramType)) {
@@ -336,7 +511,7 @@ func (p *GenericParameterParser) ConvertParameterType(value string, paramType st
 			return nil, fmt.Errorf("cannot convert %s to integer: %w", value, err)
 		}
 		return intValue, nil
-	case config.ParameterTypeFloat:
+	case config.ParameterTypeFloat, config.ParameterTypeNumber:
 		floatValue, err := strconv.ParseFloat(value, 64)
 		if err != nil {
 			return nil, fmt.Errorf("cannot convert %s to float: %w", value, err)
@@ -348,8 +523,14 @@ func (p *GenericParameterParser) ConvertParameterType(value string, paramType st
 			return nil, fmt.Errorf("cannot convert %s to boolean: %w", value, err)
 		}
 		return boolValue, nil
+	case config.ParameterTypeObject:
+		return convertSchemaValue(value, parameterSchema(config.GenericToolParameter{Type: paramType}))
 	case config.ParameterTypeArray:
-		// Simple array parsing, assume value is comma-separated
+		// JSON arrays keep items with commas
+		if strings.HasPrefix(strings.TrimSpace(value), "[") {
+			return convertSchemaValue(value, parameterSchema(config.GenericToolParameter{Type: paramType}))
+		}
+		// Otherwise assume value is comma-separated
 		if strings.Contains(value, ",") {
 			return strings.Split(value, ","), nil
 		}
@@ -359,33 +540,6 @@ func (p *GenericParameterParser) ConvertParameterType(value string, paramType st
 	}
 }
 
-// validateParameterType Validate parameter type
-func (p *GenericParameterParser) validateParameterType(value interface{}, paramType string) error {
-	switch config.ParameterType(strings.ToLower(paramType)) {
-	case config.ParameterTypeString:
-		if _, ok := value.(string); !ok {
-			return fmt.Errorf("expected string, got %T", value)
-		}
-	case config.ParameterTypeInteger:
-		if _, ok := value.(int); !ok {
-			return fmt.Errorf("expected integer, got %T", value)
-		}
-	case config.ParameterTypeFloat:
-		if _, ok := value.(float64); !ok {
-			return fmt.Errorf("expected float, got %T", value)
-		}
-	case config.ParameterTypeBoolean:
-		if _, ok := value.(bool); !ok {
-			return fmt.Errorf("expected boolean, got %T", value)
-		}
-	case config.ParameterTypeArray:
-		if _, ok := value.([]string); !ok {
-			return fmt.Errorf("expected array, got %T", value)
-		}
-	}
-	return nil
-}
-
 // processPathParameter Process special conversion for path parameters
 func (p *GenericParameterParser) processPathParameter(path string, osType string) string {
 	// If Windows system, convert Unix path separators to Windows path separators
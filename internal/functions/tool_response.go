@@ -0,0 +1,122 @@
+package functions
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/tidwall/gjson"
+	"go.uber.org/zap"
+
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/logger"
+)
+
+// toolTemplates Parsed result and follow-up templates, by template text
+var toolTemplates sync.Map
+
+// toolTemplateFuncs Functions of the result and follow-up templates
+var toolTemplateFuncs = template.FuncMap{
+	// truncate keeps the first n characters of the value
+	"truncate": func(n int, value interface{}) string {
+		text := []rune(fmt.Sprint(value))
+		if len(text) <= n {
+			return string(text)
+		}
+		return string(text[:n]) + "..."
+	},
+	// json encodes the value as compact JSON
+	"json": func(value interface{}) (string, error) {
+		data, err := json.Marshal(value)
+		return string(data), err
+	},
+	// join joins the items of a list with the separator
+	"join": func(items interface{}, sep string) string {
+		list := reflect.ValueOf(items)
+		if items == nil || list.Kind() != reflect.Slice {
+			return fmt.Sprint(items)
+		}
+		values := make([]string, 0, list.Len())
+		for i := 0; i < list.Len(); i++ {
+			values = append(values, fmt.Sprint(list.Index(i).Interface()))
+		}
+		return strings.Join(values, sep)
+	},
+	// trim removes the surrounding whitespace of the value
+	"trim": func(value interface{}) string {
+		return strings.TrimSpace(fmt.Sprint(value))
+	},
+}
+
+// toolFollowUpData Data of the follow-up instruction template
+type toolFollowUpData struct {
+	ToolName string
+	// Names of all tools
+	Tools []string
+}
+
+// renderToolTemplate Render the template text with the data
+func renderToolTemplate(text string, data interface{}) (string, error) {
+	tmpl, ok := toolTemplates.Load(text)
+	if !ok {
+		parsed, err := template.New("tool").Funcs(toolTemplateFuncs).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse template: %w", err)
+		}
+		tmpl, _ = toolTemplates.LoadOrStore(text, parsed)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.(*template.Template).Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// processToolResponse Keep the selected fields of the JSON response and render them with the template of the
+// tool. Responses that are not JSON are returned as they are, and so are responses that fail to render.
+func processToolResponse(toolConfig config.GenericToolConfig, response string) string {
+	settings := toolConfig.Response
+	if (settings.Select == "" && settings.Template == "") || !gjson.Valid(response) {
+		return response
+	}
+
+	selected := response
+	if settings.Select != "" {
+		selected = gjson.Get(response, settings.Select).Raw
+	}
+	if settings.Template == "" {
+		if selected == "" {
+			// An empty tool result reads as a failure to the model, the response is given instead
+			logger.Warn("tool response select path matched nothing, the response is given as it is",
+				zap.String("tool", toolConfig.Name), zap.String("select", settings.Select))
+			return response
+		}
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, []byte(selected)); err != nil {
+			return selected
+		}
+		return compact.String()
+	}
+
+	// Numbers are kept as written, not as floats
+	var value interface{}
+	if selected != "" {
+		decoder := json.NewDecoder(strings.NewReader(selected))
+		decoder.UseNumber()
+		if err := decoder.Decode(&value); err != nil {
+			return response
+		}
+	}
+	result, err := renderToolTemplate(settings.Template, value)
+	if err != nil {
+		logger.Warn("failed to render tool response, the response is given as it is",
+			zap.String("tool", toolConfig.Name), zap.Error(err))
+		return response
+	}
+	return result
+}
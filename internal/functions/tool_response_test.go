@@ -0,0 +1,129 @@
+package functions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zgsm-ai/chat-rag/internal/config"
+)
+
+func TestProcessToolResponse(t *testing.T) {
+	const results = `{"data": {"results": [{"path": "a.go", "content": "package a", "score": 0.9}]}}`
+
+	tests := []struct {
+		name     string
+		settings config.ToolResponseConfig
+		response string
+		want     string
+	}{
+		{
+			name:     "no select nor template keeps the response",
+			response: results,
+			want:     results,
+		},
+		{
+			name:     "no select nor template keeps a non-JSON body",
+			response: "plain text results",
+			want:     "plain text results",
+		},
+		{
+			name:     "select without template gives compact JSON",
+			settings: config.ToolResponseConfig{Select: "data.results.#.{path,content}"},
+			response: results,
+			want:     `[{"path":"a.go","content":"package a"}]`,
+		},
+		{
+			name:     "template of the whole response",
+			settings: config.ToolResponseConfig{Template: `{{range .data.results}}{{.path}}: {{.content}}{{end}}`},
+			response: results,
+			want:     "a.go: package a",
+		},
+		{
+			name: "template of the selected fields with functions",
+			settings: config.ToolResponseConfig{
+				Select:   "data.results",
+				Template: "{{range .}}\n{{.path}} ({{.score}}): {{truncate 4 .content}} {{json .path}}\n{{end}}",
+			},
+			response: results,
+			want:     `a.go (0.9): pack... "a.go"`,
+		},
+		{
+			name:     "numbers are kept as written",
+			settings: config.ToolResponseConfig{Template: "{{.id}}"},
+			response: `{"id": 12345678901234567890}`,
+			want:     "12345678901234567890",
+		},
+		{
+			name:     "missing select path without template",
+			settings: config.ToolResponseConfig{Select: "data.missing"},
+			response: results,
+			want:     results,
+		},
+		{
+			name:     "missing select path renders the template without value",
+			settings: config.ToolResponseConfig{Select: "data.missing", Template: "{{if .}}{{join . \", \"}}{{else}}no results{{end}}"},
+			response: results,
+			want:     "no results",
+		},
+		{
+			name:     "non-JSON body is not selected",
+			settings: config.ToolResponseConfig{Select: "data.results"},
+			response: "upstream timeout",
+			want:     "upstream timeout",
+		},
+		{
+			name:     "non-JSON body is not rendered",
+			settings: config.ToolResponseConfig{Template: "{{.data}}"},
+			response: "upstream timeout",
+			want:     "upstream timeout",
+		},
+		{
+			name:     "truncated JSON body is not rendered",
+			settings: config.ToolResponseConfig{Select: "data", Template: "{{.results}}"},
+			response: `{"data": {"results": [`,
+			want:     `{"data": {"results": [`,
+		},
+		{
+			name:     "template parse error gives the raw body",
+			settings: config.ToolResponseConfig{Select: "data.results", Template: "{{range .}}"},
+			response: results,
+			want:     results,
+		},
+		{
+			name:     "template execution error gives the raw body",
+			settings: config.ToolResponseConfig{Select: "data.results", Template: "{{index . 5}}"},
+			response: results,
+			want:     results,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			toolConfig := config.GenericToolConfig{Name: "search", Response: tt.settings}
+			assert.Equal(t, tt.want, processToolResponse(toolConfig, tt.response))
+		})
+	}
+}
+
+func TestGetToolFollowUp(t *testing.T) {
+	toolConfig := config.ToolConfig{GenericTools: []config.GenericToolConfig{
+		{Name: "search"},
+		{Name: "lookup", Response: config.ToolResponseConfig{FollowUp: "Summarize the results of {{.ToolName}}, then use {{join .Tools \", \"}}."}},
+		{Name: "broken", Response: config.ToolResponseConfig{FollowUp: "{{.ToolName"}},
+	}}
+	executor := NewGenericToolExecutor(&toolConfig, nil)
+
+	// Tools without follow-up template use the default instruction of the caller
+	followUp, err := executor.GetToolFollowUp("search")
+	require.NoError(t, err)
+	assert.Empty(t, followUp)
+
+	followUp, err = executor.GetToolFollowUp("lookup")
+	require.NoError(t, err)
+	assert.Equal(t, "Summarize the results of lookup, then use search, lookup, broken.", followUp)
+
+	_, err = executor.GetToolFollowUp("broken")
+	assert.ErrorContains(t, err, "failed to parse template")
+	_, err = executor.GetToolFollowUp("unknown")
+	assert.Error(t, err)
+}
@@ -0,0 +1,384 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zgsm-ai/chat-rag/internal/bootstrap"
+	"github.com/zgsm-ai/chat-rag/internal/logger"
+	"github.com/zgsm-ai/chat-rag/internal/logic"
+	"github.com/zgsm-ai/chat-rag/internal/model"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+	"go.uber.org/zap"
+)
+
+// headerAnthropicApiKey is the api key header sent by Anthropic SDK clients
+const headerAnthropicApiKey = "x-api-key"
+
+// AnthropicAuthMiddleware maps the Anthropic x-api-key header to the authorization header
+// so that identity extraction works the same as on the OpenAI style endpoint
+func AnthropicAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader(types.HeaderAuthorization) == "" {
+			if apiKey := c.GetHeader(headerAnthropicApiKey); apiKey != "" {
+				c.Request.Header.Set(types.HeaderAuthorization, "Bearer "+apiKey)
+			}
+		}
+		c.Next()
+	}
+}
+
+// AnthropicMessagesHandler handles Anthropic Messages API requests on top of the chat completion pipeline
+func AnthropicMessagesHandler(svcCtx *bootstrap.ServiceContext) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 1. Parse and convert request
+		var anthropicReq types.AnthropicMessagesRequest
+		if err := c.ShouldBindJSON(&anthropicReq); err != nil {
+			sendAnthropicErrorResponse(c, http.StatusBadRequest, err)
+			return
+		}
+
+		req, err := anthropicReq.ToChatCompletionRequest()
+		if err != nil {
+			sendAnthropicErrorResponse(c, http.StatusBadRequest, err)
+			return
+		}
+
+		// 2. Get identity from context (set by middleware)
+		identity, exists := model.GetIdentityFromContext(c.Request.Context())
+		if !exists {
+			logger.Warn("failed to get identity from context")
+			return
+		}
+
+		c.Header(types.HeaderRequestId, identity.RequestID)
+
+		// 3. Handle stream and non-stream cases separately
+		if anthropicReq.Stream {
+			setSSEResponseHeaders(c)
+			c.Status(http.StatusOK)
+
+			writer := newAnthropicStreamWriter(c.Writer, req.Model, identity.RequestID)
+			l := logic.NewChatCompletionLogic(c.Request.Context(), svcCtx, req, writer, &c.Request.Header, identity)
+			if err := l.ChatCompletionStream(); err != nil {
+				writer.writeError(err)
+			}
+			return
+		}
+
+		l := logic.NewChatCompletionLogic(c.Request.Context(), svcCtx, req, c.Writer, &c.Request.Header, identity)
+		resp, err := l.ChatCompletion()
+		if err != nil {
+			sendAnthropicErrorResponse(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.JSON(http.StatusOK, types.NewAnthropicMessagesResponse(resp))
+	}
+}
+
+// sendAnthropicErrorResponse sends an error response in Anthropic format
+func sendAnthropicErrorResponse(c *gin.Context, statusCode int, err error) {
+	message := err.Error()
+	if apiErr, ok := err.(*types.APIError); ok {
+		if apiErr.StatusCode > 0 {
+			statusCode = apiErr.StatusCode
+		}
+		if apiErr.Message != "" {
+			message = apiErr.Message
+		}
+	}
+
+	c.AbortWithStatusJSON(statusCode, types.AnthropicErrorResponse{
+		Type: types.AnthropicEventError,
+		Error: types.AnthropicError{
+			Type:    anthropicErrorType(statusCode),
+			Message: message,
+		},
+	})
+}
+
+// anthropicErrorType maps an HTTP status code to an Anthropic error type
+func anthropicErrorType(statusCode int) string {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return "invalid_request_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case http.StatusServiceUnavailable, 529:
+		return "overloaded_error"
+	default:
+		return "api_error"
+	}
+}
+
+// anthropicBlock is the content block currently open in the stream
+type anthropicBlock struct {
+	index int
+	kind  string
+}
+
+// anthropicStreamWriter translates the OpenAI style SSE chunks written by ChatCompletionLogic
+// into Anthropic Messages stream events
+type anthropicStreamWriter struct {
+	*sseChunkWriter
+
+	model      string
+	messageID  string
+	started    bool
+	finished   bool
+	nextIndex  int
+	current    *anthropicBlock
+	toolBlocks map[int]int
+	stopReason string
+	usage      types.AnthropicUsage
+}
+
+func newAnthropicStreamWriter(w gin.ResponseWriter, model, requestID string) *anthropicStreamWriter {
+	writer := &anthropicStreamWriter{
+		model:      model,
+		messageID:  "msg_" + requestID,
+		toolBlocks: make(map[int]int),
+	}
+	writer.sseChunkWriter = newSSEChunkWriter(w, writer)
+	return writer
+}
+
+// onUpstreamError converts an error written by the chat pipeline into an error event
+func (w *anthropicStreamWriter) onUpstreamError(message string) error {
+	if w.finished {
+		return nil
+	}
+	w.finished = true
+	return w.writeEvent(types.AnthropicEventError, types.AnthropicStreamEvent{
+		Type: types.AnthropicEventError,
+		Error: &types.AnthropicError{
+			Type:    "api_error",
+			Message: message,
+		},
+	})
+}
+
+// onChunk converts a chat completion chunk into content block events
+func (w *anthropicStreamWriter) onChunk(chunk *types.ChatCompletionResponse) error {
+	if w.finished {
+		return nil
+	}
+	if err := w.start(chunk.Model); err != nil {
+		return err
+	}
+
+	if chunk.Usage.PromptTokens > 0 || chunk.Usage.CompletionTokens > 0 {
+		w.usage = types.NewAnthropicUsage(chunk.Usage)
+	}
+
+	if len(chunk.Choices) == 0 {
+		return nil
+	}
+	choice := chunk.Choices[0]
+
+	if choice.Delta.ReasoningContent != "" {
+		if err := w.appendDelta(types.AnthropicBlockThinking, map[string]any{
+			"type":     "thinking_delta",
+			"thinking": choice.Delta.ReasoningContent,
+		}); err != nil {
+			return err
+		}
+	}
+	if choice.Delta.Content != "" {
+		if err := w.appendDelta(types.AnthropicBlockText, map[string]any{
+			"type": "text_delta",
+			"text": choice.Delta.Content,
+		}); err != nil {
+			return err
+		}
+	}
+	for _, call := range choice.Delta.ToolCalls {
+		if err := w.appendToolCall(call); err != nil {
+			return err
+		}
+	}
+	if choice.FinishReason != "" {
+		w.stopReason = types.AnthropicStopReason(choice.FinishReason)
+	}
+	return nil
+}
+
+// start emits message_start before the first content event
+func (w *anthropicStreamWriter) start(model string) error {
+	if w.started {
+		return nil
+	}
+	w.started = true
+	if model != "" {
+		w.model = model
+	}
+
+	return w.writeEvent(types.AnthropicEventMessageStart, types.AnthropicStreamEvent{
+		Type: types.AnthropicEventMessageStart,
+		Message: &types.AnthropicMessagesResponse{
+			ID:      w.messageID,
+			Type:    "message",
+			Role:    types.RoleAssistant,
+			Model:   w.model,
+			Content: []types.AnthropicContentBlock{},
+		},
+	})
+}
+
+// appendDelta writes a delta into the open text or thinking block, opening a new one if needed
+func (w *anthropicStreamWriter) appendDelta(kind string, delta map[string]any) error {
+	if w.current == nil || w.current.kind != kind {
+		block := map[string]any{"type": kind}
+		if kind == types.AnthropicBlockThinking {
+			block["thinking"] = ""
+		} else {
+			block["text"] = ""
+		}
+		if err := w.openBlock(kind, block); err != nil {
+			return err
+		}
+	}
+	return w.emitDelta(delta)
+}
+
+// appendToolCall converts an OpenAI tool call delta into tool_use block events
+func (w *anthropicStreamWriter) appendToolCall(call any) error {
+	callMap, ok := call.(map[string]any)
+	if !ok {
+		return nil
+	}
+	toolIndex := 0
+	if idx, ok := callMap["index"].(float64); ok {
+		toolIndex = int(idx)
+	}
+	function, _ := callMap["function"].(map[string]any)
+
+	blockIndex, exists := w.toolBlocks[toolIndex]
+	if !exists {
+		id, _ := callMap["id"].(string)
+		name, _ := function["name"].(string)
+		if err := w.openBlock(types.AnthropicBlockToolUse, map[string]any{
+			"type":  types.AnthropicBlockToolUse,
+			"id":    id,
+			"name":  name,
+			"input": map[string]any{},
+		}); err != nil {
+			return err
+		}
+		w.toolBlocks[toolIndex] = w.current.index
+	} else if w.current == nil || w.current.index != blockIndex {
+		// Blocks cannot be reopened once another block has started
+		return nil
+	}
+
+	if arguments, _ := function["arguments"].(string); arguments != "" {
+		return w.emitDelta(map[string]any{
+			"type":         "input_json_delta",
+			"partial_json": arguments,
+		})
+	}
+	return nil
+}
+
+func (w *anthropicStreamWriter) openBlock(kind string, block map[string]any) error {
+	if err := w.closeBlock(); err != nil {
+		return err
+	}
+	index := w.nextIndex
+	w.nextIndex++
+	w.current = &anthropicBlock{index: index, kind: kind}
+
+	return w.writeEvent(types.AnthropicEventContentBlockStart, types.AnthropicStreamEvent{
+		Type:         types.AnthropicEventContentBlockStart,
+		Index:        &index,
+		ContentBlock: block,
+	})
+}
+
+func (w *anthropicStreamWriter) closeBlock() error {
+	if w.current == nil {
+		return nil
+	}
+	index := w.current.index
+	w.current = nil
+
+	return w.writeEvent(types.AnthropicEventContentBlockStop, types.AnthropicStreamEvent{
+		Type:  types.AnthropicEventContentBlockStop,
+		Index: &index,
+	})
+}
+
+func (w *anthropicStreamWriter) emitDelta(delta map[string]any) error {
+	index := w.current.index
+	return w.writeEvent(types.AnthropicEventContentBlockDelta, types.AnthropicStreamEvent{
+		Type:  types.AnthropicEventContentBlockDelta,
+		Index: &index,
+		Delta: delta,
+	})
+}
+
+// onDone closes the open block and emits message_delta and message_stop
+func (w *anthropicStreamWriter) onDone() error {
+	if w.finished {
+		return nil
+	}
+	if err := w.start(""); err != nil {
+		return err
+	}
+	if err := w.closeBlock(); err != nil {
+		return err
+	}
+	w.finished = true
+
+	stopReason := w.stopReason
+	if stopReason == "" {
+		stopReason = types.AnthropicStopEndTurn
+	}
+	if err := w.writeEvent(types.AnthropicEventMessageDelta, types.AnthropicStreamEvent{
+		Type:  types.AnthropicEventMessageDelta,
+		Delta: map[string]any{"stop_reason": stopReason, "stop_sequence": nil},
+		Usage: &w.usage,
+	}); err != nil {
+		return err
+	}
+	return w.writeEvent(types.AnthropicEventMessageStop, types.AnthropicStreamEvent{
+		Type: types.AnthropicEventMessageStop,
+	})
+}
+
+// writeError emits an error event unless the stream has already been finished
+func (w *anthropicStreamWriter) writeError(err error) {
+	if w.finished {
+		return
+	}
+	w.finished = true
+
+	statusCode := http.StatusInternalServerError
+	message := err.Error()
+	if apiErr, ok := err.(*types.APIError); ok {
+		if apiErr.StatusCode > 0 {
+			statusCode = apiErr.StatusCode
+		}
+		if apiErr.Message != "" {
+			message = apiErr.Message
+		}
+	}
+
+	if emitErr := w.writeEvent(types.AnthropicEventError, types.AnthropicStreamEvent{
+		Type: types.AnthropicEventError,
+		Error: &types.AnthropicError{
+			Type:    anthropicErrorType(statusCode),
+			Message: message,
+		},
+	}); emitErr != nil {
+		logger.Warn("failed to write anthropic stream error", zap.Error(emitErr))
+	}
+}
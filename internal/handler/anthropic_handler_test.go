@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parseSSEEvents splits an SSE body into event names and their decoded payloads
+func parseSSEEvents(t *testing.T, body string) ([]string, []map[string]any) {
+	var names []string
+	var payloads []map[string]any
+	for _, event := range strings.Split(strings.TrimSpace(body), "\n\n") {
+		lines := strings.SplitN(event, "\n", 2)
+		require.Len(t, lines, 2)
+		names = append(names, strings.TrimPrefix(lines[0], "event: "))
+
+		var payload map[string]any
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &payload))
+		payloads = append(payloads, payload)
+	}
+	return names, payloads
+}
+
+func newTestAnthropicWriter() (*anthropicStreamWriter, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	return newAnthropicStreamWriter(c.Writer, "auto", "req-1"), recorder
+}
+
+func TestAnthropicStreamWriter_TextStream(t *testing.T) {
+	w, recorder := newTestAnthropicWriter()
+
+	chunks := []string{
+		`data: {"id":"1","model":"gpt-test","choices":[{"index":0,"delta":{"reasoning_content":"hmm"}}]}`,
+		`data: {"id":"1","model":"gpt-test","choices":[{"index":0,"delta":{"content":"Hel"}}]}`,
+		`data: {"id":"1","model":"gpt-test","choices":[{"index":0,"delta":{"content":"lo"},"finish_reason":"stop"}]}`,
+		`data: {"id":"1","model":"gpt-test","choices":[],"usage":{"prompt_tokens":3,"completion_tokens":2}}`,
+		`data: [DONE]`,
+	}
+	for _, chunk := range chunks {
+		// Split writes to make sure partial events are buffered
+		half := len(chunk) / 2
+		_, err := w.Write([]byte(chunk[:half]))
+		require.NoError(t, err)
+		_, err = w.Write([]byte(chunk[half:] + "\n\n"))
+		require.NoError(t, err)
+	}
+
+	names, payloads := parseSSEEvents(t, recorder.Body.String())
+	assert.Equal(t, []string{
+		"message_start",
+		"content_block_start", "content_block_delta", "content_block_stop",
+		"content_block_start", "content_block_delta", "content_block_delta", "content_block_stop",
+		"message_delta", "message_stop",
+	}, names)
+
+	message := payloads[0]["message"].(map[string]any)
+	assert.Equal(t, "gpt-test", message["model"])
+	assert.Equal(t, "msg_req-1", message["id"])
+
+	assert.Equal(t, "thinking_delta", payloads[2]["delta"].(map[string]any)["type"])
+	assert.Equal(t, "Hel", payloads[5]["delta"].(map[string]any)["text"])
+	assert.Equal(t, float64(1), payloads[5]["index"])
+
+	assert.Equal(t, "end_turn", payloads[8]["delta"].(map[string]any)["stop_reason"])
+	assert.Equal(t, float64(2), payloads[8]["usage"].(map[string]any)["output_tokens"])
+}
+
+func TestAnthropicStreamWriter_ToolCalls(t *testing.T) {
+	w, recorder := newTestAnthropicWriter()
+
+	body := `data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"search","arguments":""}}]}}]}` + "\n\n" +
+		`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"q\":"}}]}}]}` + "\n\n" +
+		`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"go\"}"}}]},"finish_reason":"tool_calls"}]}` + "\n\n" +
+		"data: [DONE]\n\n"
+	_, err := w.WriteString(body)
+	require.NoError(t, err)
+
+	names, payloads := parseSSEEvents(t, recorder.Body.String())
+	assert.Equal(t, []string{
+		"message_start",
+		"content_block_start", "content_block_delta", "content_block_delta", "content_block_stop",
+		"message_delta", "message_stop",
+	}, names)
+
+	block := payloads[1]["content_block"].(map[string]any)
+	assert.Equal(t, "tool_use", block["type"])
+	assert.Equal(t, "call_1", block["id"])
+	assert.Equal(t, "search", block["name"])
+	assert.Equal(t, `{"q":`, payloads[2]["delta"].(map[string]any)["partial_json"])
+	assert.Equal(t, "tool_use", payloads[5]["delta"].(map[string]any)["stop_reason"])
+}
+
+func TestAnthropicStreamWriter_Error(t *testing.T) {
+	w, recorder := newTestAnthropicWriter()
+
+	_, err := w.WriteString(`data: {"error":{"message":"boom","type":"server_error"}}` + "\n\n" + "data: [DONE]\n\n")
+	require.NoError(t, err)
+	w.writeError(assert.AnError)
+
+	names, payloads := parseSSEEvents(t, recorder.Body.String())
+	assert.Equal(t, []string{"error"}, names)
+	assert.Equal(t, "boom", payloads[0]["error"].(map[string]any)["message"])
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zgsm-ai/chat-rag/internal/bootstrap"
+)
+
+// CircuitBreakersHandler lists the circuit breaker state of every tracked model
+func CircuitBreakersHandler(svcCtx *bootstrap.ServiceContext) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"enabled": svcCtx.CircuitBreaker.Enabled(),
+			"models":  svcCtx.CircuitBreaker.Snapshot(),
+		})
+	}
+}
+
+// CircuitBreakerResetHandler forces circuit breakers back to closed.
+// Resets the model given by the "model" query parameter, or every model when it is empty.
+func CircuitBreakerResetHandler(svcCtx *bootstrap.ServiceContext) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		model := c.Query("model")
+		if model == "" {
+			svcCtx.CircuitBreaker.ResetAll()
+		} else if !svcCtx.CircuitBreaker.Reset(model) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"message": "no circuit breaker state for model " + model,
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"models":  svcCtx.CircuitBreaker.Snapshot(),
+		})
+	}
+}
@@ -2,6 +2,8 @@ package handler
 
 import (
 	"context"
+	"crypto/subtle"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -9,6 +11,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/zgsm-ai/chat-rag/internal/bootstrap"
+	"github.com/zgsm-ai/chat-rag/internal/client"
+	"github.com/zgsm-ai/chat-rag/internal/config"
 	"github.com/zgsm-ai/chat-rag/internal/logger"
 	"github.com/zgsm-ai/chat-rag/internal/model"
 	"github.com/zgsm-ai/chat-rag/internal/types"
@@ -29,6 +33,11 @@ func IdentityMiddleware(svcCtx *bootstrap.ServiceContext) gin.HandlerFunc {
 		if identity.RequestID != "" {
 			ctxWithIdentity = context.WithValue(ctxWithIdentity, types.HeaderRequestId, identity.RequestID)
 		}
+		// Let the request record or replay its upstream traffic
+		if mode := c.GetHeader(types.HeaderCassetteMode); mode != "" && svcCtx.Config.Cassette.AllowHeader &&
+			client.IsCassetteMode(mode) {
+			ctxWithIdentity = client.WithCassetteMode(ctxWithIdentity, mode)
+		}
 		// If request verification is enabled, perform verification
 		if svcCtx.Config.RequestVerify.Enabled {
 			if err := verifyRequest(c, identity, svcCtx); err != nil {
@@ -45,6 +54,43 @@ func IdentityMiddleware(svcCtx *bootstrap.ServiceContext) gin.HandlerFunc {
 	}
 }
 
+// AdminMiddleware lets only admins through, it runs after IdentityMiddleware.
+// An admin sends the configured admin token, or is one of the configured admin users
+func AdminMiddleware(svcCtx *bootstrap.ServiceContext) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity, _ := model.GetIdentityFromContext(c.Request.Context())
+		if !isAdmin(svcCtx.Config.Admin, c.GetHeader(types.HeaderAdminToken), identity) {
+			logger.WarnC(c.Request.Context(), "admin request refused", zap.String("path", c.FullPath()))
+			sendErrorResponse(c, http.StatusForbidden, errors.New("admin access required"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// isAdmin reports whether the token or the identity of the request belongs to an admin
+func isAdmin(cfg config.AdminConfig, token string, identity *model.Identity) bool {
+	if cfg.Token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(cfg.Token)) == 1 {
+		return true
+	}
+	if identity == nil {
+		return false
+	}
+
+	candidates := []string{identity.UserName}
+	if identity.UserInfo != nil {
+		candidates = append(candidates, identity.UserInfo.UUID, identity.UserInfo.Email)
+	}
+	for _, user := range cfg.Users {
+		for _, candidate := range candidates {
+			if user != "" && user == candidate {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func verifyRequest(c *gin.Context, identity *model.Identity, svcCtx *bootstrap.ServiceContext) error {
 	// verify x-request-id
 	verifyTime := false
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/zgsm-ai/chat-rag/internal/bootstrap"
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/model"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+func TestAdminMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name  string
+		admin config.AdminConfig
+		token string
+		want  int
+	}{
+		{"not configured", config.AdminConfig{}, "", http.StatusForbidden},
+		{"missing token", config.AdminConfig{Token: "s3cret"}, "", http.StatusForbidden},
+		{"wrong token", config.AdminConfig{Token: "s3cret"}, "guess", http.StatusForbidden},
+		{"admin token", config.AdminConfig{Token: "s3cret"}, "s3cret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svcCtx := &bootstrap.ServiceContext{Config: config.Config{Admin: tt.admin}}
+			router := gin.New()
+			router.GET("/admin", IdentityMiddleware(svcCtx), AdminMiddleware(svcCtx), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			if tt.token != "" {
+				req.Header.Set(types.HeaderAdminToken, tt.token)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			assert.Equal(t, tt.want, recorder.Code)
+		})
+	}
+}
+
+func TestIsAdmin_Users(t *testing.T) {
+	cfg := config.AdminConfig{Users: []string{"ops@example.com"}}
+
+	assert.True(t, isAdmin(cfg, "", &model.Identity{UserInfo: &model.UserInfo{Email: "ops@example.com"}}))
+	assert.False(t, isAdmin(cfg, "", &model.Identity{UserInfo: &model.UserInfo{Email: "dev@example.com"}}))
+	assert.False(t, isAdmin(cfg, "", &model.Identity{}))
+	assert.False(t, isAdmin(cfg, "", nil))
+}
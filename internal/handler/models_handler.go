@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zgsm-ai/chat-rag/internal/bootstrap"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+// ModelsHandler lists the models accepted by chat-rag
+func ModelsHandler(svcCtx *bootstrap.ServiceContext) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		models := svcCtx.GetModelList()
+		if models == nil {
+			models = []types.ModelInfo{}
+		}
+
+		c.JSON(http.StatusOK, types.ModelListResponse{
+			Object: "list",
+			Data:   models,
+		})
+	}
+}
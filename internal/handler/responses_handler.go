@@ -0,0 +1,428 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/zgsm-ai/chat-rag/internal/bootstrap"
+	"github.com/zgsm-ai/chat-rag/internal/logger"
+	"github.com/zgsm-ai/chat-rag/internal/logic"
+	"github.com/zgsm-ai/chat-rag/internal/model"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+	"go.uber.org/zap"
+)
+
+// ResponsesHandler handles OpenAI Responses API requests with server-side conversation state
+func ResponsesHandler(svcCtx *bootstrap.ServiceContext) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 1. Parse and validate request
+		var responsesReq types.ResponsesRequest
+		if err := c.ShouldBindJSON(&responsesReq); err != nil {
+			sendErrorResponse(c, http.StatusBadRequest, err)
+			return
+		}
+
+		input, err := responsesReq.InputMessages()
+		if err != nil {
+			sendErrorResponse(c, http.StatusBadRequest, err)
+			return
+		}
+
+		// 2. Get identity from context (set by middleware)
+		identity, exists := model.GetIdentityFromContext(c.Request.Context())
+		if !exists {
+			logger.Warn("failed to get identity from context")
+			return
+		}
+
+		// 3. Rebuild the full conversation from the previous response
+		var history []types.Message
+		if responsesReq.PreviousResponseID != "" {
+			history, err = svcCtx.ConversationStore.Load(c.Request.Context(), responsesReq.PreviousResponseID, identity.Owner())
+			if err != nil {
+				logger.WarnC(c.Request.Context(), "failed to load previous response",
+					zap.String("previousResponseId", responsesReq.PreviousResponseID),
+					zap.Error(err),
+				)
+				sendErrorResponse(c, http.StatusNotFound, types.NewPreviousResponseNotFoundError(responsesReq.PreviousResponseID))
+				return
+			}
+		}
+
+		// Keep a copy of the conversation, the chat pipeline may rewrite request messages
+		conversation := make([]types.Message, 0, len(history)+len(input)+1)
+		conversation = append(conversation, history...)
+		conversation = append(conversation, input...)
+
+		req := responsesReq.ToChatCompletionRequest(history, input)
+		responseID := newResponseID()
+		createdAt := time.Now().Unix()
+
+		c.Header(types.HeaderRequestId, identity.RequestID)
+
+		// 4. Handle stream and non-stream cases separately
+		var result *types.ResponsesResponse
+		if responsesReq.Stream {
+			setSSEResponseHeaders(c)
+			c.Status(http.StatusOK)
+
+			writer := newResponsesStreamWriter(c.Writer, responseID, createdAt, req.Model, responsesReq.PreviousResponseID)
+			l := logic.NewChatCompletionLogic(c.Request.Context(), svcCtx, req, writer, &c.Request.Header, identity)
+			if err := l.ChatCompletionStream(); err != nil {
+				writer.writeError(err)
+			}
+			result = writer.response()
+		} else {
+			l := logic.NewChatCompletionLogic(c.Request.Context(), svcCtx, req, c.Writer, &c.Request.Header, identity)
+			resp, err := l.ChatCompletion()
+			if err != nil {
+				sendErrorResponse(c, http.StatusInternalServerError, err)
+				return
+			}
+			result = types.NewResponsesResponse(responseID, createdAt, responsesReq.PreviousResponseID, resp)
+			c.JSON(http.StatusOK, result)
+		}
+
+		// 5. Save the conversation state so a follow-up only needs to send new input.
+		// Only completed responses are saved, a truncated or interrupted output would poison the follow-ups
+		if result.Status != types.ResponsesStatusCompleted || !responsesReq.ShouldStore() {
+			return
+		}
+		conversation = append(conversation, result.OutputMessage())
+		if err := svcCtx.ConversationStore.Save(c.Request.Context(), responseID, identity.Owner(), conversation); err != nil {
+			logger.WarnC(c.Request.Context(), "failed to save conversation state",
+				zap.String("responseId", responseID),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// newResponseID generates a Responses API style response id
+func newResponseID() string {
+	return "resp_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+}
+
+// responsesToolCall tracks a function call output item while streaming
+type responsesToolCall struct {
+	outputIndex int
+	item        types.ResponsesOutputItem
+}
+
+// responsesStreamWriter translates the OpenAI style SSE chunks written by ChatCompletionLogic
+// into Responses API stream events and accumulates the final response
+type responsesStreamWriter struct {
+	*sseChunkWriter
+
+	result    *types.ResponsesResponse
+	sequence  int
+	started   bool
+	finished  bool
+	text      strings.Builder
+	textIndex int
+	textOpen  bool
+	toolCalls map[int]*responsesToolCall
+	toolOpen  *responsesToolCall
+}
+
+func newResponsesStreamWriter(w gin.ResponseWriter, responseID string, createdAt int64, model, previousID string) *responsesStreamWriter {
+	writer := &responsesStreamWriter{
+		result: &types.ResponsesResponse{
+			ID:                 responseID,
+			Object:             "response",
+			CreatedAt:          createdAt,
+			Status:             types.ResponsesStatusInProgress,
+			Model:              model,
+			Output:             []types.ResponsesOutputItem{},
+			PreviousResponseID: previousID,
+		},
+		toolCalls: make(map[int]*responsesToolCall),
+	}
+	writer.sseChunkWriter = newSSEChunkWriter(w, writer)
+	return writer
+}
+
+// response returns the accumulated response
+func (w *responsesStreamWriter) response() *types.ResponsesResponse {
+	return w.result
+}
+
+// onChunk converts a chat completion chunk into output item events
+func (w *responsesStreamWriter) onChunk(chunk *types.ChatCompletionResponse) error {
+	if w.finished {
+		return nil
+	}
+	if chunk.Model != "" {
+		w.result.Model = chunk.Model
+	}
+	if err := w.start(); err != nil {
+		return err
+	}
+
+	if chunk.Usage.PromptTokens > 0 || chunk.Usage.CompletionTokens > 0 {
+		w.result.Usage = types.NewResponsesUsage(chunk.Usage)
+	}
+
+	if len(chunk.Choices) == 0 {
+		return nil
+	}
+	choice := chunk.Choices[0]
+
+	if choice.Delta.Content != "" {
+		if err := w.appendText(choice.Delta.Content); err != nil {
+			return err
+		}
+	}
+	for _, call := range choice.Delta.ToolCalls {
+		if err := w.appendToolCall(call); err != nil {
+			return err
+		}
+	}
+	if choice.FinishReason == "length" {
+		w.result.Status = types.ResponsesStatusIncomplete
+	}
+	return nil
+}
+
+// onUpstreamError converts an error written by the chat pipeline into error and response.failed events
+func (w *responsesStreamWriter) onUpstreamError(message string) error {
+	return w.fail(types.ErrCodeInernalError, message)
+}
+
+// onDone closes the open output items and emits response.completed
+func (w *responsesStreamWriter) onDone() error {
+	if w.finished {
+		return nil
+	}
+	if err := w.start(); err != nil {
+		return err
+	}
+	if err := w.closeText(); err != nil {
+		return err
+	}
+	if err := w.closeToolCall(); err != nil {
+		return err
+	}
+	w.finished = true
+
+	if w.result.Status == types.ResponsesStatusInProgress {
+		w.result.Status = types.ResponsesStatusCompleted
+	}
+	return w.emit(types.ResponsesEventCompleted, types.ResponsesStreamEvent{Response: w.result})
+}
+
+// writeError emits the failure events unless the stream has already been finished
+func (w *responsesStreamWriter) writeError(err error) {
+	code := types.ErrCodeInernalError
+	message := err.Error()
+	if apiErr, ok := err.(*types.APIError); ok {
+		code = apiErr.Code
+		if apiErr.Message != "" {
+			message = apiErr.Message
+		}
+	}
+	if emitErr := w.fail(code, message); emitErr != nil {
+		logger.Warn("failed to write responses stream error", zap.Error(emitErr))
+	}
+}
+
+func (w *responsesStreamWriter) fail(code, message string) error {
+	if w.finished {
+		return nil
+	}
+	w.finished = true
+	w.result.Status = types.ResponsesStatusFailed
+	w.result.Error = &types.ResponsesError{Code: code, Message: message}
+
+	if err := w.emit(types.ResponsesEventError, types.ResponsesStreamEvent{
+		Code:    code,
+		Message: message,
+	}); err != nil {
+		return err
+	}
+	return w.emit(types.ResponsesEventFailed, types.ResponsesStreamEvent{Response: w.result})
+}
+
+// start emits response.created before the first output event
+func (w *responsesStreamWriter) start() error {
+	if w.started {
+		return nil
+	}
+	w.started = true
+
+	created := *w.result
+	created.Output = []types.ResponsesOutputItem{}
+	return w.emit(types.ResponsesEventCreated, types.ResponsesStreamEvent{Response: &created})
+}
+
+// appendText writes a text delta into the message output item, opening it if needed
+func (w *responsesStreamWriter) appendText(delta string) error {
+	if !w.textOpen {
+		if err := w.closeToolCall(); err != nil {
+			return err
+		}
+		w.textOpen = true
+		w.text.Reset()
+		w.textIndex = len(w.result.Output)
+
+		item := types.NewResponsesMessageItem(fmt.Sprintf("msg_%s_%d", w.result.ID, w.textIndex), "")
+		item.Status = types.ResponsesStatusInProgress
+		item.Content = []types.ResponsesOutputContent{}
+		w.result.Output = append(w.result.Output, item)
+
+		if err := w.emit(types.ResponsesEventOutputItemAdded, types.ResponsesStreamEvent{
+			OutputIndex: intPtr(w.textIndex),
+			Item:        &item,
+		}); err != nil {
+			return err
+		}
+		if err := w.emit(types.ResponsesEventContentPartAdded, types.ResponsesStreamEvent{
+			ItemID:       item.ID,
+			OutputIndex:  intPtr(w.textIndex),
+			ContentIndex: intPtr(0),
+			Part:         &types.ResponsesOutputContent{Type: types.ResponsesContentOutputText, Annotations: []any{}},
+		}); err != nil {
+			return err
+		}
+	}
+
+	w.text.WriteString(delta)
+	return w.emit(types.ResponsesEventOutputTextDelta, types.ResponsesStreamEvent{
+		ItemID:       w.result.Output[w.textIndex].ID,
+		OutputIndex:  intPtr(w.textIndex),
+		ContentIndex: intPtr(0),
+		Delta:        delta,
+	})
+}
+
+func (w *responsesStreamWriter) closeText() error {
+	if !w.textOpen {
+		return nil
+	}
+	w.textOpen = false
+
+	text := w.text.String()
+	item := types.NewResponsesMessageItem(w.result.Output[w.textIndex].ID, text)
+	w.result.Output[w.textIndex] = item
+
+	if err := w.emit(types.ResponsesEventOutputTextDone, types.ResponsesStreamEvent{
+		ItemID:       item.ID,
+		OutputIndex:  intPtr(w.textIndex),
+		ContentIndex: intPtr(0),
+		Text:         &text,
+	}); err != nil {
+		return err
+	}
+	if err := w.emit(types.ResponsesEventContentPartDone, types.ResponsesStreamEvent{
+		ItemID:       item.ID,
+		OutputIndex:  intPtr(w.textIndex),
+		ContentIndex: intPtr(0),
+		Part:         &item.Content[0],
+	}); err != nil {
+		return err
+	}
+	return w.emit(types.ResponsesEventOutputItemDone, types.ResponsesStreamEvent{
+		OutputIndex: intPtr(w.textIndex),
+		Item:        &item,
+	})
+}
+
+// appendToolCall converts an OpenAI tool call delta into function call output item events
+func (w *responsesStreamWriter) appendToolCall(call any) error {
+	callMap, ok := call.(map[string]any)
+	if !ok {
+		return nil
+	}
+	toolIndex := 0
+	if idx, ok := callMap["index"].(float64); ok {
+		toolIndex = int(idx)
+	}
+	function, _ := callMap["function"].(map[string]any)
+
+	toolCall, exists := w.toolCalls[toolIndex]
+	if !exists {
+		if err := w.closeText(); err != nil {
+			return err
+		}
+		if err := w.closeToolCall(); err != nil {
+			return err
+		}
+
+		callID, _ := callMap["id"].(string)
+		name, _ := function["name"].(string)
+		toolCall = &responsesToolCall{
+			outputIndex: len(w.result.Output),
+			item: types.ResponsesOutputItem{
+				Type:   types.ResponsesItemFunctionCall,
+				ID:     "fc_" + callID,
+				Status: types.ResponsesStatusInProgress,
+				CallID: callID,
+				Name:   name,
+			},
+		}
+		w.toolCalls[toolIndex] = toolCall
+		w.toolOpen = toolCall
+		w.result.Output = append(w.result.Output, toolCall.item)
+
+		if err := w.emit(types.ResponsesEventOutputItemAdded, types.ResponsesStreamEvent{
+			OutputIndex: intPtr(toolCall.outputIndex),
+			Item:        &toolCall.item,
+		}); err != nil {
+			return err
+		}
+	} else if w.toolOpen != toolCall {
+		// Output items cannot be reopened once another item has started
+		return nil
+	}
+
+	arguments, _ := function["arguments"].(string)
+	if arguments == "" {
+		return nil
+	}
+	toolCall.item.Arguments += arguments
+	return w.emit(types.ResponsesEventFunctionArgsDelta, types.ResponsesStreamEvent{
+		ItemID:      toolCall.item.ID,
+		OutputIndex: intPtr(toolCall.outputIndex),
+		Delta:       arguments,
+	})
+}
+
+func (w *responsesStreamWriter) closeToolCall() error {
+	toolCall := w.toolOpen
+	if toolCall == nil {
+		return nil
+	}
+	w.toolOpen = nil
+
+	toolCall.item.Status = types.ResponsesStatusCompleted
+	w.result.Output[toolCall.outputIndex] = toolCall.item
+
+	arguments := toolCall.item.Arguments
+	if err := w.emit(types.ResponsesEventFunctionArgsDone, types.ResponsesStreamEvent{
+		ItemID:      toolCall.item.ID,
+		OutputIndex: intPtr(toolCall.outputIndex),
+		Arguments:   &arguments,
+	}); err != nil {
+		return err
+	}
+	return w.emit(types.ResponsesEventOutputItemDone, types.ResponsesStreamEvent{
+		OutputIndex: intPtr(toolCall.outputIndex),
+		Item:        &toolCall.item,
+	})
+}
+
+// emit writes a Responses API event with an increasing sequence number
+func (w *responsesStreamWriter) emit(eventType string, event types.ResponsesStreamEvent) error {
+	event.Type = eventType
+	event.SequenceNumber = w.sequence
+	w.sequence++
+	return w.writeEvent(eventType, event)
+}
+
+func intPtr(v int) *int {
+	return &v
+}
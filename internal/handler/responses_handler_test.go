@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+func newTestResponsesWriter() (*responsesStreamWriter, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	return newResponsesStreamWriter(c.Writer, "resp_1", 1700000000, "auto", "resp_0"), recorder
+}
+
+func TestResponsesStreamWriter_TextAndToolCall(t *testing.T) {
+	w, recorder := newTestResponsesWriter()
+
+	body := `data: {"model":"gpt-test","choices":[{"index":0,"delta":{"content":"Hel"}}]}` + "\n\n" +
+		`data: {"model":"gpt-test","choices":[{"index":0,"delta":{"content":"lo"}}]}` + "\n\n" +
+		`data: {"model":"gpt-test","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"search","arguments":"{\"q\""}}]}}]}` + "\n\n" +
+		`data: {"model":"gpt-test","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":":1}"}}]},"finish_reason":"tool_calls"}]}` + "\n\n" +
+		`data: {"model":"gpt-test","choices":[],"usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}` + "\n\n" +
+		"data: [DONE]\n\n"
+	_, err := w.WriteString(body)
+	require.NoError(t, err)
+
+	names, payloads := parseSSEEvents(t, recorder.Body.String())
+	assert.Equal(t, []string{
+		"response.created",
+		"response.output_item.added", "response.content_part.added",
+		"response.output_text.delta", "response.output_text.delta",
+		"response.output_text.done", "response.content_part.done", "response.output_item.done",
+		"response.output_item.added",
+		"response.function_call_arguments.delta", "response.function_call_arguments.delta",
+		"response.function_call_arguments.done", "response.output_item.done",
+		"response.completed",
+	}, names)
+	for i, payload := range payloads {
+		assert.Equal(t, float64(i), payload["sequence_number"])
+	}
+	assert.Equal(t, "Hello", payloads[5]["text"])
+	assert.Equal(t, `{"q":1}`, payloads[11]["arguments"])
+
+	result := w.response()
+	assert.Equal(t, types.ResponsesStatusCompleted, result.Status)
+	assert.Equal(t, "gpt-test", result.Model)
+	assert.Equal(t, 5, result.Usage.TotalTokens)
+	require.Len(t, result.Output, 2)
+
+	msg := result.OutputMessage()
+	assert.Equal(t, "Hello", msg.Content)
+	assert.Len(t, msg.Extra["tool_calls"], 1)
+}
+
+func TestResponsesStreamWriter_Error(t *testing.T) {
+	w, recorder := newTestResponsesWriter()
+
+	_, err := w.WriteString(`data: {"error":{"message":"boom"}}` + "\n\n" + "data: [DONE]\n\n")
+	require.NoError(t, err)
+	w.writeError(assert.AnError)
+
+	names, _ := parseSSEEvents(t, recorder.Body.String())
+	assert.Equal(t, []string{"error", "response.failed"}, names)
+	assert.Equal(t, types.ResponsesStatusFailed, w.response().Status)
+	assert.Equal(t, "boom", w.response().Error.Message)
+}
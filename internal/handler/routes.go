@@ -15,6 +15,23 @@ func RegisterHandlers(router *gin.Engine, serverCtx *bootstrap.ServiceContext) {
 		apiGroup.POST("/v1/chat/completions", IdentityMiddleware(serverCtx), ChatCompletionHandler(serverCtx))
 		apiGroup.GET("/v1/chat/requests/:requestId/status", ChatStatusHandler(serverCtx))
 
+		// Anthropic Messages 协议兼容接口
+		apiGroup.POST("/v1/messages", AnthropicAuthMiddleware(), IdentityMiddleware(serverCtx), AnthropicMessagesHandler(serverCtx))
+
+		// OpenAI Responses 协议接口，支持通过 previous_response_id 续接服务端会话
+		apiGroup.POST("/v1/responses", IdentityMiddleware(serverCtx), ResponsesHandler(serverCtx))
+
+		// 模型列表，由路由候选模型和模型注册表生成
+		apiGroup.GET("/v1/models", ModelsHandler(serverCtx))
+
+		// 熔断器状态查询与强制重置，仅管理员可访问
+		adminGroup := apiGroup.Group("/admin", IdentityMiddleware(serverCtx), AdminMiddleware(serverCtx))
+		adminGroup.GET("/circuit-breakers", CircuitBreakersHandler(serverCtx))
+		adminGroup.POST("/circuit-breakers/reset", CircuitBreakerResetHandler(serverCtx))
+
+		// 工具就绪状态查询（后台探测缓存），仅管理员可访问
+		adminGroup.GET("/tool-readiness", ToolReadinessHandler(serverCtx))
+
 		// 添加转发接口 - 支持所有HTTP方法（仅在启用时注册）
 		if serverCtx.Config.Forward.Enabled {
 			apiGroup.Any("/forward/*path", ForwardHandler(serverCtx))
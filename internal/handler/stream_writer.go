@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zgsm-ai/chat-rag/internal/logger"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+	"go.uber.org/zap"
+)
+
+// chunkTranslator converts OpenAI style stream chunks into another streaming protocol
+type chunkTranslator interface {
+	// onChunk handles a parsed chat completion chunk
+	onChunk(chunk *types.ChatCompletionResponse) error
+	// onUpstreamError handles an error payload written by the chat pipeline
+	onUpstreamError(message string) error
+	// onDone handles the [DONE] marker
+	onDone() error
+}
+
+// sseChunkWriter intercepts the OpenAI style SSE data written by ChatCompletionLogic
+// and hands every complete chunk to a chunkTranslator
+type sseChunkWriter struct {
+	gin.ResponseWriter
+
+	pending    []byte
+	translator chunkTranslator
+}
+
+func newSSEChunkWriter(w gin.ResponseWriter, translator chunkTranslator) *sseChunkWriter {
+	return &sseChunkWriter{
+		ResponseWriter: w,
+		translator:     translator,
+	}
+}
+
+// Write buffers the written bytes and converts every complete SSE event
+func (w *sseChunkWriter) Write(data []byte) (int, error) {
+	w.pending = append(w.pending, data...)
+	for {
+		idx := bytes.Index(w.pending, []byte("\n\n"))
+		if idx < 0 {
+			break
+		}
+		event := string(w.pending[:idx])
+		w.pending = w.pending[idx+2:]
+		if err := w.handleEvent(event); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+// WriteString implements gin.ResponseWriter
+func (w *sseChunkWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteHeader ignores status changes once the stream has been committed
+func (w *sseChunkWriter) WriteHeader(statusCode int) {
+	if w.ResponseWriter.Written() {
+		return
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *sseChunkWriter) handleEvent(event string) error {
+	for _, line := range strings.Split(event, "\n") {
+		data, ok := strings.CutPrefix(strings.TrimSpace(line), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			return w.translator.onDone()
+		}
+		if err := w.handleData(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *sseChunkWriter) handleData(data string) error {
+	var errResp struct {
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(data), &errResp); err == nil && errResp.Error != nil {
+		return w.translator.onUpstreamError(errResp.Error.Message)
+	}
+
+	var chunk types.ChatCompletionResponse
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		logger.Warn("failed to parse stream chunk for protocol conversion", zap.Error(err))
+		return nil
+	}
+	return w.translator.onChunk(&chunk)
+}
+
+// writeEvent writes a named SSE event to the underlying writer and flushes it
+func (w *sseChunkWriter) writeEvent(eventType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal %s event: %w", eventType, err)
+	}
+	if _, err := fmt.Fprintf(w.ResponseWriter, "event: %s\ndata: %s\n\n", eventType, data); err != nil {
+		return err
+	}
+	w.ResponseWriter.Flush()
+	return nil
+}
@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zgsm-ai/chat-rag/internal/bootstrap"
+)
+
+// ToolReadinessHandler lists the probed readiness of the tools, per client and codebase.
+// The "tool" query parameter keeps the statuses of one tool.
+func ToolReadinessHandler(svcCtx *bootstrap.ServiceContext) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		statuses := svcCtx.ToolReadiness.Snapshot()
+		if tool := c.Query("tool"); tool != "" {
+			filtered := statuses[:0]
+			for _, status := range statuses {
+				if status.Tool == tool {
+					filtered = append(filtered, status)
+				}
+			}
+			statuses = filtered
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"enabled": svcCtx.ToolReadiness.Enabled(),
+			"tools":   statuses,
+		})
+	}
+}
@@ -39,6 +39,12 @@ type ChatCompletionLogic struct {
 	orderedModels   []string
 	streamCommitted bool
 	originalModel   string
+	// Next shrink step of the context-length recovery
+	recoveryStep int
+	// Server tools offered to function calling models as native tools
+	nativeTools []types.Function
+	// State of the last stream, continued when it fails mid-generation
+	lastStream *streamState
 }
 
 func NewChatCompletionLogic(
@@ -89,6 +95,7 @@ func (l *ChatCompletionLogic) processRequest() (*model.ChatLog, *ds.ProcessedPro
 
 	// Initialize chat log
 	chatLog := l.newChatLog(startTime)
+	l.applyReasoningEffort()
 
 	promptArranger := promptflow.NewPromptProcessor(
 		l.ctx,
@@ -208,6 +215,7 @@ func (l *ChatCompletionLogic) ChatCompletion() (resp *types.ChatCompletionRespon
 
 	if err == nil {
 		l.request.Messages = processedPrompt.Messages
+		l.setNativeTools(processedPrompt.Tools)
 		chatLog.IsPromptProceed = true
 	} else {
 		err := fmt.Errorf("ChatCompletion failed to process request:\n%w", err)
@@ -216,6 +224,18 @@ func (l *ChatCompletionLogic) ChatCompletion() (resp *types.ChatCompletionRespon
 		chatLog.IsPromptProceed = false
 	}
 
+	cacheKey := l.responseCacheKey(chatLog)
+	if cached := l.cachedResponse(cacheKey); cached != nil {
+		chatLog.CacheHit = true
+		l.responseHandler.extractResponseInfo(chatLog, cached)
+		return withReasoningMode(cached, l.reasoningMode()), nil
+	}
+	semanticQuery := l.semanticCacheQuery(chatLog)
+	if cached := l.semanticCachedResponse(semanticQuery, chatLog); cached != nil {
+		l.responseHandler.extractResponseInfo(chatLog, cached)
+		return withReasoningMode(cached, l.reasoningMode()), nil
+	}
+
 	// Create shared idle tracker for the entire request (both retry and degradation)
 	_, _, _, totalIdleTimeout := l.getRetryConfig()
 	idleTracker := timeout.NewIdleTracker(totalIdleTimeout)
@@ -228,6 +248,9 @@ func (l *ChatCompletionLogic) ChatCompletion() (resp *types.ChatCompletionRespon
 			zap.Strings("ordered", l.orderedModels),
 		)
 		resp, derr := l.callWithDegradation(l.request.LLMRequestParams, idleTracker)
+		for derr != nil && l.isContextLengthError(derr) && l.recoverContext(chatLog, idleTracker) {
+			resp, derr = l.callWithDegradation(l.request.LLMRequestParams, idleTracker)
+		}
 		if derr != nil {
 			chatLog.AddError(types.ErrApiError, derr)
 			return nil, derr
@@ -237,6 +260,9 @@ func (l *ChatCompletionLogic) ChatCompletion() (resp *types.ChatCompletionRespon
 		// Fallback to single model with retry
 		var err2 error
 		response, err2 = l.callModelWithRetry(l.request.Model, l.request.LLMRequestParams, idleTracker)
+		for err2 != nil && l.isContextLengthError(err2) && l.recoverContext(chatLog, idleTracker) {
+			response, err2 = l.callModelWithRetry(l.request.Model, l.request.LLMRequestParams, idleTracker)
+		}
 		if err2 != nil {
 			if l.isContextLengthError(err2) {
 				logger.ErrorC(l.ctx, "Input context too long, exceeded limit.", zap.Error(err2))
@@ -250,11 +276,19 @@ func (l *ChatCompletionLogic) ChatCompletion() (resp *types.ChatCompletionRespon
 		}
 	}
 
+	// Server tools called natively are executed and their results sent back to the model
+	response, err = l.completeNativeToolCalls(response, chatLog, idleTracker)
+	if err != nil {
+		chatLog.AddError(types.ErrApiError, err)
+		return nil, err
+	}
+
 	chatLog.Latency.MainModelLatency = time.Since(modelStart).Milliseconds()
 
 	// Extract response content and usage information
 	l.responseHandler.extractResponseInfo(chatLog, &response)
-	return &response, nil
+	l.storeCachedResponse(cacheKey, semanticQuery, completedResponse(chatLog, &response))
+	return withReasoningMode(&response, l.reasoningMode()), nil
 }
 
 // getRetryConfig returns retry and timeout configuration based on the current mode
@@ -329,6 +363,7 @@ func (l *ChatCompletionLogic) ChatCompletionStream() error {
 
 	if err == nil {
 		l.request.Messages = processedPrompt.Messages
+		l.setNativeTools(processedPrompt.Tools)
 		chatLog.IsPromptProceed = true
 	} else {
 		err := fmt.Errorf("ChatCompletionStream failed to process request: %w", err)
@@ -342,6 +377,15 @@ func (l *ChatCompletionLogic) ChatCompletionStream() error {
 		return fmt.Errorf("streaming not supported")
 	}
 
+	cacheKey := l.responseCacheKey(chatLog)
+	if cached := l.cachedResponse(cacheKey); cached != nil {
+		return l.replayCachedStream(flusher, cached, chatLog)
+	}
+	semanticQuery := l.semanticCacheQuery(chatLog)
+	if cached := l.semanticCachedResponse(semanticQuery, chatLog); cached != nil {
+		return l.replayCachedStream(flusher, cached, chatLog)
+	}
+
 	// Create shared idle tracker for the entire request (both retry and degradation)
 	_, _, _, totalIdleTimeout := l.getRetryConfig()
 	idleTracker := timeout.NewIdleTracker(totalIdleTimeout)
@@ -365,16 +409,21 @@ func (l *ChatCompletionLogic) ChatCompletionStream() error {
 				chatLog.AddError(types.ErrServerError, err)
 				return fmt.Errorf("LLM client creation failed: %w", err)
 			}
-			llmClient.SetTools(processedPrompt.Tools)
+			llmClient.SetTools(l.toolsFor(l.request.Model))
 			l.streamCommitted = false
 
-			err = l.handleStreamingWithTools(l.ctx, llmClient, flusher, chatLog, MaxToolCallDepth, idleTracker)
+			err = l.streamWithContextRecovery(llmClient, flusher, chatLog, idleTracker)
+			l.svcCtx.CircuitBreaker.RecordResult(l.request.Model, err)
 			if err == nil {
+				l.storeCachedResponse(cacheKey, semanticQuery, l.streamedResponse(chatLog))
 				return nil
 			}
 
 			lastErr = err
 			if l.streamCommitted {
+				if err = l.continueStream(flusher, chatLog, err, idleTracker); err == nil {
+					return nil
+				}
 				return l.handleStreamError(err, chatLog)
 			}
 
@@ -408,7 +457,19 @@ func (l *ChatCompletionLogic) ChatCompletionStream() error {
 
 	maxRetryCount, retryInterval, _, _ := l.getRetryConfig()
 	var lastErr error
-	for _, modelName := range models {
+	// Backups that already failed in a hedged race are not tried again
+	failedBackups := make(map[string]bool)
+	for i, modelName := range models {
+		if failedBackups[modelName] {
+			continue
+		}
+		if !l.circuitAllows(modelName) {
+			if lastErr == nil {
+				lastErr = types.NewModelServiceUnavailableError()
+			}
+			continue
+		}
+
 		// Update header immediately when switching to a different model in auto mode
 		if l.writer != nil {
 			l.writer.Header().Set(types.HeaderSelectLLm, modelName)
@@ -429,18 +490,45 @@ func (l *ChatCompletionLogic) ChatCompletionStream() error {
 				lastErr = err
 				logger.WarnC(l.ctx, "degradation(stream): failed to create llm client",
 					zap.String("model", modelName), zap.Error(err))
+				// Nothing was sent, give back the probe slot reserved by circuitAllows
+				l.svcCtx.CircuitBreaker.Release(modelName)
 				break
 			}
-			llmClient.SetTools(processedPrompt.Tools)
+			llmClient.SetTools(l.toolsFor(modelName))
+			if attempt == 0 {
+				var next []string
+				for _, nextModel := range models[i+1:] {
+					if !failedBackups[nextModel] {
+						next = append(next, nextModel)
+					}
+				}
+				llmClient = l.hedgeClient(llmClient, modelName, next)
+			}
 
-			err = l.handleStreamingWithTools(l.ctx, llmClient, flusher, chatLog, MaxToolCallDepth, idleTracker)
+			err = l.streamWithContextRecovery(llmClient, flusher, chatLog, idleTracker)
+			hedged, isHedged := llmClient.(*hedgedLLMClient)
+			if isHedged {
+				hedged.release()
+				chatLog.HedgedAttempts = append(chatLog.HedgedAttempts, hedged.lostAttempts()...)
+			}
+			if isHedged && hedged.failedBoth() {
+				// Both attempts are recorded by the hedged client
+				failedBackups[hedged.targets[1].model] = true
+			} else {
+				// l.request.Model is the winner when a hedge won the race
+				l.svcCtx.CircuitBreaker.RecordResult(l.request.Model, err)
+			}
 			if err == nil {
+				l.storeCachedResponse(cacheKey, semanticQuery, l.streamedResponse(chatLog))
 				return nil
 			}
 
 			lastErr = err
 			if l.streamCommitted {
-				// Already started streaming; report error to client and stop
+				// Already started streaming; continue from the partial output or report error to client and stop
+				if err = l.continueStream(flusher, chatLog, err, idleTracker); err == nil {
+					return nil
+				}
 				return l.handleStreamError(err, chatLog)
 			}
 
@@ -487,13 +575,16 @@ type streamState struct {
 	modelStart   time.Time
 	firstToken   bool // Flag to track if first token has been received
 	windowSent   bool // Flag to track if first token has been sent to client
+	reasoning    *reasoningNormalizer
+	toolCalls    map[int]*types.ToolCallInfo // Native tool calls, nil when tools are not offered natively
 }
 
-func newStreamState() *streamState {
+func newStreamState(reasoningMode string) *streamState {
 	return &streamState{
 		windowSize: 6,
 		modelStart: time.Now(),
 		firstToken: true, // Initialize as true to detect first token
+		reasoning:  newReasoningNormalizer(reasoningMode),
 	}
 }
 
@@ -511,31 +602,24 @@ func (l *ChatCompletionLogic) handleStreamingWithTools(
 		zap.String("promptMode", string(l.request.ExtraBody.PromptMode)),
 	)
 
+	l.lastStream = nil
+
 	// If raw mode, directly pass through results to client
 	if l.request.ExtraBody.PromptMode == types.Raw {
 		return l.handleRawModeStream(ctx, llmClient, flusher, chatLog, idleTracker)
 	}
 
 	// If Tools or Functions are provided, also use raw mode for direct tool handling
-	hasTools := false
-	if tools, ok := l.request.Extra["tools"]; ok {
-		if toolsSlice, ok := tools.([]any); ok && len(toolsSlice) > 0 {
-			hasTools = true
-		}
-	}
-	hasFunctions := false
-	if functions, ok := l.request.Extra["functions"]; ok {
-		if functionsSlice, ok := functions.([]any); ok && len(functionsSlice) > 0 {
-			hasFunctions = true
-		}
-	}
-
-	if hasTools || hasFunctions {
+	if l.hasClientTools() {
 		logger.InfoC(ctx, "received function call in streaming request")
 		return l.handleRawModeStream(ctx, llmClient, flusher, chatLog, idleTracker)
 	}
 
-	state := newStreamState()
+	state := newStreamState(l.reasoningMode())
+	if len(l.nativeTools) > 0 && remainingDepth > 0 {
+		state.toolCalls = make(map[int]*types.ToolCallInfo)
+	}
+	l.lastStream = state
 
 	// Phase 1: Process streaming response
 	toolDetected, err := l.processStream(ctx, llmClient, flusher, state, remainingDepth, chatLog, idleTracker)
@@ -545,6 +629,9 @@ func (l *ChatCompletionLogic) handleStreamingWithTools(
 	}
 
 	// Phase 2: Handle tool execution or complete response
+	if len(state.toolCalls) > 0 {
+		return l.handleNativeToolCalls(ctx, llmClient, flusher, chatLog, state, remainingDepth, idleTracker)
+	}
 	if toolDetected {
 		return l.handleToolExecution(ctx, llmClient, flusher, chatLog, state, remainingDepth, idleTracker)
 	}
@@ -575,7 +662,11 @@ func (l *ChatCompletionLogic) processStream(
 
 		return l.handleStreamChunk(ctx, flusher, llmResp.ResonseLine, state, remainingDepth, chatLog, idleTimer)
 	})
-	if c, ok := llmClient.(*client.LLMClient); ok {
+	statsClient := llmClient
+	if hedged, ok := llmClient.(*hedgedLLMClient); ok {
+		statsClient = hedged.current()
+	}
+	if c, ok := statsClient.(*client.LLMClient); ok {
 		streamState := c.StreamChunkInfo
 		if streamState != nil {
 			chatLog.Latency.ChunkInfo = &model.StreamChunkInfo{
@@ -623,6 +714,16 @@ func (l *ChatCompletionLogic) handleStreamChunk(
 	chatLog *model.ChatLog,
 	idleTimer *timeout.IdleTimer,
 ) error {
+	// Text held back by the reasoning splitter goes out before the stream ends
+	if isDoneLine(rawLine) {
+		if flushed := state.reasoning.flushLine(); flushed != "" {
+			if err := l.handleStreamChunk(ctx, flusher, flushed, state, remainingDepth, chatLog, idleTimer); err != nil {
+				return err
+			}
+		}
+	}
+
+	rawLine, reasoning := state.reasoning.normalizeLine(rawLine)
 	content, usage, resp := l.responseHandler.extractStreamingData(rawLine)
 	if resp != nil {
 		state.response = resp
@@ -630,11 +731,19 @@ func (l *ChatCompletionLogic) handleStreamChunk(
 	if usage != nil {
 		l.usage = usage
 	}
-	if content == "" {
+
+	// Native tool calls are executed by the server, they and the end of their turn are not forwarded
+	if state.toolCalls != nil && (isToolCallLine(rawLine) || len(state.toolCalls) > 0 && isDoneLine(rawLine)) {
+		var ignored types.ResponseContent
+		l.responseHandler.extractSSEFunctionResp(rawLine, &ignored, state.toolCalls)
+		return nil
+	}
+
+	if content == "" && reasoning == "" {
 		return l.sendRawLine(flusher, rawLine)
 	}
 
-	// Log first token response
+	// Log first token response, reasoning counts as the first token
 	if state.firstToken && content != "[DONE]" {
 		// Mark streaming committed and set selected model header
 		l.streamCommitted = true
@@ -655,14 +764,24 @@ func (l *ChatCompletionLogic) handleStreamChunk(
 		}
 	}
 
+	// Reasoning is not held in the window, it never contains tools
+	if content == "" {
+		return l.sendRawLine(flusher, rawLine)
+	}
+	if reasoning != "" {
+		if err := l.sendStreamDelta(flusher, state.response, types.Delta{ReasoningContent: reasoning}); err != nil {
+			return err
+		}
+	}
+
 	// Add to window and complete content
 	state.window = append(state.window, content)
 	if content != "[DONE]" {
 		state.fullContent.WriteString(content)
 	}
 
-	// Check for tool detection
-	if !state.toolDetected && l.toolExecutor != nil && remainingDepth > 0 &&
+	// Check for tool detection, XML tools are only offered when tools are not offered natively
+	if !state.toolDetected && l.toolExecutor != nil && remainingDepth > 0 && len(l.nativeTools) == 0 &&
 		l.svcCtx.Config.Tools != nil && !l.svcCtx.Config.Tools.DisableTools {
 		if err := l.detectAndHandleTool(ctx, flusher, state); err != nil {
 			return err
@@ -717,7 +836,7 @@ func (l *ChatCompletionLogic) detectAndHandleTool(ctx context.Context, flusher h
 	return nil
 }
 
-// handleToolExecution executes the detected tool and continues processing
+// handleToolExecution executes the detected tools and continues processing
 func (l *ChatCompletionLogic) handleToolExecution(
 	ctx context.Context,
 	llmClient client.LLMInterface,
@@ -727,97 +846,28 @@ func (l *ChatCompletionLogic) handleToolExecution(
 	remainingDepth int,
 	idleTracker *timeout.IdleTracker,
 ) error {
-	logger.InfoC(ctx, "starting to call tool", zap.String("name", state.toolName))
-	toolContent := strings.Join(state.window, "")
-	toolCall := model.ToolCall{
-		ToolName:  state.toolName,
-		ToolInput: toolContent,
-	}
-
-	l.updateToolStatus(state.toolName, types.ToolStatusRunning)
-	// Send tool use information to client page
-	if err := l.sendStreamContent(flusher, state.response,
-		fmt.Sprintf("%s`%s` %s", types.StrFilterToolSearchStart, state.toolName,
-			types.StrFilterToolSearchEnd)); err != nil {
-		return err
-	}
-
-	// wait client to refesh content
-	for i := 0; i < 5; i++ {
-		if err := l.sendStreamContent(flusher, state.response, "."); err != nil {
-			return err
-		}
-		time.Sleep(600 * time.Millisecond)
-	}
-
-	// execute and record tool call latency
-	toolStart := time.Now()
-	result, err := l.toolExecutor.ExecuteTools(ctx, state.toolName, toolContent)
-	toolLatency := time.Since(toolStart).Milliseconds()
-	toolCall.Latency = toolLatency
-	toolCall.ToolOutput = result
-
-	status := types.ToolStatusSuccess
+	// Every complete invocation of the turn is run, the results are returned in one message
+	runs := l.xmlToolRuns(ctx, state.toolName, strings.Join(state.window, ""))
+	err := l.runTools(ctx, runs, func(names []string) error {
+		return l.sendToolStart(flusher, state.response, names...)
+	})
 	if err != nil {
-		logger.WarnC(ctx, "tool execute failed", zap.String("tool", state.toolName), zap.Error(err))
-		status = types.ToolStatusFailed
-		result = fmt.Sprintf("%s execute failed, err: %v", state.toolName, err)
-		toolCall.Error = err.Error()
-	} else {
-		logResult := result
-		if len(logResult) > 400 {
-			logResult = logResult[:400] + "..."
-		}
-		logger.InfoC(ctx, "tool execute succeed", zap.String("tool", state.toolName),
-			zap.String("result", logResult), zap.Int("result length", len(result)))
-
-		if len(result) > MaxToolResultLength {
-			logger.WarnC(ctx, "tool result truncated due to excessive length",
-				zap.String("tool", state.toolName),
-				zap.Int("original_length", len(result)),
-				zap.Int("truncated_length", MaxToolResultLength))
-			result = result[:MaxToolResultLength] + "... (truncated due to excessive length)"
-		}
+		return err
 	}
-	toolCall.ResultStatus = string(status)
 
 	l.request.Messages = append(l.request.Messages,
 		types.Message{
 			Role:    types.RoleAssistant,
 			Content: state.fullContent.String(),
 		},
-		types.Message{
-			Role: types.RoleUser,
-			Content: []model.Content{
-				{
-					Type: model.ContTypeText,
-					Text: fmt.Sprintf("[%s] Result:", state.toolName),
-				}, {
-					Type: model.ContTypeText,
-					Text: result,
-				}, {
-					Type: model.ContTypeText,
-					Text: fmt.Sprintf("Please summarize the key findings and/or code from the results above within the <think></think> tags. No need to summarize error messages. \nIf the search failed, don't say 'failed', describe this outcome as 'did not found relevant results' instead - MUST NOT using terms like 'failure', 'error', or 'unsuccessful' in your description. \nIn your summary, must include the name of the tool used and specify which tools you intend to use next. \nWhen appropriate, prioritize using these tools: %s", l.toolExecutor.GetAllTools()),
-				},
-			},
-		},
+		l.xmlToolResultMessage(runs),
 	)
-
-	l.updateToolStatus(state.toolName, status)
 	chatLog.ProcessedPrompt = l.request.Messages
-	chatLog.ToolCalls = append(chatLog.ToolCalls, toolCall)
-
-	// sending tool call ending response to client page
-	if err := l.sendStreamContent(flusher, state.response, types.StrFilterToolAnalyzing); err != nil {
-		return err
-	}
-	for i := 0; i < 3; i++ {
-		time.Sleep(100 * time.Millisecond)
-		if err := l.sendStreamContent(flusher, state.response, "."); err != nil {
-			return err
-		}
+	for _, run := range runs {
+		chatLog.ToolCalls = append(chatLog.ToolCalls, run.call)
 	}
-	if err := l.sendStreamContent(flusher, state.response, "\n"); err != nil {
+
+	if err := l.sendToolAnalyzing(flusher, state.response); err != nil {
 		return err
 	}
 
@@ -910,8 +960,10 @@ func (l *ChatCompletionLogic) updateStreamStats(chatLog *model.ChatLog, state *s
 	endTime := time.Since(state.modelStart)
 	logger.InfoC(l.ctx, "[last-token] stream end", zap.Duration("totalLatency", endTime))
 	chatLog.Latency.MainModelLatency = endTime.Milliseconds()
+	_, content := splitReasoning(state.fullContent.String())
 	chatLog.ResponseContent = &types.ResponseContent{
-		Content: state.fullContent.String(),
+		Content:          content,
+		ReasoningContent: state.reasoning.reasoning.String(),
 	}
 
 	if l.usage != nil {
@@ -920,6 +972,7 @@ func (l *ChatCompletionLogic) updateStreamStats(chatLog *model.ChatLog, state *s
 		chatLog.Usage = l.responseHandler.calculateUsage(
 			chatLog.Tokens.Processed.All,
 			chatLog.ResponseContent.Content,
+			chatLog.ResponseContent.ReasoningContent,
 		)
 		logger.InfoC(l.ctx, "calculated usage for streaming response")
 	}
@@ -938,15 +991,17 @@ func (l *ChatCompletionLogic) sendRawLine(flusher http.Flusher, raw string) erro
 }
 
 func (l *ChatCompletionLogic) sendStreamContent(flusher http.Flusher, response *types.ChatCompletionResponse, content string) error {
+	return l.sendStreamDelta(flusher, response, types.Delta{Content: content})
+}
+
+func (l *ChatCompletionLogic) sendStreamDelta(flusher http.Flusher, response *types.ChatCompletionResponse, delta types.Delta) error {
 	if response == nil {
-		logger.WarnC(l.ctx, "response is nil, use default response", zap.String("method", "sendStreamContent"))
+		logger.WarnC(l.ctx, "response is nil, use default response", zap.String("method", "sendStreamDelta"))
 		response = &types.ChatCompletionResponse{}
 	}
 
 	response.Choices = []types.Choice{{
-		Delta: types.Delta{
-			Content: content,
-		},
+		Delta: delta,
 	}}
 	jsonData, _ := json.Marshal(response)
 
@@ -1008,6 +1063,9 @@ func (l *ChatCompletionLogic) isContextLengthError(err error) bool {
 		strings.Contains(errMsg, "Input text is too long")
 }
 
+// errLLMClientCreation marks the errors of callModelWithRetry returned before any request was sent
+var errLLMClientCreation = errors.New("llm client creation failed")
+
 func (l *ChatCompletionLogic) callModelWithRetry(modelName string, params types.LLMRequestParams, idleTrackerOpt ...*timeout.IdleTracker) (types.ChatCompletionResponse, error) {
 	nilResp := types.ChatCompletionResponse{}
 
@@ -1035,14 +1093,16 @@ func (l *ChatCompletionLogic) callModelWithRetry(modelName string, params types.
 		if err != nil {
 			logger.WarnC(l.ctx, "single-model retry: failed to create llm client",
 				zap.String("model", modelName), zap.Error(err))
-			return nilResp, err
+			return nilResp, fmt.Errorf("%w: %w", errLLMClientCreation, err)
 		}
+		llmClient.SetTools(l.toolsFor(modelName))
 
 		// Use the shared idle tracker instead of creating a new one
 		timerCtx, timerCancel, idleTimer := timeout.NewIdleTimer(l.ctx, idleTimeout, sharedTracker)
 		resp, err := llmClient.ChatLLMWithMessagesRaw(timerCtx, params, idleTimer)
 		idleTimer.Stop()
 		timerCancel()
+		l.svcCtx.CircuitBreaker.RecordResult(modelName, err)
 		if err == nil {
 			l.request.Model = modelName
 			if l.writer != nil {
@@ -1106,6 +1166,13 @@ func (l *ChatCompletionLogic) callWithDegradation(params types.LLMRequestParams,
 
 	var lastErr error
 	for _, modelName := range ordered {
+		if !l.circuitAllows(modelName) {
+			if lastErr == nil {
+				lastErr = types.NewModelServiceUnavailableError()
+			}
+			continue
+		}
+
 		logger.InfoC(l.ctx, "degradation: attempting model",
 			zap.String("model", modelName),
 		)
@@ -1115,6 +1182,10 @@ func (l *ChatCompletionLogic) callWithDegradation(params types.LLMRequestParams,
 			logger.InfoC(l.ctx, "degradation: model succeeded", zap.String("model", modelName))
 			return resp, nil
 		}
+		if errors.Is(err, errLLMClientCreation) {
+			// Nothing was sent, give back the probe slot reserved by circuitAllows
+			l.svcCtx.CircuitBreaker.Release(modelName)
+		}
 
 		lastErr = err
 
@@ -1135,6 +1206,65 @@ func (l *ChatCompletionLogic) callWithDegradation(params types.LLMRequestParams,
 	return nilResp, lastErr
 }
 
+// hedgeClient races the next available model of the degradation order against the model
+// when the caller has a hedge delay configured, otherwise returns the client unchanged
+func (l *ChatCompletionLogic) hedgeClient(llmClient client.LLMInterface, modelName string, next []string) client.LLMInterface {
+	caller := ""
+	if l.identity != nil {
+		caller = l.identity.Caller
+	}
+	delay := l.svcCtx.Config.Hedge.DelayFor(caller)
+	if delay <= 0 {
+		return llmClient
+	}
+
+	for _, backupModel := range next {
+		// The backup takes a probe slot of a half-open circuit, the hedged client records or releases it
+		if backupModel == modelName || !l.svcCtx.CircuitBreaker.Allow(backupModel) {
+			continue
+		}
+		backup, err := client.NewLLMClient(l.svcCtx.Config.LLM, l.svcCtx.Config.LLMTimeout, backupModel, l.headers)
+		if err != nil {
+			l.svcCtx.CircuitBreaker.Release(backupModel)
+			logger.WarnC(l.ctx, "hedge: failed to create llm client",
+				zap.String("model", backupModel), zap.Error(err))
+			continue
+		}
+		backup.SetTools(l.toolsFor(backupModel))
+
+		logger.InfoC(l.ctx, "hedge: enabled for request",
+			zap.String("caller", caller),
+			zap.String("primary", modelName),
+			zap.String("hedge", backupModel),
+			zap.Duration("delay", delay),
+		)
+		return newHedgedLLMClient(
+			hedgeTarget{model: modelName, llm: llmClient},
+			hedgeTarget{model: backupModel, llm: backup},
+			delay,
+			func(winner string) {
+				l.request.Model = winner
+				if l.writer != nil {
+					l.writer.Header().Set(types.HeaderSelectLLm, winner)
+				}
+			},
+			l.svcCtx.CircuitBreaker,
+		)
+	}
+	return llmClient
+}
+
+// circuitAllows reports whether the circuit breaker lets a degradation attempt reach the model
+func (l *ChatCompletionLogic) circuitAllows(modelName string) bool {
+	if l.svcCtx.CircuitBreaker.Allow(modelName) {
+		return true
+	}
+	logger.WarnC(l.ctx, "degradation: circuit open, skipping model",
+		zap.String("model", modelName),
+	)
+	return false
+}
+
 // isRetryableAPIError returns true when we should retry the same model: timeout/network/5xx
 func isRetryableAPIError(err error) bool {
 	if err == nil {
@@ -1174,6 +1304,7 @@ func (l *ChatCompletionLogic) handleRawModeStream(
 	// Initialize accumulated response for function call format
 	accumulatedResp := &types.ResponseContent{}
 	toolCallsMap := make(map[int]*types.ToolCallInfo) // Map to accumulate tool calls by index
+	reasoning := newReasoningNormalizer(l.reasoningMode())
 
 	// Use the provided shared idle tracker instead of creating a new one
 	_, _, idleTimeout, _ := l.getRetryConfig()
@@ -1187,8 +1318,20 @@ func (l *ChatCompletionLogic) handleRawModeStream(
 		// Handle response headers
 		l.handleResonseHeaders(llmResp.Header, types.ResponseHeadersToForward, chatLog)
 
-		// Direct pass through response line to client
+		// Text held back by the reasoning splitter goes out before the stream ends
+		if isDoneLine(llmResp.ResonseLine) {
+			if flushed := reasoning.flushLine(); flushed != "" {
+				if _, err := fmt.Fprintf(l.writer, "%s\n\n", flushed); err != nil {
+					return err
+				}
+			}
+		}
+
+		// Direct pass through response line to client, with reasoning handled according to the mode
 		if llmResp.ResonseLine != "" {
+			usageLine := llmResp.ResonseLine
+			llmResp.ResonseLine, _ = reasoning.normalizeLine(llmResp.ResonseLine)
+
 			// Record first token time
 			if !firstTokenReceived {
 				firstTokenReceived = true
@@ -1203,7 +1346,7 @@ func (l *ChatCompletionLogic) handleRawModeStream(
 			}
 
 			// Extract usage information from streaming response
-			_, usage, _ := l.responseHandler.extractStreamingData(llmResp.ResonseLine)
+			_, usage, _ := l.responseHandler.extractStreamingData(usageLine)
 			if usage != nil {
 				l.usage = usage
 			}
@@ -1279,7 +1422,9 @@ func (l *ChatCompletionLogic) handleRawModeStream(
 	logger.InfoC(ctx, "raw mode streaming completed",
 		zap.Int64("modelLatency", chatLog.Latency.MainModelLatency))
 
-	// Finalize and store accumulated response
+	// Finalize and store accumulated response, reasoning is logged apart from the content
+	_, accumulatedResp.Content = splitReasoning(accumulatedResp.Content)
+	accumulatedResp.ReasoningContent = reasoning.reasoning.String()
 	l.recordFuncionCallResponse(ctx, accumulatedResp, toolCallsMap, chatLog)
 
 	return nil
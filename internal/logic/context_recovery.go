@@ -0,0 +1,329 @@
+package logic
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/zgsm-ai/chat-rag/internal/client"
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/logger"
+	"github.com/zgsm-ai/chat-rag/internal/model"
+	"github.com/zgsm-ai/chat-rag/internal/promptflow/processor"
+	"github.com/zgsm-ai/chat-rag/internal/timeout"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+	"github.com/zgsm-ai/chat-rag/internal/utils"
+)
+
+const (
+	toolResultOmitted = "[tool result omitted to fit the context window]"
+	historySummary    = "Summary of the earlier conversation, older messages were summarized to fit the context window:\n\n"
+)
+
+// recoverContext shrinks the request messages with the next step of the agent's shrink policy
+// after a context-length error. Steps that leave the prompt unchanged are skipped. It returns
+// false when recovery is disabled, every step was applied or the idle budget is spent, in which
+// case the caller reports the context-length error as before.
+func (l *ChatCompletionLogic) recoverContext(chatLog *model.ChatLog, idleTracker *timeout.IdleTracker) bool {
+	cfg := l.svcCtx.Config.ContextRecovery
+	if !cfg.Enabled {
+		return false
+	}
+
+	policy := cfg.PolicyFor(chatLog.Agent)
+	for l.recoveryStep < len(policy.Steps) {
+		if idleTracker != nil && idleTracker.Remaining() <= 0 {
+			logger.WarnC(l.ctx, "context recovery: idle budget spent, giving up")
+			return false
+		}
+
+		stepName := policy.Steps[l.recoveryStep]
+		l.recoveryStep++
+
+		start := time.Now()
+		tokensBefore := l.countTokensInMessages(l.request.Messages)
+		messages, err := l.applyShrinkStep(stepName, policy, l.request.Messages, idleTracker)
+		step := model.ContextRecoveryStep{
+			Step:         stepName,
+			TokensBefore: tokensBefore,
+			TokensAfter:  tokensBefore,
+			Latency:      time.Since(start).Milliseconds(),
+		}
+		if err != nil {
+			step.Error = err.Error()
+		}
+		if messages != nil {
+			step.TokensAfter = l.countTokensInMessages(messages)
+		}
+		chatLog.ContextRecovery = append(chatLog.ContextRecovery, step)
+
+		logger.InfoC(l.ctx, "context recovery: applied shrink step",
+			zap.String("agent", chatLog.Agent),
+			zap.String("step", stepName),
+			zap.Int("tokensBefore", step.TokensBefore),
+			zap.Int("tokensAfter", step.TokensAfter),
+			zap.Error(err),
+		)
+		if step.TokensAfter < step.TokensBefore {
+			l.request.Messages = messages
+			return true
+		}
+	}
+	return false
+}
+
+// streamWithContextRecovery streams the response and, when the prompt exceeds the context window
+// before anything was sent to the client, shrinks the prompt and streams again
+func (l *ChatCompletionLogic) streamWithContextRecovery(llmClient client.LLMInterface, flusher http.Flusher, chatLog *model.ChatLog, idleTracker *timeout.IdleTracker) error {
+	for {
+		err := l.handleStreamingWithTools(l.ctx, llmClient, flusher, chatLog, MaxToolCallDepth, idleTracker)
+		if err == nil || l.streamCommitted || !l.isContextLengthError(err) || !l.recoverContext(chatLog, idleTracker) {
+			return err
+		}
+	}
+}
+
+// applyShrinkStep returns the shrunk messages, nil when the step does not apply
+func (l *ChatCompletionLogic) applyShrinkStep(step string, policy config.ShrinkPolicyConfig, messages []types.Message, idleTracker *timeout.IdleTracker) ([]types.Message, error) {
+	switch step {
+	case config.ShrinkStepDropToolResults:
+		return dropToolResults(messages, policy.KeepRecentMessages), nil
+	case config.ShrinkStepSummarizeHistory:
+		return l.summarizeHistory(messages, policy, idleTracker)
+	case config.ShrinkStepTruncateUserContent:
+		return truncateUserContent(messages, policy.MaxUserContentChars), nil
+	default:
+		return nil, fmt.Errorf("unknown shrink step: %s", step)
+	}
+}
+
+// summarizeHistory replaces the history before the recent messages with a summary of the summary model
+func (l *ChatCompletionLogic) summarizeHistory(messages []types.Message, policy config.ShrinkPolicyConfig, idleTracker *timeout.IdleTracker) ([]types.Message, error) {
+	head, older, recent := splitHistory(messages, policy.KeepRecentMessages)
+	if len(older) == 0 {
+		return nil, nil
+	}
+
+	summaryModel := policy.SummaryModel
+	if summaryModel == "" {
+		summaryModel = l.svcCtx.Config.ContextCompressConfig.SummaryModel
+	}
+	if summaryModel == "" {
+		return nil, fmt.Errorf("no summary model configured")
+	}
+
+	// Keep the summary input within the summary model threshold, oldest messages go first
+	if threshold := l.svcCtx.Config.ContextCompressConfig.SummaryModelTokenThreshold; threshold > 0 {
+		for len(older) > 1 && l.countTokensInMessages(older) > threshold {
+			older = older[1:]
+		}
+	}
+
+	// Summaries are not billed to the user
+	headers := make(http.Header)
+	if l.headers != nil {
+		headers = l.headers.Clone()
+	}
+	headers.Set(types.HeaderQuotaIdentity, "system")
+
+	llmClient, err := client.NewLLMClient(l.svcCtx.Config.LLM, l.svcCtx.Config.LLMTimeout, summaryModel, &headers)
+	if err != nil {
+		return nil, fmt.Errorf("create summary client: %w", err)
+	}
+
+	ctx := l.ctx
+	if idleTracker != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(l.ctx, idleTracker.Remaining())
+		defer cancel()
+		start := time.Now()
+		defer func() { idleTracker.Consume(time.Since(start)) }()
+	}
+
+	toSummarize := append(append([]types.Message{}, older...), types.Message{
+		Role:    types.RoleUser,
+		Content: "Summarize the conversation so far, as described in the prompt instructions.",
+	})
+	summary, err := llmClient.GenerateContent(ctx, processor.USER_SUMMARY_PROMPT, toSummarize)
+	if err != nil {
+		return nil, fmt.Errorf("summarize history: %w", err)
+	}
+
+	shrunk := make([]types.Message, 0, len(head)+1+len(recent))
+	shrunk = append(shrunk, head...)
+	shrunk = append(shrunk, types.Message{Role: types.RoleAssistant, Content: historySummary + summary})
+	shrunk = append(shrunk, recent...)
+	return shrunk, nil
+}
+
+// splitHistory splits messages into the leading system messages, the older history and the recent
+// messages. The recent part starts at a user message so that no tool result loses its call.
+func splitHistory(messages []types.Message, keepRecent int) (head, older, recent []types.Message) {
+	start := 0
+	for start < len(messages) && messages[start].Role == types.RoleSystem {
+		start++
+	}
+
+	split := len(messages) - max(keepRecent, 1)
+	for split > start && messages[split].Role != types.RoleUser {
+		split--
+	}
+	if split <= start {
+		return messages[:start], nil, messages[start:]
+	}
+	return messages[:start], messages[start:split], messages[split:]
+}
+
+// dropToolResults replaces the content of tool results before the recent messages with a placeholder,
+// nil when there is none. Tool messages are kept so that tool calls stay paired with their results.
+func dropToolResults(messages []types.Message, keepRecent int) []types.Message {
+	var shrunk []types.Message
+	for i := 0; i < len(messages)-keepRecent; i++ {
+		msg := messages[i]
+		var replaced string
+		switch {
+		case msg.Role == types.RoleTool:
+			replaced = toolResultOmitted
+		case msg.Role == types.RoleUser:
+			header, ok := toolResultHeader(utils.GetContentAsString(msg.Content))
+			if !ok {
+				continue
+			}
+			replaced = header + "\n" + toolResultOmitted
+		default:
+			continue
+		}
+		if utils.GetContentAsString(msg.Content) == replaced {
+			continue
+		}
+
+		if shrunk == nil {
+			shrunk = append([]types.Message(nil), messages...)
+		}
+		msg.Content = replaced
+		shrunk[i] = msg
+	}
+	return shrunk
+}
+
+// toolResultHeader returns the "[tool] Result:" header of a user message carrying a tool result
+func toolResultHeader(content string) (string, bool) {
+	if !strings.HasPrefix(content, "[") {
+		return "", false
+	}
+	end := strings.Index(content, "] Result:")
+	if end < 0 || strings.Contains(content[:end], "\n") {
+		return "", false
+	}
+	return content[:end+len("] Result:")], true
+}
+
+// truncateUserContent keeps the head and tail of the text of user messages longer than maxChars,
+// nil when there is none. Images and other non-text parts of multipart messages are kept.
+func truncateUserContent(messages []types.Message, maxChars int) []types.Message {
+	var shrunk []types.Message
+	for i, msg := range messages {
+		if msg.Role != types.RoleUser {
+			continue
+		}
+
+		var content interface{}
+		switch v := msg.Content.(type) {
+		case string:
+			text := []rune(v)
+			if len(text) <= maxChars {
+				continue
+			}
+			half := maxChars / 2
+			content = string(text[:half]) + truncatedMarker(len(text)-2*half) + string(text[len(text)-half:])
+		case []interface{}:
+			parts, ok := truncateTextParts(v, maxChars)
+			if !ok {
+				continue
+			}
+			content = parts
+		default:
+			continue
+		}
+
+		if shrunk == nil {
+			shrunk = append([]types.Message(nil), messages...)
+		}
+		msg.Content = content
+		shrunk[i] = msg
+	}
+	return shrunk
+}
+
+// truncateTextParts keeps the head and tail of the text of the content parts, as if the text parts were
+// one text. Text parts left empty are dropped, other parts are kept in place. The parts are copied, false
+// when the text is not longer than maxChars.
+func truncateTextParts(parts []interface{}, maxChars int) ([]interface{}, bool) {
+	total := 0
+	for _, item := range parts {
+		if text, ok := textPart(item); ok {
+			total += len([]rune(text))
+		}
+	}
+	if total <= maxChars {
+		return nil, false
+	}
+
+	half := maxChars / 2
+	headEnd, tailStart := half, total-half
+	truncated := make([]interface{}, 0, len(parts))
+	pos, marked := 0, false
+	for _, item := range parts {
+		text, ok := textPart(item)
+		if !ok {
+			truncated = append(truncated, item)
+			continue
+		}
+
+		runes := []rune(text)
+		start, end := pos, pos+len(runes)
+		pos = end
+
+		var kept strings.Builder
+		if start < headEnd {
+			kept.WriteString(string(runes[:min(end, headEnd)-start]))
+		}
+		if !marked && end >= headEnd {
+			kept.WriteString(truncatedMarker(total - 2*half))
+			marked = true
+		}
+		if end > tailStart {
+			kept.WriteString(string(runes[max(start, tailStart)-start:]))
+		}
+		if kept.Len() == 0 {
+			continue
+		}
+
+		part := make(map[string]interface{}, len(item.(map[string]interface{})))
+		for k, v := range item.(map[string]interface{}) {
+			part[k] = v
+		}
+		part["text"] = kept.String()
+		truncated = append(truncated, part)
+	}
+	return truncated, true
+}
+
+// textPart returns the text of a text content part
+func textPart(item interface{}) (string, bool) {
+	part, ok := item.(map[string]interface{})
+	if !ok || part["type"] != utils.ContentTypeText {
+		return "", false
+	}
+	text, _ := part["text"].(string)
+	return text, true
+}
+
+// truncatedMarker replaces the omitted characters of truncated user content
+func truncatedMarker(omitted int) string {
+	return fmt.Sprintf("\n\n[... %d characters truncated to fit the context window ...]\n\n", omitted)
+}
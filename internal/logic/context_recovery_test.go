@@ -0,0 +1,156 @@
+package logic
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zgsm-ai/chat-rag/internal/bootstrap"
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/model"
+	"github.com/zgsm-ai/chat-rag/internal/timeout"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+func TestSplitHistory(t *testing.T) {
+	messages := []types.Message{
+		{Role: types.RoleSystem, Content: "system"},
+		{Role: types.RoleUser, Content: "q1"},
+		{Role: types.RoleAssistant, Content: "a1"},
+		{Role: types.RoleUser, Content: "q2"},
+		{Role: types.RoleAssistant, Content: "a2"},
+		{Role: types.RoleTool, Content: "result"},
+		{Role: types.RoleUser, Content: "q3"},
+	}
+
+	head, older, recent := splitHistory(messages, 1)
+	assert.Len(t, head, 1)
+	assert.Equal(t, messages[1:6], older)
+	assert.Equal(t, messages[6:], recent)
+
+	// The recent part never starts in the middle of a tool call
+	head, older, recent = splitHistory(messages, 2)
+	assert.Len(t, head, 1)
+	assert.Equal(t, messages[1:3], older)
+	assert.Equal(t, messages[3:], recent)
+
+	_, older, recent = splitHistory(messages, 10)
+	assert.Empty(t, older)
+	assert.Equal(t, messages[1:], recent)
+}
+
+func TestDropToolResults(t *testing.T) {
+	messages := []types.Message{
+		{Role: types.RoleUser, Content: "read the file"},
+		{Role: types.RoleTool, Content: strings.Repeat("line\n", 100)},
+		{Role: types.RoleUser, Content: "[read_file for 'main.go'] Result:\npackage main"},
+		{Role: types.RoleUser, Content: "[not a tool result"},
+		{Role: types.RoleTool, Content: "recent result"},
+	}
+
+	shrunk := dropToolResults(messages, 1)
+	require.Len(t, shrunk, len(messages))
+	assert.Equal(t, "read the file", shrunk[0].Content)
+	assert.Equal(t, toolResultOmitted, shrunk[1].Content)
+	assert.Equal(t, "[read_file for 'main.go'] Result:\n"+toolResultOmitted, shrunk[2].Content)
+	assert.Equal(t, "[not a tool result", shrunk[3].Content)
+	assert.Equal(t, "recent result", shrunk[4].Content)
+	// The original messages are left untouched
+	assert.Equal(t, "[read_file for 'main.go'] Result:\npackage main", messages[2].Content)
+
+	// Nothing left to drop
+	assert.Nil(t, dropToolResults(shrunk, 1))
+}
+
+func TestTruncateUserContent(t *testing.T) {
+	messages := []types.Message{
+		{Role: types.RoleAssistant, Content: strings.Repeat("a", 50)},
+		{Role: types.RoleUser, Content: strings.Repeat("h", 10) + strings.Repeat("x", 30) + strings.Repeat("t", 10)},
+	}
+
+	shrunk := truncateUserContent(messages, 20)
+	require.Len(t, shrunk, 2)
+	assert.Equal(t, messages[0], shrunk[0])
+	content := shrunk[1].Content.(string)
+	assert.True(t, strings.HasPrefix(content, strings.Repeat("h", 10)+"\n"))
+	assert.True(t, strings.HasSuffix(content, "\n"+strings.Repeat("t", 10)))
+	assert.Contains(t, content, "30 characters truncated")
+
+	assert.Nil(t, truncateUserContent(messages, 100))
+}
+
+func TestTruncateUserContent_Multipart(t *testing.T) {
+	image := map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": "data:image/png;base64,AAAA"}}
+	messages := []types.Message{{Role: types.RoleUser, Content: []interface{}{
+		map[string]interface{}{"type": "text", "text": strings.Repeat("h", 10) + strings.Repeat("x", 10)},
+		image,
+		map[string]interface{}{"type": "text", "text": strings.Repeat("y", 10)},
+		map[string]interface{}{"type": "text", "text": strings.Repeat("x", 10) + strings.Repeat("t", 10), "cache_control": "ephemeral"},
+	}}}
+
+	shrunk := truncateUserContent(messages, 20)
+	require.Len(t, shrunk, 1)
+	parts := shrunk[0].Content.([]interface{})
+	require.Len(t, parts, 3, "the text part left empty is dropped")
+	assert.Equal(t, strings.Repeat("h", 10)+truncatedMarker(30), parts[0].(map[string]interface{})["text"])
+	assert.Equal(t, image, parts[1], "the image is kept in place")
+	assert.Equal(t, map[string]interface{}{"type": "text", "text": strings.Repeat("t", 10), "cache_control": "ephemeral"}, parts[2])
+	// The original parts are left untouched
+	assert.Equal(t, strings.Repeat("y", 10), messages[0].Content.([]interface{})[2].(map[string]interface{})["text"])
+
+	// Images do not count toward the text length
+	assert.Nil(t, truncateUserContent(messages, 50))
+}
+
+func TestRecoverContext(t *testing.T) {
+	svcCtx := &bootstrap.ServiceContext{
+		Config: config.Config{ContextRecovery: config.ContextRecoveryConfig{
+			Enabled: true,
+			Agents: map[string]config.ShrinkPolicyConfig{
+				"code": {
+					Steps:               []string{config.ShrinkStepDropToolResults, config.ShrinkStepTruncateUserContent},
+					KeepRecentMessages:  1,
+					MaxUserContentChars: 100,
+				},
+			},
+		}},
+	}
+	request := &types.ChatCompletionRequest{Model: "gpt-4o"}
+	request.Messages = []types.Message{
+		{Role: types.RoleUser, Content: strings.Repeat("question ", 100)},
+		{Role: types.RoleTool, Content: strings.Repeat("result ", 100)},
+		{Role: types.RoleUser, Content: "next"},
+	}
+	l := &ChatCompletionLogic{
+		ctx:     context.Background(),
+		svcCtx:  svcCtx,
+		request: request,
+	}
+	chatLog := &model.ChatLog{Agent: "code"}
+	idleTracker := timeout.NewIdleTracker(time.Minute)
+
+	require.True(t, l.recoverContext(chatLog, idleTracker))
+	assert.Equal(t, toolResultOmitted, l.request.Messages[1].Content)
+
+	require.True(t, l.recoverContext(chatLog, idleTracker))
+	assert.Contains(t, l.request.Messages[0].Content, "characters truncated")
+
+	// Every step of the policy was applied
+	assert.False(t, l.recoverContext(chatLog, idleTracker))
+
+	require.Len(t, chatLog.ContextRecovery, 2)
+	assert.Equal(t, config.ShrinkStepDropToolResults, chatLog.ContextRecovery[0].Step)
+	assert.Equal(t, config.ShrinkStepTruncateUserContent, chatLog.ContextRecovery[1].Step)
+	for _, step := range chatLog.ContextRecovery {
+		assert.Less(t, step.TokensAfter, step.TokensBefore)
+		assert.Empty(t, step.Error)
+	}
+
+	// Disabled recovery leaves the request untouched
+	svcCtx.Config.ContextRecovery.Enabled = false
+	l.recoveryStep = 0
+	assert.False(t, l.recoverContext(&model.ChatLog{}, idleTracker))
+}
@@ -0,0 +1,366 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/zgsm-ai/chat-rag/internal/client"
+	"github.com/zgsm-ai/chat-rag/internal/logger"
+	"github.com/zgsm-ai/chat-rag/internal/model"
+	"github.com/zgsm-ai/chat-rag/internal/timeout"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+// hedgeTarget is one side of a hedged request
+type hedgeTarget struct {
+	model string
+	llm   client.LLMInterface
+}
+
+// hedgeLine is a stream line produced by an attempt, answered through reply
+type hedgeLine struct {
+	idx   int
+	resp  client.LLMResponse
+	reply chan error
+}
+
+// hedgeResult is the end of an attempt
+type hedgeResult struct {
+	idx int
+	err error
+}
+
+// hedgeRecorder receives the results of the hedged attempts, e.g. the circuit breaker
+type hedgeRecorder interface {
+	// RecordResult records the result of an attempt, releasing its probe slot
+	RecordResult(model string, err error)
+	// Release gives back the probe slot of an attempt that was not started
+	Release(model string)
+}
+
+// hedgedLLMClient streams from the primary model and, when it has not produced a first token
+// within the hedge delay, also from the backup model. The first attempt to produce a token
+// wins and is forwarded to the caller, the other attempt is cancelled.
+// Each attempt has its own idle timer. The results of the losing attempt, and of both attempts
+// when both fail, are recorded by the recorder, the caller records the result of the winner.
+// The probe slot reserved for the backup is released when the backup is not started.
+// Once a winner is known, later calls (e.g. after a tool call) go to the winner only.
+type hedgedLLMClient struct {
+	targets [2]hedgeTarget
+	delay   time.Duration
+	// onWin is called in the caller goroutine before the first line of the winner is forwarded
+	onWin    func(model string)
+	recorder hedgeRecorder
+
+	mu       sync.Mutex
+	winner   int
+	attempts []model.HedgedAttempt
+	// backupReserved is set while the probe slot of the backup is held and not used
+	backupReserved bool
+	// bothFailed is set when both attempts failed, they are recorded already
+	bothFailed bool
+}
+
+// newHedgedLLMClient creates a client racing backup against primary after delay. The caller
+// reserved the probe slot of the backup, which the client records or releases.
+func newHedgedLLMClient(primary, backup hedgeTarget, delay time.Duration, onWin func(model string), recorder hedgeRecorder) *hedgedLLMClient {
+	return &hedgedLLMClient{
+		targets:        [2]hedgeTarget{primary, backup},
+		delay:          delay,
+		onWin:          onWin,
+		recorder:       recorder,
+		winner:         -1,
+		backupReserved: true,
+	}
+}
+
+// release gives back the probe slot of the backup when it was not started
+func (h *hedgedLLMClient) release() {
+	h.mu.Lock()
+	reserved := h.backupReserved
+	h.backupReserved = false
+	h.mu.Unlock()
+
+	if reserved && h.recorder != nil {
+		h.recorder.Release(h.targets[1].model)
+	}
+}
+
+// failedBoth reports whether both attempts failed, in which case both results are recorded
+// and the backup should not be tried again
+func (h *hedgedLLMClient) failedBoth() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.bothFailed
+}
+
+// current returns the winner, or the primary before the race is decided
+func (h *hedgedLLMClient) current() client.LLMInterface {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.winner >= 0 {
+		return h.targets[h.winner].llm
+	}
+	return h.targets[0].llm
+}
+
+// lostAttempts returns the attempts that did not win
+func (h *hedgedLLMClient) lostAttempts() []model.HedgedAttempt {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]model.HedgedAttempt(nil), h.attempts...)
+}
+
+func (h *hedgedLLMClient) GetModelName() string {
+	return h.current().GetModelName()
+}
+
+func (h *hedgedLLMClient) GenerateContent(ctx context.Context, systemPrompt string, userMessages []types.Message) (string, error) {
+	return h.current().GenerateContent(ctx, systemPrompt, userMessages)
+}
+
+func (h *hedgedLLMClient) ChatLLMWithMessagesRaw(ctx context.Context, params types.LLMRequestParams, idleTimer *timeout.IdleTimer) (types.ChatCompletionResponse, error) {
+	return h.current().ChatLLMWithMessagesRaw(ctx, params, idleTimer)
+}
+
+func (h *hedgedLLMClient) SetTools(tools []types.Function) {
+	for _, target := range h.targets {
+		target.llm.SetTools(tools)
+	}
+}
+
+// ChatLLMWithMessagesStreamRaw races the primary and backup streams
+func (h *hedgedLLMClient) ChatLLMWithMessagesStreamRaw(ctx context.Context, params types.LLMRequestParams, idleTimer *timeout.IdleTimer, callback func(client.LLMResponse) error) error {
+	h.mu.Lock()
+	decided := h.winner >= 0
+	h.mu.Unlock()
+	if decided {
+		return h.current().ChatLLMWithMessagesStreamRaw(ctx, params, idleTimer, callback)
+	}
+
+	defer h.release()
+
+	raceStart := time.Now()
+	lines := make(chan hedgeLine)
+	results := make(chan hedgeResult, len(h.targets))
+	var cancels [2]context.CancelFunc
+	var timers [2]*timeout.IdleTimer
+	var starts [2]time.Time
+	defer func() {
+		for idx, cancel := range cancels {
+			if timers[idx] != nil {
+				timers[idx].Stop()
+			}
+			if cancel != nil {
+				cancel()
+			}
+		}
+	}()
+
+	// start runs an attempt with its own idle timer, so that the silence of one attempt
+	// does not time out the other
+	start := func(idx int) {
+		var attemptCtx context.Context
+		if idleTimer != nil {
+			attemptCtx, cancels[idx], timers[idx] = idleTimer.NewSibling(ctx)
+		} else {
+			attemptCtx, cancels[idx] = context.WithCancel(ctx)
+		}
+		starts[idx] = time.Now()
+		if idx == 1 {
+			// The slot of the backup is released by the record of its result
+			h.mu.Lock()
+			h.backupReserved = false
+			h.mu.Unlock()
+		}
+		target, attemptTimer := h.targets[idx], timers[idx]
+		go func() {
+			err := target.llm.ChatLLMWithMessagesStreamRaw(attemptCtx, params, attemptTimer, func(resp client.LLMResponse) error {
+				line := hedgeLine{idx: idx, resp: resp, reply: make(chan error, 1)}
+				select {
+				case lines <- line:
+				case <-attemptCtx.Done():
+					return attemptCtx.Err()
+				}
+				return <-line.reply
+			})
+			results <- hedgeResult{idx: idx, err: err}
+		}()
+	}
+
+	// keepAlive resets the idle timer of the caller, which runs over the whole race while the
+	// attempts time out on their own timers
+	keepAlive := func() {
+		if idleTimer != nil {
+			idleTimer.Reset()
+		}
+	}
+
+	// record marks an attempt as lost
+	record := func(idx int, status string, err error) {
+		attempt := model.HedgedAttempt{
+			Model:         h.targets[idx].model,
+			Hedge:         idx == 1,
+			Status:        status,
+			StartOffsetMs: starts[idx].Sub(raceStart).Milliseconds(),
+			Latency:       time.Since(starts[idx]).Milliseconds(),
+		}
+		if err != nil {
+			attempt.Error = err.Error()
+		}
+		h.mu.Lock()
+		h.attempts = append(h.attempts, attempt)
+		h.mu.Unlock()
+
+		// A cancelled attempt is recorded with the cancellation, which does not count as a failure
+		if status == model.HedgeStatusCancelled {
+			err = context.Canceled
+		}
+		if h.recorder != nil {
+			h.recorder.RecordResult(h.targets[idx].model, err)
+		}
+	}
+
+	winner := -1
+	var buffered [2][]client.LLMResponse
+	running := [2]bool{true, false}
+	hedging := false
+
+	// win forwards the buffered lines of the winner and cancels the other attempt
+	win := func(idx int) error {
+		winner = idx
+		h.mu.Lock()
+		h.winner = idx
+		h.mu.Unlock()
+
+		// The winner produced its first token, later silences only log as for a single attempt
+		if timers[idx] != nil {
+			timers[idx].SetFirstTokenReceived()
+		}
+
+		other := 1 - idx
+		if running[other] {
+			if timers[other] != nil {
+				timers[other].Stop()
+			}
+			cancels[other]()
+			record(other, model.HedgeStatusCancelled, nil)
+			logger.InfoC(ctx, "hedge: attempt won, cancelled the other",
+				zap.String("winner", h.targets[idx].model),
+				zap.String("cancelled", h.targets[other].model),
+			)
+		}
+		if h.onWin != nil {
+			h.onWin(h.targets[idx].model)
+		}
+		for _, resp := range buffered[idx] {
+			if err := callback(resp); err != nil {
+				return err
+			}
+		}
+		buffered[idx] = nil
+		return nil
+	}
+
+	start(0)
+	hedgeTimer := time.NewTimer(h.delay)
+	defer hedgeTimer.Stop()
+
+	for {
+		select {
+		case <-hedgeTimer.C:
+			if winner >= 0 || !running[0] {
+				continue
+			}
+			logger.InfoC(ctx, "hedge: no first token within hedge delay, starting hedge",
+				zap.String("primary", h.targets[0].model),
+				zap.String("hedge", h.targets[1].model),
+				zap.Duration("delay", h.delay),
+			)
+			running[1] = true
+			hedging = true
+			start(1)
+			keepAlive()
+
+		case line := <-lines:
+			keepAlive()
+			switch {
+			case winner == line.idx:
+				line.reply <- callback(line.resp)
+			case winner >= 0:
+				line.reply <- context.Canceled
+			default:
+				buffered[line.idx] = append(buffered[line.idx], line.resp)
+				if hasStreamToken(line.resp.ResonseLine) {
+					line.reply <- win(line.idx)
+				} else {
+					line.reply <- nil
+				}
+			}
+
+		case result := <-results:
+			running[result.idx] = false
+			if winner == result.idx {
+				return result.err
+			}
+			if winner >= 0 {
+				// The cancelled attempt has finished
+				continue
+			}
+			if result.err == nil {
+				// Stream ended without any token, let the caller judge the empty response
+				if err := win(result.idx); err != nil {
+					return err
+				}
+				return nil
+			}
+
+			other := 1 - result.idx
+			if !running[other] {
+				if hedging {
+					// Both attempts failed, both are recorded here since the caller has no winner to record
+					record(result.idx, model.HedgeStatusFailed, result.err)
+					h.mu.Lock()
+					h.bothFailed = true
+					h.mu.Unlock()
+				}
+				// Nothing left to race, the caller degrades as without hedging
+				return result.err
+			}
+			record(result.idx, model.HedgeStatusFailed, result.err)
+			logger.WarnC(ctx, "hedge: attempt failed before first token, waiting for the other",
+				zap.String("failed", h.targets[result.idx].model),
+				zap.String("remaining", h.targets[other].model),
+				zap.Error(result.err),
+			)
+		}
+	}
+}
+
+// hasStreamToken reports whether an SSE line carries generated output
+func hasStreamToken(line string) bool {
+	data, ok := strings.CutPrefix(line, "data: ")
+	if !ok {
+		return false
+	}
+	data = strings.TrimSpace(data)
+	if data == "[DONE]" {
+		return true
+	}
+
+	var chunk types.ChatCompletionResponse
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return false
+	}
+	for _, choice := range chunk.Choices {
+		if choice.Delta.Content != "" || choice.Delta.ReasoningContent != "" || len(choice.Delta.ToolCalls) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,217 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zgsm-ai/chat-rag/internal/client"
+	"github.com/zgsm-ai/chat-rag/internal/model"
+	"github.com/zgsm-ai/chat-rag/internal/timeout"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+// fakeStreamClient streams fixed lines after a delay, or fails
+type fakeStreamClient struct {
+	name      string
+	delay     time.Duration
+	lines     []string
+	err       error
+	cancelled chan struct{}
+}
+
+func newFakeStreamClient(name string, delay time.Duration, lines ...string) *fakeStreamClient {
+	return &fakeStreamClient{name: name, delay: delay, lines: lines, cancelled: make(chan struct{}, 1)}
+}
+
+func (f *fakeStreamClient) GetModelName() string { return f.name }
+
+func (f *fakeStreamClient) GenerateContent(ctx context.Context, systemPrompt string, userMessages []types.Message) (string, error) {
+	return "", nil
+}
+
+func (f *fakeStreamClient) ChatLLMWithMessagesRaw(ctx context.Context, params types.LLMRequestParams, idleTimer *timeout.IdleTimer) (types.ChatCompletionResponse, error) {
+	return types.ChatCompletionResponse{}, nil
+}
+
+func (f *fakeStreamClient) SetTools(tools []types.Function) {}
+
+func (f *fakeStreamClient) ChatLLMWithMessagesStreamRaw(ctx context.Context, params types.LLMRequestParams, idleTimer *timeout.IdleTimer, callback func(client.LLMResponse) error) error {
+	select {
+	case <-time.After(f.delay):
+	case <-ctx.Done():
+		f.cancelled <- struct{}{}
+		return ctx.Err()
+	}
+	if f.err != nil {
+		return f.err
+	}
+	for _, line := range f.lines {
+		if err := callback(client.LLMResponse{ResonseLine: line}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func contentLine(content string) string {
+	return `data: {"choices":[{"index":0,"delta":{"content":"` + content + `"}}]}`
+}
+
+// recordedResults collects the results recorded by the hedged client, by model
+type recordedResults map[string]error
+
+// fakeHedgeRecorder collects the results recorded and the probe slots released by the hedged client
+type fakeHedgeRecorder struct {
+	results  recordedResults
+	released []string
+}
+
+func (r *fakeHedgeRecorder) RecordResult(model string, err error) { r.results[model] = err }
+
+func (r *fakeHedgeRecorder) Release(model string) { r.released = append(r.released, model) }
+
+func runHedge(t *testing.T, primary, backup *fakeStreamClient, delay time.Duration) ([]string, string, *hedgedLLMClient, error) {
+	t.Helper()
+	got, winner, hedged, _, err := runHedgeWithTimer(t, context.Background(), primary, backup, delay, nil)
+	return got, winner, hedged, err
+}
+
+func runHedgeWithTimer(t *testing.T, ctx context.Context, primary, backup *fakeStreamClient, delay time.Duration, idleTimer *timeout.IdleTimer) ([]string, string, *hedgedLLMClient, recordedResults, error) {
+	t.Helper()
+	got, winner, hedged, recorder, err := runHedgeWithRecorder(t, ctx, primary, backup, delay, idleTimer)
+	return got, winner, hedged, recorder.results, err
+}
+
+func runHedgeWithRecorder(t *testing.T, ctx context.Context, primary, backup *fakeStreamClient, delay time.Duration, idleTimer *timeout.IdleTimer) ([]string, string, *hedgedLLMClient, *fakeHedgeRecorder, error) {
+	t.Helper()
+	var winner string
+	recorder := &fakeHedgeRecorder{results: recordedResults{}}
+	hedged := newHedgedLLMClient(
+		hedgeTarget{model: primary.name, llm: primary},
+		hedgeTarget{model: backup.name, llm: backup},
+		delay,
+		func(model string) { winner = model },
+		recorder,
+	)
+
+	var got []string
+	err := hedged.ChatLLMWithMessagesStreamRaw(ctx, types.LLMRequestParams{}, idleTimer, func(resp client.LLMResponse) error {
+		got = append(got, resp.ResonseLine)
+		return nil
+	})
+	return got, winner, hedged, recorder, err
+}
+
+func TestHedgedLLMClient_PrimaryFast(t *testing.T) {
+	primary := newFakeStreamClient("primary", 0, contentLine("a"), "data: [DONE]")
+	backup := newFakeStreamClient("backup", 0, contentLine("b"))
+
+	got, winner, hedged, err := runHedge(t, primary, backup, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "primary", winner)
+	assert.Equal(t, []string{contentLine("a"), "data: [DONE]"}, got)
+	assert.Empty(t, hedged.lostAttempts())
+}
+
+func TestHedgedLLMClient_HedgeWins(t *testing.T) {
+	primary := newFakeStreamClient("primary", 5*time.Second, contentLine("a"))
+	backup := newFakeStreamClient("backup", 0, `data: {"choices":[{"delta":{"role":"assistant"}}]}`, contentLine("b"))
+
+	got, winner, hedged, recorded, err := runHedgeWithTimer(t, context.Background(), primary, backup, 20*time.Millisecond, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "backup", winner)
+	// Lines received before the first token are forwarded once the hedge wins
+	assert.Len(t, got, 2)
+	assert.Equal(t, contentLine("b"), got[1])
+
+	select {
+	case <-primary.cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("primary stream was not cancelled")
+	}
+
+	attempts := hedged.lostAttempts()
+	require.Len(t, attempts, 1)
+	assert.Equal(t, "primary", attempts[0].Model)
+	assert.False(t, attempts[0].Hedge)
+	assert.Equal(t, model.HedgeStatusCancelled, attempts[0].Status)
+	assert.Equal(t, backup, hedged.current())
+	// The losing primary is recorded with its cancellation only, the caller records the winner
+	assert.Equal(t, recordedResults{"primary": context.Canceled}, recorded)
+}
+
+func TestHedgedLLMClient_Failures(t *testing.T) {
+	// Primary failing before the hedge delay is returned to the caller for degradation,
+	// the slot of the backup that was not started is released
+	primary := newFakeStreamClient("primary", 0)
+	primary.err = errors.New("bad gateway")
+	backup := newFakeStreamClient("backup", 0, contentLine("b"))
+	_, _, hedged, recorder, err := runHedgeWithRecorder(t, context.Background(), primary, backup, time.Second, nil)
+	assert.EqualError(t, err, "bad gateway")
+	assert.False(t, hedged.failedBoth())
+	assert.Empty(t, recorder.results, "the caller records the primary")
+	assert.Equal(t, []string{"backup"}, recorder.released)
+	hedged.release()
+	assert.Equal(t, []string{"backup"}, recorder.released, "the slot is released once")
+
+	// Hedge failing leaves the slow primary running
+	primary = newFakeStreamClient("primary", 100*time.Millisecond, contentLine("a"))
+	backup = newFakeStreamClient("backup", 0)
+	backup.err = errors.New("overloaded")
+	got, winner, hedged, recorder, err := runHedgeWithRecorder(t, context.Background(), primary, backup, 10*time.Millisecond, nil)
+	recorded := recorder.results
+	require.NoError(t, err)
+	assert.Equal(t, "primary", winner)
+	assert.Equal(t, []string{contentLine("a")}, got)
+	assert.Empty(t, recorder.released, "the started backup is recorded, not released")
+	attempts := hedged.lostAttempts()
+	require.Len(t, attempts, 1)
+	assert.True(t, attempts[0].Hedge)
+	assert.Equal(t, model.HedgeStatusFailed, attempts[0].Status)
+	assert.Equal(t, recordedResults{"backup": backup.err}, recorded)
+}
+
+func TestHedgedLLMClient_BothFail(t *testing.T) {
+	primary := newFakeStreamClient("primary", 50*time.Millisecond)
+	primary.err = errors.New("bad gateway")
+	backup := newFakeStreamClient("backup", 0)
+	backup.err = errors.New("overloaded")
+
+	_, winner, hedged, recorder, err := runHedgeWithRecorder(t, context.Background(), primary, backup, 10*time.Millisecond, nil)
+	assert.EqualError(t, err, "bad gateway")
+	assert.Empty(t, winner)
+	// Both failures are recorded against their own model, the caller records nothing
+	assert.True(t, hedged.failedBoth())
+	assert.Equal(t, recordedResults{"primary": primary.err, "backup": backup.err}, recorder.results)
+	assert.Empty(t, recorder.released)
+	assert.Len(t, hedged.lostAttempts(), 2)
+}
+
+func TestHedgedLLMClient_IdleTimerPerAttempt(t *testing.T) {
+	// The primary stays silent past the idle timeout, the hedge answers within its own window
+	primary := newFakeStreamClient("primary", 5*time.Second, contentLine("a"))
+	backup := newFakeStreamClient("backup", 60*time.Millisecond, contentLine("b"))
+
+	timerCtx, cancel, idleTimer := timeout.NewIdleTimer(context.Background(), 100*time.Millisecond, timeout.NewIdleTracker(time.Second))
+	defer func() {
+		idleTimer.Stop()
+		cancel()
+	}()
+
+	got, winner, hedged, recorded, err := runHedgeWithTimer(t, timerCtx, primary, backup, 80*time.Millisecond, idleTimer)
+	require.NoError(t, err)
+	assert.Equal(t, "backup", winner)
+	assert.Equal(t, []string{contentLine("b")}, got)
+	assert.NoError(t, timerCtx.Err(), "the idle timer of the caller is kept alive during the race")
+
+	// The primary timed out on its own timer and is recorded as a failure
+	attempts := hedged.lostAttempts()
+	require.Len(t, attempts, 1)
+	assert.Equal(t, "primary", attempts[0].Model)
+	assert.Equal(t, model.HedgeStatusFailed, attempts[0].Status)
+	assert.Contains(t, recorded, "primary")
+}
@@ -0,0 +1,338 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/zgsm-ai/chat-rag/internal/client"
+	"github.com/zgsm-ai/chat-rag/internal/logger"
+	"github.com/zgsm-ai/chat-rag/internal/model"
+	"github.com/zgsm-ai/chat-rag/internal/timeout"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+// hasClientTools reports whether the request brings its own tools or functions
+func (l *ChatCompletionLogic) hasClientTools() bool {
+	for _, key := range []string{"tools", "functions"} {
+		if items, ok := l.request.Extra[key].([]any); ok && len(items) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// setNativeTools keeps the server tools of the processed prompt, unless the client brings its own
+// tools, whose calls are returned to the client
+func (l *ChatCompletionLogic) setNativeTools(tools []types.Function) {
+	if l.hasClientTools() {
+		return
+	}
+	l.nativeTools = tools
+	l.keepFuncCallingModels()
+}
+
+// keepFuncCallingModels keeps degradation and hedging within function calling models once the prompt
+// offers the server tools natively. Its tool descriptions were left out for them, so a model without
+// function calling would silently answer without tools
+func (l *ChatCompletionLogic) keepFuncCallingModels() {
+	if len(l.nativeTools) == 0 || len(l.orderedModels) == 0 {
+		return
+	}
+
+	kept := make([]string, 0, len(l.orderedModels))
+	var dropped []string
+	for _, modelName := range l.orderedModels {
+		if l.svcCtx.Config.IsFuncCallingModel(modelName) {
+			kept = append(kept, modelName)
+		} else {
+			dropped = append(dropped, modelName)
+		}
+	}
+	if len(dropped) == 0 {
+		return
+	}
+	// The prompt was built for the selected model, which calls functions
+	if len(kept) == 0 {
+		kept = append(kept, l.request.Model)
+	}
+	logger.InfoC(l.ctx, "native tools: degradation limited to function calling models",
+		zap.Strings("kept", kept),
+		zap.Strings("dropped", dropped),
+	)
+	l.orderedModels = kept
+}
+
+// toolsFor returns the server tools offered natively to the model, only function calling models get them
+func (l *ChatCompletionLogic) toolsFor(modelName string) []types.Function {
+	if len(l.nativeTools) == 0 || !l.svcCtx.Config.IsFuncCallingModel(modelName) {
+		return nil
+	}
+	return l.nativeTools
+}
+
+// isToolCallLine reports whether the SSE line carries native tool calls or ends the turn with them
+func isToolCallLine(rawLine string) bool {
+	jsonData, ok := strings.CutPrefix(rawLine, "data: ")
+	if !ok || !strings.Contains(jsonData, "tool_calls") {
+		return false
+	}
+
+	var chunk struct {
+		Choices []struct {
+			Delta struct {
+				ToolCalls []any `json:"tool_calls"`
+			} `json:"delta"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal([]byte(jsonData), &chunk); err != nil || len(chunk.Choices) == 0 {
+		return false
+	}
+	choice := chunk.Choices[0]
+	return len(choice.Delta.ToolCalls) > 0 || choice.FinishReason == "tool_calls"
+}
+
+// sortedToolCalls returns the tool calls accumulated from the stream in index order
+func sortedToolCalls(toolCalls map[int]*types.ToolCallInfo) []types.ToolCallInfo {
+	indexes := make([]int, 0, len(toolCalls))
+	for index := range toolCalls {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	calls := make([]types.ToolCallInfo, 0, len(toolCalls))
+	for _, index := range indexes {
+		calls = append(calls, *toolCalls[index])
+	}
+	return calls
+}
+
+// responseToolCalls returns the tool calls of a non-streaming response
+func responseToolCalls(resp *types.ChatCompletionResponse) []types.ToolCallInfo {
+	if len(resp.Choices) == 0 || resp.Choices[0].Message.Extra["tool_calls"] == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(resp.Choices[0].Message.Extra["tool_calls"])
+	if err != nil {
+		return nil
+	}
+	var calls []types.ToolCallInfo
+	if err := json.Unmarshal(data, &calls); err != nil {
+		return nil
+	}
+	return calls
+}
+
+// runNativeToolCalls executes the tool calls of the assistant turn and appends the turn and the
+// tool results to the request messages. Tools are run concurrently, results are appended in call order.
+func (l *ChatCompletionLogic) runNativeToolCalls(
+	ctx context.Context,
+	content string,
+	calls []types.ToolCallInfo,
+	chatLog *model.ChatLog,
+	remainingDepth int,
+	beforeTools func(names []string) error,
+) error {
+	for i := range calls {
+		if calls[i].ID == "" {
+			calls[i].ID = "call_" + uuid.NewString()
+		}
+		calls[i].Type = "function"
+	}
+	l.request.Messages = append(l.request.Messages, types.Message{
+		Role:    types.RoleAssistant,
+		Content: content,
+		Extra:   map[string]any{"tool_calls": calls},
+	})
+
+	runs := make([]toolRun, 0, len(calls))
+	for _, call := range calls {
+		logger.InfoC(ctx, "native tool called", zap.String("name", call.Function.Name), zap.String("id", call.ID))
+		runs = append(runs, toolRun{name: call.Function.Name, input: call.Function.Arguments})
+	}
+	if err := l.runTools(ctx, runs, beforeTools); err != nil {
+		return err
+	}
+
+	for i, run := range runs {
+		l.request.Messages = append(l.request.Messages, types.Message{
+			Role:    types.RoleTool,
+			Content: run.result,
+			Extra:   map[string]any{"tool_call_id": calls[i].ID},
+		})
+		chatLog.ToolCalls = append(chatLog.ToolCalls, run.call)
+	}
+	chatLog.ProcessedPrompt = l.request.Messages
+
+	// The last turn must answer, not call tools again
+	if remainingDepth <= 1 {
+		if l.request.Extra == nil {
+			l.request.Extra = make(map[string]any)
+		}
+		l.request.Extra["tool_choice"] = "none"
+	}
+	return nil
+}
+
+// handleNativeToolCalls executes the tool calls collected from the stream and continues processing
+func (l *ChatCompletionLogic) handleNativeToolCalls(
+	ctx context.Context,
+	llmClient client.LLMInterface,
+	flusher http.Flusher,
+	chatLog *model.ChatLog,
+	state *streamState,
+	remainingDepth int,
+	idleTracker *timeout.IdleTracker,
+) error {
+	// Tool progress is sent to the client from here on
+	l.streamCommitted = true
+
+	// Send the content held back in the window
+	if len(state.window) > 0 {
+		if err := l.sendStreamContent(flusher, state.response, strings.Join(state.window, "")); err != nil {
+			return err
+		}
+		state.window = nil
+	}
+
+	_, content := splitReasoning(state.fullContent.String())
+	err := l.runNativeToolCalls(ctx, content, sortedToolCalls(state.toolCalls), chatLog, remainingDepth,
+		func(names []string) error {
+			return l.sendToolStart(flusher, state.response, names...)
+		})
+	if err != nil {
+		return err
+	}
+	if err := l.sendToolAnalyzing(flusher, state.response); err != nil {
+		return err
+	}
+
+	// Recursive processing
+	return l.handleStreamingWithTools(
+		ctx,
+		llmClient,
+		flusher,
+		chatLog,
+		remainingDepth-1,
+		idleTracker,
+	)
+}
+
+// completeNativeToolCalls executes the tool calls of non-streaming responses and calls the model
+// again with the results, until it answers or the tool call depth is reached
+func (l *ChatCompletionLogic) completeNativeToolCalls(
+	response types.ChatCompletionResponse,
+	chatLog *model.ChatLog,
+	idleTracker *timeout.IdleTracker,
+) (types.ChatCompletionResponse, error) {
+	if len(l.nativeTools) == 0 {
+		return response, nil
+	}
+
+	for remainingDepth := MaxToolCallDepth; remainingDepth > 0; remainingDepth-- {
+		calls := responseToolCalls(&response)
+		if len(calls) == 0 {
+			return response, nil
+		}
+
+		_, content := messageReasoning(response.Choices[0].Message)
+		if err := l.runNativeToolCalls(l.ctx, content, calls, chatLog, remainingDepth, nil); err != nil {
+			return response, err
+		}
+
+		var err error
+		if len(l.orderedModels) > 0 {
+			response, err = l.callWithDegradation(l.request.LLMRequestParams, idleTracker)
+		} else {
+			response, err = l.callModelWithRetry(l.request.Model, l.request.LLMRequestParams, idleTracker)
+		}
+		if err != nil {
+			return response, fmt.Errorf("call model with tool results: %w", err)
+		}
+	}
+	return response, nil
+}
+
+// runTool executes a server tool and returns its result for the model and the tool call record
+func (l *ChatCompletionLogic) runTool(ctx context.Context, name string, input string) (string, model.ToolCall) {
+	toolCall := model.ToolCall{
+		ToolName:  name,
+		ToolInput: input,
+	}
+
+	// execute and record tool call latency
+	ctx, cacheHit := client.WithToolCacheStatus(ctx)
+	toolStart := time.Now()
+	result, err := l.toolExecutor.ExecuteTools(ctx, name, input)
+	toolCall.Latency = time.Since(toolStart).Milliseconds()
+	toolCall.ToolOutput = result
+	toolCall.CacheHit = cacheHit.Load()
+
+	status := types.ToolStatusSuccess
+	if err != nil {
+		logger.WarnC(ctx, "tool execute failed", zap.String("tool", name), zap.Error(err))
+		status = types.ToolStatusFailed
+		result = fmt.Sprintf("%s execute failed, err: %v", name, err)
+		toolCall.Error = err.Error()
+	} else {
+		logResult := result
+		if len(logResult) > 400 {
+			logResult = logResult[:400] + "..."
+		}
+		logger.InfoC(ctx, "tool execute succeed", zap.String("tool", name),
+			zap.String("result", logResult), zap.Int("result length", len(result)))
+
+		if len(result) > MaxToolResultLength {
+			logger.WarnC(ctx, "tool result truncated due to excessive length",
+				zap.String("tool", name),
+				zap.Int("original_length", len(result)),
+				zap.Int("truncated_length", MaxToolResultLength))
+			result = result[:MaxToolResultLength] + "... (truncated due to excessive length)"
+		}
+	}
+	toolCall.ResultStatus = string(status)
+	return result, toolCall
+}
+
+// sendToolStart sends the tool use information of the tools run together to the client page
+func (l *ChatCompletionLogic) sendToolStart(flusher http.Flusher, response *types.ChatCompletionResponse, names ...string) error {
+	for _, name := range names {
+		if err := l.sendStreamContent(flusher, response,
+			fmt.Sprintf("%s`%s` %s", types.StrFilterToolSearchStart, name,
+				types.StrFilterToolSearchEnd)); err != nil {
+			return err
+		}
+	}
+
+	// wait client to refesh content
+	for i := 0; i < 5; i++ {
+		if err := l.sendStreamContent(flusher, response, "."); err != nil {
+			return err
+		}
+		time.Sleep(600 * time.Millisecond)
+	}
+	return nil
+}
+
+// sendToolAnalyzing sends the tool call ending response to the client page
+func (l *ChatCompletionLogic) sendToolAnalyzing(flusher http.Flusher, response *types.ChatCompletionResponse) error {
+	if err := l.sendStreamContent(flusher, response, types.StrFilterToolAnalyzing); err != nil {
+		return err
+	}
+	for i := 0; i < 3; i++ {
+		time.Sleep(100 * time.Millisecond)
+		if err := l.sendStreamContent(flusher, response, "."); err != nil {
+			return err
+		}
+	}
+	return l.sendStreamContent(flusher, response, "\n")
+}
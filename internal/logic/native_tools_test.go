@@ -0,0 +1,140 @@
+package logic
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zgsm-ai/chat-rag/internal/bootstrap"
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/functions"
+	"github.com/zgsm-ai/chat-rag/internal/model"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+// echoToolExecutor returns the arguments it was called with, failing for unknown tools
+type echoToolExecutor struct {
+	functions.ToolExecutor
+}
+
+func (e *echoToolExecutor) ExecuteTools(ctx context.Context, toolName string, content string) (string, error) {
+	if toolName != "codebase_search" {
+		return "", fmt.Errorf("tool not found: %s", toolName)
+	}
+	return "results for " + content, nil
+}
+
+func TestIsToolCallLine(t *testing.T) {
+	assert.True(t, isToolCallLine(`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{}"}}]}}]}`))
+	assert.True(t, isToolCallLine(`data: {"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`))
+	assert.False(t, isToolCallLine(`data: {"choices":[{"index":0,"delta":{"content":"use tool_calls"}}]}`))
+	assert.False(t, isToolCallLine(`data: [DONE]`))
+}
+
+func TestNativeToolCallsFromStream(t *testing.T) {
+	handler := &ResponseHandler{}
+	toolCalls := make(map[int]*types.ToolCallInfo)
+	for _, line := range []string{
+		`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"b","type":"function","function":{"name":"codebase_search","arguments":"{\"query\":"}}]}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"a","type":"function","function":{"name":"codebase_search","arguments":""}}]}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"function":{"arguments":"\"router\"}"}}]}}]}`,
+	} {
+		var ignored types.ResponseContent
+		handler.extractSSEFunctionResp(line, &ignored, toolCalls)
+	}
+
+	calls := sortedToolCalls(toolCalls)
+	require.Len(t, calls, 2)
+	assert.Equal(t, "a", calls[0].ID)
+	assert.Equal(t, "b", calls[1].ID)
+	assert.Equal(t, `{"query":"router"}`, calls[1].Function.Arguments)
+}
+
+func TestResponseToolCalls(t *testing.T) {
+	resp := &types.ChatCompletionResponse{Choices: []types.Choice{{
+		Message: types.Message{
+			Role: types.RoleAssistant,
+			Extra: map[string]any{"tool_calls": []any{map[string]any{
+				"id":       "call_1",
+				"type":     "function",
+				"function": map[string]any{"name": "codebase_search", "arguments": `{"query":"router"}`},
+			}}},
+		},
+	}}}
+
+	calls := responseToolCalls(resp)
+	require.Len(t, calls, 1)
+	assert.Equal(t, "call_1", calls[0].ID)
+	assert.Equal(t, "codebase_search", calls[0].Function.Name)
+
+	assert.Nil(t, responseToolCalls(&types.ChatCompletionResponse{Choices: []types.Choice{{}}}))
+}
+
+func TestRunNativeToolCalls(t *testing.T) {
+	l := &ChatCompletionLogic{
+		ctx: context.Background(),
+		svcCtx: &bootstrap.ServiceContext{Config: config.Config{
+			LLM: config.LLMConfig{FuncCallingModels: []string{"gpt-4o"}},
+		}},
+		request:      &types.ChatCompletionRequest{},
+		identity:     &model.Identity{},
+		toolExecutor: &echoToolExecutor{},
+		nativeTools:  []types.Function{{Type: "function", Function: types.FunctionDefinition{Name: "codebase_search"}}},
+	}
+	assert.Len(t, l.toolsFor("gpt-4o"), 1)
+	assert.Nil(t, l.toolsFor("deepseek-r1"))
+
+	chatLog := &model.ChatLog{}
+	calls := []types.ToolCallInfo{
+		{Function: types.ToolCallFunction{Name: "codebase_search", Arguments: `{"query":"router"}`}},
+		{ID: "call_2", Function: types.ToolCallFunction{Name: "unknown", Arguments: `{}`}},
+	}
+	require.NoError(t, l.runNativeToolCalls(l.ctx, "Let me search", calls, chatLog, 2, nil))
+
+	messages := l.request.Messages
+	require.Len(t, messages, 3)
+	assert.Equal(t, types.RoleAssistant, messages[0].Role)
+	assistantCalls := messages[0].Extra["tool_calls"].([]types.ToolCallInfo)
+	assert.NotEmpty(t, assistantCalls[0].ID)
+	assert.Equal(t, "function", assistantCalls[0].Type)
+
+	assert.Equal(t, types.RoleTool, messages[1].Role)
+	assert.Equal(t, assistantCalls[0].ID, messages[1].Extra["tool_call_id"])
+	assert.Equal(t, `results for {"query":"router"}`, messages[1].Content)
+	assert.Equal(t, "call_2", messages[2].Extra["tool_call_id"])
+	assert.Contains(t, messages[2].Content, "execute failed")
+
+	require.Len(t, chatLog.ToolCalls, 2)
+	assert.Equal(t, string(types.ToolStatusSuccess), chatLog.ToolCalls[0].ResultStatus)
+	assert.Equal(t, string(types.ToolStatusFailed), chatLog.ToolCalls[1].ResultStatus)
+	assert.NotContains(t, l.request.Extra, "tool_choice")
+
+	// The last turn of the depth must answer
+	require.NoError(t, l.runNativeToolCalls(l.ctx, "", calls[:1], chatLog, 1, nil))
+	assert.Equal(t, "none", l.request.Extra["tool_choice"])
+}
+
+func TestSetNativeTools_KeepsFuncCallingModels(t *testing.T) {
+	newLogic := func() *ChatCompletionLogic {
+		return &ChatCompletionLogic{
+			ctx: context.Background(),
+			svcCtx: &bootstrap.ServiceContext{Config: config.Config{
+				LLM: config.LLMConfig{FuncCallingModels: []string{"gpt-4o", "claude"}},
+			}},
+			request:       &types.ChatCompletionRequest{Model: "gpt-4o"},
+			orderedModels: []string{"gpt-4o", "deepseek-r1", "claude"},
+		}
+	}
+	tools := []types.Function{{Type: "function", Function: types.FunctionDefinition{Name: "codebase_search"}}}
+
+	l := newLogic()
+	l.setNativeTools(tools)
+	assert.Equal(t, []string{"gpt-4o", "claude"}, l.orderedModels)
+
+	// Prompts without native tools degrade to any model
+	l = newLogic()
+	l.setNativeTools(nil)
+	assert.Equal(t, []string{"gpt-4o", "deepseek-r1", "claude"}, l.orderedModels)
+}
@@ -0,0 +1,142 @@
+package logic
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/zgsm-ai/chat-rag/internal/functions"
+	"github.com/zgsm-ai/chat-rag/internal/logger"
+	"github.com/zgsm-ai/chat-rag/internal/model"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+// toolRun is a tool invocation of a model turn, with its result once it was run
+type toolRun struct {
+	name   string
+	input  string
+	result string
+	call   model.ToolCall
+}
+
+// xmlToolRuns returns the complete XML tool invocations of the turn. When none is complete, the detected
+// tool is run with the whole content, its parameters are then reported missing to the model.
+func (l *ChatCompletionLogic) xmlToolRuns(ctx context.Context, detectedTool string, content string) []toolRun {
+	invocations := l.toolExecutor.DetectAllTools(ctx, content)
+	if len(invocations) == 0 {
+		invocations = []functions.ToolInvocation{{Name: detectedTool, Content: content}}
+	}
+
+	runs := make([]toolRun, 0, len(invocations))
+	for _, invocation := range invocations {
+		runs = append(runs, toolRun{name: invocation.Name, input: invocation.Content})
+	}
+	return runs
+}
+
+// runTools executes the tool invocations of a model turn concurrently, at most
+// ToolExecution.MaxConcurrency at a time. beforeTools is called with the tool names while they run,
+// an error of it cancels the tools. The status of a tool is updated once all its invocations ended.
+func (l *ChatCompletionLogic) runTools(ctx context.Context, runs []toolRun, beforeTools func(names []string) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	names := make([]string, 0, len(runs))
+	pending := make(map[string]int)
+	for _, run := range runs {
+		names = append(names, run.name)
+		if pending[run.name] == 0 {
+			l.updateToolStatus(run.name, types.ToolStatusRunning)
+		}
+		pending[run.name]++
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed = make(map[string]bool)
+		slots  = make(chan struct{}, max(l.svcCtx.Config.ToolExecution.MaxConcurrency, 1))
+	)
+	for i := range runs {
+		wg.Add(1)
+		go func(run *toolRun) {
+			defer wg.Done()
+			slots <- struct{}{}
+			logger.InfoC(ctx, "starting to call tool", zap.String("name", run.name))
+			run.result, run.call = l.runTool(ctx, run.name, run.input)
+			<-slots
+
+			mu.Lock()
+			defer mu.Unlock()
+			failed[run.name] = failed[run.name] || run.call.ResultStatus != string(types.ToolStatusSuccess)
+			if pending[run.name]--; pending[run.name] > 0 {
+				return
+			}
+			status := types.ToolStatusSuccess
+			if failed[run.name] {
+				status = types.ToolStatusFailed
+			}
+			l.updateToolStatus(run.name, status)
+		}(&runs[i])
+	}
+
+	var err error
+	if beforeTools != nil {
+		if err = beforeTools(names); err != nil {
+			cancel()
+		}
+	}
+	wg.Wait()
+	return err
+}
+
+// defaultToolFollowUp is the instruction following the tool results, for tools without their own
+const defaultToolFollowUp = "Please summarize the key findings and/or code from the results above within the <think></think> tags. No need to summarize error messages. \nIf the search failed, don't say 'failed', describe this outcome as 'did not found relevant results' instead - MUST NOT using terms like 'failure', 'error', or 'unsuccessful' in your description. \nIn your summary, must include the name of the tool used and specify which tools you intend to use next. \nWhen appropriate, prioritize using these tools: %s"
+
+// xmlToolResultMessage builds the follow-up user message with the results of the turn, in invocation order
+func (l *ChatCompletionLogic) xmlToolResultMessage(runs []toolRun) types.Message {
+	contents := make([]model.Content, 0, 2*len(runs)+1)
+	for _, run := range runs {
+		contents = append(contents,
+			model.Content{
+				Type: model.ContTypeText,
+				Text: fmt.Sprintf("[%s] Result:", run.name),
+			}, model.Content{
+				Type: model.ContTypeText,
+				Text: run.result,
+			},
+		)
+	}
+	contents = append(contents, model.Content{
+		Type: model.ContTypeText,
+		Text: l.toolFollowUp(runs),
+	})
+
+	return types.Message{
+		Role:    types.RoleUser,
+		Content: contents,
+	}
+}
+
+// toolFollowUp returns the instructions following the results of the turn, each given once in invocation
+// order. Tools without their own instruction get the default one.
+func (l *ChatCompletionLogic) toolFollowUp(runs []toolRun) string {
+	var instructions []string
+	for _, run := range runs {
+		instruction, err := l.toolExecutor.GetToolFollowUp(run.name)
+		if err != nil {
+			logger.WarnC(l.ctx, "failed to get tool follow-up instruction", zap.String("tool", run.name), zap.Error(err))
+		}
+		if instruction == "" {
+			instruction = fmt.Sprintf(defaultToolFollowUp, l.toolExecutor.GetAllTools())
+		}
+		if !slices.Contains(instructions, instruction) {
+			instructions = append(instructions, instruction)
+		}
+	}
+	return strings.Join(instructions, "\n\n")
+}
@@ -0,0 +1,140 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zgsm-ai/chat-rag/internal/bootstrap"
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/functions"
+	"github.com/zgsm-ai/chat-rag/internal/model"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+// slowToolExecutor detects tools like the generic executor, its tools take a while and record how many
+// run at the same time
+type slowToolExecutor struct {
+	functions.ToolExecutor
+	running    atomic.Int32
+	maxRunning atomic.Int32
+}
+
+func (e *slowToolExecutor) ExecuteTools(ctx context.Context, toolName string, content string) (string, error) {
+	running := e.running.Add(1)
+	defer e.running.Add(-1)
+	for {
+		maxRunning := e.maxRunning.Load()
+		if running <= maxRunning || e.maxRunning.CompareAndSwap(maxRunning, running) {
+			break
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	if toolName == "definition_search" {
+		return "", errors.New("index unavailable")
+	}
+	return toolName + " found " + content, nil
+}
+
+func newParallelToolsLogic(maxConcurrency int) (*ChatCompletionLogic, *slowToolExecutor) {
+	executor := &slowToolExecutor{ToolExecutor: functions.NewGenericToolExecutor(&config.ToolConfig{
+		GenericTools: []config.GenericToolConfig{{Name: "codebase_search"}, {Name: "definition_search"}},
+	}, nil)}
+	return &ChatCompletionLogic{
+		ctx: context.Background(),
+		svcCtx: &bootstrap.ServiceContext{Config: config.Config{
+			ToolExecution: config.ToolExecutionConfig{MaxConcurrency: maxConcurrency},
+		}},
+		request:      &types.ChatCompletionRequest{},
+		identity:     &model.Identity{},
+		toolExecutor: executor,
+	}, executor
+}
+
+func TestXMLToolRuns_Parallel(t *testing.T) {
+	l, executor := newParallelToolsLogic(2)
+	content := "<codebase_search><query>a</query></codebase_search> then " +
+		"<definition_search><symbol>b</symbol></definition_search>\n" +
+		"<codebase_search><query>c</query></codebase_search>" +
+		"<codebase_search><query>unfinished"
+
+	runs := l.xmlToolRuns(l.ctx, "codebase_search", content)
+	require.Len(t, runs, 3)
+	assert.Equal(t, "definition_search", runs[1].name)
+	assert.Equal(t, "<definition_search><symbol>b</symbol></definition_search>", runs[1].input)
+
+	var started []string
+	require.NoError(t, l.runTools(l.ctx, runs, func(names []string) error {
+		started = names
+		return nil
+	}))
+	assert.Equal(t, int32(2), executor.maxRunning.Load())
+	assert.Equal(t, []string{"codebase_search", "definition_search", "codebase_search"}, started)
+
+	// Results keep the invocation order
+	assert.Equal(t, "codebase_search found <codebase_search><query>a</query></codebase_search>", runs[0].result)
+	assert.Contains(t, runs[1].result, "execute failed")
+	assert.Equal(t, string(types.ToolStatusFailed), runs[1].call.ResultStatus)
+	assert.Equal(t, "codebase_search found <codebase_search><query>c</query></codebase_search>", runs[2].result)
+
+	message := l.xmlToolResultMessage(runs)
+	assert.Equal(t, types.RoleUser, message.Role)
+	contents := message.Content.([]model.Content)
+	require.Len(t, contents, 7)
+	assert.Equal(t, "[codebase_search] Result:", contents[0].Text)
+	assert.Equal(t, "[definition_search] Result:", contents[2].Text)
+	assert.Equal(t, runs[2].result, contents[5].Text)
+	assert.Contains(t, contents[6].Text, "Please summarize")
+}
+
+func TestXMLToolRuns_Incomplete(t *testing.T) {
+	l, executor := newParallelToolsLogic(0)
+	content := "<codebase_search><query>a</query>"
+
+	runs := l.xmlToolRuns(l.ctx, "codebase_search", content)
+	require.Len(t, runs, 1)
+	assert.Equal(t, content, runs[0].input)
+
+	// Without a configured limit, tools run one at a time
+	runs = append(runs, runs[0])
+	require.NoError(t, l.runTools(l.ctx, runs, nil))
+	assert.Equal(t, int32(1), executor.maxRunning.Load())
+}
+
+func TestRunTools_BeforeToolsError(t *testing.T) {
+	l, _ := newParallelToolsLogic(2)
+	runs := []toolRun{{name: "codebase_search", input: "a"}}
+
+	err := l.runTools(l.ctx, runs, func(names []string) error {
+		return errors.New("client gone")
+	})
+	assert.EqualError(t, err, "client gone")
+}
+
+func TestXMLToolResultMessage_FollowUp(t *testing.T) {
+	l, _ := newParallelToolsLogic(1)
+	l.toolExecutor = functions.NewGenericToolExecutor(&config.ToolConfig{
+		GenericTools: []config.GenericToolConfig{
+			{Name: "codebase_search"},
+			{Name: "definition_search", Response: config.ToolResponseConfig{
+				FollowUp: "Check the definitions found by {{.ToolName}}, then use one of {{join .Tools \", \"}}.",
+			}},
+		},
+	}, nil)
+
+	runs := []toolRun{{name: "definition_search"}, {name: "codebase_search"}, {name: "definition_search"}}
+	contents := l.xmlToolResultMessage(runs).Content.([]model.Content)
+	followUp := contents[len(contents)-1].Text
+
+	// Each instruction is given once, in invocation order
+	custom := "Check the definitions found by definition_search, then use one of codebase_search, definition_search."
+	assert.True(t, strings.HasPrefix(followUp, custom+"\n\nPlease summarize"))
+	assert.Equal(t, 1, strings.Count(followUp, custom))
+	assert.Contains(t, followUp, "[codebase_search definition_search]")
+}
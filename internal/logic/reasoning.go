@@ -0,0 +1,317 @@
+package logic
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/logger"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+	"github.com/zgsm-ai/chat-rag/internal/utils"
+)
+
+const (
+	thinkOpenTag  = "<think>"
+	thinkCloseTag = "</think>"
+)
+
+// reasoningFields are the message and delta fields providers return reasoning in
+var reasoningFields = []string{"reasoning_content", "reasoning"}
+
+const (
+	// Nothing but whitespace seen yet
+	splitterStart = iota
+	// Inside the leading <think> block
+	splitterThinking
+	// Past the reasoning, everything is content
+	splitterContent
+)
+
+// reasoningSplitter separates the reasoning some models write inline in a <think> block at the
+// start of their content. Text that may be part of a tag split across stream chunks is held back
+// until the next chunk.
+type reasoningSplitter struct {
+	state   int
+	tagged  bool
+	pending string
+}
+
+// split returns the reasoning and content of the chunk
+func (s *reasoningSplitter) split(chunk string) (reasoning, content string) {
+	text := s.pending + chunk
+	s.pending = ""
+
+	if s.state == splitterStart {
+		trimmed := strings.TrimLeft(text, " \t\r\n")
+		switch {
+		case strings.HasPrefix(trimmed, thinkOpenTag):
+			s.state = splitterThinking
+			s.tagged = true
+			text = trimmed[len(thinkOpenTag):]
+		case strings.HasPrefix(thinkOpenTag, trimmed):
+			s.pending = text
+			return "", ""
+		default:
+			s.state = splitterContent
+		}
+	}
+
+	if s.state == splitterThinking {
+		if end := strings.Index(text, thinkCloseTag); end >= 0 {
+			s.state = splitterContent
+			return text[:end], text[end+len(thinkCloseTag):]
+		}
+		keep := partialTagSuffix(text, thinkCloseTag)
+		s.pending = text[len(text)-keep:]
+		return text[:len(text)-keep], ""
+	}
+	return "", text
+}
+
+// flush returns the text held back at the end of the stream
+func (s *reasoningSplitter) flush() (reasoning, content string) {
+	pending := s.pending
+	s.pending = ""
+	if s.state == splitterThinking {
+		return pending, ""
+	}
+	return "", pending
+}
+
+// partialTagSuffix returns the length of the longest suffix of the text that starts the tag
+func partialTagSuffix(text, tag string) int {
+	for n := min(len(text), len(tag)-1); n > 0; n-- {
+		if strings.HasSuffix(text, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// splitReasoning separates the leading <think> block from the content
+func splitReasoning(text string) (reasoning, content string) {
+	var s reasoningSplitter
+	reasoning, content = s.split(text)
+	flushedReasoning, flushedContent := s.flush()
+	reasoning += flushedReasoning
+	content += flushedContent
+	if s.tagged {
+		content = strings.TrimLeft(content, "\r\n")
+	}
+	return reasoning, content
+}
+
+// messageReasoning returns the reasoning of the message, from a reasoning field or inline, and its content
+func messageReasoning(msg types.Message) (reasoning, content string) {
+	for _, field := range reasoningFields {
+		if r, ok := msg.Extra[field].(string); ok {
+			reasoning += r
+		}
+	}
+	inline, content := splitReasoning(utils.GetContentAsString(msg.Content))
+	return reasoning + inline, content
+}
+
+// reasoningMode returns how reasoning is handed to the client of the request
+func (l *ChatCompletionLogic) reasoningMode() string {
+	clientIDE := ""
+	if l.identity != nil {
+		clientIDE = l.identity.ClientIDE
+	}
+	return l.svcCtx.Config.Reasoning.ModeFor(clientIDE)
+}
+
+// applyReasoningEffort sets the reasoning_effort configured for the prompt mode unless the request sets one
+func (l *ChatCompletionLogic) applyReasoningEffort() {
+	effort := l.svcCtx.Config.Reasoning.EffortFor(string(l.request.ExtraBody.PromptMode))
+	if effort == "" {
+		return
+	}
+	if _, ok := l.request.Extra["reasoning_effort"]; ok {
+		return
+	}
+
+	if l.request.Extra == nil {
+		l.request.Extra = make(map[string]any)
+	}
+	l.request.Extra["reasoning_effort"] = effort
+	logger.InfoC(l.ctx, "reasoning effort set by prompt mode",
+		zap.String("promptMode", string(l.request.ExtraBody.PromptMode)),
+		zap.String("effort", effort),
+	)
+}
+
+// withReasoningMode returns the response with its reasoning handled according to the mode.
+// The response is copied, so that cached responses are left untouched.
+func withReasoningMode(resp *types.ChatCompletionResponse, mode string) *types.ChatCompletionResponse {
+	if resp == nil || mode == config.ReasoningModePassthrough {
+		return resp
+	}
+
+	normalized := *resp
+	normalized.Choices = make([]types.Choice, len(resp.Choices))
+	for i, choice := range resp.Choices {
+		msg := choice.Message
+		reasoning := ""
+		for _, field := range reasoningFields {
+			if r, ok := msg.Extra[field].(string); ok {
+				reasoning += r
+			}
+		}
+		if content, ok := msg.Content.(string); ok {
+			inline, rest := splitReasoning(content)
+			reasoning += inline
+			msg.Content = rest
+		}
+
+		extra := make(map[string]any, len(msg.Extra)+1)
+		for k, v := range msg.Extra {
+			extra[k] = v
+		}
+		for _, field := range reasoningFields {
+			delete(extra, field)
+		}
+		if mode == config.ReasoningModeConvert && reasoning != "" {
+			extra["reasoning_content"] = reasoning
+		}
+		msg.Extra = nil
+		if len(extra) > 0 {
+			msg.Extra = extra
+		}
+
+		choice.Message = msg
+		normalized.Choices[i] = choice
+	}
+	return &normalized
+}
+
+// reasoningNormalizer collects the reasoning of a response stream, returned in a reasoning field
+// or inline in <think> tags, and rewrites the stream lines according to the reasoning mode
+type reasoningNormalizer struct {
+	mode     string
+	splitter reasoningSplitter
+	// All reasoning of the stream, for the chat log
+	reasoning strings.Builder
+	// Metadata of the last chunk, reused by the chunk of held back text
+	meta map[string]any
+}
+
+func newReasoningNormalizer(mode string) *reasoningNormalizer {
+	return &reasoningNormalizer{mode: mode}
+}
+
+// normalizeLine rewrites an SSE data line according to the mode. It returns the rewritten line and
+// the reasoning left in its reasoning field.
+func (n *reasoningNormalizer) normalizeLine(rawLine string) (string, string) {
+	jsonData, ok := strings.CutPrefix(rawLine, "data: ")
+	if !ok || jsonData == "[DONE]" {
+		return rawLine, ""
+	}
+
+	var chunk map[string]any
+	if err := json.Unmarshal([]byte(jsonData), &chunk); err != nil {
+		return rawLine, ""
+	}
+	n.meta = map[string]any{}
+	for _, key := range []string{"id", "object", "created", "model"} {
+		if v, ok := chunk[key]; ok {
+			n.meta[key] = v
+		}
+	}
+
+	choices, _ := chunk["choices"].([]any)
+	if len(choices) == 0 {
+		return rawLine, ""
+	}
+	choice, _ := choices[0].(map[string]any)
+	delta, _ := choice["delta"].(map[string]any)
+	if delta == nil {
+		return rawLine, ""
+	}
+
+	fieldReasoning := ""
+	for _, field := range reasoningFields {
+		if r, ok := delta[field].(string); ok {
+			fieldReasoning += r
+		}
+	}
+	reasoning := fieldReasoning
+	content, hasContent := delta["content"].(string)
+	if hasContent {
+		var inline string
+		inline, content = n.splitter.split(content)
+		reasoning += inline
+	}
+	n.reasoning.WriteString(reasoning)
+
+	if n.mode == config.ReasoningModePassthrough {
+		return rawLine, fieldReasoning
+	}
+
+	for _, field := range reasoningFields {
+		delete(delta, field)
+	}
+	if hasContent {
+		delta["content"] = content
+	}
+	if n.mode != config.ReasoningModeConvert {
+		reasoning = ""
+	} else if reasoning != "" {
+		delta["reasoning_content"] = reasoning
+	}
+
+	data, err := marshalChunk(chunk)
+	if err != nil {
+		return rawLine, fieldReasoning
+	}
+	return "data: " + data, reasoning
+}
+
+// flushLine returns a data line carrying the text held back at the end of the stream,
+// empty when there is none or it already reached the client untouched
+func (n *reasoningNormalizer) flushLine() string {
+	reasoning, content := n.splitter.flush()
+	n.reasoning.WriteString(reasoning)
+	if n.mode == config.ReasoningModePassthrough {
+		return ""
+	}
+	if n.mode != config.ReasoningModeConvert {
+		reasoning = ""
+	}
+	if reasoning == "" && content == "" {
+		return ""
+	}
+
+	delta := map[string]any{"content": content}
+	if reasoning != "" {
+		delta["reasoning_content"] = reasoning
+	}
+	chunk := map[string]any{"choices": []any{map[string]any{"index": 0, "delta": delta}}}
+	for k, v := range n.meta {
+		chunk[k] = v
+	}
+	data, err := marshalChunk(chunk)
+	if err != nil {
+		return ""
+	}
+	return "data: " + data
+}
+
+// marshalChunk marshals a rewritten stream chunk, leaving HTML characters of code unescaped as upstreams do
+func marshalChunk(chunk map[string]any) (string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(chunk); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// isDoneLine reports whether the SSE line ends the stream
+func isDoneLine(rawLine string) bool {
+	return strings.TrimSpace(strings.TrimPrefix(rawLine, "data: ")) == "[DONE]"
+}
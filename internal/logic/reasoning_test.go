@@ -0,0 +1,136 @@
+package logic
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+func TestReasoningSplitter_SplitTags(t *testing.T) {
+	var s reasoningSplitter
+	var reasoning, content strings.Builder
+	for _, chunk := range []string{"\n<thi", "nk>Let me ", "think.</th", "ink>\n\nThe answer", " is 42"} {
+		r, c := s.split(chunk)
+		reasoning.WriteString(r)
+		content.WriteString(c)
+	}
+	r, c := s.flush()
+	reasoning.WriteString(r)
+	content.WriteString(c)
+
+	assert.Equal(t, "Let me think.", reasoning.String())
+	assert.Equal(t, "\n\nThe answer is 42", content.String())
+}
+
+func TestSplitReasoning(t *testing.T) {
+	reasoning, content := splitReasoning("<think>step 1</think>\n\nanswer")
+	assert.Equal(t, "step 1", reasoning)
+	assert.Equal(t, "answer", content)
+
+	// Tags after the start of the content are content
+	reasoning, content = splitReasoning("use <think> tags\n")
+	assert.Empty(t, reasoning)
+	assert.Equal(t, "use <think> tags\n", content)
+
+	// Unterminated reasoning
+	reasoning, content = splitReasoning("<think>still thinking</thi")
+	assert.Equal(t, "still thinking</thi", reasoning)
+	assert.Empty(t, content)
+}
+
+func TestReasoningNormalizer_Modes(t *testing.T) {
+	lines := []string{
+		`data: {"id":"1","model":"m","choices":[{"index":0,"delta":{"reasoning_content":"field "}}]}`,
+		`data: {"id":"1","model":"m","choices":[{"index":0,"delta":{"content":"<think>inline</think>answer"}}]}`,
+	}
+	deltas := func(n *reasoningNormalizer) []map[string]any {
+		var result []map[string]any
+		for _, line := range lines {
+			normalized, _ := n.normalizeLine(line)
+			var chunk map[string]any
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(normalized, "data: ")), &chunk))
+			result = append(result, chunk["choices"].([]any)[0].(map[string]any)["delta"].(map[string]any))
+		}
+		return result
+	}
+
+	passthrough := newReasoningNormalizer(config.ReasoningModePassthrough)
+	d := deltas(passthrough)
+	assert.Equal(t, "field ", d[0]["reasoning_content"])
+	assert.Equal(t, "<think>inline</think>answer", d[1]["content"])
+	assert.Equal(t, "field inline", passthrough.reasoning.String())
+
+	strip := newReasoningNormalizer(config.ReasoningModeStrip)
+	d = deltas(strip)
+	assert.NotContains(t, d[0], "reasoning_content")
+	assert.NotContains(t, d[1], "reasoning_content")
+	assert.Equal(t, "answer", d[1]["content"])
+	assert.Equal(t, "field inline", strip.reasoning.String())
+
+	convert := newReasoningNormalizer(config.ReasoningModeConvert)
+	d = deltas(convert)
+	assert.Equal(t, "field ", d[0]["reasoning_content"])
+	assert.Equal(t, "inline", d[1]["reasoning_content"])
+	assert.Equal(t, "answer", d[1]["content"])
+}
+
+func TestReasoningNormalizer_FlushLine(t *testing.T) {
+	n := newReasoningNormalizer(config.ReasoningModeConvert)
+	line, reasoning := n.normalizeLine(`data: {"id":"1","choices":[{"index":0,"delta":{"content":"<think>almost</th"}}]}`)
+	assert.Equal(t, "almost", reasoning)
+	assert.Contains(t, line, `"reasoning_content":"almost"`)
+
+	flushed := n.flushLine()
+	assert.Contains(t, flushed, `"reasoning_content":"</th"`)
+	assert.Contains(t, flushed, `"id":"1"`)
+	assert.Empty(t, n.flushLine())
+
+	// Passthrough already sent the held back text
+	n = newReasoningNormalizer(config.ReasoningModePassthrough)
+	n.normalizeLine(`data: {"choices":[{"index":0,"delta":{"content":"<"}}]}`)
+	assert.Empty(t, n.flushLine())
+}
+
+func TestWithReasoningMode(t *testing.T) {
+	resp := &types.ChatCompletionResponse{Choices: []types.Choice{{
+		Message: types.Message{
+			Role:    types.RoleAssistant,
+			Content: "<think>inline</think>\nanswer",
+			Extra:   map[string]any{"reasoning": "field "},
+		},
+	}}}
+
+	assert.Same(t, resp, withReasoningMode(resp, config.ReasoningModePassthrough))
+
+	converted := withReasoningMode(resp, config.ReasoningModeConvert)
+	assert.Equal(t, "answer", converted.Choices[0].Message.Content)
+	assert.Equal(t, map[string]any{"reasoning_content": "field inline"}, converted.Choices[0].Message.Extra)
+
+	stripped := withReasoningMode(resp, config.ReasoningModeStrip)
+	assert.Equal(t, "answer", stripped.Choices[0].Message.Content)
+	assert.Nil(t, stripped.Choices[0].Message.Extra)
+
+	// The original response is left untouched
+	assert.Equal(t, "<think>inline</think>\nanswer", resp.Choices[0].Message.Content)
+	assert.Equal(t, "field ", resp.Choices[0].Message.Extra["reasoning"])
+
+	reasoning, content := messageReasoning(resp.Choices[0].Message)
+	assert.Equal(t, "field inline", reasoning)
+	assert.Equal(t, "answer", content)
+}
+
+func TestReasoningConfig(t *testing.T) {
+	cfg := config.ReasoningConfig{
+		ClientModes: map[string]string{"jetbrains": config.ReasoningModeStrip},
+		Efforts:     map[string]string{"performance": "high"},
+	}
+	assert.Equal(t, config.ReasoningModePassthrough, cfg.ModeFor("vscode"))
+	assert.Equal(t, config.ReasoningModeStrip, cfg.ModeFor("JetBrains"))
+	assert.Equal(t, "high", cfg.EffortFor(string(types.Performance)))
+	assert.Empty(t, cfg.EffortFor(string(types.Cost)))
+}
@@ -0,0 +1,208 @@
+package logic
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/zgsm-ai/chat-rag/internal/logger"
+	"github.com/zgsm-ai/chat-rag/internal/model"
+	"github.com/zgsm-ai/chat-rag/internal/service"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+// responseCacheKey returns the response cache key of the processed request,
+// empty when the cache is disabled, bypassed or the request is not cacheable
+func (l *ChatCompletionLogic) responseCacheKey(chatLog *model.ChatLog) string {
+	if l.svcCtx.ResponseCache == nil || chatLog == nil || !chatLog.IsPromptProceed {
+		return ""
+	}
+	if l.headers != nil && strings.EqualFold(l.headers.Get(types.HeaderCacheBypass), "true") {
+		logger.InfoC(l.ctx, "response cache: bypassed by request header")
+		return ""
+	}
+	if l.svcCtx.Config.ResponseCache.DeterministicOnly && !isDeterministic(l.request.LLMRequestParams) {
+		return ""
+	}
+
+	// Responses are only shared between users when configured
+	var owner string
+	if !l.svcCtx.Config.ResponseCache.ShareAcrossUsers {
+		if owner = l.identity.Owner(); owner == "" {
+			return ""
+		}
+	}
+
+	// Key on the requested model so that auto mode requests hit whichever model answered
+	key, err := service.ResponseCacheKey(l.originalModel, owner, l.request.LLMRequestParams)
+	if err != nil {
+		logger.WarnC(l.ctx, "response cache: failed to build key", zap.Error(err))
+		return ""
+	}
+	return key
+}
+
+// cachedResponse returns the cached response of the key, nil on miss
+func (l *ChatCompletionLogic) cachedResponse(key string) *types.ChatCompletionResponse {
+	if key == "" {
+		return nil
+	}
+
+	resp, err := l.svcCtx.ResponseCache.Get(l.ctx, key)
+	if err != nil {
+		logger.WarnC(l.ctx, "response cache: failed to read cached response", zap.Error(err))
+	}
+	if resp == nil || len(resp.Choices) == 0 {
+		l.setResponseHeader(types.HeaderCache, "MISS")
+		return nil
+	}
+
+	logger.InfoC(l.ctx, "response cache: hit",
+		zap.String("key", key),
+		zap.String("model", resp.Model),
+	)
+	l.setResponseHeader(types.HeaderCache, "HIT")
+	if resp.Model != "" {
+		l.setResponseHeader(types.HeaderSelectLLm, resp.Model)
+	}
+	return resp
+}
+
+// storeResponse caches the response under the key
+func (l *ChatCompletionLogic) storeResponse(key string, resp *types.ChatCompletionResponse) {
+	if key == "" || resp == nil || len(resp.Choices) == 0 {
+		return
+	}
+	if err := l.svcCtx.ResponseCache.Set(l.ctx, key, resp); err != nil {
+		logger.WarnC(l.ctx, "response cache: failed to store response", zap.Error(err))
+	}
+}
+
+// streamedResponse rebuilds the response of a completed stream from the chat log.
+// Streams that failed or ran server side tools are not cacheable and return nil.
+func (l *ChatCompletionLogic) streamedResponse(chatLog *model.ChatLog) *types.ChatCompletionResponse {
+	if len(chatLog.Error) > 0 || len(chatLog.ToolCalls) > 0 || chatLog.ResponseContent == nil {
+		return nil
+	}
+
+	content := chatLog.ResponseContent
+	message := types.Message{Role: types.RoleAssistant, Content: content.Content}
+	finishReason := "stop"
+	if len(content.ToolCalls) > 0 || content.ReasoningContent != "" {
+		message.Extra = make(map[string]any)
+	}
+	if len(content.ToolCalls) > 0 {
+		message.Extra["tool_calls"] = content.ToolCalls
+		finishReason = "tool_calls"
+	}
+	if content.ReasoningContent != "" {
+		message.Extra["reasoning_content"] = content.ReasoningContent
+	}
+
+	return &types.ChatCompletionResponse{
+		Id:      "chatcmpl-" + uuid.NewString(),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   l.request.Model,
+		Choices: []types.Choice{{
+			Index:        0,
+			Message:      message,
+			FinishReason: finishReason,
+		}},
+		Usage: chatLog.Usage,
+	}
+}
+
+// completedResponse returns the non-streaming response to cache.
+// Responses of requests that failed or ran server side tools are not cacheable and return nil.
+func completedResponse(chatLog *model.ChatLog, response *types.ChatCompletionResponse) *types.ChatCompletionResponse {
+	if len(chatLog.Error) > 0 || len(chatLog.ToolCalls) > 0 {
+		return nil
+	}
+	return response
+}
+
+// replayCachedStream writes a cached response to the client as SSE chunks
+func (l *ChatCompletionLogic) replayCachedStream(flusher http.Flusher, resp *types.ChatCompletionResponse, chatLog *model.ChatLog) error {
+	// Semantic hits are recorded in chatLog.SemanticCache
+	if chatLog.SemanticCache == nil || !chatLog.SemanticCache.Hit {
+		chatLog.CacheHit = true
+	}
+	l.responseHandler.extractResponseInfo(chatLog, resp)
+	resp = withReasoningMode(resp, l.reasoningMode())
+
+	writeChunk := func(choices []map[string]any, usage *types.Usage) error {
+		chunk := map[string]any{
+			"id":      resp.Id,
+			"object":  "chat.completion.chunk",
+			"created": resp.Created,
+			"model":   resp.Model,
+			"choices": choices,
+		}
+		if usage != nil {
+			chunk["usage"] = usage
+		}
+		data, err := json.Marshal(chunk)
+		if err != nil {
+			return err
+		}
+		return l.sendRawLine(flusher, string(data))
+	}
+
+	choice := resp.Choices[0]
+	delta := map[string]any{
+		"role":    types.RoleAssistant,
+		"content": choice.Message.Content,
+	}
+	if reasoning, ok := choice.Message.Extra["reasoning_content"]; ok {
+		delta["reasoning_content"] = reasoning
+	}
+	if calls, ok := choice.Message.Extra["tool_calls"].([]any); ok && len(calls) > 0 {
+		indexed := make([]any, 0, len(calls))
+		for i, call := range calls {
+			if fields, ok := call.(map[string]any); ok {
+				withIndex := make(map[string]any, len(fields)+1)
+				for k, v := range fields {
+					withIndex[k] = v
+				}
+				withIndex["index"] = i
+				call = withIndex
+			}
+			indexed = append(indexed, call)
+		}
+		delta["tool_calls"] = indexed
+	}
+
+	finishReason := choice.FinishReason
+	if finishReason == "" {
+		finishReason = "stop"
+	}
+
+	if err := writeChunk([]map[string]any{{"index": 0, "delta": delta}}, nil); err != nil {
+		return err
+	}
+	if err := writeChunk([]map[string]any{{"index": 0, "delta": map[string]any{}, "finish_reason": finishReason}}, nil); err != nil {
+		return err
+	}
+	if err := writeChunk([]map[string]any{}, &resp.Usage); err != nil {
+		return err
+	}
+	return l.sendRawLine(flusher, "[DONE]")
+}
+
+// setResponseHeader sets a header on the client response
+func (l *ChatCompletionLogic) setResponseHeader(key, value string) {
+	if l.writer != nil {
+		l.writer.Header().Set(key, value)
+	}
+}
+
+// isDeterministic reports whether the request samples with temperature 0
+func isDeterministic(params types.LLMRequestParams) bool {
+	temperature, ok := params.Extra["temperature"].(float64)
+	return ok && temperature == 0
+}
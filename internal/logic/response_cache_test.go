@@ -0,0 +1,106 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zgsm-ai/chat-rag/internal/bootstrap"
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/model"
+	"github.com/zgsm-ai/chat-rag/internal/service"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+func TestReplayCachedStream(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	svcCtx := &bootstrap.ServiceContext{}
+	l := &ChatCompletionLogic{
+		ctx:             context.Background(),
+		svcCtx:          svcCtx,
+		request:         &types.ChatCompletionRequest{Model: "gpt-4o"},
+		writer:          recorder,
+		responseHandler: NewResponseHandler(context.Background(), svcCtx),
+	}
+
+	// A stream response is cached through the chat log and replayed as SSE
+	chatLog := &model.ChatLog{
+		ResponseContent: &types.ResponseContent{
+			Content:   "LGTM",
+			ToolCalls: []types.ToolCallInfo{{ID: "call_1", Type: "function"}},
+		},
+		Usage: types.Usage{PromptTokens: 10, CompletionTokens: 2, TotalTokens: 12},
+	}
+	cached := l.streamedResponse(chatLog)
+	require.NotNil(t, cached)
+	data, err := json.Marshal(cached)
+	require.NoError(t, err)
+	var stored types.ChatCompletionResponse
+	require.NoError(t, json.Unmarshal(data, &stored))
+
+	replayLog := &model.ChatLog{}
+	require.NoError(t, l.replayCachedStream(recorder, &stored, replayLog))
+	assert.True(t, replayLog.CacheHit)
+	assert.Equal(t, 12, replayLog.Usage.TotalTokens)
+
+	var lines []string
+	for _, line := range strings.Split(recorder.Body.String(), "\n\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	require.Len(t, lines, 4)
+	assert.Contains(t, lines[0], `"content":"LGTM"`)
+	assert.Contains(t, lines[0], `"tool_calls":[{"function":`)
+	assert.Contains(t, lines[0], `"index":0`)
+	assert.Contains(t, lines[1], `"finish_reason":"tool_calls"`)
+	assert.Contains(t, lines[2], `"total_tokens":12`)
+	assert.Equal(t, "data: [DONE]", lines[3])
+
+	// Streams that ran server side tools are not cached
+	chatLog.ToolCalls = []model.ToolCall{{ToolName: "search"}}
+	assert.Nil(t, l.streamedResponse(chatLog))
+}
+
+func TestCompletedResponse(t *testing.T) {
+	response := &types.ChatCompletionResponse{Choices: []types.Choice{{Message: types.Message{Content: "LGTM"}}}}
+
+	assert.Same(t, response, completedResponse(&model.ChatLog{}, response))
+	// Responses built from the results of server side tools are not cached
+	assert.Nil(t, completedResponse(&model.ChatLog{ToolCalls: []model.ToolCall{{ToolName: "search"}}}, response))
+}
+
+func TestResponseCacheKey_Owner(t *testing.T) {
+	svcCtx := &bootstrap.ServiceContext{
+		Config:        config.Config{ResponseCache: config.ResponseCacheConfig{Enabled: true}},
+		ResponseCache: service.NewResponseCache(nil, time.Hour),
+	}
+	newLogic := func(identity *model.Identity) *ChatCompletionLogic {
+		request := &types.ChatCompletionRequest{Model: "gpt-4o"}
+		request.Messages = []types.Message{{Role: types.RoleUser, Content: "review this diff"}}
+		return &ChatCompletionLogic{
+			ctx:           context.Background(),
+			svcCtx:        svcCtx,
+			request:       request,
+			identity:      identity,
+			originalModel: "gpt-4o",
+		}
+	}
+	chatLog := &model.ChatLog{IsPromptProceed: true}
+
+	first := newLogic(&model.Identity{UserInfo: &model.UserInfo{UUID: "user-1"}}).responseCacheKey(chatLog)
+	second := newLogic(&model.Identity{UserInfo: &model.UserInfo{UUID: "user-2"}}).responseCacheKey(chatLog)
+	require.NotEmpty(t, first)
+	assert.NotEqual(t, first, second, "users do not share responses")
+	assert.Empty(t, newLogic(&model.Identity{}).responseCacheKey(chatLog), "requests without a user are not cached")
+
+	// Sharing between users is explicit
+	svcCtx.Config.ResponseCache.ShareAcrossUsers = true
+	assert.Equal(t, newLogic(&model.Identity{UserInfo: &model.UserInfo{UUID: "user-1"}}).responseCacheKey(chatLog),
+		newLogic(&model.Identity{}).responseCacheKey(chatLog))
+}
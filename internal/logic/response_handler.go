@@ -12,7 +12,6 @@ import (
 	"github.com/zgsm-ai/chat-rag/internal/model"
 	"github.com/zgsm-ai/chat-rag/internal/tokenizer"
 	"github.com/zgsm-ai/chat-rag/internal/types"
-	"github.com/zgsm-ai/chat-rag/internal/utils"
 	"go.uber.org/zap"
 )
 
@@ -33,11 +32,12 @@ func (h *ResponseHandler) extractResponseInfo(chatLog *model.ChatLog, response *
 		zap.Int("choicesCount", len(response.Choices)),
 	)
 
-	// Extract response content from choices
+	// Extract response content from choices, reasoning is logged apart from the content
 	if len(response.Choices) > 0 {
-		contentStr := utils.GetContentAsString(response.Choices[0].Message.Content)
+		reasoning, contentStr := messageReasoning(response.Choices[0].Message)
 		chatLog.ResponseContent = &types.ResponseContent{
-			Content: contentStr,
+			Content:          contentStr,
+			ReasoningContent: reasoning,
 		}
 	}
 
@@ -46,13 +46,15 @@ func (h *ResponseHandler) extractResponseInfo(chatLog *model.ChatLog, response *
 		zap.Int("totalTokens", response.Usage.TotalTokens),
 		zap.Int("promptTokens", response.Usage.PromptTokens),
 		zap.Int("completionTokens", response.Usage.CompletionTokens),
+		zap.Int("cachedTokens", response.Usage.CachedTokens()),
+		zap.Int("reasoningTokens", response.Usage.ReasoningTokens()),
 	)
 
 	if response.Usage.TotalTokens > 0 {
 		chatLog.Usage = response.Usage
 	} else {
 		// Calculate usage if not provided
-		chatLog.Usage = h.calculateUsage(chatLog.Tokens.Processed.All, chatLog.ResponseContent.Content)
+		chatLog.Usage = h.calculateUsage(chatLog.Tokens.Processed.All, chatLog.ResponseContent.Content, chatLog.ResponseContent.ReasoningContent)
 		logger.Info("calculated usage",
 			zap.Int("totalTokens", chatLog.Usage.TotalTokens),
 		)
@@ -66,14 +68,19 @@ func (h *ResponseHandler) countTokens(text string) int {
 	return tokenizer.EstimateTokens(text)
 }
 
-// calculateUsage calculates usage information when not provided by the model
-func (h *ResponseHandler) calculateUsage(promptTokens int, responseContent string) types.Usage {
+// calculateUsage calculates usage information when not provided by the model,
+// reasoning counts towards the completion tokens
+func (h *ResponseHandler) calculateUsage(promptTokens int, responseContent string, reasoningContent string) types.Usage {
 	completionTokens := h.countTokens(responseContent)
-	return types.Usage{
-		PromptTokens:     promptTokens,
-		CompletionTokens: completionTokens,
-		TotalTokens:      promptTokens + completionTokens,
+	usage := types.Usage{PromptTokens: promptTokens}
+	if reasoningContent != "" {
+		reasoningTokens := h.countTokens(reasoningContent)
+		completionTokens += reasoningTokens
+		usage.CompletionTokensDetails = &types.CompletionTokensDetails{ReasoningTokens: reasoningTokens}
 	}
+	usage.CompletionTokens = completionTokens
+	usage.TotalTokens = promptTokens + completionTokens
+	return usage
 }
 
 // extractStreamingData extracts content and usage from streaming response lines
@@ -136,6 +143,22 @@ func (h *ResponseHandler) extractStreamingData(rawLine string) (content string,
 		if totalTokens, ok := usageData["total_tokens"].(float64); ok {
 			usage.TotalTokens = int(totalTokens)
 		}
+		if details, ok := usageData["prompt_tokens_details"].(map[string]interface{}); ok {
+			if cachedTokens, ok := details["cached_tokens"].(float64); ok {
+				usage.PromptTokensDetails = &types.PromptTokensDetails{CachedTokens: int(cachedTokens)}
+			}
+		}
+		if cacheRead, ok := usageData["cache_read_input_tokens"].(float64); ok {
+			usage.CacheReadInputTokens = int(cacheRead)
+		}
+		if cacheCreation, ok := usageData["cache_creation_input_tokens"].(float64); ok {
+			usage.CacheCreationInputTokens = int(cacheCreation)
+		}
+		if details, ok := usageData["completion_tokens_details"].(map[string]interface{}); ok {
+			if reasoningTokens, ok := details["reasoning_tokens"].(float64); ok {
+				usage.CompletionTokensDetails = &types.CompletionTokensDetails{ReasoningTokens: int(reasoningTokens)}
+			}
+		}
 	}
 
 	return
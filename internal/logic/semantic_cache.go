@@ -0,0 +1,158 @@
+package logic
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/zgsm-ai/chat-rag/internal/logger"
+	"github.com/zgsm-ai/chat-rag/internal/model"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+	"github.com/zgsm-ai/chat-rag/internal/utils"
+)
+
+// semanticCacheQuery is the question of a request eligible for the semantic cache
+type semanticCacheQuery struct {
+	scope     string
+	question  string
+	embedding []float32
+}
+
+// semanticCacheQuery returns the semantic cache query of the processed request,
+// nil when the cache is disabled, bypassed or not configured for the agent and mode
+func (l *ChatCompletionLogic) semanticCacheQuery(chatLog *model.ChatLog) *semanticCacheQuery {
+	if l.svcCtx.SemanticCache == nil || chatLog == nil || !chatLog.IsPromptProceed {
+		return nil
+	}
+	if l.headers != nil && strings.EqualFold(l.headers.Get(types.HeaderCacheBypass), "true") {
+		return nil
+	}
+
+	mode := l.request.ExtraBody.Mode
+	if !l.svcCtx.Config.SemanticCache.Applies(chatLog.Agent, mode) {
+		return nil
+	}
+
+	question, err := utils.GetLastUserMsgContent(l.request.Messages)
+	if err != nil || strings.TrimSpace(question) == "" {
+		return nil
+	}
+
+	// Shared answers are only served to requests of the same model, agent and mode
+	// that follow the same system prompt
+	scope := []string{l.originalModel, chatLog.Agent, mode}
+	if l.svcCtx.Config.SemanticCache.ShareAcrossUsers {
+		system, ok := systemPromptHash(l.request.Messages)
+		if !ok {
+			return nil
+		}
+		return &semanticCacheQuery{
+			scope:    strings.Join(append(scope, system), ":"),
+			question: question,
+		}
+	}
+
+	// Otherwise answers are only served to the same user in the same conversation
+	owner := l.identity.Owner()
+	if owner == "" {
+		return nil
+	}
+	history, ok := conversationHash(l.request.Messages)
+	if !ok {
+		return nil
+	}
+	return &semanticCacheQuery{
+		scope:    strings.Join(append(scope, owner, history), ":"),
+		question: question,
+	}
+}
+
+// systemPromptHash returns the hash of the system messages, so that answers are only shared
+// between requests following the same instructions
+func systemPromptHash(messages []types.Message) (string, bool) {
+	var system []types.Message
+	for _, msg := range messages {
+		if msg.Role == types.RoleSystem {
+			system = append(system, msg)
+		}
+	}
+	return messagesHash(system)
+}
+
+// conversationHash returns the hash of the messages before the last user message, so that the same
+// question asked in different conversations gets different answers
+func conversationHash(messages []types.Message) (string, bool) {
+	last := -1
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == types.RoleUser {
+			last = i
+			break
+		}
+	}
+	if last < 0 {
+		return "", false
+	}
+	return messagesHash(messages[:last])
+}
+
+// messagesHash returns a short hash of the messages
+func messagesHash(messages []types.Message) (string, bool) {
+	data, err := json.Marshal(messages)
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8]), true
+}
+
+// semanticCachedResponse returns the cached answer of a near-duplicate question, nil on miss
+func (l *ChatCompletionLogic) semanticCachedResponse(query *semanticCacheQuery, chatLog *model.ChatLog) *types.ChatCompletionResponse {
+	if query == nil {
+		return nil
+	}
+
+	match, err := l.svcCtx.SemanticCache.Lookup(l.ctx, query.scope, query.question)
+	if err != nil {
+		logger.WarnC(l.ctx, "semantic cache: lookup failed", zap.Error(err))
+	}
+	if match == nil {
+		return nil
+	}
+
+	query.embedding = match.Embedding
+	hit := match.Response != nil && len(match.Response.Choices) > 0
+	chatLog.SemanticCache = &model.SemanticCacheLookup{Hit: hit, Similarity: match.Similarity}
+	if !hit {
+		return nil
+	}
+
+	logger.InfoC(l.ctx, "semantic cache: hit",
+		zap.String("scope", query.scope),
+		zap.Float64("similarity", match.Similarity),
+		zap.String("model", match.Response.Model),
+	)
+	l.setResponseHeader(types.HeaderCache, "SEMANTIC-HIT")
+	if match.Response.Model != "" {
+		l.setResponseHeader(types.HeaderSelectLLm, match.Response.Model)
+	}
+	return match.Response
+}
+
+// storeSemanticResponse caches the answer of the query
+func (l *ChatCompletionLogic) storeSemanticResponse(query *semanticCacheQuery, resp *types.ChatCompletionResponse) {
+	if query == nil || resp == nil || len(resp.Choices) == 0 {
+		return
+	}
+	if err := l.svcCtx.SemanticCache.Store(l.ctx, query.scope, query.question, query.embedding, resp); err != nil {
+		logger.WarnC(l.ctx, "semantic cache: failed to store response", zap.Error(err))
+	}
+}
+
+// storeCachedResponse stores the response in the exact-match and semantic caches
+func (l *ChatCompletionLogic) storeCachedResponse(cacheKey string, query *semanticCacheQuery, resp *types.ChatCompletionResponse) {
+	l.storeResponse(cacheKey, resp)
+	l.storeSemanticResponse(query, resp)
+}
@@ -0,0 +1,116 @@
+package logic
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zgsm-ai/chat-rag/internal/bootstrap"
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/model"
+	"github.com/zgsm-ai/chat-rag/internal/service"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+func TestSemanticCache_AgentsAndModes(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	svcCtx := &bootstrap.ServiceContext{
+		Config: config.Config{SemanticCache: config.SemanticCacheConfig{
+			Enabled: true,
+			Agents:  []string{"ask"},
+			Modes:   []string{"chat"},
+		}},
+		SemanticCache: service.NewSemanticCacheWith(service.NewHashingEmbedder(512), service.NewMemorySemanticIndex(10, time.Hour), 0.9),
+	}
+	newUserLogic := func(user string, history []types.Message, question, mode string, headers http.Header) *ChatCompletionLogic {
+		request := &types.ChatCompletionRequest{Model: "gpt-4o"}
+		request.Messages = append(append([]types.Message(nil), history...), types.Message{Role: types.RoleUser, Content: question})
+		request.ExtraBody.Mode = mode
+		return &ChatCompletionLogic{
+			ctx:           context.Background(),
+			svcCtx:        svcCtx,
+			request:       request,
+			identity:      &model.Identity{UserInfo: &model.UserInfo{UUID: user}},
+			originalModel: "gpt-4o",
+			headers:       &headers,
+			writer:        recorder,
+		}
+	}
+	system := []types.Message{{Role: types.RoleSystem, Content: "You are helpful"}}
+	newLogic := func(question, mode string, headers http.Header) *ChatCompletionLogic {
+		return newUserLogic("user-1", system, question, mode, headers)
+	}
+
+	// Other agents and modes are not looked up
+	assert.Nil(t, newLogic("How do I configure X?", "code", nil).semanticCacheQuery(&model.ChatLog{IsPromptProceed: true, Agent: "code"}))
+	bypass := http.Header{}
+	bypass.Set(types.HeaderCacheBypass, "true")
+	assert.Nil(t, newLogic("How do I configure X?", "chat", bypass).semanticCacheQuery(&model.ChatLog{IsPromptProceed: true}))
+
+	l := newLogic("How do I configure X?", "chat", nil)
+	chatLog := &model.ChatLog{IsPromptProceed: true}
+	query := l.semanticCacheQuery(chatLog)
+	require.NotNil(t, query)
+	assert.Nil(t, l.semanticCachedResponse(query, chatLog))
+	require.NotNil(t, chatLog.SemanticCache)
+	assert.False(t, chatLog.SemanticCache.Hit)
+	l.storeCachedResponse("", query, &types.ChatCompletionResponse{
+		Model:   "gpt-4o",
+		Choices: []types.Choice{{Message: types.Message{Role: types.RoleAssistant, Content: "Set X in the config"}}},
+	})
+
+	l = newLogic("how do I configure X", "chat", nil)
+	chatLog = &model.ChatLog{IsPromptProceed: true}
+	cached := l.semanticCachedResponse(l.semanticCacheQuery(chatLog), chatLog)
+	require.NotNil(t, cached)
+	assert.Equal(t, "Set X in the config", cached.Choices[0].Message.Content)
+	assert.True(t, chatLog.SemanticCache.Hit)
+	assert.InDelta(t, 1, chatLog.SemanticCache.Similarity, 1e-6)
+	assert.Equal(t, "SEMANTIC-HIT", recorder.Header().Get(types.HeaderCache))
+
+	// Other users and other conversations do not share the answer
+	l = newUserLogic("user-2", system, "how do I configure X", "chat", nil)
+	chatLog = &model.ChatLog{IsPromptProceed: true}
+	assert.Nil(t, l.semanticCachedResponse(l.semanticCacheQuery(chatLog), chatLog))
+	history := append(append([]types.Message(nil), system...),
+		types.Message{Role: types.RoleUser, Content: "We use the Y framework"},
+		types.Message{Role: types.RoleAssistant, Content: "Noted"})
+	l = newUserLogic("user-1", history, "how do I configure X", "chat", nil)
+	chatLog = &model.ChatLog{IsPromptProceed: true}
+	assert.Nil(t, l.semanticCachedResponse(l.semanticCacheQuery(chatLog), chatLog))
+
+	// Requests without a user are not cached
+	l = newLogic("how do I configure X", "chat", nil)
+	l.identity = &model.Identity{}
+	assert.Nil(t, l.semanticCacheQuery(&model.ChatLog{IsPromptProceed: true}))
+
+	// Shared answers are served to other users and conversations with the same system prompt
+	svcCtx.Config.SemanticCache.ShareAcrossUsers = true
+	l = newUserLogic("user-1", system, "How do I configure X?", "chat", nil)
+	query = l.semanticCacheQuery(&model.ChatLog{IsPromptProceed: true})
+	require.NotNil(t, query)
+	l.storeCachedResponse("", query, &types.ChatCompletionResponse{
+		Model:   "gpt-4o",
+		Choices: []types.Choice{{Message: types.Message{Role: types.RoleAssistant, Content: "Set X in the shared config"}}},
+	})
+	for _, shared := range []*ChatCompletionLogic{
+		newUserLogic("user-2", system, "how do I configure X", "chat", nil),
+		newUserLogic("user-1", history, "how do I configure X", "chat", nil),
+	} {
+		chatLog = &model.ChatLog{IsPromptProceed: true}
+		cached = shared.semanticCachedResponse(shared.semanticCacheQuery(chatLog), chatLog)
+		require.NotNil(t, cached)
+		assert.Equal(t, "Set X in the shared config", cached.Choices[0].Message.Content)
+	}
+	other := []types.Message{{Role: types.RoleSystem, Content: "You are terse"}}
+	l = newUserLogic("user-2", other, "how do I configure X", "chat", nil)
+	chatLog = &model.ChatLog{IsPromptProceed: true}
+	assert.Nil(t, l.semanticCachedResponse(l.semanticCacheQuery(chatLog), chatLog))
+	l = newLogic("how do I configure X", "chat", nil)
+	l.identity = &model.Identity{}
+	assert.NotNil(t, l.semanticCacheQuery(&model.ChatLog{IsPromptProceed: true}))
+}
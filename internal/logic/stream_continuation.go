@@ -0,0 +1,288 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+
+	"github.com/zgsm-ai/chat-rag/internal/client"
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/logger"
+	"github.com/zgsm-ai/chat-rag/internal/model"
+	"github.com/zgsm-ai/chat-rag/internal/timeout"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+const (
+	// Start of the continuation checked for text repeating the partial output
+	seamWindow = 256
+	// Shortest repetition dropped at the seam, shorter matches are likely legitimate text
+	minSeamOverlap = 4
+
+	continuationPrompt = "Your previous answer was cut off. Continue it exactly from where it stopped, " +
+		"without repeating any of it and without any preamble."
+)
+
+// seamGuard holds back the start of a continuation until it can drop the text that repeats the end
+// of the partial output
+type seamGuard struct {
+	partial string
+	pending strings.Builder
+	done    bool
+	// Characters dropped at the seam
+	dropped int
+}
+
+func newSeamGuard(partial string) *seamGuard {
+	return &seamGuard{partial: partial}
+}
+
+// write returns the continuation text ready to be sent
+func (g *seamGuard) write(text string) string {
+	if g.done {
+		return text
+	}
+	g.pending.WriteString(text)
+	if g.pending.Len() < seamWindow {
+		return ""
+	}
+	return g.flush()
+}
+
+// flush returns the held back text without the repetition of the partial output
+func (g *seamGuard) flush() string {
+	if g.done {
+		return ""
+	}
+	g.done = true
+	text := g.pending.String()
+	overlap := seamOverlap(g.partial, text)
+	g.dropped = utf8.RuneCountInString(text[:overlap])
+	return text[overlap:]
+}
+
+// seamOverlap returns the length of the longest start of the text that repeats the end of the partial output
+func seamOverlap(partial, text string) int {
+	for n := min(len(partial), len(text)); n >= minSeamOverlap; n-- {
+		if (n == len(text) || utf8.RuneStart(text[n])) && strings.HasSuffix(partial, text[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// continueStream completes a stream that failed after content was sent to the client. The content
+// sent so far is handed to the failed model, then to the next models of the degradation order, as
+// their own unfinished answer to continue. It returns nil when the response was completed,
+// otherwise the error to report.
+func (l *ChatCompletionLogic) continueStream(flusher http.Flusher, chatLog *model.ChatLog, cause error, idleTracker *timeout.IdleTracker) error {
+	cfg := l.svcCtx.Config.StreamContinuation
+	state := l.lastStream
+	if !cfg.Enabled || state == nil || state.toolDetected || len(state.toolCalls) > 0 ||
+		errors.Is(cause, context.Canceled) || l.ctx.Err() != nil || l.isContextLengthError(cause) {
+		return cause
+	}
+
+	// Content held back for tool detection is part of the answer
+	var held strings.Builder
+	for _, content := range state.window {
+		if content != "[DONE]" {
+			held.WriteString(content)
+		}
+	}
+	state.window = nil
+	if held.Len() > 0 {
+		if err := l.sendStreamContent(flusher, state.response, held.String()); err != nil {
+			return err
+		}
+	}
+
+	models := l.continuationModels()
+	for attempt := 0; attempt < cfg.MaxAttempts; attempt++ {
+		if idleTracker != nil && idleTracker.Remaining() <= 0 {
+			logger.WarnC(l.ctx, "stream continuation: idle budget spent, giving up")
+			break
+		}
+		modelName := models[min(attempt, len(models)-1)]
+		if !l.circuitAllows(modelName) {
+			continue
+		}
+
+		_, partial := splitReasoning(state.fullContent.String())
+		continuation := model.StreamContinuation{
+			Model:        modelName,
+			SpliceOffset: utf8.RuneCountInString(partial),
+			Cause:        cause.Error(),
+		}
+		start := time.Now()
+		err := l.streamContinuation(modelName, partial, flusher, state, &continuation, idleTracker)
+		continuation.Latency = time.Since(start).Milliseconds()
+		l.svcCtx.CircuitBreaker.RecordResult(modelName, err)
+		if err != nil {
+			continuation.Error = err.Error()
+		}
+		chatLog.StreamContinuations = append(chatLog.StreamContinuations, continuation)
+
+		logger.InfoC(l.ctx, "stream continuation: attempt finished",
+			zap.String("model", modelName),
+			zap.Int("spliceOffset", continuation.SpliceOffset),
+			zap.Int("overlapChars", continuation.OverlapChars),
+			zap.String("cause", continuation.Cause),
+			zap.Error(err),
+		)
+		if err == nil {
+			l.request.Model = modelName
+			if l.writer != nil && len(l.orderedModels) > 0 {
+				l.writer.Header().Set(types.HeaderSelectLLm, modelName)
+			}
+			return l.finishContinuedStream(flusher, chatLog, state)
+		}
+
+		cause = err
+		if errors.Is(err, context.Canceled) || l.ctx.Err() != nil {
+			break
+		}
+	}
+	return cause
+}
+
+// continuationModels returns the failed model followed by the next models of the degradation order
+func (l *ChatCompletionLogic) continuationModels() []string {
+	models := []string{l.request.Model}
+	for i, modelName := range l.orderedModels {
+		if modelName != l.request.Model {
+			continue
+		}
+		for _, next := range l.orderedModels[i+1:] {
+			if next != l.request.Model {
+				models = append(models, next)
+			}
+		}
+		break
+	}
+	return models
+}
+
+// continuationMessages returns the request messages followed by the partial answer and the request to continue it
+func continuationMessages(messages []types.Message, partial string) []types.Message {
+	if partial == "" {
+		return messages
+	}
+	continued := make([]types.Message, 0, len(messages)+2)
+	continued = append(continued, messages...)
+	return append(continued,
+		types.Message{Role: types.RoleAssistant, Content: partial},
+		types.Message{Role: types.RoleUser, Content: continuationPrompt},
+	)
+}
+
+// streamContinuation streams the continuation of the partial answer of the model to the client
+func (l *ChatCompletionLogic) streamContinuation(
+	modelName string,
+	partial string,
+	flusher http.Flusher,
+	state *streamState,
+	continuation *model.StreamContinuation,
+	idleTracker *timeout.IdleTracker,
+) error {
+	llmClient, err := client.NewLLMClient(l.svcCtx.Config.LLM, l.svcCtx.Config.LLMTimeout, modelName, l.headers)
+	if err != nil {
+		return err
+	}
+
+	params := l.request.LLMRequestParams
+	params.Messages = continuationMessages(l.request.Messages, partial)
+	if tools := l.toolsFor(modelName); len(tools) > 0 {
+		// Tool calls of the continuation would not be executed
+		llmClient.SetTools(tools)
+		extra := make(map[string]any, len(params.Extra)+1)
+		for k, v := range params.Extra {
+			extra[k] = v
+		}
+		extra["tool_choice"] = "none"
+		params.Extra = extra
+	}
+
+	// Reasoning of the continuation is only sent in a reasoning field
+	mode := config.ReasoningModeConvert
+	if l.reasoningMode() == config.ReasoningModeStrip {
+		mode = config.ReasoningModeStrip
+	}
+	normalizer := newReasoningNormalizer(mode)
+	seam := newSeamGuard(partial)
+	send := func(content string) error {
+		if content == "" {
+			return nil
+		}
+		state.fullContent.WriteString(content)
+		return l.sendStreamContent(flusher, state.response, content)
+	}
+
+	_, _, idleTimeout, _ := l.getRetryConfig()
+	timerCtx, cancel, idleTimer := timeout.NewIdleTimer(l.ctx, idleTimeout, idleTracker)
+	defer func() {
+		idleTimer.Stop()
+		cancel()
+	}()
+
+	handleLine := func(rawLine string) error {
+		line, reasoning := normalizer.normalizeLine(rawLine)
+		content, usage, _ := l.responseHandler.extractStreamingData(line)
+		if usage != nil {
+			l.usage = usage
+		}
+		if reasoning != "" {
+			idleTimer.SetFirstTokenReceived()
+			if err := l.sendStreamDelta(flusher, state.response, types.Delta{ReasoningContent: reasoning}); err != nil {
+				return err
+			}
+		}
+		if content == "" || content == "[DONE]" {
+			return nil
+		}
+		idleTimer.SetFirstTokenReceived()
+		return send(seam.write(content))
+	}
+	err = llmClient.ChatLLMWithMessagesStreamRaw(timerCtx, params, idleTimer, func(llmResp client.LLMResponse) error {
+		if isDoneLine(llmResp.ResonseLine) {
+			if flushed := normalizer.flushLine(); flushed != "" {
+				return handleLine(flushed)
+			}
+			return nil
+		}
+		return handleLine(llmResp.ResonseLine)
+	})
+
+	// Text received before a failure reaches the client too, the next attempt continues after it
+	if sendErr := send(seam.flush()); err == nil {
+		err = sendErr
+	}
+	state.reasoning.reasoning.WriteString(normalizer.reasoning.String())
+	continuation.OverlapChars = seam.dropped
+	return err
+}
+
+// finishContinuedStream ends the continued stream and updates statistics
+func (l *ChatCompletionLogic) finishContinuedStream(flusher http.Flusher, chatLog *model.ChatLog, state *streamState) error {
+	if state.response != nil && l.usage != nil {
+		state.response.Usage = *l.usage
+	}
+	if err := l.sendStreamContent(flusher, state.response, ""); err != nil {
+		return err
+	}
+	if err := l.sendRawLine(flusher, "[DONE]"); err != nil {
+		return err
+	}
+
+	l.updateStreamStats(chatLog, state)
+	logger.InfoC(l.ctx, "stream continuation: response completed",
+		zap.Int("continuations", len(chatLog.StreamContinuations)),
+		zap.Int("contentLength", len(chatLog.ResponseContent.Content)),
+	)
+	return nil
+}
@@ -0,0 +1,126 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zgsm-ai/chat-rag/internal/bootstrap"
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/model"
+	"github.com/zgsm-ai/chat-rag/internal/service"
+	"github.com/zgsm-ai/chat-rag/internal/timeout"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+func TestSeamGuard(t *testing.T) {
+	// The continuation repeats the end of the partial output
+	g := newSeamGuard("The quick brown fox jumps over the lazy")
+	assert.Empty(t, g.write("over the lazy"))
+	assert.Equal(t, " dog.", g.write(" dog.")+g.flush())
+	assert.Equal(t, 13, g.dropped)
+
+	// Short matches are legitimate text
+	g = newSeamGuard("Hello wor")
+	assert.Equal(t, "world", g.write("world")+g.flush())
+	assert.Zero(t, g.dropped)
+
+	// Text past the seam window is sent as it arrives
+	g = newSeamGuard("partial")
+	long := strings.Repeat("x", seamWindow)
+	assert.Equal(t, long, g.write(long))
+	assert.Equal(t, "y", g.write("y"))
+	assert.Empty(t, g.flush())
+}
+
+func TestContinuationModels(t *testing.T) {
+	l := &ChatCompletionLogic{
+		request:       &types.ChatCompletionRequest{Model: "b"},
+		orderedModels: []string{"a", "b", "c", "b", "d"},
+	}
+	assert.Equal(t, []string{"b", "c", "d"}, l.continuationModels())
+
+	l.orderedModels = nil
+	assert.Equal(t, []string{"b"}, l.continuationModels())
+}
+
+func TestContinueStream(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first continuation attempt fails as well
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		assert.Contains(t, string(body), continuationPrompt)
+		assert.Contains(t, string(body), "The quick brown fox jumps over the lazy")
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, content := range []string{"over the lazy", " dog."} {
+			fmt.Fprintf(w, "data: {\"id\":\"2\",\"choices\":[{\"index\":0,\"delta\":{\"content\":%q}}]}\n\n", content)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	recorder := httptest.NewRecorder()
+	svcCtx := &bootstrap.ServiceContext{
+		Config: config.Config{
+			LLM:                config.LLMConfig{Endpoint: server.URL},
+			LLMTimeout:         config.LLMTimeoutConfig{IdleTimeoutMs: 5000},
+			StreamContinuation: config.StreamContinuationConfig{Enabled: true, MaxAttempts: 2},
+		},
+		CircuitBreaker: service.NewCircuitBreaker(config.CircuitBreakerConfig{}),
+	}
+	l := &ChatCompletionLogic{
+		ctx:             context.Background(),
+		svcCtx:          svcCtx,
+		request:         &types.ChatCompletionRequest{Model: "gpt-4o"},
+		writer:          recorder,
+		headers:         &http.Header{},
+		responseHandler: NewResponseHandler(context.Background(), svcCtx),
+		streamCommitted: true,
+	}
+	l.request.Messages = []types.Message{{Role: types.RoleUser, Content: "Write the pangram"}}
+
+	// "lazy" was still held back for tool detection when the stream failed
+	state := newStreamState(config.ReasoningModePassthrough)
+	state.response = &types.ChatCompletionResponse{Id: "1"}
+	state.fullContent.WriteString("The quick brown fox jumps over the lazy")
+	state.window = []string{"lazy"}
+	l.lastStream = state
+
+	chatLog := &model.ChatLog{}
+	err := l.continueStream(recorder, chatLog, errors.New("connection reset"), timeout.NewIdleTracker(time.Minute))
+	require.NoError(t, err)
+
+	output := recorder.Body.String()
+	assert.Contains(t, output, `"content":"lazy"`)
+	assert.Contains(t, output, `"content":" dog."`)
+	assert.NotContains(t, output, `over the lazy`)
+	assert.True(t, strings.HasSuffix(output, "data: [DONE]\n\n"))
+
+	require.Len(t, chatLog.StreamContinuations, 2)
+	assert.NotEmpty(t, chatLog.StreamContinuations[0].Error)
+	assert.Equal(t, "connection reset", chatLog.StreamContinuations[0].Cause)
+	splice := chatLog.StreamContinuations[1]
+	assert.Empty(t, splice.Error)
+	assert.Equal(t, len("The quick brown fox jumps over the lazy"), splice.SpliceOffset)
+	assert.Equal(t, 13, splice.OverlapChars)
+	assert.Equal(t, "The quick brown fox jumps over the lazy dog.", chatLog.ResponseContent.Content)
+
+	// Disabled continuation reports the failure
+	svcCtx.Config.StreamContinuation.Enabled = false
+	cause := errors.New("connection reset")
+	assert.Equal(t, cause, l.continueStream(recorder, &model.ChatLog{}, cause, nil))
+}
@@ -38,6 +38,53 @@ type ToolCall struct {
 	ResultStatus string `json:"result_status"`
 	Latency      int64  `json:"latency"`
 	Error        string `json:"error"`
+	CacheHit     bool   `json:"cache_hit"`
+}
+
+// Hedged attempt status values
+const (
+	HedgeStatusCancelled = "cancelled"
+	HedgeStatusFailed    = "failed"
+)
+
+// HedgedAttempt records an attempt of a hedged request that did not win the race
+type HedgedAttempt struct {
+	Model string `json:"model"`
+	// Hedge is true for the attempt started after the hedge delay, false for the original attempt
+	Hedge  bool   `json:"hedge"`
+	Status string `json:"status"`
+	// Start of the attempt relative to the start of the race
+	StartOffsetMs int64  `json:"start_offset_ms"`
+	Latency       int64  `json:"latency"`
+	Error         string `json:"error,omitempty"`
+}
+
+// SemanticCacheLookup records the semantic cache lookup of a request
+type SemanticCacheLookup struct {
+	Hit bool `json:"hit"`
+	// Cosine similarity of the nearest cached question
+	Similarity float64 `json:"similarity"`
+}
+
+// ContextRecoveryStep records a prompt shrink step applied after a context-length error
+type ContextRecoveryStep struct {
+	Step         string `json:"step"`
+	TokensBefore int    `json:"tokens_before"`
+	TokensAfter  int    `json:"tokens_after"`
+	Latency      int64  `json:"latency"`
+	Error        string `json:"error,omitempty"`
+}
+
+// StreamContinuation records a continuation of a stream that failed after the first token was sent
+type StreamContinuation struct {
+	Model string `json:"model"`
+	// Content characters sent to the client before the splice
+	SpliceOffset int `json:"splice_offset"`
+	// Characters dropped from the start of the continuation because they repeated the partial output
+	OverlapChars int    `json:"overlap_chars"`
+	Cause        string `json:"cause"`
+	Latency      int64  `json:"latency"`
+	Error        string `json:"error,omitempty"`
 }
 
 // RequestParams represents the request parameters for a chat completion
@@ -64,6 +111,21 @@ type ChatLog struct {
 	// Tools
 	ToolCalls []ToolCall `json:"tool_calls"`
 
+	// Response served from the response cache
+	CacheHit bool `json:"cache_hit,omitempty"`
+
+	// Semantic cache lookup, nil when the request was not looked up
+	SemanticCache *SemanticCacheLookup `json:"semantic_cache,omitempty"`
+
+	// Prompt shrink steps applied to recover from context-length errors
+	ContextRecovery []ContextRecoveryStep `json:"context_recovery,omitempty"`
+
+	// Attempts of hedged requests that lost the race
+	HedgedAttempts []HedgedAttempt `json:"hedged_attempts,omitempty"`
+
+	// Continuations of a stream that failed mid-generation, each one a splice point of the response
+	StreamContinuations []StreamContinuation `json:"stream_continuations,omitempty"`
+
 	Params RequestParams `json:"params"`
 
 	// OriginalPrompt  []types.Message `json:"original_prompt"`
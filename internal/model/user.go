@@ -252,3 +252,18 @@ func GetIdentityFromContext(ctx context.Context) (*Identity, bool) {
 	identity, ok := ctx.Value(IdentityContextKey).(*Identity)
 	return identity, ok
 }
+
+// Owner returns the key of the user the identity belongs to, the user id from the token when known,
+// otherwise the client id. Empty when the identity carries neither.
+func (i *Identity) Owner() string {
+	if i == nil {
+		return ""
+	}
+	if i.UserInfo != nil && i.UserInfo.UUID != "" {
+		return "user:" + i.UserInfo.UUID
+	}
+	if i.ClientID != "" {
+		return "client:" + i.ClientID
+	}
+	return ""
+}
@@ -0,0 +1,159 @@
+package processor
+
+import (
+	"fmt"
+
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/logger"
+	"github.com/zgsm-ai/chat-rag/internal/model"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+	"go.uber.org/zap"
+)
+
+// CacheBreakpointMarker places provider prompt cache breakpoints (cache_control: ephemeral)
+// at the end of the last stable history turns, so that long sessions reuse the cached prefix
+// of their history and not only of the system message.
+type CacheBreakpointMarker struct {
+	BaseProcessor
+
+	config config.PromptCacheConfig
+	// Breakpoints placed by the last execution
+	Placed int
+}
+
+func NewCacheBreakpointMarker(cfg config.PromptCacheConfig) *CacheBreakpointMarker {
+	return &CacheBreakpointMarker{
+		config: cfg,
+	}
+}
+
+func (c *CacheBreakpointMarker) Execute(promptMsg *PromptMsg) {
+	const method = "CacheBreakpointMarker.Execute"
+
+	if promptMsg == nil {
+		c.Err = fmt.Errorf("received prompt message is empty")
+		logger.Error(c.Err.Error(), zap.String("method", method))
+		return
+	}
+
+	if !c.config.Enabled || c.config.Strategy != config.PromptCacheStrategyLastTurns {
+		c.passToNext(promptMsg)
+		return
+	}
+
+	// Existing breakpoints, e.g. on the system message, count towards the provider limit
+	existing := 0
+	for _, msg := range promptMsg.AssemblePrompt() {
+		if hasCacheControl(&msg) {
+			existing++
+		}
+	}
+	budget := min(c.config.Turns, c.config.MaxBreakpoints-existing)
+	c.Placed = 0
+
+	// A turn ends with the message before the next user message. The newest turns are marked
+	// first, the current user message is not stable and is never marked.
+	history := promptMsg.olderUserMsgList
+	for end := len(history) - 1; end >= 0 && c.Placed < budget; end-- {
+		if end < len(history)-1 && history[end+1].Role != types.RoleUser {
+			continue
+		}
+		if hasCacheControl(&history[end]) {
+			continue
+		}
+		// Messages without text, e.g. tool call only replies, cannot carry a breakpoint
+		for i := end; i >= 0; i-- {
+			if setCacheControl(&history[i]) {
+				c.Placed++
+				break
+			}
+			if history[i].Role == types.RoleUser {
+				break
+			}
+		}
+	}
+
+	logger.Info("placed prompt cache breakpoints",
+		zap.String("method", method),
+		zap.Int("existing", existing),
+		zap.Int("placed", c.Placed),
+	)
+	c.Handled = true
+	c.passToNext(promptMsg)
+}
+
+// ephemeralCacheControl returns the cache_control value of a breakpoint
+func ephemeralCacheControl() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "ephemeral",
+	}
+}
+
+// hasCacheControl reports whether any content part of the message carries cache_control
+func hasCacheControl(msg *types.Message) bool {
+	switch v := msg.Content.(type) {
+	case []model.Content:
+		for _, part := range v {
+			if part.CacheControl != nil {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if part, ok := item.(map[string]interface{}); ok && part["cache_control"] != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// setCacheControl marks the last content part of the message, false when the message has no content.
+// Content parts are copied so that the original request messages are left untouched.
+func setCacheControl(msg *types.Message) bool {
+	switch v := msg.Content.(type) {
+	case string:
+		if v == "" {
+			return false
+		}
+		msg.Content = []model.Content{
+			{
+				Type:         model.ContTypeText,
+				Text:         v,
+				CacheControl: ephemeralCacheControl(),
+			},
+		}
+		return true
+
+	case []model.Content:
+		if len(v) == 0 {
+			return false
+		}
+		parts := make([]model.Content, len(v))
+		copy(parts, v)
+		parts[len(parts)-1].CacheControl = ephemeralCacheControl()
+		msg.Content = parts
+		return true
+
+	case []interface{}:
+		if len(v) == 0 {
+			return false
+		}
+		last, ok := v[len(v)-1].(map[string]interface{})
+		if !ok {
+			return false
+		}
+		marked := make(map[string]interface{}, len(last)+1)
+		for k, val := range last {
+			marked[k] = val
+		}
+		marked["cache_control"] = ephemeralCacheControl()
+
+		parts := make([]interface{}, len(v))
+		copy(parts, v)
+		parts[len(parts)-1] = marked
+		msg.Content = parts
+		return true
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/model"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+func TestCacheBreakpointMarker_LastTurns(t *testing.T) {
+	original := []interface{}{map[string]interface{}{"type": "text", "text": "second task"}}
+	messages := []types.Message{
+		{Role: types.RoleSystem, Content: "You are Roo"},
+		{Role: types.RoleUser, Content: "first task"},
+		{Role: types.RoleAssistant, Content: "first answer"},
+		{Role: types.RoleUser, Content: original},
+		{Role: types.RoleAssistant, Content: "calling a tool"},
+		{Role: types.RoleAssistant, Content: ""},
+		{Role: types.RoleUser, Content: "current task"},
+	}
+	promptMsg, err := NewPromptMsg(messages)
+	require.NoError(t, err)
+	promptMsg.UpdateSystemMsg("You are Roo")
+
+	marker := NewCacheBreakpointMarker(config.PromptCacheConfig{
+		Enabled:        true,
+		Strategy:       config.PromptCacheStrategyLastTurns,
+		Turns:          4,
+		MaxBreakpoints: 3,
+	})
+	marker.SetNext(NewEndpoint())
+	marker.Execute(promptMsg)
+
+	// The system breakpoint leaves room for two history breakpoints
+	assert.Equal(t, 2, marker.Placed)
+	prompt := promptMsg.AssemblePrompt()
+	require.Len(t, prompt, 7)
+
+	marked := []bool{}
+	for _, msg := range prompt {
+		marked = append(marked, hasCacheControl(&msg))
+	}
+	// The empty reply cannot carry a breakpoint, the message before it in the same turn does
+	assert.Equal(t, []bool{true, false, true, false, true, false, false}, marked)
+	assert.Equal(t, "first answer", prompt[2].Content.([]model.Content)[0].Text)
+
+	// Request messages are left untouched
+	assert.Nil(t, original[0].(map[string]interface{})["cache_control"])
+}
+
+func TestCacheBreakpointMarker_Disabled(t *testing.T) {
+	promptMsg, err := NewPromptMsg([]types.Message{
+		{Role: types.RoleSystem, Content: "You are Roo"},
+		{Role: types.RoleUser, Content: "first task"},
+		{Role: types.RoleAssistant, Content: "first answer"},
+		{Role: types.RoleUser, Content: "current task"},
+	})
+	require.NoError(t, err)
+
+	marker := NewCacheBreakpointMarker(config.PromptCacheConfig{Enabled: true, Strategy: config.PromptCacheStrategySystemOnly, Turns: 2, MaxBreakpoints: 4})
+	marker.SetNext(NewEndpoint())
+	marker.Execute(promptMsg)
+	assert.Zero(t, marker.Placed)
+	assert.Equal(t, "first answer", promptMsg.AssemblePrompt()[2].Content)
+}
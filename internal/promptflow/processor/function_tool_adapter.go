@@ -0,0 +1,125 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/functions"
+	"github.com/zgsm-ai/chat-rag/internal/logger"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+	"go.uber.org/zap"
+)
+
+// FunctionToolAdapter offers the ready tools to function calling models as native tools definitions,
+// in place of the XML descriptions XmlToolAdapter inserts into the system prompt
+type FunctionToolAdapter struct {
+	BaseProcessor
+
+	ctx          context.Context
+	toolExecutor functions.ToolExecutor
+	toolConfig   *config.ToolConfig
+	agentName    string
+	promptMode   string
+}
+
+func NewFunctionToolAdapter(ctx context.Context, toolExecutor functions.ToolExecutor, toolConfig *config.ToolConfig, agentName string, promptMode string) *FunctionToolAdapter {
+	return &FunctionToolAdapter{
+		ctx:          ctx,
+		toolExecutor: toolExecutor,
+		toolConfig:   toolConfig,
+		agentName:    agentName,
+		promptMode:   promptMode,
+	}
+}
+
+func (f *FunctionToolAdapter) Execute(promptMsg *PromptMsg) {
+	const method = "FunctionToolAdapter.Execute"
+
+	if promptMsg == nil {
+		f.Err = fmt.Errorf("received prompt message is empty")
+		logger.Error(f.Err.Error(), zap.String("method", method))
+		return
+	}
+
+	if f.toolExecutor == nil {
+		logger.ErrorC(f.ctx, "ToolExecutor is nil, skipping tool adaptation", zap.String("method", method))
+		f.passToNext(promptMsg)
+		return
+	}
+
+	// Check if all tools are disabled globally
+	if f.toolConfig != nil && f.toolConfig.DisableTools {
+		logger.InfoC(f.ctx, "All tools are disabled globally", zap.String("method", method))
+		f.passToNext(promptMsg)
+		return
+	}
+
+	// Check if this agent is disabled from using tools
+	if isAgentToolsDisabled(f.toolConfig, f.agentName, f.promptMode) {
+		logger.InfoC(f.ctx, "Agent is disabled from using tools",
+			zap.String("agent", f.agentName), zap.String("mode", f.promptMode), zap.String("method", method))
+		f.passToNext(promptMsg)
+		return
+	}
+
+	promptMsg.tools = append(promptMsg.tools, f.readyToolDefinitions()...)
+
+	f.Handled = true
+	f.passToNext(promptMsg)
+}
+
+// readyToolDefinitions returns the definitions of the ready tools, in configuration order
+func (f *FunctionToolAdapter) readyToolDefinitions() []types.Function {
+	const method = "FunctionToolAdapter.readyToolDefinitions"
+
+	toolNames := f.toolExecutor.GetAllTools()
+	if len(toolNames) == 0 {
+		logger.InfoC(f.ctx, "No tools available", zap.String("method", method))
+		return nil
+	}
+
+	type toolResult struct {
+		ready      bool
+		readyErr   error
+		definition types.Function
+		defErr     error
+	}
+
+	// Parallel processing of tool checks
+	results := make([]toolResult, len(toolNames))
+	var wg sync.WaitGroup
+	for i, toolName := range toolNames {
+		wg.Add(1)
+		go func(index int, name string) {
+			defer wg.Done()
+
+			result := toolResult{}
+			result.ready, result.readyErr = f.toolExecutor.CheckToolReady(f.ctx, name)
+			if result.ready {
+				result.definition, result.defErr = f.toolExecutor.GetToolDefinition(name)
+			}
+			results[index] = result
+		}(i, toolName)
+	}
+	wg.Wait()
+
+	var definitions []types.Function
+	for i, result := range results {
+		if !result.ready {
+			logger.WarnC(f.ctx, "Tool is not ready, skip adapt", zap.String("tool", toolNames[i]),
+				zap.String("method", method), zap.Error(result.readyErr))
+			continue
+		}
+		if result.defErr != nil {
+			logger.WarnC(f.ctx, "Failed to get tool definition", zap.String("tool", toolNames[i]),
+				zap.String("method", method), zap.Error(result.defErr))
+			continue
+		}
+
+		definitions = append(definitions, result.definition)
+		logger.InfoC(f.ctx, "Tool adapted as function tool", zap.String("name", toolNames[i]))
+	}
+	return definitions
+}
@@ -0,0 +1,82 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/functions"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+// fakeToolExecutor serves tool definitions, tools missing from ready are not ready
+type fakeToolExecutor struct {
+	functions.ToolExecutor
+	tools []string
+	ready map[string]bool
+}
+
+func (f *fakeToolExecutor) GetAllTools() []string {
+	return f.tools
+}
+
+func (f *fakeToolExecutor) CheckToolReady(ctx context.Context, toolName string) (bool, error) {
+	if !f.ready[toolName] {
+		return false, fmt.Errorf("%s is indexing", toolName)
+	}
+	return true, nil
+}
+
+func (f *fakeToolExecutor) GetToolDefinition(toolName string) (types.Function, error) {
+	return types.Function{Type: "function", Function: types.FunctionDefinition{Name: toolName}}, nil
+}
+
+func TestFunctionToolAdapter_ReadyTools(t *testing.T) {
+	executor := &fakeToolExecutor{
+		tools: []string{"codebase_search", "knowledge_base_search", "code_definition_search"},
+		ready: map[string]bool{"codebase_search": true, "code_definition_search": true},
+	}
+	promptMsg, err := NewPromptMsg([]types.Message{
+		{Role: types.RoleSystem, Content: "You are Roo\n\n# Tools\n"},
+		{Role: types.RoleUser, Content: "find the handler"},
+	})
+	require.NoError(t, err)
+
+	adapter := NewFunctionToolAdapter(context.Background(), executor, &config.ToolConfig{}, "code", "vibe")
+	adapter.SetNext(NewEndpoint())
+	adapter.Execute(promptMsg)
+
+	require.Len(t, promptMsg.GetTools(), 2)
+	assert.Equal(t, "codebase_search", promptMsg.GetTools()[0].Function.Name)
+	assert.Equal(t, "code_definition_search", promptMsg.GetTools()[1].Function.Name)
+	// The system prompt is left untouched
+	assert.Equal(t, "You are Roo\n\n# Tools\n", promptMsg.GetSystemMsg().Content)
+}
+
+func TestFunctionToolAdapter_Disabled(t *testing.T) {
+	executor := &fakeToolExecutor{
+		tools: []string{"codebase_search"},
+		ready: map[string]bool{"codebase_search": true},
+	}
+	configs := []*config.ToolConfig{
+		{DisableTools: true},
+		{DisabledAgents: map[string][]string{"vibe": {"code"}}},
+	}
+	for _, toolConfig := range configs {
+		promptMsg, err := NewPromptMsg([]types.Message{
+			{Role: types.RoleSystem, Content: "You are Roo"},
+			{Role: types.RoleUser, Content: "find the handler"},
+		})
+		require.NoError(t, err)
+
+		adapter := NewFunctionToolAdapter(context.Background(), executor, toolConfig, "code", "vibe")
+		adapter.SetNext(NewEndpoint())
+		adapter.Execute(promptMsg)
+
+		assert.Empty(t, promptMsg.GetTools())
+		assert.False(t, adapter.Handled)
+	}
+}
@@ -243,12 +243,18 @@ func insertContentAfterMarker(content, marker, newContent string) (string, error
 
 // isAgentDisabled checks if the agent is disabled from using tools in the current mode
 func (x *XmlToolAdapter) isAgentDisabled(agentName, mode string) bool {
-	if x.toolConfig == nil || x.toolConfig.DisabledAgents == nil {
+	return isAgentToolsDisabled(x.toolConfig, agentName, mode)
+}
+
+// isAgentToolsDisabled checks if the agent is disabled from using tools in the mode,
+// shared by the XML and function calling tool adapters
+func isAgentToolsDisabled(toolConfig *config.ToolConfig, agentName, mode string) bool {
+	if toolConfig == nil || toolConfig.DisabledAgents == nil {
 		return false
 	}
 
 	// Check if the mode exists in the disabled agents configuration
-	disabledAgents, exists := x.toolConfig.DisabledAgents[mode]
+	disabledAgents, exists := toolConfig.DisabledAgents[mode]
 	if !exists {
 		return false
 	}
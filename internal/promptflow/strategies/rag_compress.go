@@ -42,7 +42,8 @@ type RagCompressProcessor struct {
 
 	userMsgFilter        *processor.UserMsgFilter
 	taskContentProcessor *processor.TaskContentProcessor
-	xmlToolAdapter       *processor.XmlToolAdapter
+	toolAdapter          processor.Processor // XML, or function tool adapter for function calling models
+	cacheBreakpoints     *processor.CacheBreakpointMarker
 	start                *processor.Start
 	end                  *processor.End
 
@@ -154,13 +155,24 @@ func (p *RagCompressProcessor) buildProcessorChain() error {
 		p.agentName,
 		p.promptMode,
 	)
-	p.xmlToolAdapter = processor.NewXmlToolAdapter(
-		p.ctx,
-		p.toolsExecutor,
-		p.config.Tools,
-		p.agentName,
-		p.promptMode,
-	)
+	if p.config.IsFuncCallingModel(p.modelName) {
+		p.toolAdapter = processor.NewFunctionToolAdapter(
+			p.ctx,
+			p.toolsExecutor,
+			p.config.Tools,
+			p.agentName,
+			p.promptMode,
+		)
+	} else {
+		p.toolAdapter = processor.NewXmlToolAdapter(
+			p.ctx,
+			p.toolsExecutor,
+			p.config.Tools,
+			p.agentName,
+			p.promptMode,
+		)
+	}
+	p.cacheBreakpoints = processor.NewCacheBreakpointMarker(p.config.PromptCache)
 	// p.userCompressor = processor.NewUserCompressor(
 	// 	p.ctx,
 	// 	p.config,
@@ -171,9 +183,10 @@ func (p *RagCompressProcessor) buildProcessorChain() error {
 	// execute chain
 	p.start.SetNext(p.userMsgFilter)
 	p.userMsgFilter.SetNext(p.taskContentProcessor)
-	p.taskContentProcessor.SetNext(p.xmlToolAdapter)
-	// p.xmlToolAdapter.SetNext(p.userCompressor)
-	p.xmlToolAdapter.SetNext(p.end)
+	p.taskContentProcessor.SetNext(p.toolAdapter)
+	// p.toolAdapter.SetNext(p.userCompressor)
+	p.toolAdapter.SetNext(p.cacheBreakpoints)
+	p.cacheBreakpoints.SetNext(p.end)
 
 	return nil
 }
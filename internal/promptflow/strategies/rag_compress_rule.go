@@ -53,8 +53,8 @@ func (r *RagWithRuleProcessor) buildProcessorChain() error {
 	r.ruleInjector = processor.NewRulesInjector(r.promptMode, r.rulesConfig, r.agentName)
 
 	// Rebuild chain with rule injector inserted at the beginning
-	r.xmlToolAdapter.SetNext(r.ruleInjector)
-	r.ruleInjector.SetNext(r.end)
+	r.toolAdapter.SetNext(r.ruleInjector)
+	r.ruleInjector.SetNext(r.cacheBreakpoints)
 	// The rest of the chain remains the same as in parent
 
 	return nil
@@ -89,13 +89,19 @@ func (s *Strategy) Run(
 		return "", "", nil, errors.New("priority group not found")
 	}
 
-	// 3. Select model using round-robin within the priority group
-	selectedModel := highestPriorityGroup.selectModelByRoundRobin()
+	// 3. Select model using round-robin within the highest priority group whose
+	// models are not all behind an open circuit
+	selectedModel, priority, groupSize := s.selectAvailableModel(svcCtx)
+	if selectedModel == "" {
+		logger.WarnC(ctx, "priority router: all candidates have open circuits, ignoring circuit state")
+		selectedModel = highestPriorityGroup.selectModelByRoundRobin(nil)
+		priority, groupSize = s.lowestPriority, len(highestPriorityGroup.models)
+	}
 
 	logger.InfoC(ctx, "priority router: model selected",
 		zap.String("selectedModel", selectedModel),
-		zap.Int("priority", s.lowestPriority),
-		zap.Int("groupSize", len(highestPriorityGroup.models)),
+		zap.Int("priority", priority),
+		zap.Int("groupSize", groupSize),
 	)
 
 	// 4. Build ordered candidates list (selectedModel first, then by priority and weight)
@@ -104,6 +110,29 @@ func (s *Strategy) Run(
 	return selectedModel, "", orderedCandidates, nil
 }
 
+// selectAvailableModel walks the priority groups in order and selects by round-robin
+// among the models whose circuit is not open
+func (s *Strategy) selectAvailableModel(svcCtx *bootstrap.ServiceContext) (string, int, int) {
+	var skip func(string) bool
+	if svcCtx != nil && svcCtx.CircuitBreaker.Enabled() {
+		skip = svcCtx.CircuitBreaker.IsOpen
+	}
+
+	priorities := make([]int, 0, len(s.priorityGroups))
+	for priority := range s.priorityGroups {
+		priorities = append(priorities, priority)
+	}
+	sort.Ints(priorities)
+
+	for _, priority := range priorities {
+		group := s.priorityGroups[priority]
+		if selected := group.selectModelByRoundRobin(skip); selected != "" {
+			return selected, priority, len(group.models)
+		}
+	}
+	return "", 0, 0
+}
+
 // initializePriorityGroups initializes priority groups from configuration
 func (s *Strategy) initializePriorityGroups() error {
 	for _, candidate := range s.cfg.Candidates {
@@ -42,27 +42,42 @@ func (pg *PriorityGroup) addModel(model *ModelCandidate) {
 //    - Add configured weight to current weight for all models
 //    - Select the model with the highest current weight
 //    - Subtract total weight from the selected model's current weight
-func (pg *PriorityGroup) selectModelByRoundRobin() string {
+//
+// Models rejected by skip (e.g. open circuit) take no part in the selection.
+// Returns an empty string when every model is skipped.
+func (pg *PriorityGroup) selectModelByRoundRobin(skip func(string) bool) string {
 	// Optimization: If only one model, return directly without locking
 	// This provides zero-lock overhead for single-model scenarios
 	if len(pg.models) == 1 {
+		if skip != nil && skip(pg.models[0].modelName) {
+			return ""
+		}
 		return pg.models[0].modelName
 	}
 
 	pg.mu.Lock()
 	defer pg.mu.Unlock()
 
-	// Calculate total weight
+	// Calculate total weight of the selectable models
 	totalWeight := 0
 	for _, model := range pg.models {
+		if skip != nil && skip(model.modelName) {
+			continue
+		}
 		totalWeight += model.weight
 	}
+	if totalWeight == 0 {
+		return ""
+	}
 
 	// Find the model with the maximum current weight after adding configured weights
 	maxWeight := -1
 	selectedIdx := -1
 
 	for i, model := range pg.models {
+		if skip != nil && skip(model.modelName) {
+			continue
+		}
 		// Add configured weight to current weight
 		pg.currentWeights[i] += model.weight
 
@@ -54,7 +54,7 @@ func (s *Strategy) Run(
 	current, history := s.extractInputs(req)
 
 	// 2) Rule engine prefilter (align with plugin: rule first, then analyzer)
-	cands := filterEnabled(s.cfg.Routing.Candidates)
+	cands := filterOpenCircuits(ctx, svcCtx, filterEnabled(s.cfg.Routing.Candidates))
 	if s.cfg.RuleEngine.Enabled && len(s.cfg.RuleEngine.InlineRules) > 0 {
 		filtered, forcedFallback := s.applyRuleEngine(ctx, svcCtx, headers, req, cands)
 		if forcedFallback {
@@ -84,6 +84,10 @@ func (s *Strategy) Run(
 
 	// Build analyzer-specific LLM client (non-streaming) with optional endpoint/token overrides
 	llmCfg := svcCtx.Config.LLM
+	if s.cfg.Analyzer.Endpoint != "" || s.cfg.Analyzer.ApiToken != "" {
+		// Explicit analyzer overrides take precedence over the model registry
+		llmCfg.Models = nil
+	}
 	if s.cfg.Analyzer.Endpoint != "" {
 		llmCfg.Endpoint = s.cfg.Analyzer.Endpoint
 	}
@@ -812,6 +816,28 @@ func filterEnabled(cands []config.RoutingCandidate) []config.RoutingCandidate {
 	return out
 }
 
+// filterOpenCircuits drops candidates whose circuit breaker is open.
+// All candidates are kept when every one of them is open, so routing still has a choice.
+func filterOpenCircuits(ctx context.Context, svcCtx *bootstrap.ServiceContext, cands []config.RoutingCandidate) []config.RoutingCandidate {
+	if svcCtx == nil || !svcCtx.CircuitBreaker.Enabled() {
+		return cands
+	}
+	var out []config.RoutingCandidate
+	for _, c := range cands {
+		if svcCtx.CircuitBreaker.IsOpen(c.ModelName) {
+			logger.InfoC(ctx, "semantic router: skipping candidate with open circuit",
+				zap.String("model", c.ModelName),
+			)
+			continue
+		}
+		out = append(out, c)
+	}
+	if len(out) == 0 {
+		return cands
+	}
+	return out
+}
+
 func indexMap(arr []string) map[string]int {
 	m := make(map[string]int, len(arr))
 	for i, v := range arr {
@@ -0,0 +1,410 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/logger"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+	"go.uber.org/zap"
+)
+
+// CircuitState is the state of a model circuit breaker
+type CircuitState string
+
+const (
+	// CircuitClosed lets every request through
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen rejects requests until the open duration elapses
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen lets a limited number of probe requests through
+	CircuitHalfOpen CircuitState = "half_open"
+
+	metricCircuitBreakerState = "chat_rag_circuit_breaker_state"
+	metricCircuitBreakerTrips = "chat_rag_circuit_breaker_trips_total"
+)
+
+// stateValue is the gauge value reported for a circuit state
+func (s CircuitState) stateValue() float64 {
+	switch s {
+	case CircuitOpen:
+		return 2
+	case CircuitHalfOpen:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// callOutcome classifies the result of an upstream call for the breaker
+type callOutcome int
+
+const (
+	outcomeIgnored callOutcome = iota
+	outcomeSuccess
+	outcomeFailure
+	outcomeTimeout
+)
+
+// CircuitBreakerStatus is a snapshot of one model circuit breaker
+type CircuitBreakerStatus struct {
+	Model               string       `json:"model"`
+	State               CircuitState `json:"state"`
+	Requests            int          `json:"requests"`
+	Failures            int          `json:"failures"`
+	ConsecutiveTimeouts int          `json:"consecutiveTimeouts"`
+	Trips               int          `json:"trips"`
+	OpenedAt            *time.Time   `json:"openedAt,omitempty"`
+	RetryAt             *time.Time   `json:"retryAt,omitempty"`
+}
+
+// modelCircuit holds the breaker state of a single model
+type modelCircuit struct {
+	state               CircuitState
+	windowStart         time.Time
+	requests            int
+	failures            int
+	consecutiveTimeouts int
+	openedAt            time.Time
+	halfOpenInFlight    int
+	trips               int
+}
+
+// CircuitBreaker tracks upstream failures per model and opens the circuit of failing models,
+// so routing and degradation skip them until they recover.
+// All methods are safe on a nil receiver, which behaves as a disabled breaker.
+type CircuitBreaker struct {
+	cfg      config.CircuitBreakerConfig
+	circuits map[string]*modelCircuit
+	mu       sync.Mutex
+	now      func() time.Time
+
+	stateDesc *prometheus.Desc
+	tripsDesc *prometheus.Desc
+}
+
+// NewCircuitBreaker creates a circuit breaker with the given configuration
+func NewCircuitBreaker(cfg config.CircuitBreakerConfig) *CircuitBreaker {
+	return &CircuitBreaker{
+		cfg:      cfg,
+		circuits: make(map[string]*modelCircuit),
+		now:      time.Now,
+		stateDesc: prometheus.NewDesc(metricCircuitBreakerState,
+			"Circuit breaker state per model (0 closed, 1 half-open, 2 open)",
+			[]string{metricsBaseLabelModel}, nil),
+		tripsDesc: prometheus.NewDesc(metricCircuitBreakerTrips,
+			"Total number of times the circuit breaker of a model opened",
+			[]string{metricsBaseLabelModel}, nil),
+	}
+}
+
+// Enabled reports whether the breaker is active
+func (cb *CircuitBreaker) Enabled() bool {
+	return cb != nil && cb.cfg.Enabled
+}
+
+// Allow reports whether a request may be sent to the model.
+// An open circuit whose open duration has elapsed moves to half-open and the call
+// reserves one of its probe slots, released by the following RecordResult.
+func (cb *CircuitBreaker) Allow(model string) bool {
+	if !cb.Enabled() {
+		return true
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	c, ok := cb.circuits[model]
+	if !ok {
+		return true
+	}
+	cb.advance(model, c)
+
+	switch c.state {
+	case CircuitOpen:
+		return false
+	case CircuitHalfOpen:
+		if c.halfOpenInFlight >= cb.cfg.HalfOpenMaxRequests {
+			return false
+		}
+		c.halfOpenInFlight++
+		return true
+	default:
+		return true
+	}
+}
+
+// Release gives back the probe slot reserved by Allow for a request that was not sent,
+// without recording any outcome
+func (cb *CircuitBreaker) Release(model string) {
+	if !cb.Enabled() {
+		return
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if c, ok := cb.circuits[model]; ok && c.state == CircuitHalfOpen && c.halfOpenInFlight > 0 {
+		c.halfOpenInFlight--
+	}
+}
+
+// IsOpen reports whether requests to the model are currently rejected, without reserving a probe
+func (cb *CircuitBreaker) IsOpen(model string) bool {
+	if !cb.Enabled() {
+		return false
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	c, ok := cb.circuits[model]
+	if !ok {
+		return false
+	}
+	cb.advance(model, c)
+	return c.state == CircuitOpen ||
+		(c.state == CircuitHalfOpen && c.halfOpenInFlight >= cb.cfg.HalfOpenMaxRequests)
+}
+
+// Available returns the models whose circuit is not open, keeping their order
+func (cb *CircuitBreaker) Available(models []string) []string {
+	if !cb.Enabled() {
+		return models
+	}
+
+	out := make([]string, 0, len(models))
+	for _, m := range models {
+		if !cb.IsOpen(m) {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// RecordResult records the outcome of a call to the model.
+// Client cancellations are ignored; timeouts, network errors, 5xx and 429 count as failures.
+func (cb *CircuitBreaker) RecordResult(model string, err error) {
+	if !cb.Enabled() || model == "" {
+		return
+	}
+	outcome := classifyOutcome(err)
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	c, ok := cb.circuits[model]
+	if !ok {
+		c = &modelCircuit{state: CircuitClosed, windowStart: cb.now()}
+		cb.circuits[model] = c
+	}
+	cb.advance(model, c)
+
+	switch c.state {
+	case CircuitHalfOpen:
+		if c.halfOpenInFlight > 0 {
+			c.halfOpenInFlight--
+		}
+		switch outcome {
+		case outcomeSuccess:
+			cb.close(model, c)
+		case outcomeFailure, outcomeTimeout:
+			cb.trip(model, c, "half-open probe failed")
+		}
+	case CircuitClosed:
+		switch outcome {
+		case outcomeSuccess:
+			c.requests++
+			c.consecutiveTimeouts = 0
+		case outcomeFailure:
+			c.requests++
+			c.failures++
+			c.consecutiveTimeouts = 0
+		case outcomeTimeout:
+			c.requests++
+			c.failures++
+			c.consecutiveTimeouts++
+		default:
+			return
+		}
+
+		if c.consecutiveTimeouts >= cb.cfg.ConsecutiveTimeouts {
+			cb.trip(model, c, "consecutive timeouts")
+			return
+		}
+		if c.requests >= cb.cfg.MinRequests &&
+			float64(c.failures)/float64(c.requests) >= cb.cfg.ErrorRateThreshold {
+			cb.trip(model, c, "error rate")
+		}
+	}
+}
+
+// Snapshot returns the state of every tracked model sorted by model name
+func (cb *CircuitBreaker) Snapshot() []CircuitBreakerStatus {
+	if cb == nil {
+		return []CircuitBreakerStatus{}
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	statuses := make([]CircuitBreakerStatus, 0, len(cb.circuits))
+	for model, c := range cb.circuits {
+		cb.advance(model, c)
+		status := CircuitBreakerStatus{
+			Model:               model,
+			State:               c.state,
+			Requests:            c.requests,
+			Failures:            c.failures,
+			ConsecutiveTimeouts: c.consecutiveTimeouts,
+			Trips:               c.trips,
+		}
+		if c.state != CircuitClosed {
+			openedAt := c.openedAt
+			retryAt := openedAt.Add(cb.openDuration())
+			status.OpenedAt = &openedAt
+			status.RetryAt = &retryAt
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Model < statuses[j].Model })
+	return statuses
+}
+
+// Reset forces the circuit of the model back to closed, reporting whether it was tracked
+func (cb *CircuitBreaker) Reset(model string) bool {
+	if cb == nil {
+		return false
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	c, ok := cb.circuits[model]
+	if !ok {
+		return false
+	}
+	cb.close(model, c)
+	logger.Info("circuit breaker: reset", zap.String("model", model))
+	return true
+}
+
+// ResetAll forces every circuit back to closed
+func (cb *CircuitBreaker) ResetAll() {
+	if cb == nil {
+		return
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	for model, c := range cb.circuits {
+		cb.close(model, c)
+	}
+	logger.Info("circuit breaker: reset all models")
+}
+
+// Describe implements prometheus.Collector
+func (cb *CircuitBreaker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cb.stateDesc
+	ch <- cb.tripsDesc
+}
+
+// Collect implements prometheus.Collector
+func (cb *CircuitBreaker) Collect(ch chan<- prometheus.Metric) {
+	for _, status := range cb.Snapshot() {
+		ch <- prometheus.MustNewConstMetric(cb.stateDesc, prometheus.GaugeValue,
+			status.State.stateValue(), status.Model)
+		ch <- prometheus.MustNewConstMetric(cb.tripsDesc, prometheus.CounterValue,
+			float64(status.Trips), status.Model)
+	}
+}
+
+// advance applies time based transitions: window rollover and open to half-open
+func (cb *CircuitBreaker) advance(model string, c *modelCircuit) {
+	now := cb.now()
+	switch c.state {
+	case CircuitOpen:
+		if now.Sub(c.openedAt) >= cb.openDuration() {
+			c.state = CircuitHalfOpen
+			c.halfOpenInFlight = 0
+			logger.Info("circuit breaker: half-open, probing model", zap.String("model", model))
+		}
+	case CircuitClosed:
+		if now.Sub(c.windowStart) >= time.Duration(cb.cfg.WindowSec)*time.Second {
+			c.windowStart = now
+			c.requests = 0
+			c.failures = 0
+		}
+	}
+}
+
+// trip opens the circuit of the model
+func (cb *CircuitBreaker) trip(model string, c *modelCircuit, reason string) {
+	logger.Warn("circuit breaker: opened",
+		zap.String("model", model),
+		zap.String("reason", reason),
+		zap.Int("requests", c.requests),
+		zap.Int("failures", c.failures),
+		zap.Int("consecutiveTimeouts", c.consecutiveTimeouts),
+	)
+	c.state = CircuitOpen
+	c.openedAt = cb.now()
+	c.halfOpenInFlight = 0
+	c.trips++
+}
+
+// close moves the circuit of the model to closed with a fresh window
+func (cb *CircuitBreaker) close(model string, c *modelCircuit) {
+	if c.state != CircuitClosed {
+		logger.Info("circuit breaker: closed", zap.String("model", model))
+	}
+	c.state = CircuitClosed
+	c.windowStart = cb.now()
+	c.requests = 0
+	c.failures = 0
+	c.consecutiveTimeouts = 0
+	c.halfOpenInFlight = 0
+}
+
+func (cb *CircuitBreaker) openDuration() time.Duration {
+	return time.Duration(cb.cfg.OpenDurationSec) * time.Second
+}
+
+// classifyOutcome maps an upstream call result to a breaker outcome
+func classifyOutcome(err error) callOutcome {
+	if err == nil {
+		return outcomeSuccess
+	}
+	if errors.Is(err, context.Canceled) {
+		return outcomeIgnored
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return outcomeTimeout
+	}
+
+	var idleErr *types.IdleTimeoutError
+	if errors.As(err, &idleErr) {
+		return outcomeTimeout
+	}
+
+	var apiErr *types.APIError
+	if errors.As(err, &apiErr) {
+		if apiErr.StatusCode == 0 ||
+			apiErr.StatusCode >= http.StatusInternalServerError ||
+			apiErr.StatusCode == http.StatusTooManyRequests {
+			return outcomeFailure
+		}
+		// Other client errors say nothing about the health of the upstream
+		return outcomeSuccess
+	}
+
+	// Transport level errors such as connection refused
+	return outcomeFailure
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+func newTestCircuitBreaker() (*CircuitBreaker, *time.Time) {
+	now := time.Unix(1700000000, 0)
+	cb := NewCircuitBreaker(config.CircuitBreakerConfig{
+		Enabled:             true,
+		WindowSec:           60,
+		MinRequests:         4,
+		ErrorRateThreshold:  0.5,
+		ConsecutiveTimeouts: 2,
+		OpenDurationSec:     30,
+		HalfOpenMaxRequests: 1,
+	})
+	cb.now = func() time.Time { return now }
+	return cb, &now
+}
+
+func TestCircuitBreaker_TripsOnErrorRate(t *testing.T) {
+	cb, _ := newTestCircuitBreaker()
+	serverErr := types.NewHTTPStatusError(http.StatusBadGateway, "bad gateway")
+
+	cb.RecordResult("m1", nil)
+	cb.RecordResult("m1", serverErr)
+	cb.RecordResult("m1", nil)
+	assert.True(t, cb.Allow("m1"), "below min requests")
+
+	cb.RecordResult("m1", serverErr)
+	assert.False(t, cb.Allow("m1"))
+	assert.True(t, cb.IsOpen("m1"))
+	assert.Equal(t, []string{"m2"}, cb.Available([]string{"m1", "m2"}))
+}
+
+func TestCircuitBreaker_TripsOnConsecutiveTimeouts(t *testing.T) {
+	cb, _ := newTestCircuitBreaker()
+
+	cb.RecordResult("m1", types.NewStreamIdleTimeoutError())
+	assert.False(t, cb.IsOpen("m1"))
+	cb.RecordResult("m1", types.NewStreamIdleTimeoutError())
+	assert.True(t, cb.IsOpen("m1"))
+}
+
+func TestCircuitBreaker_IgnoresClientErrors(t *testing.T) {
+	cb, _ := newTestCircuitBreaker()
+
+	for i := 0; i < 10; i++ {
+		cb.RecordResult("m1", types.NewContextTooLongError())
+		cb.RecordResult("m1", context.Canceled)
+	}
+	assert.False(t, cb.IsOpen("m1"))
+
+	status := cb.Snapshot()[0]
+	assert.Equal(t, 10, status.Requests)
+	assert.Equal(t, 0, status.Failures)
+}
+
+func TestCircuitBreaker_HalfOpen(t *testing.T) {
+	cb, now := newTestCircuitBreaker()
+	for i := 0; i < 4; i++ {
+		cb.RecordResult("m1", errors.New("connection refused"))
+	}
+	assert.False(t, cb.Allow("m1"))
+
+	// Only one probe is let through once the open duration elapses
+	*now = now.Add(31 * time.Second)
+	assert.True(t, cb.Allow("m1"))
+	assert.False(t, cb.Allow("m1"))
+	assert.Equal(t, CircuitHalfOpen, cb.Snapshot()[0].State)
+
+	// A failed probe opens the circuit again
+	cb.RecordResult("m1", errors.New("connection refused"))
+	assert.False(t, cb.Allow("m1"))
+	assert.Equal(t, 2, cb.Snapshot()[0].Trips)
+
+	// A successful probe closes it
+	*now = now.Add(31 * time.Second)
+	assert.True(t, cb.Allow("m1"))
+	cb.RecordResult("m1", nil)
+	assert.True(t, cb.Allow("m1"))
+	assert.Equal(t, CircuitClosed, cb.Snapshot()[0].State)
+}
+
+func TestCircuitBreaker_Release(t *testing.T) {
+	cb, now := newTestCircuitBreaker()
+	for i := 0; i < 4; i++ {
+		cb.RecordResult("m1", errors.New("connection refused"))
+	}
+	*now = now.Add(31 * time.Second)
+
+	// A reserved probe that was not sent gives its slot back, the circuit stays half-open
+	assert.True(t, cb.Allow("m1"))
+	cb.Release("m1")
+	assert.Equal(t, CircuitHalfOpen, cb.Snapshot()[0].State)
+	assert.True(t, cb.Allow("m1"))
+	assert.False(t, cb.Allow("m1"))
+
+	// Releasing without a reservation does nothing
+	cb.Release("m1")
+	cb.Release("m1")
+	cb.Release("m2")
+	assert.True(t, cb.Allow("m1"))
+	assert.False(t, cb.Allow("m1"))
+}
+
+func TestCircuitBreaker_Reset(t *testing.T) {
+	cb, _ := newTestCircuitBreaker()
+	cb.RecordResult("m1", types.NewStreamIdleTimeoutError())
+	cb.RecordResult("m1", types.NewStreamIdleTimeoutError())
+	cb.RecordResult("m2", types.NewStreamIdleTimeoutError())
+	cb.RecordResult("m2", types.NewStreamIdleTimeoutError())
+
+	assert.True(t, cb.Reset("m1"))
+	assert.False(t, cb.Reset("unknown"))
+	assert.False(t, cb.IsOpen("m1"))
+	assert.True(t, cb.IsOpen("m2"))
+
+	cb.ResetAll()
+	assert.False(t, cb.IsOpen("m2"))
+}
+
+func TestCircuitBreaker_Disabled(t *testing.T) {
+	var nilBreaker *CircuitBreaker
+	assert.True(t, nilBreaker.Allow("m1"))
+	nilBreaker.RecordResult("m1", errors.New("boom"))
+	assert.Empty(t, nilBreaker.Snapshot())
+
+	cb := NewCircuitBreaker(config.CircuitBreakerConfig{Enabled: false, ConsecutiveTimeouts: 1})
+	cb.RecordResult("m1", types.NewStreamIdleTimeoutError())
+	assert.True(t, cb.Allow("m1"))
+	assert.Equal(t, []string{"m1"}, cb.Available([]string{"m1"}))
+}
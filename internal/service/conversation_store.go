@@ -0,0 +1,190 @@
+package service
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/zgsm-ai/chat-rag/internal/client"
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+const (
+	// ConversationStoreRedis stores conversation state in Redis
+	ConversationStoreRedis = "redis"
+	// ConversationStoreMemory stores conversation state in process memory
+	ConversationStoreMemory = "memory"
+
+	conversationRedisKeyPrefix = "response_state:"
+	conversationRedisField     = "state"
+)
+
+// ErrConversationNotOwned is returned when a conversation is loaded by another user than the one who saved it
+var ErrConversationNotOwned = errors.New("conversation belongs to another user")
+
+// ConversationStoreInterface saves and loads the message history of Responses API turns.
+// Every conversation has an owner, the identity of the user who created it, and only the owner can load it.
+type ConversationStoreInterface interface {
+	// Save stores the full message history ending with the given response
+	Save(ctx context.Context, responseID string, owner string, messages []types.Message) error
+	// Load returns the full message history ending with the given response
+	Load(ctx context.Context, responseID string, owner string) ([]types.Message, error)
+}
+
+// conversationState is a stored conversation with its owner
+type conversationState struct {
+	Owner    string          `json:"owner"`
+	Messages []types.Message `json:"messages"`
+}
+
+// checkOwner rejects conversations without an owner, and conversations loaded by another user
+func checkOwner(owner string, caller string) error {
+	if owner == "" || caller == "" {
+		return errors.New("conversation owner is required")
+	}
+	if owner != caller {
+		return ErrConversationNotOwned
+	}
+	return nil
+}
+
+// NewConversationStore creates a conversation store according to the configured backend
+func NewConversationStore(cfg config.ResponsesConfig, redisClient client.RedisInterface) ConversationStoreInterface {
+	ttl := time.Duration(cfg.TTLSec) * time.Second
+	if cfg.Store == ConversationStoreMemory || redisClient == nil {
+		return NewMemoryConversationStore(cfg.MaxEntries, ttl)
+	}
+	return NewRedisConversationStore(redisClient, ttl)
+}
+
+// RedisConversationStore keeps conversation state in Redis
+type RedisConversationStore struct {
+	redisClient client.RedisInterface
+	ttl         time.Duration
+}
+
+// NewRedisConversationStore creates a Redis backed conversation store
+func NewRedisConversationStore(redisClient client.RedisInterface, ttl time.Duration) *RedisConversationStore {
+	return &RedisConversationStore{
+		redisClient: redisClient,
+		ttl:         ttl,
+	}
+}
+
+// Save stores the message history and its owner in a Redis hash with expiration
+func (s *RedisConversationStore) Save(ctx context.Context, responseID string, owner string, messages []types.Message) error {
+	if owner == "" {
+		return errors.New("conversation owner is required")
+	}
+	data, err := json.Marshal(conversationState{Owner: owner, Messages: messages})
+	if err != nil {
+		return fmt.Errorf("failed to marshal conversation messages: %w", err)
+	}
+	return s.redisClient.SetHashField(ctx, conversationRedisKeyPrefix+responseID, conversationRedisField, string(data), s.ttl)
+}
+
+// Load reads the message history from Redis, when it belongs to the owner
+func (s *RedisConversationStore) Load(ctx context.Context, responseID string, owner string) ([]types.Message, error) {
+	data, err := s.redisClient.GetHashField(ctx, conversationRedisKeyPrefix+responseID, conversationRedisField)
+	if err != nil {
+		return nil, err
+	}
+
+	var state conversationState
+	if err := json.Unmarshal([]byte(data), &state); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal conversation messages: %w", err)
+	}
+	if err := checkOwner(state.Owner, owner); err != nil {
+		return nil, err
+	}
+	return state.Messages, nil
+}
+
+// memoryConversationEntry is a stored conversation with its expiration time
+type memoryConversationEntry struct {
+	responseID string
+	owner      string
+	messages   []types.Message
+	expireAt   time.Time
+}
+
+// MemoryConversationStore keeps conversation state in memory with LRU eviction
+type MemoryConversationStore struct {
+	mu         sync.Mutex
+	entries    map[string]*list.Element
+	order      *list.List
+	maxEntries int
+	ttl        time.Duration
+}
+
+// NewMemoryConversationStore creates an in-memory conversation store
+func NewMemoryConversationStore(maxEntries int, ttl time.Duration) *MemoryConversationStore {
+	return &MemoryConversationStore{
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+		maxEntries: maxEntries,
+		ttl:        ttl,
+	}
+}
+
+// Save stores the message history, evicting the least recently used entries when full
+func (s *MemoryConversationStore) Save(ctx context.Context, responseID string, owner string, messages []types.Message) error {
+	if owner == "" {
+		return errors.New("conversation owner is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := &memoryConversationEntry{
+		responseID: responseID,
+		owner:      owner,
+		messages:   messages,
+	}
+	if s.ttl > 0 {
+		entry.expireAt = time.Now().Add(s.ttl)
+	}
+
+	if elem, ok := s.entries[responseID]; ok {
+		elem.Value = entry
+		s.order.MoveToFront(elem)
+		return nil
+	}
+
+	s.entries[responseID] = s.order.PushFront(entry)
+	for s.maxEntries > 0 && s.order.Len() > s.maxEntries {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*memoryConversationEntry).responseID)
+	}
+	return nil
+}
+
+// Load returns the message history if it exists, has not expired and belongs to the owner
+func (s *MemoryConversationStore) Load(ctx context.Context, responseID string, owner string) ([]types.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.entries[responseID]
+	if !ok {
+		return nil, fmt.Errorf("conversation not found: %s", responseID)
+	}
+
+	entry := elem.Value.(*memoryConversationEntry)
+	if !entry.expireAt.IsZero() && time.Now().After(entry.expireAt) {
+		s.order.Remove(elem)
+		delete(s.entries, responseID)
+		return nil, fmt.Errorf("conversation expired: %s", responseID)
+	}
+	if err := checkOwner(entry.owner, owner); err != nil {
+		return nil, err
+	}
+
+	s.order.MoveToFront(elem)
+	return entry.messages, nil
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+func TestMemoryConversationStore_SaveLoad(t *testing.T) {
+	store := NewMemoryConversationStore(10, time.Minute)
+	ctx := context.Background()
+	messages := []types.Message{
+		{Role: types.RoleUser, Content: "hello"},
+		{Role: types.RoleAssistant, Content: "hi"},
+	}
+
+	require.NoError(t, store.Save(ctx, "resp_1", "user:1", messages))
+
+	loaded, err := store.Load(ctx, "resp_1", "user:1")
+	require.NoError(t, err)
+	assert.Equal(t, messages, loaded)
+
+	_, err = store.Load(ctx, "resp_missing", "user:1")
+	assert.Error(t, err)
+}
+
+func TestMemoryConversationStore_Owner(t *testing.T) {
+	store := NewMemoryConversationStore(10, time.Minute)
+	ctx := context.Background()
+	messages := []types.Message{{Role: types.RoleUser, Content: "hello"}}
+
+	require.NoError(t, store.Save(ctx, "resp_1", "user:1", messages))
+	assert.Error(t, store.Save(ctx, "resp_2", "", messages), "conversations without an owner are not saved")
+
+	_, err := store.Load(ctx, "resp_1", "user:2")
+	assert.ErrorIs(t, err, ErrConversationNotOwned)
+	_, err = store.Load(ctx, "resp_1", "")
+	assert.Error(t, err)
+	loaded, err := store.Load(ctx, "resp_1", "user:1")
+	require.NoError(t, err)
+	assert.Equal(t, messages, loaded)
+}
+
+func TestMemoryConversationStore_Eviction(t *testing.T) {
+	store := NewMemoryConversationStore(2, 0)
+	ctx := context.Background()
+
+	require.NoError(t, store.Save(ctx, "resp_1", "user:1", nil))
+	require.NoError(t, store.Save(ctx, "resp_2", "user:1", nil))
+	// Touch resp_1 so resp_2 becomes the least recently used entry
+	_, err := store.Load(ctx, "resp_1", "user:1")
+	require.NoError(t, err)
+	require.NoError(t, store.Save(ctx, "resp_3", "user:1", nil))
+
+	_, err = store.Load(ctx, "resp_2", "user:1")
+	assert.Error(t, err)
+	_, err = store.Load(ctx, "resp_1", "user:1")
+	assert.NoError(t, err)
+	_, err = store.Load(ctx, "resp_3", "user:1")
+	assert.NoError(t, err)
+}
+
+func TestMemoryConversationStore_Expiration(t *testing.T) {
+	store := NewMemoryConversationStore(10, time.Millisecond)
+	ctx := context.Background()
+
+	require.NoError(t, store.Save(ctx, "resp_1", "user:1", nil))
+	time.Sleep(5 * time.Millisecond)
+
+	_, err := store.Load(ctx, "resp_1", "user:1")
+	assert.Error(t, err)
+}
+
+func TestNewConversationStore(t *testing.T) {
+	store := NewConversationStore(config.ResponsesConfig{Store: ConversationStoreMemory}, nil)
+	assert.IsType(t, &MemoryConversationStore{}, store)
+
+	// Falls back to memory when Redis is unavailable
+	store = NewConversationStore(config.ResponsesConfig{Store: ConversationStoreRedis}, nil)
+	assert.IsType(t, &MemoryConversationStore{}, store)
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/zgsm-ai/chat-rag/internal/client"
+	"github.com/zgsm-ai/chat-rag/internal/config"
+)
+
+const (
+	// EmbedderHTTP embeds text through an OpenAI compatible embeddings endpoint
+	EmbedderHTTP = "http"
+	// EmbedderHashing embeds text locally by feature hashing, no network required
+	EmbedderHashing = "hashing"
+)
+
+// Embedder turns text into a vector, texts with similar meaning have a high cosine similarity
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+}
+
+// NewEmbedder creates an embedder according to the configured type
+func NewEmbedder(cfg config.EmbedderConfig) (Embedder, error) {
+	switch cfg.Type {
+	case EmbedderHTTP:
+		if cfg.Endpoint == "" {
+			return nil, fmt.Errorf("embedder endpoint is required for type %q", cfg.Type)
+		}
+		timeout := time.Duration(cfg.TimeoutMs) * time.Millisecond
+		return client.NewEmbeddingClient(cfg.Endpoint, cfg.Model, cfg.ApiToken, timeout), nil
+	case EmbedderHashing, "":
+		return NewHashingEmbedder(cfg.Dimensions), nil
+	default:
+		return nil, fmt.Errorf("unknown embedder type: %s", cfg.Type)
+	}
+}
+
+// HashingEmbedder hashes words and word bigrams into a fixed size vector weighted by
+// sublinear term frequency. It matches questions sharing most of their wording, which
+// covers rephrased near-duplicates without an embeddings service.
+type HashingEmbedder struct {
+	dimensions int
+}
+
+// NewHashingEmbedder creates a hashing embedder of the given dimensions
+func NewHashingEmbedder(dimensions int) *HashingEmbedder {
+	if dimensions <= 0 {
+		dimensions = 512
+	}
+	return &HashingEmbedder{dimensions: dimensions}
+}
+
+// Embed returns the L2 normalized hashed term vector of the text
+func (e *HashingEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	tokens := tokenize(text)
+	counts := make(map[string]int, len(tokens)*2)
+	for i, token := range tokens {
+		counts[token]++
+		if i > 0 {
+			counts[tokens[i-1]+" "+token]++
+		}
+	}
+
+	vector := make([]float64, e.dimensions)
+	for feature, count := range counts {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		weight := 1 + math.Log(float64(count))
+		// The top bit signs the feature so that collisions tend to cancel out
+		if sum>>63 == 1 {
+			weight = -weight
+		}
+		vector[sum%uint64(e.dimensions)] += weight
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += v * v
+	}
+	norm = math.Sqrt(norm)
+
+	result := make([]float32, e.dimensions)
+	if norm == 0 {
+		return result, nil
+	}
+	for i, v := range vector {
+		result[i] = float32(v / norm)
+	}
+	return result, nil
+}
+
+// tokenize splits text into lowercase words, each Han character is a word of its own
+func tokenize(text string) []string {
+	var tokens []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flush()
+			tokens = append(tokens, string(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			word.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tokens
+}
+
+// CosineSimilarity returns the cosine similarity of two vectors, 0 when they differ in length or are empty
+func CosineSimilarity(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -27,6 +27,9 @@ const (
 	metricsLabelCategory   = "category"
 	metricsLabelTokenScope = "token_scope"
 	metricsLabelErrorType  = "error_type"
+	metricsLabelResult     = "result"
+	metricsLabelTool       = "tool"
+	metricsLabelCache      = "cache"
 
 	// Metric names
 	metricRequestsTotal         = "chat_rag_requests_total"
@@ -37,8 +40,14 @@ const (
 	metricMainModelLatency      = "chat_rag_main_model_latency_ms"
 	metricTotalLatency          = "chat_rag_total_latency_ms"
 	metricResponseTokens        = "chat_rag_response_tokens_total"
+	metricCachedPromptTokens    = "chat_rag_cached_prompt_tokens_total"
+	metricReasoningTokens       = "chat_rag_reasoning_tokens_total"
 	metricErrorsTotal           = "chat_rag_errors_total"
 	metricTokenRatio            = "chat_rag_token_ratio"
+	metricResponseCacheHits     = "chat_rag_response_cache_hits_total"
+	metricSemanticCacheLookups  = "chat_rag_semantic_cache_lookups_total"
+	metricSemanticSimilarity    = "chat_rag_semantic_cache_similarity"
+	metricToolLatency           = "chat_rag_tool_latency_ms"
 
 	// Default values
 	defaultCategory    = "unknown"
@@ -48,6 +57,14 @@ const (
 	tokenScopeSystem = "system"
 	tokenScopeUser   = "user"
 	tokenScopeAll    = "all"
+
+	// Semantic cache lookup results
+	semanticCacheHit  = "hit"
+	semanticCacheMiss = "miss"
+
+	// Tool result cache results
+	toolCacheHit  = "hit"
+	toolCacheMiss = "miss"
 )
 
 // Bucket definitions
@@ -56,6 +73,12 @@ var (
 		100, 500, 1000, 2000, 5000, 10000,
 		20000, 30000, 60000, 120000, 300000,
 	}
+	similarityBuckets = []float64{
+		0.5, 0.6, 0.7, 0.8, 0.85, 0.9, 0.92, 0.94, 0.96, 0.98, 1,
+	}
+	toolLatencyBuckets = []float64{
+		5, 10, 50, 100, 200, 500, 1000, 2000, 5000, 10000, 30000,
+	}
 )
 
 // Base label list
@@ -90,8 +113,14 @@ type MetricsService struct {
 	mainModelLatency      *prometheus.HistogramVec
 	totalLatency          *prometheus.HistogramVec
 	responseTokens        *prometheus.CounterVec
+	cachedPromptTokens    *prometheus.CounterVec
+	reasoningTokens       *prometheus.CounterVec
 	errorsTotal           *prometheus.CounterVec
 	tokenRatio            *prometheus.GaugeVec
+	responseCacheHits     *prometheus.CounterVec
+	semanticCacheLookups  *prometheus.CounterVec
+	semanticSimilarity    *prometheus.HistogramVec
+	toolLatency           *prometheus.HistogramVec
 }
 
 // NewMetricsService creates a new metrics service
@@ -106,8 +135,14 @@ func NewMetricsService() MetricsInterface {
 	ms.mainModelLatency = ms.createHistogramVec(metricMainModelLatency, "Main model processing latency in milliseconds", nil, modelLatencyBuckets)
 	ms.totalLatency = ms.createHistogramVec(metricTotalLatency, "Total processing latency in milliseconds", nil, modelLatencyBuckets)
 	ms.responseTokens = ms.createCounterVec(metricResponseTokens, "Total number of response tokens generated")
+	ms.cachedPromptTokens = ms.createCounterVec(metricCachedPromptTokens, "Total number of prompt tokens read from the provider prompt cache")
+	ms.reasoningTokens = ms.createCounterVec(metricReasoningTokens, "Total number of completion tokens spent on reasoning")
 	ms.errorsTotal = ms.createCounterVec(metricErrorsTotal, "Total number of errors encountered", metricsLabelErrorType)
 	ms.tokenRatio = ms.createGaugeVec(metricTokenRatio, "Token compression ratio by scope", metricsLabelTokenScope)
+	ms.responseCacheHits = ms.createCounterVec(metricResponseCacheHits, "Total number of requests served from the response cache")
+	ms.semanticCacheLookups = ms.createCounterVec(metricSemanticCacheLookups, "Total number of semantic cache lookups by result", metricsLabelResult)
+	ms.semanticSimilarity = ms.createHistogramVec(metricSemanticSimilarity, "Similarity of the nearest cached question in semantic cache lookups", nil, similarityBuckets)
+	ms.toolLatency = ms.createHistogramVec(metricToolLatency, "Tool call latency in milliseconds by tool and result cache hit or miss", []string{metricsLabelTool, metricsLabelCache}, toolLatencyBuckets)
 
 	ms.registerMetrics()
 	return ms
@@ -170,8 +205,14 @@ func (ms *MetricsService) registerMetrics() {
 		ms.mainModelLatency,
 		ms.totalLatency,
 		ms.responseTokens,
+		ms.cachedPromptTokens,
+		ms.reasoningTokens,
 		ms.errorsTotal,
 		ms.tokenRatio,
+		ms.responseCacheHits,
+		ms.semanticCacheLookups,
+		ms.semanticSimilarity,
+		ms.toolLatency,
 	)
 }
 
@@ -188,6 +229,8 @@ func (ms *MetricsService) RecordChatLog(log *model.ChatLog) {
 	ms.recordResponseMetrics(log, labels)
 	ms.recordErrorMetrics(log, labels)
 	ms.recordTokenRatioMetrics(log, labels)
+	ms.recordCacheMetrics(log, labels)
+	ms.recordToolMetrics(log, labels)
 }
 
 // recordRequestMetrics records request related metrics
@@ -252,6 +295,12 @@ func (ms *MetricsService) recordResponseMetrics(log *model.ChatLog, labels prome
 	if log.Usage.CompletionTokens > 0 {
 		ms.responseTokens.With(labels).Add(float64(log.Usage.CompletionTokens))
 	}
+	if cached := log.Usage.CachedTokens(); cached > 0 {
+		ms.cachedPromptTokens.With(labels).Add(float64(cached))
+	}
+	if reasoning := log.Usage.ReasoningTokens(); reasoning > 0 {
+		ms.reasoningTokens.With(labels).Add(float64(reasoning))
+	}
 }
 
 // recordErrorMetrics records error related metrics
@@ -265,6 +314,36 @@ func (ms *MetricsService) recordErrorMetrics(log *model.ChatLog, labels promethe
 	}
 }
 
+// recordCacheMetrics records response cache hits and semantic cache lookups
+func (ms *MetricsService) recordCacheMetrics(log *model.ChatLog, labels prometheus.Labels) {
+	if log.CacheHit {
+		ms.responseCacheHits.With(labels).Inc()
+	}
+
+	if log.SemanticCache != nil {
+		result := semanticCacheMiss
+		if log.SemanticCache.Hit {
+			result = semanticCacheHit
+		}
+		ms.semanticCacheLookups.With(ms.addLabel(labels, metricsLabelResult, result)).Inc()
+		if log.SemanticCache.Similarity > 0 {
+			ms.semanticSimilarity.With(labels).Observe(log.SemanticCache.Similarity)
+		}
+	}
+}
+
+// recordToolMetrics records the latency of the tool calls, separating result cache hits from misses
+func (ms *MetricsService) recordToolMetrics(log *model.ChatLog, labels prometheus.Labels) {
+	for _, toolCall := range log.ToolCalls {
+		cache := toolCacheMiss
+		if toolCall.CacheHit {
+			cache = toolCacheHit
+		}
+		toolLabels := ms.addLabel(ms.addLabel(labels, metricsLabelTool, toolCall.ToolName), metricsLabelCache, cache)
+		ms.toolLatency.With(toolLabels).Observe(float64(toolCall.Latency))
+	}
+}
+
 // getBaseLabels creates base labels map
 func (ms *MetricsService) getBaseLabels(log *model.ChatLog) prometheus.Labels {
 	promptMode := string(log.Params.LlmParams.ExtraBody.PromptMode)
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/zgsm-ai/chat-rag/internal/client"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+const (
+	responseCacheRedisKeyPrefix = "response_cache:"
+	responseCacheRedisField     = "response"
+)
+
+// responseCacheIgnoredParams are request fields that do not change the generated response
+var responseCacheIgnoredParams = map[string]struct{}{
+	"stream":         {},
+	"stream_options": {},
+}
+
+// ResponseCacheInterface stores chat completion responses by request hash
+type ResponseCacheInterface interface {
+	// Get returns the cached response of the key, nil on miss
+	Get(ctx context.Context, key string) (*types.ChatCompletionResponse, error)
+	// Set caches the response under the key
+	Set(ctx context.Context, key string, resp *types.ChatCompletionResponse) error
+}
+
+// RedisResponseCache keeps cached responses in Redis
+type RedisResponseCache struct {
+	redisClient client.RedisInterface
+	ttl         time.Duration
+}
+
+// NewResponseCache creates a Redis backed response cache
+func NewResponseCache(redisClient client.RedisInterface, ttl time.Duration) *RedisResponseCache {
+	return &RedisResponseCache{
+		redisClient: redisClient,
+		ttl:         ttl,
+	}
+}
+
+// Get reads the cached response from Redis, a missing key is a miss
+func (c *RedisResponseCache) Get(ctx context.Context, key string) (*types.ChatCompletionResponse, error) {
+	data, err := c.redisClient.GetHashField(ctx, responseCacheRedisKeyPrefix+key, responseCacheRedisField)
+	if err != nil || data == "" {
+		return nil, nil
+	}
+
+	var resp types.ChatCompletionResponse
+	if err := json.Unmarshal([]byte(data), &resp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal cached response: %w", err)
+	}
+	return &resp, nil
+}
+
+// Set stores the response in a Redis hash with expiration
+func (c *RedisResponseCache) Set(ctx context.Context, key string, resp *types.ChatCompletionResponse) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cached response: %w", err)
+	}
+	return c.redisClient.SetHashField(ctx, responseCacheRedisKeyPrefix+key, responseCacheRedisField, string(data), c.ttl)
+}
+
+// ResponseCacheKey returns the canonical hash of the model, owner, messages and sampling params of a request.
+// Map keys are sorted by encoding/json, so equal requests hash equally regardless of field order.
+// An empty owner shares the response between all users.
+func ResponseCacheKey(model string, owner string, params types.LLMRequestParams) (string, error) {
+	sampling := make(map[string]any, len(params.Extra))
+	for k, v := range params.Extra {
+		if _, ignored := responseCacheIgnoredParams[k]; ignored {
+			continue
+		}
+		sampling[k] = v
+	}
+
+	canonical, err := json.Marshal(struct {
+		Model     string          `json:"model"`
+		Owner     string          `json:"owner,omitempty"`
+		Messages  []types.Message `json:"messages"`
+		Params    map[string]any  `json:"params"`
+		ExtraBody types.ExtraBody `json:"extra_body"`
+	}{
+		Model:     model,
+		Owner:     owner,
+		Messages:  params.Messages,
+		Params:    sampling,
+		ExtraBody: params.ExtraBody,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal response cache key: %w", err)
+	}
+
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
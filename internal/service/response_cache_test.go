@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zgsm-ai/chat-rag/internal/client"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+// fakeHashRedis keeps hash fields in memory
+type fakeHashRedis struct {
+	client.RedisInterface
+	fields map[string]string
+	ttl    time.Duration
+}
+
+func (f *fakeHashRedis) SetHashField(ctx context.Context, key string, field string, value interface{}, expiration time.Duration) error {
+	f.fields[key+"/"+field] = value.(string)
+	f.ttl = expiration
+	return nil
+}
+
+func (f *fakeHashRedis) GetHashField(ctx context.Context, key string, field string) (string, error) {
+	value, ok := f.fields[key+"/"+field]
+	if !ok {
+		return "", errors.New("redis: nil")
+	}
+	return value, nil
+}
+
+func TestResponseCacheKey(t *testing.T) {
+	messages := []types.Message{{Role: types.RoleUser, Content: "review this diff"}}
+	params := func(extra map[string]any) types.LLMRequestParams {
+		return types.LLMRequestParams{Messages: messages, Extra: extra}
+	}
+
+	base, err := ResponseCacheKey("gpt-4o", "user:1", params(map[string]any{"temperature": 0.0, "max_tokens": 100.0}))
+	require.NoError(t, err)
+
+	// Stream flags do not change the key
+	streamed, err := ResponseCacheKey("gpt-4o", "user:1", params(map[string]any{"max_tokens": 100.0, "temperature": 0.0, "stream": true}))
+	require.NoError(t, err)
+	assert.Equal(t, base, streamed)
+
+	// Model, owner and sampling params do
+	otherModel, _ := ResponseCacheKey("gpt-4o-mini", "user:1", params(map[string]any{"temperature": 0.0, "max_tokens": 100.0}))
+	assert.NotEqual(t, base, otherModel)
+	otherOwner, _ := ResponseCacheKey("gpt-4o", "user:2", params(map[string]any{"temperature": 0.0, "max_tokens": 100.0}))
+	assert.NotEqual(t, base, otherOwner)
+	shared, _ := ResponseCacheKey("gpt-4o", "", params(map[string]any{"temperature": 0.0, "max_tokens": 100.0}))
+	assert.NotEqual(t, base, shared)
+	otherTemperature, _ := ResponseCacheKey("gpt-4o", "user:1", params(map[string]any{"temperature": 0.5, "max_tokens": 100.0}))
+	assert.NotEqual(t, base, otherTemperature)
+}
+
+func TestRedisResponseCache_GetSet(t *testing.T) {
+	redis := &fakeHashRedis{fields: make(map[string]string)}
+	cache := NewResponseCache(redis, time.Hour)
+	ctx := context.Background()
+
+	resp, err := cache.Get(ctx, "k1")
+	require.NoError(t, err)
+	assert.Nil(t, resp, "missing key is a miss")
+
+	stored := &types.ChatCompletionResponse{
+		Id:      "chatcmpl-1",
+		Model:   "gpt-4o",
+		Choices: []types.Choice{{Message: types.Message{Role: types.RoleAssistant, Content: "LGTM"}, FinishReason: "stop"}},
+		Usage:   types.Usage{PromptTokens: 10, CompletionTokens: 1, TotalTokens: 11},
+	}
+	require.NoError(t, cache.Set(ctx, "k1", stored))
+	assert.Equal(t, time.Hour, redis.ttl)
+
+	resp, err = cache.Get(ctx, "k1")
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, "LGTM", resp.Choices[0].Message.Content)
+	assert.Equal(t, stored.Usage, resp.Usage)
+}
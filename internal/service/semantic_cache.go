@@ -0,0 +1,308 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/zgsm-ai/chat-rag/internal/client"
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+const (
+	// SemanticCacheStoreRedis keeps the semantic cache index in Redis
+	SemanticCacheStoreRedis = "redis"
+	// SemanticCacheStoreMemory keeps the semantic cache index in process memory
+	SemanticCacheStoreMemory = "memory"
+
+	semanticCacheRedisKeyPrefix         = "semantic_cache:"
+	semanticCacheResponseRedisKeyPrefix = "semantic_cache_response:"
+)
+
+// SemanticCacheEntry is a cached answer with the embedding of its question
+type SemanticCacheEntry struct {
+	Question  string                        `json:"question"`
+	Embedding []float32                     `json:"embedding"`
+	Response  *types.ChatCompletionResponse `json:"response"`
+	CreatedAt time.Time                     `json:"created_at"`
+}
+
+// SemanticMatch is the result of a semantic cache lookup
+type SemanticMatch struct {
+	// Cached response, nil on miss
+	Response *types.ChatCompletionResponse
+	// Similarity of the nearest cached question, 0 when none
+	Similarity float64
+	// Embedding of the looked up question, reused when storing its answer
+	Embedding []float32
+}
+
+// SemanticCacheInterface serves cached answers of near-duplicate questions
+type SemanticCacheInterface interface {
+	// Lookup returns the nearest cached answer of the question in the scope
+	Lookup(ctx context.Context, scope, question string) (*SemanticMatch, error)
+	// Store caches the answer of the question in the scope
+	Store(ctx context.Context, scope, question string, embedding []float32, resp *types.ChatCompletionResponse) error
+}
+
+// SemanticIndexInterface stores cached answers by question embedding
+type SemanticIndexInterface interface {
+	// Nearest returns the unexpired entry of the scope most similar to the embedding and its similarity,
+	// nil when the scope has no entry
+	Nearest(ctx context.Context, scope string, embedding []float32) (*SemanticCacheEntry, float64, error)
+	// Add stores the entry in the scope
+	Add(ctx context.Context, scope string, entry *SemanticCacheEntry) error
+}
+
+// SemanticCache embeds questions and looks up neighbours above the similarity threshold
+type SemanticCache struct {
+	embedder  Embedder
+	index     SemanticIndexInterface
+	threshold float64
+}
+
+// NewSemanticCache creates a semantic cache according to the configured embedder and index backend
+func NewSemanticCache(cfg config.SemanticCacheConfig, redisClient client.RedisInterface) (*SemanticCache, error) {
+	embedder, err := NewEmbedder(cfg.Embedder)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := time.Duration(cfg.TTLSec) * time.Second
+	var index SemanticIndexInterface
+	if cfg.Store == SemanticCacheStoreMemory || redisClient == nil {
+		index = NewMemorySemanticIndex(cfg.MaxEntries, ttl)
+	} else {
+		index = NewRedisSemanticIndex(redisClient, cfg.MaxEntries, ttl)
+	}
+	return NewSemanticCacheWith(embedder, index, cfg.SimilarityThreshold), nil
+}
+
+// NewSemanticCacheWith creates a semantic cache of the given embedder and index
+func NewSemanticCacheWith(embedder Embedder, index SemanticIndexInterface, threshold float64) *SemanticCache {
+	return &SemanticCache{
+		embedder:  embedder,
+		index:     index,
+		threshold: threshold,
+	}
+}
+
+// Lookup embeds the question and returns the nearest cached answer when it is similar enough
+func (c *SemanticCache) Lookup(ctx context.Context, scope, question string) (*SemanticMatch, error) {
+	embedding, err := c.embedder.Embed(ctx, question)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed question: %w", err)
+	}
+
+	match := &SemanticMatch{Embedding: embedding}
+	entry, similarity, err := c.index.Nearest(ctx, scope, embedding)
+	if err != nil {
+		return match, fmt.Errorf("failed to search semantic index: %w", err)
+	}
+	if entry == nil {
+		return match, nil
+	}
+
+	match.Similarity = similarity
+	if similarity >= c.threshold {
+		match.Response = entry.Response
+	}
+	return match, nil
+}
+
+// Store caches the answer under the embedding of the question, embedding it when not given
+func (c *SemanticCache) Store(ctx context.Context, scope, question string, embedding []float32, resp *types.ChatCompletionResponse) error {
+	if len(embedding) == 0 {
+		var err error
+		if embedding, err = c.embedder.Embed(ctx, question); err != nil {
+			return fmt.Errorf("failed to embed question: %w", err)
+		}
+	}
+
+	return c.index.Add(ctx, scope, &SemanticCacheEntry{
+		Question:  question,
+		Embedding: embedding,
+		Response:  resp,
+		CreatedAt: time.Now(),
+	})
+}
+
+// expired reports whether the entry is older than the ttl
+func (e *SemanticCacheEntry) expired(ttl time.Duration, now time.Time) bool {
+	return ttl > 0 && now.Sub(e.CreatedAt) > ttl
+}
+
+// nearestEntry returns the unexpired entry most similar to the embedding
+func nearestEntry(entries []*SemanticCacheEntry, embedding []float32, ttl time.Duration) (*SemanticCacheEntry, float64) {
+	var best *SemanticCacheEntry
+	var bestSimilarity float64
+	now := time.Now()
+	for _, entry := range entries {
+		if entry.expired(ttl, now) {
+			continue
+		}
+		if similarity := CosineSimilarity(embedding, entry.Embedding); best == nil || similarity > bestSimilarity {
+			best, bestSimilarity = entry, similarity
+		}
+	}
+	return best, bestSimilarity
+}
+
+// redisSemanticEmbedding is the part of an entry scanned by lookups, its response is stored apart
+type redisSemanticEmbedding struct {
+	Question  string    `json:"question"`
+	Embedding []float32 `json:"embedding"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// redisSemanticResponse is the response of an entry, with its question to detect a replaced slot
+type redisSemanticResponse struct {
+	Question string                        `json:"question"`
+	Response *types.ChatCompletionResponse `json:"response"`
+}
+
+// RedisSemanticIndex keeps the embeddings of a scope in one Redis hash and their responses in another,
+// so lookups only read the embeddings and then the response of the nearest entry.
+//
+// A question always maps to the same of maxEntries slots, so repeated questions replace their entry and
+// the hashes stay bounded. This is not LRU eviction: unrelated questions sharing a slot replace each
+// other, so a scope holds at most maxEntries answers and usually fewer.
+type RedisSemanticIndex struct {
+	redisClient client.RedisInterface
+	maxEntries  int
+	ttl         time.Duration
+}
+
+// NewRedisSemanticIndex creates a Redis backed semantic index
+func NewRedisSemanticIndex(redisClient client.RedisInterface, maxEntries int, ttl time.Duration) *RedisSemanticIndex {
+	return &RedisSemanticIndex{
+		redisClient: redisClient,
+		maxEntries:  maxEntries,
+		ttl:         ttl,
+	}
+}
+
+// Nearest scans the embeddings of the scope and reads the response of the nearest one, a missing scope
+// has no entry
+func (i *RedisSemanticIndex) Nearest(ctx context.Context, scope string, embedding []float32) (*SemanticCacheEntry, float64, error) {
+	fields, err := i.redisClient.GetHash(ctx, semanticCacheRedisKeyPrefix+scope)
+	if err != nil || len(fields) == 0 {
+		return nil, 0, nil
+	}
+
+	entries := make([]*SemanticCacheEntry, 0, len(fields))
+	for _, data := range fields {
+		var stored redisSemanticEmbedding
+		if err := json.Unmarshal([]byte(data), &stored); err != nil {
+			continue
+		}
+		entries = append(entries, &SemanticCacheEntry{
+			Question:  stored.Question,
+			Embedding: stored.Embedding,
+			CreatedAt: stored.CreatedAt,
+		})
+	}
+
+	best, similarity := nearestEntry(entries, embedding, i.ttl)
+	if best == nil {
+		return nil, 0, nil
+	}
+
+	// The slot may have been taken by another question since the scan
+	data, err := i.redisClient.GetHashField(ctx, semanticCacheResponseRedisKeyPrefix+scope, i.slot(best.Question))
+	if err != nil {
+		return nil, 0, nil
+	}
+	var stored redisSemanticResponse
+	if err := json.Unmarshal([]byte(data), &stored); err != nil || stored.Question != best.Question {
+		return nil, 0, nil
+	}
+	best.Response = stored.Response
+	return best, similarity, nil
+}
+
+// Add stores the entry in its slot of the scope hashes and refreshes their expiration. The response is
+// written first, so an embedding found by a lookup always has a response.
+func (i *RedisSemanticIndex) Add(ctx context.Context, scope string, entry *SemanticCacheEntry) error {
+	slot := i.slot(entry.Question)
+
+	response, err := json.Marshal(&redisSemanticResponse{Question: entry.Question, Response: entry.Response})
+	if err != nil {
+		return fmt.Errorf("failed to marshal semantic cache response: %w", err)
+	}
+	if err := i.redisClient.SetHashField(ctx, semanticCacheResponseRedisKeyPrefix+scope, slot, string(response), i.ttl); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(&redisSemanticEmbedding{
+		Question:  entry.Question,
+		Embedding: entry.Embedding,
+		CreatedAt: entry.CreatedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal semantic cache embedding: %w", err)
+	}
+	return i.redisClient.SetHashField(ctx, semanticCacheRedisKeyPrefix+scope, slot, string(data), i.ttl)
+}
+
+// slot returns the hash field of the question, unrelated questions may share a slot
+func (i *RedisSemanticIndex) slot(question string) string {
+	h := fnv.New32a()
+	h.Write([]byte(question))
+	if i.maxEntries <= 0 {
+		return strconv.FormatUint(uint64(h.Sum32()), 10)
+	}
+	return strconv.FormatUint(uint64(h.Sum32())%uint64(i.maxEntries), 10)
+}
+
+// MemorySemanticIndex keeps entries in memory, evicting the oldest entries of a scope when full
+type MemorySemanticIndex struct {
+	mu         sync.Mutex
+	scopes     map[string][]*SemanticCacheEntry
+	maxEntries int
+	ttl        time.Duration
+}
+
+// NewMemorySemanticIndex creates an in-memory semantic index
+func NewMemorySemanticIndex(maxEntries int, ttl time.Duration) *MemorySemanticIndex {
+	return &MemorySemanticIndex{
+		scopes:     make(map[string][]*SemanticCacheEntry),
+		maxEntries: maxEntries,
+		ttl:        ttl,
+	}
+}
+
+// Nearest scans the entries of the scope
+func (i *MemorySemanticIndex) Nearest(ctx context.Context, scope string, embedding []float32) (*SemanticCacheEntry, float64, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	best, similarity := nearestEntry(i.scopes[scope], embedding, i.ttl)
+	return best, similarity, nil
+}
+
+// Add appends the entry to the scope, replacing an entry of the same question
+func (i *MemorySemanticIndex) Add(ctx context.Context, scope string, entry *SemanticCacheEntry) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	now := time.Now()
+	entries := make([]*SemanticCacheEntry, 0, len(i.scopes[scope])+1)
+	for _, existing := range i.scopes[scope] {
+		if existing.Question == entry.Question || existing.expired(i.ttl, now) {
+			continue
+		}
+		entries = append(entries, existing)
+	}
+	entries = append(entries, entry)
+	if i.maxEntries > 0 && len(entries) > i.maxEntries {
+		entries = entries[len(entries)-i.maxEntries:]
+	}
+	i.scopes[scope] = entries
+	return nil
+}
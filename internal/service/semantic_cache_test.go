@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zgsm-ai/chat-rag/internal/types"
+)
+
+// GetHash returns the fields of the hash key
+func (f *fakeHashRedis) GetHash(ctx context.Context, key string) (map[string]string, error) {
+	fields := make(map[string]string)
+	for k, v := range f.fields {
+		if field, ok := strings.CutPrefix(k, key+"/"); ok {
+			fields[field] = v
+		}
+	}
+	return fields, nil
+}
+
+func answer(content string) *types.ChatCompletionResponse {
+	return &types.ChatCompletionResponse{
+		Model:   "gpt-4o",
+		Choices: []types.Choice{{Message: types.Message{Role: types.RoleAssistant, Content: content}}},
+	}
+}
+
+func TestHashingEmbedder_Similarity(t *testing.T) {
+	embedder := NewHashingEmbedder(512)
+	ctx := context.Background()
+	embed := func(text string) []float32 {
+		v, err := embedder.Embed(ctx, text)
+		require.NoError(t, err)
+		return v
+	}
+
+	question := embed("How do I configure the Redis address?")
+	assert.InDelta(t, 1, CosineSimilarity(question, embed("how do I configure the redis address")), 1e-6)
+	assert.Greater(t, CosineSimilarity(question, embed("How do I configure the Redis address please?")), 0.85)
+	assert.Less(t, CosineSimilarity(question, embed("Write a unit test for the tokenizer")), 0.3)
+
+	// Han characters are words of their own
+	assert.Greater(t, CosineSimilarity(embed("如何配置 Redis 地址"), embed("如何配置Redis的地址")), 0.6)
+}
+
+func TestSemanticCache_MemoryIndex(t *testing.T) {
+	cache := NewSemanticCacheWith(NewHashingEmbedder(512), NewMemorySemanticIndex(2, time.Hour), 0.85)
+	ctx := context.Background()
+
+	match, err := cache.Lookup(ctx, "gpt-4o:ask:", "How do I configure the Redis address?")
+	require.NoError(t, err)
+	assert.Nil(t, match.Response)
+	assert.Zero(t, match.Similarity)
+	require.NoError(t, cache.Store(ctx, "gpt-4o:ask:", "How do I configure the Redis address?", match.Embedding, answer("set redis.addr")))
+
+	match, err = cache.Lookup(ctx, "gpt-4o:ask:", "How do I configure the Redis address please?")
+	require.NoError(t, err)
+	require.NotNil(t, match.Response)
+	assert.Equal(t, "set redis.addr", match.Response.Choices[0].Message.Content)
+	assert.Greater(t, match.Similarity, 0.85)
+
+	// Scopes do not share answers
+	match, _ = cache.Lookup(ctx, "gpt-4o:code:", "How do I configure the Redis address?")
+	assert.Nil(t, match.Response)
+
+	// Dissimilar questions miss but report the nearest similarity
+	match, _ = cache.Lookup(ctx, "gpt-4o:ask:", "Write a unit test for the tokenizer")
+	assert.Nil(t, match.Response)
+	assert.Less(t, match.Similarity, 0.85)
+
+	// The oldest entry is evicted when the scope is full
+	require.NoError(t, cache.Store(ctx, "gpt-4o:ask:", "What is a goroutine?", nil, answer("a lightweight thread")))
+	require.NoError(t, cache.Store(ctx, "gpt-4o:ask:", "What is a channel?", nil, answer("a typed conduit")))
+	match, _ = cache.Lookup(ctx, "gpt-4o:ask:", "How do I configure the Redis address?")
+	assert.Nil(t, match.Response)
+}
+
+func TestSemanticCache_RedisIndex(t *testing.T) {
+	redis := &fakeHashRedis{fields: make(map[string]string)}
+	cache := NewSemanticCacheWith(NewHashingEmbedder(512), NewRedisSemanticIndex(redis, 100, time.Hour), 0.85)
+	ctx := context.Background()
+
+	require.NoError(t, cache.Store(ctx, "gpt-4o:ask:", "How do I configure the Redis address?", nil, answer("set redis.addr")))
+	// Storing the same question again replaces its slot
+	require.NoError(t, cache.Store(ctx, "gpt-4o:ask:", "How do I configure the Redis address?", nil, answer("set redis.addr in etc/chat-api.yaml")))
+	// One field of the embeddings and one of the responses
+	assert.Len(t, redis.fields, 2)
+	assert.Equal(t, time.Hour, redis.ttl)
+	assert.NotContains(t, redis.fields["semantic_cache:gpt-4o:ask:/"+NewRedisSemanticIndex(redis, 100, time.Hour).slot(
+		"How do I configure the Redis address?")], "set redis.addr", "lookups do not read the responses")
+
+	match, err := cache.Lookup(ctx, "gpt-4o:ask:", "how do i configure the redis address")
+	require.NoError(t, err)
+	require.NotNil(t, match.Response)
+	assert.Equal(t, "set redis.addr in etc/chat-api.yaml", match.Response.Choices[0].Message.Content)
+
+	match, err = cache.Lookup(ctx, "gpt-4o:code:", "how do i configure the redis address")
+	require.NoError(t, err)
+	assert.Nil(t, match.Response)
+
+	// A slot whose response was replaced by another question is a miss
+	index := NewRedisSemanticIndex(redis, 1, time.Hour)
+	require.NoError(t, index.Add(ctx, "gpt-4o:chat:", &SemanticCacheEntry{
+		Question: "first", Embedding: []float32{1, 0}, Response: answer("first"), CreatedAt: time.Now(),
+	}))
+	redis.fields["semantic_cache_response:gpt-4o:chat:/0"] = `{"question":"second","response":null}`
+	entry, _, err := index.Nearest(ctx, "gpt-4o:chat:", []float32{1, 0})
+	require.NoError(t, err)
+	assert.Nil(t, entry)
+}
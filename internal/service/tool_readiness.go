@@ -0,0 +1,384 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/functions"
+	"github.com/zgsm-ai/chat-rag/internal/logger"
+	"github.com/zgsm-ai/chat-rag/internal/model"
+	"go.uber.org/zap"
+)
+
+const (
+	metricToolReadyScopes         = "chat_rag_tool_ready_scopes"
+	metricToolReadyChecks         = "chat_rag_tool_ready_checks_total"
+	metricToolReadyBudgetExceeded = "chat_rag_tool_ready_budget_exceeded_total"
+
+	metricsLabelStatus = "status"
+
+	// Tool readiness results
+	toolReady    = "ready"
+	toolNotReady = "not_ready"
+
+	// Timeout of a ready check, which outlives the request that started it
+	toolReadyCheckTimeout = 10 * time.Second
+)
+
+// ToolReadinessStatus is a snapshot of the probed readiness of a tool for a client and codebase
+type ToolReadinessStatus struct {
+	Tool          string     `json:"tool"`
+	ClientID      string     `json:"clientId"`
+	CodebasePath  string     `json:"codebasePath"`
+	Ready         bool       `json:"ready"`
+	Error         string     `json:"error,omitempty"`
+	Checking      bool       `json:"checking"`
+	CheckedAt     *time.Time `json:"checkedAt,omitempty"`
+	LastRequestAt time.Time  `json:"lastRequestAt"`
+}
+
+// toolScope identifies the readiness of a tool for a client and codebase
+type toolScope struct {
+	tool         string
+	clientID     string
+	codebasePath string
+}
+
+// toolReadiness holds the probed readiness of a scope, and what is needed to probe it again
+type toolReadiness struct {
+	executor functions.ToolExecutor
+	// Identity the background probes check the scope with, without the token of the request.
+	// Nil when the tool uses the credentials of the caller, the scope is then only checked by requests
+	identity      *model.Identity
+	lastRequestAt time.Time
+
+	ready     bool
+	err       error
+	checkedAt time.Time
+	// Closed when the running check ends, nil when none runs
+	checking chan struct{}
+}
+
+// ToolReadinessProber checks the readiness of the tools in the background, for every client and codebase
+// that recently requested them, and caches the status. Requests read the cached status and wait on a
+// ready check no longer than the budget. Tools that use the credentials of the caller are only checked
+// by requests.
+// All methods are safe on a nil receiver, which behaves as a disabled prober.
+type ToolReadinessProber struct {
+	cfg    config.ToolReadinessConfig
+	scopes map[toolScope]*toolReadiness
+	mu     sync.Mutex
+	slots  chan struct{}
+
+	// Ready check results by tool, and requests that exceeded the budget by tool
+	checks         map[string]map[string]int
+	budgetExceeded map[string]int
+
+	stopChan chan struct{}
+	stopOnce sync.Once
+
+	scopesDesc         *prometheus.Desc
+	checksDesc         *prometheus.Desc
+	budgetExceededDesc *prometheus.Desc
+}
+
+// NewToolReadinessProber creates a tool readiness prober with the given configuration
+func NewToolReadinessProber(cfg config.ToolReadinessConfig) *ToolReadinessProber {
+	return &ToolReadinessProber{
+		cfg:            cfg,
+		scopes:         make(map[toolScope]*toolReadiness),
+		slots:          make(chan struct{}, max(cfg.MaxConcurrency, 1)),
+		checks:         make(map[string]map[string]int),
+		budgetExceeded: make(map[string]int),
+		stopChan:       make(chan struct{}),
+		scopesDesc: prometheus.NewDesc(metricToolReadyScopes,
+			"Clients and codebases probed per tool by readiness status",
+			[]string{metricsLabelTool, metricsLabelStatus}, nil),
+		checksDesc: prometheus.NewDesc(metricToolReadyChecks,
+			"Total number of tool ready checks by result",
+			[]string{metricsLabelTool, metricsLabelResult}, nil),
+		budgetExceededDesc: prometheus.NewDesc(metricToolReadyBudgetExceeded,
+			"Total number of requests that stopped waiting on a tool ready check",
+			[]string{metricsLabelTool}, nil),
+	}
+}
+
+// Enabled reports whether the prober is active
+func (p *ToolReadinessProber) Enabled() bool {
+	return p != nil && p.cfg.Enabled
+}
+
+// Start probes the requested scopes in the background until Stop is called
+func (p *ToolReadinessProber) Start() {
+	if !p.Enabled() {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(p.interval())
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.probe()
+			case <-p.stopChan:
+				return
+			}
+		}
+	}()
+	logger.Info("tool readiness prober started",
+		zap.Duration("interval", p.interval()), zap.Duration("ttl", p.ttl()), zap.Duration("budget", p.budget()))
+}
+
+// Stop ends the background probing
+func (p *ToolReadinessProber) Stop() {
+	if p == nil {
+		return
+	}
+	p.stopOnce.Do(func() { close(p.stopChan) })
+}
+
+// Wrap returns the executor with its ready checks served by the prober, the executor itself when the
+// prober is disabled
+func (p *ToolReadinessProber) Wrap(executor functions.ToolExecutor) functions.ToolExecutor {
+	if !p.Enabled() || executor == nil {
+		return executor
+	}
+	return &probedToolExecutor{ToolExecutor: executor, prober: p}
+}
+
+// CheckToolReady returns the cached readiness of the tool for the client and codebase of the request.
+// A missing or expired status is checked, and the request waits on the check up to the budget. Past it,
+// the last known status is returned, and the tool is reported not ready when there is none.
+func (p *ToolReadinessProber) CheckToolReady(ctx context.Context, executor functions.ToolExecutor, toolName string) (bool, error) {
+	identity, exists := model.GetIdentityFromContext(ctx)
+	if !p.Enabled() || !exists {
+		return executor.CheckToolReady(ctx, toolName)
+	}
+
+	scope := toolScope{tool: toolName, clientID: identity.ClientID, codebasePath: identity.ProjectPath}
+	now := time.Now()
+
+	p.mu.Lock()
+	entry, exists := p.scopes[scope]
+	if !exists {
+		entry = &toolReadiness{}
+		p.scopes[scope] = entry
+	}
+	entry.executor = executor
+	entry.identity = probeIdentity(executor, toolName, identity)
+	entry.lastRequestAt = now
+	if !entry.checkedAt.IsZero() && now.Sub(entry.checkedAt) < p.ttl() {
+		ready, err := entry.ready, entry.err
+		p.mu.Unlock()
+		return ready, err
+	}
+	done := entry.checking
+	if done == nil {
+		done = p.startCheck(scope, entry, identity)
+	}
+	p.mu.Unlock()
+
+	timer := time.NewTimer(p.budget())
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if entry.checking == done {
+		if ctx.Err() == nil {
+			p.budgetExceeded[toolName]++
+			logger.WarnC(ctx, "tool ready check exceeded the budget, the last known status is used",
+				zap.String("tool", toolName), zap.Duration("budget", p.budget()))
+		}
+		if entry.checkedAt.IsZero() {
+			return false, fmt.Errorf("ready check of %s exceeded the budget of %v", toolName, p.budget())
+		}
+	}
+	return entry.ready, entry.err
+}
+
+// probeIdentity returns the identity background probes may check the tool with. Tools that authenticate
+// as the end user are never probed in the background, it would replay the credentials of the last requester
+func probeIdentity(executor functions.ToolExecutor, toolName string, identity *model.Identity) *model.Identity {
+	if executor.UsesCallerCredentials(toolName) {
+		return nil
+	}
+	probe := *identity
+	probe.AuthToken = ""
+	return &probe
+}
+
+// probe checks the scopes whose status is older than the interval, and forgets the idle ones
+func (p *ToolReadinessProber) probe() {
+	now := time.Now()
+	idle := time.Duration(p.cfg.IdleSec) * time.Second
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for scope, entry := range p.scopes {
+		if now.Sub(entry.lastRequestAt) > idle {
+			delete(p.scopes, scope)
+			continue
+		}
+		// Statuses checked within the last half interval, such as by a request, are recent enough
+		if entry.identity != nil && entry.checking == nil && now.Sub(entry.checkedAt) >= p.interval()/2 {
+			p.startCheck(scope, entry, entry.identity)
+		}
+	}
+}
+
+// startCheck runs a ready check of the scope with the identity in the background, the caller holds the lock
+func (p *ToolReadinessProber) startCheck(scope toolScope, entry *toolReadiness, identity *model.Identity) chan struct{} {
+	done := make(chan struct{})
+	entry.checking = done
+	executor := entry.executor
+
+	go func() {
+		defer close(done)
+		p.slots <- struct{}{}
+		ctx, cancel := context.WithTimeout(
+			context.WithValue(context.Background(), model.IdentityContextKey, identity), toolReadyCheckTimeout)
+		ready, err := executor.CheckToolReady(ctx, scope.tool)
+		cancel()
+		<-p.slots
+
+		result := toolReady
+		if !ready {
+			result = toolNotReady
+		}
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		entry.ready, entry.err, entry.checkedAt = ready, err, time.Now()
+		entry.checking = nil
+		if p.checks[scope.tool] == nil {
+			p.checks[scope.tool] = make(map[string]int)
+		}
+		p.checks[scope.tool][result]++
+	}()
+	return done
+}
+
+// Snapshot returns the readiness of every probed scope, ordered by tool, client and codebase
+func (p *ToolReadinessProber) Snapshot() []ToolReadinessStatus {
+	if p == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	statuses := make([]ToolReadinessStatus, 0, len(p.scopes))
+	for scope, entry := range p.scopes {
+		status := ToolReadinessStatus{
+			Tool:          scope.tool,
+			ClientID:      scope.clientID,
+			CodebasePath:  scope.codebasePath,
+			Ready:         entry.ready,
+			Checking:      entry.checking != nil,
+			LastRequestAt: entry.lastRequestAt,
+		}
+		if entry.err != nil {
+			status.Error = entry.err.Error()
+		}
+		if !entry.checkedAt.IsZero() {
+			checkedAt := entry.checkedAt
+			status.CheckedAt = &checkedAt
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		a, b := statuses[i], statuses[j]
+		if a.Tool != b.Tool {
+			return a.Tool < b.Tool
+		}
+		if a.ClientID != b.ClientID {
+			return a.ClientID < b.ClientID
+		}
+		return a.CodebasePath < b.CodebasePath
+	})
+	return statuses
+}
+
+// Describe implements prometheus.Collector
+func (p *ToolReadinessProber) Describe(ch chan<- *prometheus.Desc) {
+	ch <- p.scopesDesc
+	ch <- p.checksDesc
+	ch <- p.budgetExceededDesc
+}
+
+// Collect implements prometheus.Collector
+func (p *ToolReadinessProber) Collect(ch chan<- prometheus.Metric) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	scopes := make(map[string]map[string]int)
+	for scope, entry := range p.scopes {
+		if entry.checkedAt.IsZero() {
+			continue
+		}
+		status := toolReady
+		if !entry.ready {
+			status = toolNotReady
+		}
+		if scopes[scope.tool] == nil {
+			scopes[scope.tool] = map[string]int{toolReady: 0, toolNotReady: 0}
+		}
+		scopes[scope.tool][status]++
+	}
+	for tool, counts := range scopes {
+		for status, count := range counts {
+			ch <- prometheus.MustNewConstMetric(p.scopesDesc, prometheus.GaugeValue, float64(count), tool, status)
+		}
+	}
+	for tool, results := range p.checks {
+		for result, count := range results {
+			ch <- prometheus.MustNewConstMetric(p.checksDesc, prometheus.CounterValue, float64(count), tool, result)
+		}
+	}
+	for tool, count := range p.budgetExceeded {
+		ch <- prometheus.MustNewConstMetric(p.budgetExceededDesc, prometheus.CounterValue, float64(count), tool)
+	}
+}
+
+func (p *ToolReadinessProber) interval() time.Duration {
+	return time.Duration(p.cfg.IntervalSec) * time.Second
+}
+
+func (p *ToolReadinessProber) ttl() time.Duration {
+	return time.Duration(p.cfg.TTLSec) * time.Second
+}
+
+func (p *ToolReadinessProber) budget() time.Duration {
+	return time.Duration(p.cfg.BudgetMs) * time.Millisecond
+}
+
+// probedToolExecutor serves the ready checks of the executor from the prober
+type probedToolExecutor struct {
+	functions.ToolExecutor
+	prober *ToolReadinessProber
+}
+
+// CheckToolReady returns the cached readiness of the tool
+func (e *probedToolExecutor) CheckToolReady(ctx context.Context, toolName string) (bool, error) {
+	return e.prober.CheckToolReady(ctx, e.ToolExecutor, toolName)
+}
+
+// Close closes the wrapped executor
+func (e *probedToolExecutor) Close() error {
+	if closer, ok := e.ToolExecutor.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
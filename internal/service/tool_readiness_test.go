@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zgsm-ai/chat-rag/internal/config"
+	"github.com/zgsm-ai/chat-rag/internal/functions"
+	"github.com/zgsm-ai/chat-rag/internal/model"
+)
+
+// readyToolExecutor reports the tools ready once release is closed, or at once when it is nil
+type readyToolExecutor struct {
+	functions.ToolExecutor
+	ready             atomic.Bool
+	checks            atomic.Int32
+	release           chan struct{}
+	callerCredentials bool
+}
+
+func (e *readyToolExecutor) UsesCallerCredentials(toolName string) bool {
+	return e.callerCredentials
+}
+
+func (e *readyToolExecutor) CheckToolReady(ctx context.Context, toolName string) (bool, error) {
+	e.checks.Add(1)
+	if _, exists := model.GetIdentityFromContext(ctx); !exists {
+		return false, errors.New("identity not found in context")
+	}
+	if e.release != nil {
+		<-e.release
+	}
+	if !e.ready.Load() {
+		return false, errors.New(toolName + " is indexing")
+	}
+	return true, nil
+}
+
+func identityContext(codebasePath string) context.Context {
+	return context.WithValue(context.Background(), model.IdentityContextKey,
+		&model.Identity{ClientID: "client", ProjectPath: codebasePath, AuthToken: "Bearer user-jwt"})
+}
+
+func TestToolReadinessProber_CachesStatus(t *testing.T) {
+	executor := &readyToolExecutor{}
+	executor.ready.Store(true)
+	prober := NewToolReadinessProber(config.ToolReadinessConfig{
+		Enabled: true, IntervalSec: 30, TTLSec: 60, BudgetMs: 1000, IdleSec: 60, MaxConcurrency: 2,
+	})
+	probed := prober.Wrap(executor)
+
+	for i := 0; i < 3; i++ {
+		ready, err := probed.CheckToolReady(identityContext("/repo"), "codebase_search")
+		require.NoError(t, err)
+		assert.True(t, ready)
+	}
+	assert.EqualValues(t, 1, executor.checks.Load(), "the status is served from the cache within the TTL")
+
+	// Another codebase has its own status
+	executor.ready.Store(false)
+	ready, err := probed.CheckToolReady(identityContext("/other"), "codebase_search")
+	assert.False(t, ready)
+	assert.EqualError(t, err, "codebase_search is indexing")
+
+	statuses := prober.Snapshot()
+	require.Len(t, statuses, 2)
+	assert.Equal(t, "/other", statuses[0].CodebasePath)
+	assert.False(t, statuses[0].Ready)
+	assert.Equal(t, "/repo", statuses[1].CodebasePath)
+	assert.True(t, statuses[1].Ready)
+}
+
+func TestToolReadinessProber_Budget(t *testing.T) {
+	executor := &readyToolExecutor{release: make(chan struct{})}
+	executor.ready.Store(true)
+	// Every status is expired, so each request checks again
+	prober := NewToolReadinessProber(config.ToolReadinessConfig{
+		Enabled: true, IntervalSec: 30, BudgetMs: 20, IdleSec: 60, MaxConcurrency: 2,
+	})
+
+	start := time.Now()
+	ready, err := prober.CheckToolReady(identityContext("/repo"), executor, "codebase_search")
+	assert.False(t, ready, "no status is known yet")
+	assert.ErrorContains(t, err, "exceeded the budget")
+	assert.Less(t, time.Since(start), time.Second)
+
+	close(executor.release)
+	assert.Eventually(t, func() bool { return !prober.Snapshot()[0].Checking }, time.Second, time.Millisecond)
+
+	// The next check is slow again, the last known status is used
+	executor.release = make(chan struct{})
+	defer close(executor.release)
+	ready, err = prober.CheckToolReady(identityContext("/repo"), executor, "codebase_search")
+	assert.True(t, ready)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, executor.checks.Load())
+}
+
+func TestToolReadinessProber_Probe(t *testing.T) {
+	executor := &readyToolExecutor{}
+	prober := NewToolReadinessProber(config.ToolReadinessConfig{
+		Enabled: true, IntervalSec: 30, TTLSec: 60, BudgetMs: 1000, IdleSec: 60, MaxConcurrency: 2,
+	})
+
+	ready, _ := prober.CheckToolReady(identityContext("/repo"), executor, "codebase_search")
+	assert.False(t, ready)
+
+	// The background probe checks the scope again with the identity of the request
+	executor.ready.Store(true)
+	prober.scopes[toolScope{tool: "codebase_search", clientID: "client", codebasePath: "/repo"}].checkedAt =
+		time.Now().Add(-time.Minute)
+	prober.probe()
+	assert.Eventually(t, func() bool { return prober.Snapshot()[0].Ready }, time.Second, time.Millisecond)
+	assert.EqualValues(t, 2, executor.checks.Load())
+	assert.Empty(t, prober.scopes[toolScope{tool: "codebase_search", clientID: "client", codebasePath: "/repo"}].identity.AuthToken,
+		"the token of the request is not kept for the background probes")
+
+	// Scopes without requests for the idle time are forgotten
+	prober.cfg.IdleSec = 0
+	time.Sleep(time.Millisecond)
+	prober.probe()
+	assert.Empty(t, prober.Snapshot())
+}
+
+func TestToolReadinessProber_CallerCredentials(t *testing.T) {
+	executor := &readyToolExecutor{callerCredentials: true}
+	prober := NewToolReadinessProber(config.ToolReadinessConfig{
+		Enabled: true, IntervalSec: 30, TTLSec: 60, BudgetMs: 1000, IdleSec: 60, MaxConcurrency: 2,
+	})
+
+	ready, _ := prober.CheckToolReady(identityContext("/repo"), executor, "codebase_search")
+	assert.False(t, ready)
+	assert.EqualValues(t, 1, executor.checks.Load(), "requests check with their own identity")
+
+	// The tool authenticates as the end user, the background probe does not replay the request
+	scope := prober.scopes[toolScope{tool: "codebase_search", clientID: "client", codebasePath: "/repo"}]
+	assert.Nil(t, scope.identity)
+	scope.checkedAt = time.Now().Add(-time.Minute)
+	prober.probe()
+	assert.False(t, prober.Snapshot()[0].Checking)
+	assert.EqualValues(t, 1, executor.checks.Load())
+}
+
+func TestToolReadinessProber_Disabled(t *testing.T) {
+	executor := &readyToolExecutor{}
+	prober := NewToolReadinessProber(config.ToolReadinessConfig{})
+
+	assert.Same(t, functions.ToolExecutor(executor), prober.Wrap(executor))
+	var nilProber *ToolReadinessProber
+	assert.Same(t, functions.ToolExecutor(executor), nilProber.Wrap(executor))
+	assert.Empty(t, nilProber.Snapshot())
+}
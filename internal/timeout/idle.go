@@ -120,6 +120,12 @@ func NewIdleTimer(parentCtx context.Context, perIdle time.Duration, tracker *Idl
 	return ctx, cancel, it
 }
 
+// NewSibling creates the timer of an attempt running alongside the one of this timer, e.g. a hedged request.
+// It has the same per-idle timeout and shares the total idle budget
+func (it *IdleTimer) NewSibling(parentCtx context.Context) (context.Context, context.CancelFunc, *IdleTimer) {
+	return NewIdleTimer(parentCtx, it.perIdle, it.tracker)
+}
+
 // watch monitors the timer and contexts
 func (it *IdleTimer) watch() {
 	for {
@@ -0,0 +1,649 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	// Anthropic content block types
+	AnthropicBlockText       = "text"
+	AnthropicBlockImage      = "image"
+	AnthropicBlockToolUse    = "tool_use"
+	AnthropicBlockToolResult = "tool_result"
+	AnthropicBlockThinking   = "thinking"
+
+	// Anthropic stream event types
+	AnthropicEventMessageStart      = "message_start"
+	AnthropicEventContentBlockStart = "content_block_start"
+	AnthropicEventContentBlockDelta = "content_block_delta"
+	AnthropicEventContentBlockStop  = "content_block_stop"
+	AnthropicEventMessageDelta      = "message_delta"
+	AnthropicEventMessageStop       = "message_stop"
+	AnthropicEventError             = "error"
+
+	// Anthropic stop reasons
+	AnthropicStopEndTurn   = "end_turn"
+	AnthropicStopMaxTokens = "max_tokens"
+	AnthropicStopToolUse   = "tool_use"
+)
+
+// AnthropicMessagesRequest is the request body of the Anthropic Messages API
+type AnthropicMessagesRequest struct {
+	Model         string             `json:"model"`
+	MaxTokens     int                `json:"max_tokens,omitempty"`
+	System        any                `json:"system,omitempty"`
+	Messages      []AnthropicMessage `json:"messages"`
+	StopSequences []string           `json:"stop_sequences,omitempty"`
+	Stream        bool               `json:"stream,omitempty"`
+	Temperature   *float64           `json:"temperature,omitempty"`
+	TopP          *float64           `json:"top_p,omitempty"`
+	TopK          *int               `json:"top_k,omitempty"`
+	Tools         []AnthropicTool    `json:"tools,omitempty"`
+	ToolChoice    map[string]any     `json:"tool_choice,omitempty"`
+	Metadata      map[string]any     `json:"metadata,omitempty"`
+	ExtraBody     *ExtraBody         `json:"extra_body,omitempty"`
+}
+
+// AnthropicMessage is a single conversation turn, content is a string or a list of blocks
+type AnthropicMessage struct {
+	Role    string `json:"role"`
+	Content any    `json:"content"`
+}
+
+// AnthropicContentBlock is a content block of a request or response message
+type AnthropicContentBlock struct {
+	Type      string                `json:"type"`
+	Text      string                `json:"text,omitempty"`
+	Thinking  string                `json:"thinking,omitempty"`
+	Source    *AnthropicImageSource `json:"source,omitempty"`
+	ID        string                `json:"id,omitempty"`
+	Name      string                `json:"name,omitempty"`
+	Input     any                   `json:"input,omitempty"`
+	ToolUseID string                `json:"tool_use_id,omitempty"`
+	Content   any                   `json:"content,omitempty"`
+	IsError   bool                  `json:"is_error,omitempty"`
+
+	CacheControl any `json:"cache_control,omitempty"`
+}
+
+// AnthropicImageSource describes an inline or remote image
+type AnthropicImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+// AnthropicTool is a client tool definition
+type AnthropicTool struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	InputSchema any    `json:"input_schema"`
+}
+
+// AnthropicUsage is the token usage of the Anthropic Messages API
+type AnthropicUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens,omitempty"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"`
+}
+
+// NewAnthropicUsage converts the OpenAI usage, whose prompt tokens include cached tokens,
+// into the Anthropic usage, whose input tokens exclude them
+func NewAnthropicUsage(u Usage) AnthropicUsage {
+	cached := u.CachedTokens()
+	return AnthropicUsage{
+		InputTokens:              u.PromptTokens - cached - u.CacheCreationInputTokens,
+		OutputTokens:             u.CompletionTokens,
+		CacheReadInputTokens:     cached,
+		CacheCreationInputTokens: u.CacheCreationInputTokens,
+	}
+}
+
+// ToUsage converts the Anthropic usage into the OpenAI usage
+func (u AnthropicUsage) ToUsage() Usage {
+	prompt := u.InputTokens + u.CacheReadInputTokens + u.CacheCreationInputTokens
+	usage := Usage{
+		PromptTokens:             prompt,
+		CompletionTokens:         u.OutputTokens,
+		TotalTokens:              prompt + u.OutputTokens,
+		CacheCreationInputTokens: u.CacheCreationInputTokens,
+	}
+	if u.CacheReadInputTokens > 0 {
+		usage.PromptTokensDetails = &PromptTokensDetails{CachedTokens: u.CacheReadInputTokens}
+	}
+	return usage
+}
+
+// AnthropicMessagesResponse is the non-streaming response of the Anthropic Messages API
+type AnthropicMessagesResponse struct {
+	ID           string                  `json:"id"`
+	Type         string                  `json:"type"`
+	Role         string                  `json:"role"`
+	Model        string                  `json:"model"`
+	Content      []AnthropicContentBlock `json:"content"`
+	StopReason   *string                 `json:"stop_reason"`
+	StopSequence *string                 `json:"stop_sequence"`
+	Usage        AnthropicUsage          `json:"usage"`
+}
+
+// AnthropicStreamEvent is the payload of an Anthropic SSE event
+type AnthropicStreamEvent struct {
+	Type         string                     `json:"type"`
+	Message      *AnthropicMessagesResponse `json:"message,omitempty"`
+	Index        *int                       `json:"index,omitempty"`
+	ContentBlock any                        `json:"content_block,omitempty"`
+	Delta        map[string]any             `json:"delta,omitempty"`
+	Usage        *AnthropicUsage            `json:"usage,omitempty"`
+	Error        *AnthropicError            `json:"error,omitempty"`
+}
+
+// AnthropicError is the error object of the Anthropic Messages API
+type AnthropicError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// AnthropicErrorResponse is the error body of the Anthropic Messages API
+type AnthropicErrorResponse struct {
+	Type  string         `json:"type"`
+	Error AnthropicError `json:"error"`
+}
+
+// ToChatCompletionRequest converts an Anthropic request into the internal chat completion request
+func (r *AnthropicMessagesRequest) ToChatCompletionRequest() (*ChatCompletionRequest, error) {
+	req := &ChatCompletionRequest{
+		Model: r.Model,
+		LLMRequestParams: LLMRequestParams{
+			Extra: make(map[string]any),
+		},
+	}
+	if r.ExtraBody != nil {
+		req.ExtraBody = *r.ExtraBody
+	}
+
+	if r.System != nil {
+		systemContent, err := convertAnthropicSystem(r.System)
+		if err != nil {
+			return nil, err
+		}
+		req.Messages = append(req.Messages, Message{Role: RoleSystem, Content: systemContent})
+	}
+
+	for i, msg := range r.Messages {
+		converted, err := convertAnthropicMessage(msg)
+		if err != nil {
+			return nil, fmt.Errorf("messages[%d]: %w", i, err)
+		}
+		req.Messages = append(req.Messages, converted...)
+	}
+
+	if r.MaxTokens > 0 {
+		req.Extra["max_tokens"] = r.MaxTokens
+	}
+	if len(r.StopSequences) > 0 {
+		req.Extra["stop"] = r.StopSequences
+	}
+	if r.Temperature != nil {
+		req.Extra["temperature"] = *r.Temperature
+	}
+	if r.TopP != nil {
+		req.Extra["top_p"] = *r.TopP
+	}
+	if r.TopK != nil {
+		req.Extra["top_k"] = *r.TopK
+	}
+	if userID, ok := r.Metadata["user_id"].(string); ok && userID != "" {
+		req.Extra["user"] = userID
+	}
+	if r.Stream {
+		req.Extra["stream"] = true
+		req.Extra["stream_options"] = map[string]any{"include_usage": true}
+	}
+
+	if len(r.Tools) > 0 {
+		tools := make([]any, 0, len(r.Tools))
+		for _, tool := range r.Tools {
+			tools = append(tools, map[string]any{
+				"type": "function",
+				"function": map[string]any{
+					"name":        tool.Name,
+					"description": tool.Description,
+					"parameters":  tool.InputSchema,
+				},
+			})
+		}
+		req.Extra["tools"] = tools
+	}
+	if toolChoice := convertAnthropicToolChoice(r.ToolChoice); toolChoice != nil {
+		req.Extra["tool_choice"] = toolChoice
+	}
+
+	return req, nil
+}
+
+// convertAnthropicSystem converts the system prompt, which is a string or a list of text blocks
+func convertAnthropicSystem(system any) (any, error) {
+	switch v := system.(type) {
+	case string:
+		return v, nil
+	case []any:
+		blocks, err := decodeAnthropicBlocks(v)
+		if err != nil {
+			return nil, fmt.Errorf("system: %w", err)
+		}
+		parts := make([]any, 0, len(blocks))
+		for _, block := range blocks {
+			if block.Type != AnthropicBlockText {
+				continue
+			}
+			parts = append(parts, openAITextPart(block))
+		}
+		return parts, nil
+	default:
+		return nil, fmt.Errorf("system: unsupported content type %T", system)
+	}
+}
+
+// convertAnthropicMessage converts one Anthropic message into one or more OpenAI style messages.
+// tool_result blocks become separate tool role messages placed before the remaining user content.
+func convertAnthropicMessage(msg AnthropicMessage) ([]Message, error) {
+	if text, ok := msg.Content.(string); ok {
+		return []Message{{Role: msg.Role, Content: text}}, nil
+	}
+
+	rawBlocks, ok := msg.Content.([]any)
+	if !ok {
+		return nil, fmt.Errorf("unsupported content type %T", msg.Content)
+	}
+	blocks, err := decodeAnthropicBlocks(rawBlocks)
+	if err != nil {
+		return nil, err
+	}
+
+	if msg.Role == RoleAssistant {
+		return []Message{convertAnthropicAssistantBlocks(blocks)}, nil
+	}
+
+	var messages []Message
+	parts := make([]any, 0, len(blocks))
+	for _, block := range blocks {
+		switch block.Type {
+		case AnthropicBlockText:
+			parts = append(parts, openAITextPart(block))
+		case AnthropicBlockImage:
+			if block.Source == nil {
+				continue
+			}
+			url := block.Source.URL
+			if block.Source.Type == "base64" {
+				url = fmt.Sprintf("data:%s;base64,%s", block.Source.MediaType, block.Source.Data)
+			}
+			parts = append(parts, map[string]any{
+				"type":      "image_url",
+				"image_url": map[string]any{"url": url},
+			})
+		case AnthropicBlockToolResult:
+			messages = append(messages, Message{
+				Role:    RoleTool,
+				Content: anthropicToolResultText(block.Content),
+				Extra:   map[string]any{"tool_call_id": block.ToolUseID},
+			})
+		}
+	}
+
+	if len(parts) > 0 {
+		messages = append(messages, Message{Role: msg.Role, Content: parts})
+	}
+	return messages, nil
+}
+
+// convertAnthropicAssistantBlocks merges assistant text and tool_use blocks into a single message
+func convertAnthropicAssistantBlocks(blocks []AnthropicContentBlock) Message {
+	var text strings.Builder
+	var toolCalls []any
+	for _, block := range blocks {
+		switch block.Type {
+		case AnthropicBlockText:
+			text.WriteString(block.Text)
+		case AnthropicBlockToolUse:
+			arguments := "{}"
+			if block.Input != nil {
+				if data, err := json.Marshal(block.Input); err == nil {
+					arguments = string(data)
+				}
+			}
+			toolCalls = append(toolCalls, map[string]any{
+				"id":   block.ID,
+				"type": "function",
+				"function": map[string]any{
+					"name":      block.Name,
+					"arguments": arguments,
+				},
+			})
+		}
+	}
+
+	msg := Message{Role: RoleAssistant, Content: text.String()}
+	if len(toolCalls) > 0 {
+		msg.Extra = map[string]any{"tool_calls": toolCalls}
+	}
+	return msg
+}
+
+// convertAnthropicToolChoice maps Anthropic tool_choice to the OpenAI equivalent
+func convertAnthropicToolChoice(choice map[string]any) any {
+	if choice == nil {
+		return nil
+	}
+	switch choice["type"] {
+	case "auto":
+		return "auto"
+	case "any":
+		return "required"
+	case "none":
+		return "none"
+	case "tool":
+		return map[string]any{
+			"type":     "function",
+			"function": map[string]any{"name": choice["name"]},
+		}
+	}
+	return nil
+}
+
+// anthropicToolResultText flattens tool_result content, which is a string or a list of text blocks
+func anthropicToolResultText(content any) string {
+	switch v := content.(type) {
+	case string:
+		return v
+	case []any:
+		var sb strings.Builder
+		for _, item := range v {
+			if block, ok := item.(map[string]any); ok {
+				if text, ok := block["text"].(string); ok {
+					sb.WriteString(text)
+				}
+			}
+		}
+		return sb.String()
+	}
+	return ""
+}
+
+func openAITextPart(block AnthropicContentBlock) map[string]any {
+	part := map[string]any{
+		"type": "text",
+		"text": block.Text,
+	}
+	if block.CacheControl != nil {
+		part["cache_control"] = block.CacheControl
+	}
+	return part
+}
+
+func decodeAnthropicBlocks(raw []any) ([]AnthropicContentBlock, error) {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var blocks []AnthropicContentBlock
+	if err := json.Unmarshal(data, &blocks); err != nil {
+		return nil, fmt.Errorf("invalid content blocks: %w", err)
+	}
+	return blocks, nil
+}
+
+// AnthropicStopReason maps an OpenAI finish_reason to an Anthropic stop_reason
+func AnthropicStopReason(finishReason string) string {
+	switch finishReason {
+	case "length":
+		return AnthropicStopMaxTokens
+	case "tool_calls", "function_call":
+		return AnthropicStopToolUse
+	default:
+		return AnthropicStopEndTurn
+	}
+}
+
+// NewAnthropicMessagesResponse converts a chat completion response into an Anthropic response
+func NewAnthropicMessagesResponse(resp *ChatCompletionResponse) *AnthropicMessagesResponse {
+	result := &AnthropicMessagesResponse{
+		ID:      resp.Id,
+		Type:    "message",
+		Role:    RoleAssistant,
+		Model:   resp.Model,
+		Content: []AnthropicContentBlock{},
+		Usage:   NewAnthropicUsage(resp.Usage),
+	}
+
+	stopReason := AnthropicStopEndTurn
+	if len(resp.Choices) > 0 {
+		choice := resp.Choices[0]
+		if reasoning, ok := choice.Message.Extra["reasoning_content"].(string); ok && reasoning != "" {
+			result.Content = append(result.Content, AnthropicContentBlock{
+				Type:     AnthropicBlockThinking,
+				Thinking: reasoning,
+			})
+		}
+		if text, ok := choice.Message.Content.(string); ok && text != "" {
+			result.Content = append(result.Content, AnthropicContentBlock{
+				Type: AnthropicBlockText,
+				Text: text,
+			})
+		}
+		if toolCalls, ok := choice.Message.Extra["tool_calls"].([]any); ok {
+			for _, call := range toolCalls {
+				if block, ok := anthropicToolUseBlock(call); ok {
+					result.Content = append(result.Content, block)
+				}
+			}
+		}
+		stopReason = AnthropicStopReason(choice.FinishReason)
+	}
+	result.StopReason = &stopReason
+
+	return result
+}
+
+// anthropicToolUseBlock converts an OpenAI tool call into a tool_use block
+func anthropicToolUseBlock(call any) (AnthropicContentBlock, bool) {
+	callMap, ok := call.(map[string]any)
+	if !ok {
+		return AnthropicContentBlock{}, false
+	}
+	function, ok := callMap["function"].(map[string]any)
+	if !ok {
+		return AnthropicContentBlock{}, false
+	}
+
+	id, _ := callMap["id"].(string)
+	name, _ := function["name"].(string)
+	var input any = map[string]any{}
+	if arguments, ok := function["arguments"].(string); ok && arguments != "" {
+		if err := json.Unmarshal([]byte(arguments), &input); err != nil {
+			input = map[string]any{}
+		}
+	}
+
+	return AnthropicContentBlock{
+		Type:  AnthropicBlockToolUse,
+		ID:    id,
+		Name:  name,
+		Input: input,
+	}, true
+}
+
+// AnthropicDefaultMaxTokens is the max_tokens of upstream requests when neither the request nor the registry sets it
+const AnthropicDefaultMaxTokens = 4096
+
+// NewAnthropicUpstreamRequest converts OpenAI style request params into an Anthropic Messages request.
+// System messages are merged into the system prompt and tool results become user tool_result blocks.
+func NewAnthropicUpstreamRequest(model string, params LLMRequestParams, maxTokens int) *AnthropicMessagesRequest {
+	sampling := readSamplingParams(params.Extra)
+	if sampling.MaxTokens > 0 {
+		maxTokens = sampling.MaxTokens
+	}
+	if maxTokens <= 0 {
+		maxTokens = AnthropicDefaultMaxTokens
+	}
+
+	req := &AnthropicMessagesRequest{
+		Model:         model,
+		MaxTokens:     maxTokens,
+		Temperature:   sampling.Temperature,
+		TopP:          sampling.TopP,
+		StopSequences: sampling.StopSequences,
+		Messages:      []AnthropicMessage{},
+	}
+
+	var system []AnthropicContentBlock
+	for _, msg := range params.Messages {
+		switch msg.Role {
+		case RoleSystem, "developer":
+			system = append(system, anthropicContentBlocks(msg.Content)...)
+		case RoleAssistant:
+			blocks := anthropicContentBlocks(msg.Content)
+			for _, call := range messageToolCalls(msg) {
+				blocks = append(blocks, AnthropicContentBlock{
+					Type:  AnthropicBlockToolUse,
+					ID:    call.ID,
+					Name:  call.Function.Name,
+					Input: parseToolArguments(call.Function.Arguments),
+				})
+			}
+			req.Messages = appendAnthropicMessage(req.Messages, RoleAssistant, blocks)
+		case RoleTool:
+			toolUseID, _ := msg.Extra["tool_call_id"].(string)
+			req.Messages = appendAnthropicMessage(req.Messages, RoleUser, []AnthropicContentBlock{{
+				Type:      AnthropicBlockToolResult,
+				ToolUseID: toolUseID,
+				Content:   messageContentText(msg.Content),
+			}})
+		default:
+			req.Messages = appendAnthropicMessage(req.Messages, RoleUser, anthropicContentBlocks(msg.Content))
+		}
+	}
+	if len(system) > 0 {
+		req.System = system
+	}
+
+	for _, tool := range readFunctionTools(params.Extra) {
+		req.Tools = append(req.Tools, AnthropicTool{
+			Name:        tool.Name,
+			Description: tool.Description,
+			InputSchema: tool.Parameters,
+		})
+	}
+	if len(req.Tools) > 0 {
+		switch mode, name := readToolChoice(params.Extra); mode {
+		case "auto":
+			req.ToolChoice = map[string]any{"type": "auto"}
+		case "required":
+			req.ToolChoice = map[string]any{"type": "any"}
+		case "none":
+			req.ToolChoice = map[string]any{"type": "none"}
+		case "function":
+			req.ToolChoice = map[string]any{"type": "tool", "name": name}
+		}
+	}
+
+	return req
+}
+
+// anthropicContentBlocks converts OpenAI message content into text and image blocks, empty text is dropped
+func anthropicContentBlocks(content any) []AnthropicContentBlock {
+	var blocks []AnthropicContentBlock
+	for _, part := range messageContentParts(content) {
+		switch part["type"] {
+		case "text":
+			text, _ := part["text"].(string)
+			if text == "" {
+				continue
+			}
+			blocks = append(blocks, AnthropicContentBlock{
+				Type:         AnthropicBlockText,
+				Text:         text,
+				CacheControl: part["cache_control"],
+			})
+		case "image_url":
+			url := imagePartURL(part)
+			source := &AnthropicImageSource{Type: "url", URL: url}
+			if mediaType, data, ok := parseDataURL(url); ok {
+				source = &AnthropicImageSource{Type: "base64", MediaType: mediaType, Data: data}
+			}
+			blocks = append(blocks, AnthropicContentBlock{Type: AnthropicBlockImage, Source: source})
+		}
+	}
+	return blocks
+}
+
+// appendAnthropicMessage appends the blocks as a message, merging consecutive messages of the same role
+func appendAnthropicMessage(messages []AnthropicMessage, role string, blocks []AnthropicContentBlock) []AnthropicMessage {
+	if len(blocks) == 0 {
+		return messages
+	}
+	if n := len(messages); n > 0 && messages[n-1].Role == role {
+		if last, ok := messages[n-1].Content.([]AnthropicContentBlock); ok {
+			messages[n-1].Content = append(last, blocks...)
+			return messages
+		}
+	}
+	return append(messages, AnthropicMessage{Role: role, Content: blocks})
+}
+
+// AnthropicFinishReason maps an Anthropic stop_reason to an OpenAI finish_reason
+func AnthropicFinishReason(stopReason string) string {
+	switch stopReason {
+	case AnthropicStopMaxTokens:
+		return "length"
+	case AnthropicStopToolUse:
+		return "tool_calls"
+	default:
+		return "stop"
+	}
+}
+
+// ToChatCompletionResponse converts an Anthropic Messages response into a chat completion response
+func (r *AnthropicMessagesResponse) ToChatCompletionResponse() ChatCompletionResponse {
+	var text, reasoning strings.Builder
+	var toolCalls []any
+	for _, block := range r.Content {
+		switch block.Type {
+		case AnthropicBlockText:
+			text.WriteString(block.Text)
+		case AnthropicBlockThinking:
+			reasoning.WriteString(block.Thinking)
+		case AnthropicBlockToolUse:
+			toolCalls = append(toolCalls, openAIToolCall(block.ID, block.Name, block.Input))
+		}
+	}
+
+	msg := Message{Role: RoleAssistant, Content: text.String(), Extra: map[string]any{}}
+	if reasoning.Len() > 0 {
+		msg.Extra["reasoning_content"] = reasoning.String()
+	}
+	if len(toolCalls) > 0 {
+		msg.Extra["tool_calls"] = toolCalls
+	}
+
+	stopReason := ""
+	if r.StopReason != nil {
+		stopReason = *r.StopReason
+	}
+
+	return ChatCompletionResponse{
+		Id:     r.ID,
+		Object: "chat.completion",
+		Model:  r.Model,
+		Choices: []Choice{{
+			Index:        0,
+			Message:      msg,
+			FinishReason: AnthropicFinishReason(stopReason),
+		}},
+		Usage: r.Usage.ToUsage(),
+	}
+}
@@ -0,0 +1,120 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnthropicMessagesRequest_ToChatCompletionRequest(t *testing.T) {
+	body := `{
+		"model": "claude-test",
+		"max_tokens": 1024,
+		"stream": true,
+		"system": [{"type": "text", "text": "You are helpful", "cache_control": {"type": "ephemeral"}}],
+		"messages": [
+			{"role": "user", "content": "hello"},
+			{"role": "assistant", "content": [
+				{"type": "text", "text": "checking"},
+				{"type": "tool_use", "id": "toolu_1", "name": "search", "input": {"q": "go"}}
+			]},
+			{"role": "user", "content": [
+				{"type": "tool_result", "tool_use_id": "toolu_1", "content": [{"type": "text", "text": "result"}]},
+				{"type": "text", "text": "continue"}
+			]}
+		],
+		"tools": [{"name": "search", "description": "search code", "input_schema": {"type": "object"}}],
+		"tool_choice": {"type": "any"}
+	}`
+
+	var anthropicReq AnthropicMessagesRequest
+	require.NoError(t, json.Unmarshal([]byte(body), &anthropicReq))
+
+	req, err := anthropicReq.ToChatCompletionRequest()
+	require.NoError(t, err)
+
+	assert.Equal(t, "claude-test", req.Model)
+	assert.Equal(t, 1024, req.Extra["max_tokens"])
+	assert.Equal(t, true, req.Extra["stream"])
+	assert.Equal(t, "required", req.Extra["tool_choice"])
+	assert.Len(t, req.Extra["tools"], 1)
+
+	require.Len(t, req.Messages, 5)
+	assert.Equal(t, RoleSystem, req.Messages[0].Role)
+	systemParts := req.Messages[0].Content.([]any)
+	assert.Equal(t, map[string]any{"type": "ephemeral"}, systemParts[0].(map[string]any)["cache_control"])
+
+	assert.Equal(t, RoleUser, req.Messages[1].Role)
+	assert.Equal(t, "hello", req.Messages[1].Content)
+
+	assert.Equal(t, RoleAssistant, req.Messages[2].Role)
+	assert.Equal(t, "checking", req.Messages[2].Content)
+	toolCalls := req.Messages[2].Extra["tool_calls"].([]any)
+	function := toolCalls[0].(map[string]any)["function"].(map[string]any)
+	assert.Equal(t, "search", function["name"])
+	assert.JSONEq(t, `{"q":"go"}`, function["arguments"].(string))
+
+	assert.Equal(t, RoleTool, req.Messages[3].Role)
+	assert.Equal(t, "result", req.Messages[3].Content)
+	assert.Equal(t, "toolu_1", req.Messages[3].Extra["tool_call_id"])
+
+	assert.Equal(t, RoleUser, req.Messages[4].Role)
+}
+
+func TestAnthropicMessagesRequest_InvalidContent(t *testing.T) {
+	req := AnthropicMessagesRequest{
+		Model:    "claude-test",
+		Messages: []AnthropicMessage{{Role: RoleUser, Content: 42}},
+	}
+
+	_, err := req.ToChatCompletionRequest()
+	assert.Error(t, err)
+}
+
+func TestNewAnthropicMessagesResponse(t *testing.T) {
+	body := `{
+		"id": "chatcmpl-1",
+		"model": "gpt-test",
+		"choices": [{
+			"index": 0,
+			"message": {
+				"role": "assistant",
+				"content": "done",
+				"tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "search", "arguments": "{\"q\":\"go\"}"}}]
+			},
+			"finish_reason": "tool_calls"
+		}],
+		"usage": {"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15}
+	}`
+
+	var resp ChatCompletionResponse
+	require.NoError(t, json.Unmarshal([]byte(body), &resp))
+
+	result := NewAnthropicMessagesResponse(&resp)
+
+	assert.Equal(t, "message", result.Type)
+	assert.Equal(t, AnthropicStopToolUse, *result.StopReason)
+	assert.Equal(t, AnthropicUsage{InputTokens: 10, OutputTokens: 5}, result.Usage)
+	require.Len(t, result.Content, 2)
+	assert.Equal(t, "done", result.Content[0].Text)
+	assert.Equal(t, AnthropicBlockToolUse, result.Content[1].Type)
+	assert.Equal(t, map[string]any{"q": "go"}, result.Content[1].Input)
+}
+
+func TestAnthropicUsage_CachedTokens(t *testing.T) {
+	// Anthropic input tokens exclude cached tokens, OpenAI prompt tokens include them
+	usage := AnthropicUsage{InputTokens: 20, OutputTokens: 5, CacheReadInputTokens: 1000, CacheCreationInputTokens: 80}.ToUsage()
+	assert.Equal(t, 1100, usage.PromptTokens)
+	assert.Equal(t, 1105, usage.TotalTokens)
+	assert.Equal(t, 1000, usage.CachedTokens())
+
+	back := NewAnthropicUsage(usage)
+	assert.Equal(t, AnthropicUsage{InputTokens: 20, OutputTokens: 5, CacheReadInputTokens: 1000, CacheCreationInputTokens: 80}, back)
+
+	// Gateways reporting Anthropic style fields on OpenAI usage
+	var gateway Usage
+	require.NoError(t, json.Unmarshal([]byte(`{"prompt_tokens":300,"completion_tokens":2,"total_tokens":302,"cache_read_input_tokens":256}`), &gateway))
+	assert.Equal(t, 256, gateway.CachedTokens())
+}
@@ -67,6 +67,9 @@ const (
 
 	ErrCodeInvalidResponseContent = "chat-rag.invalid_response_content"
 	ErrMsgInvalidResponseContent  = "The model is unable to perform inference or makes errors during inference."
+
+	ErrCodePreviousResponseNotFound = "chat-rag.previous_response_not_found"
+	ErrMsgPreviousResponseNotFound  = "Previous response with id '%s' not found or expired."
 )
 
 type APIError struct {
@@ -152,6 +155,16 @@ func NewInvaildResponseContentError() *APIError {
 	}
 }
 
+func NewPreviousResponseNotFoundError(responseID string) *APIError {
+	return &APIError{
+		Code:       ErrCodePreviousResponseNotFound,
+		Message:    fmt.Sprintf(ErrMsgPreviousResponseNotFound, responseID),
+		Success:    false,
+		StatusCode: http.StatusNotFound,
+		Type:       "invalid_request_error",
+	}
+}
+
 func (e *APIError) Error() string {
 	return fmt.Sprintf(`{"code":"%s","message":"%s","success":%v}`, e.Code, e.Message, e.Success)
 }
@@ -0,0 +1,338 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	// Gemini content roles
+	GeminiRoleUser  = "user"
+	GeminiRoleModel = "model"
+
+	// Gemini finish reasons
+	GeminiFinishStop      = "STOP"
+	GeminiFinishMaxTokens = "MAX_TOKENS"
+)
+
+// GeminiRequest is the request body of the Gemini generateContent API
+type GeminiRequest struct {
+	Contents          []GeminiContent         `json:"contents"`
+	SystemInstruction *GeminiContent          `json:"systemInstruction,omitempty"`
+	Tools             []GeminiTool            `json:"tools,omitempty"`
+	ToolConfig        *GeminiToolConfig       `json:"toolConfig,omitempty"`
+	GenerationConfig  *GeminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+// GeminiContent is a single conversation turn
+type GeminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []GeminiPart `json:"parts"`
+}
+
+// GeminiPart is a part of a content, only one of the fields is set
+type GeminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	Thought          bool                    `json:"thought,omitempty"`
+	InlineData       *GeminiBlob             `json:"inlineData,omitempty"`
+	FileData         *GeminiFileData         `json:"fileData,omitempty"`
+	FunctionCall     *GeminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *GeminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+// GeminiBlob is inline base64 data
+type GeminiBlob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+// GeminiFileData references a remote file
+type GeminiFileData struct {
+	MimeType string `json:"mimeType,omitempty"`
+	FileURI  string `json:"fileUri"`
+}
+
+// GeminiFunctionCall is a function call predicted by the model
+type GeminiFunctionCall struct {
+	ID   string         `json:"id,omitempty"`
+	Name string         `json:"name"`
+	Args map[string]any `json:"args,omitempty"`
+}
+
+// GeminiFunctionResponse is the result of a function call
+type GeminiFunctionResponse struct {
+	ID       string         `json:"id,omitempty"`
+	Name     string         `json:"name"`
+	Response map[string]any `json:"response"`
+}
+
+// GeminiTool holds the function declarations of a request
+type GeminiTool struct {
+	FunctionDeclarations []GeminiFunctionDeclaration `json:"functionDeclarations,omitempty"`
+}
+
+// GeminiFunctionDeclaration declares a function with a JSON schema of its parameters
+type GeminiFunctionDeclaration struct {
+	Name                 string `json:"name"`
+	Description          string `json:"description,omitempty"`
+	ParametersJSONSchema any    `json:"parametersJsonSchema,omitempty"`
+}
+
+// GeminiToolConfig controls how the model calls functions
+type GeminiToolConfig struct {
+	FunctionCallingConfig GeminiFunctionCallingConfig `json:"functionCallingConfig"`
+}
+
+// GeminiFunctionCallingConfig is the function calling mode: AUTO, ANY or NONE
+type GeminiFunctionCallingConfig struct {
+	Mode                 string   `json:"mode"`
+	AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
+}
+
+// GeminiGenerationConfig holds the sampling parameters
+type GeminiGenerationConfig struct {
+	Temperature     *float64 `json:"temperature,omitempty"`
+	TopP            *float64 `json:"topP,omitempty"`
+	MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
+	StopSequences   []string `json:"stopSequences,omitempty"`
+}
+
+// GeminiResponse is the response, or a streamed chunk, of the Gemini generateContent API
+type GeminiResponse struct {
+	Candidates    []GeminiCandidate `json:"candidates"`
+	UsageMetadata *GeminiUsage      `json:"usageMetadata,omitempty"`
+	ModelVersion  string            `json:"modelVersion,omitempty"`
+	ResponseID    string            `json:"responseId,omitempty"`
+}
+
+// GeminiCandidate is a generated candidate
+type GeminiCandidate struct {
+	Content      GeminiContent `json:"content"`
+	FinishReason string        `json:"finishReason,omitempty"`
+	Index        int           `json:"index"`
+}
+
+// GeminiUsage is the token usage of the Gemini API
+type GeminiUsage struct {
+	PromptTokenCount        int `json:"promptTokenCount"`
+	CandidatesTokenCount    int `json:"candidatesTokenCount"`
+	ThoughtsTokenCount      int `json:"thoughtsTokenCount,omitempty"`
+	CachedContentTokenCount int `json:"cachedContentTokenCount,omitempty"`
+	TotalTokenCount         int `json:"totalTokenCount"`
+}
+
+// GeminiErrorResponse is the error body of the Gemini API
+type GeminiErrorResponse struct {
+	Error GeminiError `json:"error"`
+}
+
+// GeminiError is the error object of the Gemini API
+type GeminiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+// NewGeminiRequest converts OpenAI style request params into a Gemini generateContent request.
+// Tool results are sent as functionResponse parts named after the matching assistant tool call.
+func NewGeminiRequest(params LLMRequestParams, maxTokens int) *GeminiRequest {
+	sampling := readSamplingParams(params.Extra)
+	if sampling.MaxTokens > 0 {
+		maxTokens = sampling.MaxTokens
+	}
+
+	req := &GeminiRequest{Contents: []GeminiContent{}}
+	if maxTokens > 0 || sampling.Temperature != nil || sampling.TopP != nil || len(sampling.StopSequences) > 0 {
+		req.GenerationConfig = &GeminiGenerationConfig{
+			Temperature:     sampling.Temperature,
+			TopP:            sampling.TopP,
+			MaxOutputTokens: maxTokens,
+			StopSequences:   sampling.StopSequences,
+		}
+	}
+
+	var system []GeminiPart
+	toolNames := make(map[string]string)
+	for _, msg := range params.Messages {
+		switch msg.Role {
+		case RoleSystem, "developer":
+			system = append(system, geminiContentParts(msg.Content)...)
+		case RoleAssistant:
+			parts := geminiContentParts(msg.Content)
+			for _, call := range messageToolCalls(msg) {
+				toolNames[call.ID] = call.Function.Name
+				parts = append(parts, GeminiPart{FunctionCall: &GeminiFunctionCall{
+					ID:   call.ID,
+					Name: call.Function.Name,
+					Args: parseToolArguments(call.Function.Arguments),
+				}})
+			}
+			req.Contents = appendGeminiContent(req.Contents, GeminiRoleModel, parts)
+		case RoleTool:
+			callID, _ := msg.Extra["tool_call_id"].(string)
+			req.Contents = appendGeminiContent(req.Contents, GeminiRoleUser, []GeminiPart{{
+				FunctionResponse: &GeminiFunctionResponse{
+					ID:       callID,
+					Name:     toolNames[callID],
+					Response: geminiFunctionResult(messageContentText(msg.Content)),
+				},
+			}})
+		default:
+			req.Contents = appendGeminiContent(req.Contents, GeminiRoleUser, geminiContentParts(msg.Content))
+		}
+	}
+	if len(system) > 0 {
+		req.SystemInstruction = &GeminiContent{Parts: system}
+	}
+
+	var declarations []GeminiFunctionDeclaration
+	for _, tool := range readFunctionTools(params.Extra) {
+		declarations = append(declarations, GeminiFunctionDeclaration{
+			Name:                 tool.Name,
+			Description:          tool.Description,
+			ParametersJSONSchema: tool.Parameters,
+		})
+	}
+	if len(declarations) > 0 {
+		req.Tools = []GeminiTool{{FunctionDeclarations: declarations}}
+		switch mode, name := readToolChoice(params.Extra); mode {
+		case "auto":
+			req.ToolConfig = &GeminiToolConfig{FunctionCallingConfig: GeminiFunctionCallingConfig{Mode: "AUTO"}}
+		case "required":
+			req.ToolConfig = &GeminiToolConfig{FunctionCallingConfig: GeminiFunctionCallingConfig{Mode: "ANY"}}
+		case "none":
+			req.ToolConfig = &GeminiToolConfig{FunctionCallingConfig: GeminiFunctionCallingConfig{Mode: "NONE"}}
+		case "function":
+			req.ToolConfig = &GeminiToolConfig{FunctionCallingConfig: GeminiFunctionCallingConfig{
+				Mode:                 "ANY",
+				AllowedFunctionNames: []string{name},
+			}}
+		}
+	}
+
+	return req
+}
+
+// geminiContentParts converts OpenAI message content into text and media parts, empty text is dropped
+func geminiContentParts(content any) []GeminiPart {
+	var parts []GeminiPart
+	for _, part := range messageContentParts(content) {
+		switch part["type"] {
+		case "text":
+			if text, _ := part["text"].(string); text != "" {
+				parts = append(parts, GeminiPart{Text: text})
+			}
+		case "image_url":
+			url := imagePartURL(part)
+			if mediaType, data, ok := parseDataURL(url); ok {
+				parts = append(parts, GeminiPart{InlineData: &GeminiBlob{MimeType: mediaType, Data: data}})
+			} else if url != "" {
+				parts = append(parts, GeminiPart{FileData: &GeminiFileData{FileURI: url}})
+			}
+		}
+	}
+	return parts
+}
+
+// geminiFunctionResult wraps a tool result as the response object of a function, JSON objects are passed as is
+func geminiFunctionResult(text string) map[string]any {
+	var result map[string]any
+	if err := json.Unmarshal([]byte(text), &result); err == nil && result != nil {
+		return result
+	}
+	return map[string]any{"content": text}
+}
+
+// appendGeminiContent appends the parts as a content, merging consecutive contents of the same role
+func appendGeminiContent(contents []GeminiContent, role string, parts []GeminiPart) []GeminiContent {
+	if len(parts) == 0 {
+		return contents
+	}
+	if n := len(contents); n > 0 && contents[n-1].Role == role {
+		contents[n-1].Parts = append(contents[n-1].Parts, parts...)
+		return contents
+	}
+	return append(contents, GeminiContent{Role: role, Parts: parts})
+}
+
+// GeminiFinishReason maps a Gemini finishReason to an OpenAI finish_reason
+func GeminiFinishReason(finishReason string, hasToolCalls bool) string {
+	switch {
+	case finishReason == "":
+		return ""
+	case hasToolCalls:
+		return "tool_calls"
+	case finishReason == GeminiFinishStop:
+		return "stop"
+	case finishReason == GeminiFinishMaxTokens:
+		return "length"
+	default:
+		return "content_filter"
+	}
+}
+
+// GeminiToolCallID returns the id of a function call, generating one when the upstream omits it
+func GeminiToolCallID(call *GeminiFunctionCall, responseID string, index int) string {
+	if call.ID != "" {
+		return call.ID
+	}
+	return fmt.Sprintf("call_%s_%d", responseID, index)
+}
+
+// ToUsage converts the Gemini usage into the OpenAI usage, thought tokens count as completion tokens
+func (u *GeminiUsage) ToUsage() Usage {
+	completion := u.CandidatesTokenCount + u.ThoughtsTokenCount
+	usage := Usage{
+		PromptTokens:     u.PromptTokenCount,
+		CompletionTokens: completion,
+		TotalTokens:      u.PromptTokenCount + completion,
+	}
+	if u.CachedContentTokenCount > 0 {
+		usage.PromptTokensDetails = &PromptTokensDetails{CachedTokens: u.CachedContentTokenCount}
+	}
+	return usage
+}
+
+// ToChatCompletionResponse converts a Gemini response into a chat completion response
+func (r *GeminiResponse) ToChatCompletionResponse(model string) ChatCompletionResponse {
+	resp := ChatCompletionResponse{
+		Id:      r.ResponseID,
+		Object:  "chat.completion",
+		Model:   model,
+		Choices: []Choice{},
+	}
+	if r.UsageMetadata != nil {
+		resp.Usage = r.UsageMetadata.ToUsage()
+	}
+
+	for _, candidate := range r.Candidates {
+		var text, reasoning strings.Builder
+		var toolCalls []any
+		for _, part := range candidate.Content.Parts {
+			switch {
+			case part.FunctionCall != nil:
+				id := GeminiToolCallID(part.FunctionCall, r.ResponseID, len(toolCalls))
+				toolCalls = append(toolCalls, openAIToolCall(id, part.FunctionCall.Name, part.FunctionCall.Args))
+			case part.Thought:
+				reasoning.WriteString(part.Text)
+			default:
+				text.WriteString(part.Text)
+			}
+		}
+
+		msg := Message{Role: RoleAssistant, Content: text.String(), Extra: map[string]any{}}
+		if reasoning.Len() > 0 {
+			msg.Extra["reasoning_content"] = reasoning.String()
+		}
+		if len(toolCalls) > 0 {
+			msg.Extra["tool_calls"] = toolCalls
+		}
+		resp.Choices = append(resp.Choices, Choice{
+			Index:        candidate.Index,
+			Message:      msg,
+			FinishReason: GeminiFinishReason(candidate.FinishReason, len(toolCalls) > 0),
+		})
+	}
+	return resp
+}
@@ -0,0 +1,21 @@
+package types
+
+// AutoModelName is the virtual model that lets the router pick the upstream model
+const AutoModelName = "auto"
+
+// ModelInfo describes a model entry of the /v1/models listing
+type ModelInfo struct {
+	ID              string `json:"id"`
+	Object          string `json:"object"`
+	Created         int64  `json:"created"`
+	OwnedBy         string `json:"owned_by"`
+	FunctionCalling bool   `json:"function_calling"`
+	ContextWindow   int    `json:"context_window,omitempty"`
+	Vision          bool   `json:"vision"`
+}
+
+// ModelListResponse is the response of the /v1/models listing
+type ModelListResponse struct {
+	Object string      `json:"object"`
+	Data   []ModelInfo `json:"data"`
+}
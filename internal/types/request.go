@@ -57,11 +57,15 @@ const (
 	HeaderProjectPath   = "zgsm-project-path"
 	HeaderClientVersion = "X-Costrict-Version"
 	HeaderOriginalModel = "x-original-model"
+	HeaderCacheBypass   = "x-cache-bypass"
+	HeaderCassetteMode  = "x-cassette-mode"
+	HeaderAdminToken    = "x-admin-token"
 
 	// Response Headers
 	HeaderUserInput   = "x-user-input"
 	HeaderSelectLLm   = "x-select-llm"
 	HeaderOneAPIReqId = "x-oneapi-request-id"
+	HeaderCache       = "x-cache"
 )
 
 // ResponseHeadersToForward defines the list of response headers that should be forwarded
@@ -323,6 +327,39 @@ type Usage struct {
 	PromptTokens     int `json:"prompt_tokens"`
 	CompletionTokens int `json:"completion_tokens"`
 	TotalTokens      int `json:"total_tokens"`
+	// Prompt tokens read from the provider prompt cache, OpenAI style
+	PromptTokensDetails *PromptTokensDetails `json:"prompt_tokens_details,omitempty"`
+	// Prompt cache usage reported Anthropic style by some OpenAI compatible upstreams
+	CacheReadInputTokens     int `json:"cache_read_input_tokens,omitempty"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"`
+	// Completion tokens spent on reasoning
+	CompletionTokensDetails *CompletionTokensDetails `json:"completion_tokens_details,omitempty"`
+}
+
+// CompletionTokensDetails is the breakdown of the completion tokens
+type CompletionTokensDetails struct {
+	ReasoningTokens int `json:"reasoning_tokens"`
+}
+
+// PromptTokensDetails is the breakdown of the prompt tokens
+type PromptTokensDetails struct {
+	CachedTokens int `json:"cached_tokens"`
+}
+
+// CachedTokens returns the prompt tokens read from the provider prompt cache
+func (u Usage) CachedTokens() int {
+	if u.PromptTokensDetails != nil && u.PromptTokensDetails.CachedTokens > 0 {
+		return u.PromptTokensDetails.CachedTokens
+	}
+	return u.CacheReadInputTokens
+}
+
+// ReasoningTokens returns the completion tokens spent on reasoning
+func (u Usage) ReasoningTokens() int {
+	if u.CompletionTokensDetails == nil {
+		return 0
+	}
+	return u.CompletionTokensDetails.ReasoningTokens
 }
 
 // FunctionCall is the structure of the function called by the LLM.
@@ -343,16 +380,26 @@ type FunctionParameters struct {
 	Required   []string                   `json:"required"`
 }
 
+// PropertyDetails is the JSON schema of a function parameter
 type PropertyDetails struct {
-	Type        string      `json:"type"`
-	Description string      `json:"description"`
-	Default     interface{} `json:"default,omitempty"`
-	Items       *Items      `json:"items,omitempty"` // 用于array类型
+	Type        string                     `json:"type"`
+	Description string                     `json:"description,omitempty"`
+	Default     interface{}                `json:"default,omitempty"`
+	Enum        []interface{}              `json:"enum,omitempty"`
+	Format      string                     `json:"format,omitempty"`
+	Minimum     *float64                   `json:"minimum,omitempty"`
+	Maximum     *float64                   `json:"maximum,omitempty"`
+	MinLength   *int                       `json:"minLength,omitempty"`
+	MaxLength   *int                       `json:"maxLength,omitempty"`
+	Items       *Items                     `json:"items,omitempty"` // 用于array类型
+	MinItems    *int                       `json:"minItems,omitempty"`
+	MaxItems    *int                       `json:"maxItems,omitempty"`
+	Properties  map[string]PropertyDetails `json:"properties,omitempty"` // 用于object类型
+	Required    []string                   `json:"required,omitempty"`
 }
 
-type Items struct {
-	Type string `json:"type"`
-}
+// Items is the JSON schema of array items
+type Items = PropertyDetails
 
 // ToolStatusResponse defines tool status response structure
 type ToolStatusResponse struct {
@@ -0,0 +1,414 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	// Responses API input and output item types
+	ResponsesItemMessage            = "message"
+	ResponsesItemFunctionCall       = "function_call"
+	ResponsesItemFunctionCallOutput = "function_call_output"
+	ResponsesContentInputText       = "input_text"
+	ResponsesContentInputImage      = "input_image"
+	ResponsesContentOutputText      = "output_text"
+
+	// Responses API status values
+	ResponsesStatusInProgress = "in_progress"
+	ResponsesStatusCompleted  = "completed"
+	ResponsesStatusIncomplete = "incomplete"
+	ResponsesStatusFailed     = "failed"
+
+	// Responses API stream event types
+	ResponsesEventCreated           = "response.created"
+	ResponsesEventOutputItemAdded   = "response.output_item.added"
+	ResponsesEventOutputItemDone    = "response.output_item.done"
+	ResponsesEventContentPartAdded  = "response.content_part.added"
+	ResponsesEventContentPartDone   = "response.content_part.done"
+	ResponsesEventOutputTextDelta   = "response.output_text.delta"
+	ResponsesEventOutputTextDone    = "response.output_text.done"
+	ResponsesEventFunctionArgsDelta = "response.function_call_arguments.delta"
+	ResponsesEventFunctionArgsDone  = "response.function_call_arguments.done"
+	ResponsesEventCompleted         = "response.completed"
+	ResponsesEventFailed            = "response.failed"
+	ResponsesEventError             = "error"
+)
+
+// ResponsesRequest is the request body of the OpenAI Responses API
+type ResponsesRequest struct {
+	Model              string           `json:"model"`
+	Input              any              `json:"input"`
+	Instructions       string           `json:"instructions,omitempty"`
+	PreviousResponseID string           `json:"previous_response_id,omitempty"`
+	Stream             bool             `json:"stream,omitempty"`
+	Store              *bool            `json:"store,omitempty"`
+	MaxOutputTokens    *int             `json:"max_output_tokens,omitempty"`
+	Temperature        *float64         `json:"temperature,omitempty"`
+	TopP               *float64         `json:"top_p,omitempty"`
+	Tools              []map[string]any `json:"tools,omitempty"`
+	ToolChoice         any              `json:"tool_choice,omitempty"`
+	User               string           `json:"user,omitempty"`
+	ExtraBody          *ExtraBody       `json:"extra_body,omitempty"`
+}
+
+// ShouldStore reports whether the conversation state of this turn should be saved
+func (r *ResponsesRequest) ShouldStore() bool {
+	return r.Store == nil || *r.Store
+}
+
+// InputMessages converts the request input into chat messages
+func (r *ResponsesRequest) InputMessages() ([]Message, error) {
+	switch v := r.Input.(type) {
+	case string:
+		return []Message{{Role: RoleUser, Content: v}}, nil
+	case []any:
+		return convertResponsesInputItems(v)
+	case nil:
+		return nil, fmt.Errorf("input is required")
+	default:
+		return nil, fmt.Errorf("input: unsupported type %T", r.Input)
+	}
+}
+
+// ToChatCompletionRequest builds the internal chat completion request from the stored history and the new input
+func (r *ResponsesRequest) ToChatCompletionRequest(history []Message, input []Message) *ChatCompletionRequest {
+	req := &ChatCompletionRequest{
+		Model: r.Model,
+		LLMRequestParams: LLMRequestParams{
+			Extra: make(map[string]any),
+		},
+	}
+	if r.ExtraBody != nil {
+		req.ExtraBody = *r.ExtraBody
+	}
+
+	// Instructions are not carried over from previous responses
+	if r.Instructions != "" {
+		req.Messages = append(req.Messages, Message{Role: RoleSystem, Content: r.Instructions})
+	}
+	req.Messages = append(req.Messages, history...)
+	req.Messages = append(req.Messages, input...)
+
+	if r.MaxOutputTokens != nil {
+		req.Extra["max_tokens"] = *r.MaxOutputTokens
+	}
+	if r.Temperature != nil {
+		req.Extra["temperature"] = *r.Temperature
+	}
+	if r.TopP != nil {
+		req.Extra["top_p"] = *r.TopP
+	}
+	if r.User != "" {
+		req.Extra["user"] = r.User
+	}
+	if r.Stream {
+		req.Extra["stream"] = true
+		req.Extra["stream_options"] = map[string]any{"include_usage": true}
+	}
+
+	var tools []any
+	for _, tool := range r.Tools {
+		if tool["type"] != "function" {
+			continue
+		}
+		tools = append(tools, map[string]any{
+			"type": "function",
+			"function": map[string]any{
+				"name":        tool["name"],
+				"description": tool["description"],
+				"parameters":  tool["parameters"],
+			},
+		})
+	}
+	if len(tools) > 0 {
+		req.Extra["tools"] = tools
+	}
+	if toolChoice := convertResponsesToolChoice(r.ToolChoice); toolChoice != nil {
+		req.Extra["tool_choice"] = toolChoice
+	}
+
+	return req
+}
+
+// convertResponsesInputItems converts Responses API input items into chat messages.
+// Consecutive function_call items are merged into one assistant message.
+func convertResponsesInputItems(items []any) ([]Message, error) {
+	var messages []Message
+	for i, item := range items {
+		itemMap, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("input[%d]: unsupported item type %T", i, item)
+		}
+
+		itemType, _ := itemMap["type"].(string)
+		switch itemType {
+		case ResponsesItemFunctionCall:
+			toolCall := map[string]any{
+				"id":   itemMap["call_id"],
+				"type": "function",
+				"function": map[string]any{
+					"name":      itemMap["name"],
+					"arguments": itemMap["arguments"],
+				},
+			}
+			if n := len(messages); n > 0 && messages[n-1].Role == RoleAssistant && messages[n-1].Extra != nil {
+				if calls, ok := messages[n-1].Extra["tool_calls"].([]any); ok {
+					messages[n-1].Extra["tool_calls"] = append(calls, toolCall)
+					continue
+				}
+			}
+			messages = append(messages, Message{
+				Role:    RoleAssistant,
+				Content: "",
+				Extra:   map[string]any{"tool_calls": []any{toolCall}},
+			})
+
+		case ResponsesItemFunctionCallOutput:
+			output, ok := itemMap["output"].(string)
+			if !ok {
+				data, _ := json.Marshal(itemMap["output"])
+				output = string(data)
+			}
+			messages = append(messages, Message{
+				Role:    RoleTool,
+				Content: output,
+				Extra:   map[string]any{"tool_call_id": itemMap["call_id"]},
+			})
+
+		case ResponsesItemMessage, "":
+			role, _ := itemMap["role"].(string)
+			if role == "" {
+				return nil, fmt.Errorf("input[%d]: role is required", i)
+			}
+			if role == "developer" {
+				role = RoleSystem
+			}
+			content, err := convertResponsesContent(itemMap["content"])
+			if err != nil {
+				return nil, fmt.Errorf("input[%d]: %w", i, err)
+			}
+			messages = append(messages, Message{Role: role, Content: content})
+
+		default:
+			return nil, fmt.Errorf("input[%d]: unsupported item type %q", i, itemType)
+		}
+	}
+	return messages, nil
+}
+
+// convertResponsesContent converts message content parts into chat content parts
+func convertResponsesContent(content any) (any, error) {
+	switch v := content.(type) {
+	case string:
+		return v, nil
+	case []any:
+		parts := make([]any, 0, len(v))
+		for _, part := range v {
+			partMap, ok := part.(map[string]any)
+			if !ok {
+				continue
+			}
+			switch partMap["type"] {
+			case ResponsesContentInputText, ResponsesContentOutputText, "text":
+				parts = append(parts, map[string]any{
+					"type": "text",
+					"text": partMap["text"],
+				})
+			case ResponsesContentInputImage:
+				parts = append(parts, map[string]any{
+					"type":      "image_url",
+					"image_url": map[string]any{"url": partMap["image_url"]},
+				})
+			}
+		}
+		return parts, nil
+	default:
+		return nil, fmt.Errorf("unsupported content type %T", content)
+	}
+}
+
+// convertResponsesToolChoice maps Responses API tool_choice to the chat completion equivalent
+func convertResponsesToolChoice(choice any) any {
+	switch v := choice.(type) {
+	case string:
+		return v
+	case map[string]any:
+		if v["type"] == "function" {
+			return map[string]any{
+				"type":     "function",
+				"function": map[string]any{"name": v["name"]},
+			}
+		}
+	}
+	return nil
+}
+
+// ResponsesResponse is the response object of the OpenAI Responses API
+type ResponsesResponse struct {
+	ID                 string                `json:"id"`
+	Object             string                `json:"object"`
+	CreatedAt          int64                 `json:"created_at"`
+	Status             string                `json:"status"`
+	Model              string                `json:"model"`
+	Output             []ResponsesOutputItem `json:"output"`
+	PreviousResponseID string                `json:"previous_response_id,omitempty"`
+	Usage              *ResponsesUsage       `json:"usage,omitempty"`
+	Error              *ResponsesError       `json:"error,omitempty"`
+}
+
+// ResponsesOutputItem is an output item, either a message or a function call
+type ResponsesOutputItem struct {
+	Type      string                   `json:"type"`
+	ID        string                   `json:"id"`
+	Status    string                   `json:"status,omitempty"`
+	Role      string                   `json:"role,omitempty"`
+	Content   []ResponsesOutputContent `json:"content,omitempty"`
+	CallID    string                   `json:"call_id,omitempty"`
+	Name      string                   `json:"name,omitempty"`
+	Arguments string                   `json:"arguments,omitempty"`
+}
+
+// ResponsesOutputContent is a content part of an output message
+type ResponsesOutputContent struct {
+	Type        string `json:"type"`
+	Text        string `json:"text"`
+	Annotations []any  `json:"annotations"`
+}
+
+// ResponsesUsage is the token usage of the Responses API
+type ResponsesUsage struct {
+	InputTokens        int                    `json:"input_tokens"`
+	InputTokensDetails ResponsesTokensDetails `json:"input_tokens_details"`
+	OutputTokens       int                    `json:"output_tokens"`
+	TotalTokens        int                    `json:"total_tokens"`
+}
+
+// ResponsesTokensDetails is the breakdown of the input tokens
+type ResponsesTokensDetails struct {
+	CachedTokens int `json:"cached_tokens"`
+}
+
+// NewResponsesUsage converts the chat completion usage into the Responses API usage
+func NewResponsesUsage(u Usage) *ResponsesUsage {
+	return &ResponsesUsage{
+		InputTokens:        u.PromptTokens,
+		InputTokensDetails: ResponsesTokensDetails{CachedTokens: u.CachedTokens()},
+		OutputTokens:       u.CompletionTokens,
+		TotalTokens:        u.TotalTokens,
+	}
+}
+
+// ResponsesError is the error object of a failed response
+type ResponsesError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// NewResponsesResponse converts a chat completion response into a Responses API response
+func NewResponsesResponse(id string, createdAt int64, previousID string, resp *ChatCompletionResponse) *ResponsesResponse {
+	result := &ResponsesResponse{
+		ID:                 id,
+		Object:             "response",
+		CreatedAt:          createdAt,
+		Status:             ResponsesStatusCompleted,
+		Model:              resp.Model,
+		Output:             []ResponsesOutputItem{},
+		PreviousResponseID: previousID,
+		Usage:              NewResponsesUsage(resp.Usage),
+	}
+	if len(resp.Choices) == 0 {
+		return result
+	}
+
+	choice := resp.Choices[0]
+	if choice.FinishReason == "length" {
+		result.Status = ResponsesStatusIncomplete
+	}
+	if text, ok := choice.Message.Content.(string); ok && text != "" {
+		result.Output = append(result.Output, NewResponsesMessageItem("msg_"+id, text))
+	}
+	if toolCalls, ok := choice.Message.Extra["tool_calls"].([]any); ok {
+		for _, call := range toolCalls {
+			callMap, ok := call.(map[string]any)
+			if !ok {
+				continue
+			}
+			function, _ := callMap["function"].(map[string]any)
+			callID, _ := callMap["id"].(string)
+			name, _ := function["name"].(string)
+			arguments, _ := function["arguments"].(string)
+			result.Output = append(result.Output, ResponsesOutputItem{
+				Type:      ResponsesItemFunctionCall,
+				ID:        "fc_" + callID,
+				Status:    ResponsesStatusCompleted,
+				CallID:    callID,
+				Name:      name,
+				Arguments: arguments,
+			})
+		}
+	}
+	return result
+}
+
+// NewResponsesMessageItem creates a completed assistant message output item
+func NewResponsesMessageItem(id, text string) ResponsesOutputItem {
+	return ResponsesOutputItem{
+		Type:   ResponsesItemMessage,
+		ID:     id,
+		Status: ResponsesStatusCompleted,
+		Role:   RoleAssistant,
+		Content: []ResponsesOutputContent{{
+			Type:        ResponsesContentOutputText,
+			Text:        text,
+			Annotations: []any{},
+		}},
+	}
+}
+
+// OutputMessage converts the response output back into an assistant message for conversation state
+func (r *ResponsesResponse) OutputMessage() Message {
+	var text strings.Builder
+	var toolCalls []any
+	for _, item := range r.Output {
+		switch item.Type {
+		case ResponsesItemMessage:
+			for _, content := range item.Content {
+				text.WriteString(content.Text)
+			}
+		case ResponsesItemFunctionCall:
+			toolCalls = append(toolCalls, map[string]any{
+				"id":   item.CallID,
+				"type": "function",
+				"function": map[string]any{
+					"name":      item.Name,
+					"arguments": item.Arguments,
+				},
+			})
+		}
+	}
+
+	msg := Message{Role: RoleAssistant, Content: text.String()}
+	if len(toolCalls) > 0 {
+		msg.Extra = map[string]any{"tool_calls": toolCalls}
+	}
+	return msg
+}
+
+// ResponsesStreamEvent is the payload of a Responses API SSE event
+type ResponsesStreamEvent struct {
+	Type           string                  `json:"type"`
+	SequenceNumber int                     `json:"sequence_number"`
+	Response       *ResponsesResponse      `json:"response,omitempty"`
+	OutputIndex    *int                    `json:"output_index,omitempty"`
+	ContentIndex   *int                    `json:"content_index,omitempty"`
+	ItemID         string                  `json:"item_id,omitempty"`
+	Item           *ResponsesOutputItem    `json:"item,omitempty"`
+	Part           *ResponsesOutputContent `json:"part,omitempty"`
+	Delta          string                  `json:"delta,omitempty"`
+	Text           *string                 `json:"text,omitempty"`
+	Arguments      *string                 `json:"arguments,omitempty"`
+	Code           string                  `json:"code,omitempty"`
+	Message        string                  `json:"message,omitempty"`
+}
@@ -0,0 +1,113 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponsesRequest_InputMessages(t *testing.T) {
+	body := `{
+		"model": "auto",
+		"input": [
+			{"role": "developer", "content": "be brief"},
+			{"type": "message", "role": "user", "content": [{"type": "input_text", "text": "find usages"}]},
+			{"type": "function_call", "call_id": "call_1", "name": "search", "arguments": "{}"},
+			{"type": "function_call", "call_id": "call_2", "name": "read", "arguments": "{}"},
+			{"type": "function_call_output", "call_id": "call_1", "output": "found"}
+		]
+	}`
+
+	var req ResponsesRequest
+	require.NoError(t, json.Unmarshal([]byte(body), &req))
+
+	messages, err := req.InputMessages()
+	require.NoError(t, err)
+	require.Len(t, messages, 4)
+
+	assert.Equal(t, RoleSystem, messages[0].Role)
+	assert.Equal(t, RoleUser, messages[1].Role)
+	assert.Equal(t, "find usages", messages[1].Content.([]any)[0].(map[string]any)["text"])
+	assert.Equal(t, RoleAssistant, messages[2].Role)
+	assert.Len(t, messages[2].Extra["tool_calls"], 2)
+	assert.Equal(t, RoleTool, messages[3].Role)
+	assert.Equal(t, "call_1", messages[3].Extra["tool_call_id"])
+}
+
+func TestResponsesRequest_ToChatCompletionRequest(t *testing.T) {
+	maxTokens := 256
+	req := ResponsesRequest{
+		Model:           "auto",
+		Input:           "next question",
+		Instructions:    "You are helpful",
+		Stream:          true,
+		MaxOutputTokens: &maxTokens,
+		Tools: []map[string]any{
+			{"type": "function", "name": "search", "parameters": map[string]any{"type": "object"}},
+			{"type": "web_search"},
+		},
+	}
+	history := []Message{
+		{Role: RoleUser, Content: "first question"},
+		{Role: RoleAssistant, Content: "first answer"},
+	}
+	input, err := req.InputMessages()
+	require.NoError(t, err)
+
+	chatReq := req.ToChatCompletionRequest(history, input)
+
+	require.Len(t, chatReq.Messages, 4)
+	assert.Equal(t, RoleSystem, chatReq.Messages[0].Role)
+	assert.Equal(t, "first question", chatReq.Messages[1].Content)
+	assert.Equal(t, "next question", chatReq.Messages[3].Content)
+	assert.Equal(t, 256, chatReq.Extra["max_tokens"])
+	assert.Equal(t, true, chatReq.Extra["stream"])
+	assert.Len(t, chatReq.Extra["tools"], 1)
+}
+
+func TestResponsesRequest_InvalidInput(t *testing.T) {
+	req := ResponsesRequest{Model: "auto"}
+	_, err := req.InputMessages()
+	assert.Error(t, err)
+
+	req.Input = []any{map[string]any{"type": "unknown"}}
+	_, err = req.InputMessages()
+	assert.Error(t, err)
+}
+
+func TestNewResponsesResponse_OutputMessage(t *testing.T) {
+	body := `{
+		"id": "chatcmpl-1",
+		"model": "gpt-test",
+		"choices": [{
+			"index": 0,
+			"message": {
+				"role": "assistant",
+				"content": "calling tool",
+				"tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "search", "arguments": "{}"}}]
+			},
+			"finish_reason": "tool_calls"
+		}],
+		"usage": {"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15}
+	}`
+
+	var resp ChatCompletionResponse
+	require.NoError(t, json.Unmarshal([]byte(body), &resp))
+
+	result := NewResponsesResponse("resp_1", 1700000000, "resp_0", &resp)
+
+	assert.Equal(t, ResponsesStatusCompleted, result.Status)
+	assert.Equal(t, "resp_0", result.PreviousResponseID)
+	assert.Equal(t, 15, result.Usage.TotalTokens)
+	require.Len(t, result.Output, 2)
+	assert.Equal(t, ResponsesItemMessage, result.Output[0].Type)
+	assert.Equal(t, ResponsesItemFunctionCall, result.Output[1].Type)
+	assert.Equal(t, "call_1", result.Output[1].CallID)
+
+	msg := result.OutputMessage()
+	assert.Equal(t, RoleAssistant, msg.Role)
+	assert.Equal(t, "calling tool", msg.Content)
+	assert.Len(t, msg.Extra["tool_calls"], 1)
+}
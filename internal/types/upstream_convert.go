@@ -0,0 +1,219 @@
+package types
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// samplingParams holds the OpenAI sampling parameters shared by the native upstream requests
+type samplingParams struct {
+	MaxTokens     int
+	Temperature   *float64
+	TopP          *float64
+	StopSequences []string
+}
+
+// readSamplingParams reads the sampling parameters from the passthrough fields of a request
+func readSamplingParams(extra map[string]any) samplingParams {
+	var params samplingParams
+	if v, ok := numberValue(extra["max_completion_tokens"]); ok {
+		params.MaxTokens = int(v)
+	}
+	if v, ok := numberValue(extra["max_tokens"]); ok && params.MaxTokens == 0 {
+		params.MaxTokens = int(v)
+	}
+	if v, ok := numberValue(extra["temperature"]); ok {
+		params.Temperature = &v
+	}
+	if v, ok := numberValue(extra["top_p"]); ok {
+		params.TopP = &v
+	}
+	switch stop := extra["stop"].(type) {
+	case string:
+		params.StopSequences = []string{stop}
+	case []string:
+		params.StopSequences = stop
+	case []any:
+		for _, item := range stop {
+			if s, ok := item.(string); ok {
+				params.StopSequences = append(params.StopSequences, s)
+			}
+		}
+	}
+	return params
+}
+
+// openAIFunctionTool is a function tool definition of an OpenAI request
+type openAIFunctionTool struct {
+	Name        string
+	Description string
+	Parameters  any
+}
+
+// readFunctionTools reads the function tools from the passthrough fields of a request
+func readFunctionTools(extra map[string]any) []openAIFunctionTool {
+	var raw []map[string]any
+	if err := decodeJSON(extra["tools"], &raw); err != nil {
+		return nil
+	}
+
+	tools := make([]openAIFunctionTool, 0, len(raw))
+	for _, tool := range raw {
+		function, ok := tool["function"].(map[string]any)
+		if !ok {
+			continue
+		}
+		name, _ := function["name"].(string)
+		if name == "" {
+			continue
+		}
+		description, _ := function["description"].(string)
+		parameters := function["parameters"]
+		if parameters == nil {
+			parameters = map[string]any{"type": "object", "properties": map[string]any{}}
+		}
+		tools = append(tools, openAIFunctionTool{
+			Name:        name,
+			Description: description,
+			Parameters:  parameters,
+		})
+	}
+	return tools
+}
+
+// readToolChoice returns the tool_choice mode and the forced function name, if any
+func readToolChoice(extra map[string]any) (mode string, name string) {
+	switch choice := extra["tool_choice"].(type) {
+	case string:
+		return choice, ""
+	case map[string]any:
+		if function, ok := choice["function"].(map[string]any); ok {
+			name, _ = function["name"].(string)
+		}
+		return "function", name
+	}
+	return "", ""
+}
+
+// messageToolCalls returns the tool calls of an assistant message
+func messageToolCalls(msg Message) []ToolCallInfo {
+	var calls []ToolCallInfo
+	if err := decodeJSON(msg.Extra["tool_calls"], &calls); err != nil {
+		return nil
+	}
+	return calls
+}
+
+// messageContentParts returns the content of a message as OpenAI content parts
+func messageContentParts(content any) []map[string]any {
+	if text, ok := content.(string); ok {
+		if text == "" {
+			return nil
+		}
+		return []map[string]any{{"type": "text", "text": text}}
+	}
+	var parts []map[string]any
+	if err := decodeJSON(content, &parts); err != nil {
+		return nil
+	}
+	return parts
+}
+
+// messageContentText flattens the text parts of a message content
+func messageContentText(content any) string {
+	var sb strings.Builder
+	for _, part := range messageContentParts(content) {
+		if text, ok := part["text"].(string); ok {
+			sb.WriteString(text)
+		}
+	}
+	return sb.String()
+}
+
+// imagePartURL returns the url of an image_url content part
+func imagePartURL(part map[string]any) string {
+	switch image := part["image_url"].(type) {
+	case string:
+		return image
+	case map[string]any:
+		url, _ := image["url"].(string)
+		return url
+	}
+	return ""
+}
+
+// parseDataURL splits a base64 data url into its media type and data
+func parseDataURL(url string) (mediaType string, data string, ok bool) {
+	rest, found := strings.CutPrefix(url, "data:")
+	if !found {
+		return "", "", false
+	}
+	meta, data, found := strings.Cut(rest, ",")
+	if !found {
+		return "", "", false
+	}
+	mediaType, found = strings.CutSuffix(meta, ";base64")
+	if !found {
+		return "", "", false
+	}
+	return mediaType, data, true
+}
+
+// parseToolArguments decodes the JSON arguments of a tool call, invalid arguments become an empty object
+func parseToolArguments(arguments string) map[string]any {
+	args := map[string]any{}
+	if arguments != "" {
+		if err := json.Unmarshal([]byte(arguments), &args); err != nil || args == nil {
+			return map[string]any{}
+		}
+	}
+	return args
+}
+
+// openAIToolCall builds a tool call in the OpenAI message format
+func openAIToolCall(id, name string, args any) map[string]any {
+	arguments := "{}"
+	if args != nil {
+		if data, err := json.Marshal(args); err == nil {
+			arguments = string(data)
+		}
+	}
+	return map[string]any{
+		"id":   id,
+		"type": "function",
+		"function": map[string]any{
+			"name":      name,
+			"arguments": arguments,
+		},
+	}
+}
+
+// numberValue reads a JSON number that may have been decoded or set as a Go number
+func numberValue(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// decodeJSON converts a loosely typed value into out through a JSON round trip
+func decodeJSON(v any, out any) error {
+	if v == nil {
+		return json.Unmarshal([]byte("null"), out)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}